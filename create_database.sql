@@ -1,5 +1,6 @@
 USE dwtakehome;
 
+-- FULLTEXT keys on users, posts and comments back the /api/v1/search endpoint.
 -- For Firstname and Surname field, we can use VARCHAR(255) as we don't know the maximum length of the name.
 CREATE TABLE IF NOT EXISTS users (
     user_id INT AUTO_INCREMENT PRIMARY KEY,
@@ -9,7 +10,17 @@ CREATE TABLE IF NOT EXISTS users (
     email VARCHAR(64) UNIQUE NOT NULL,
     password VARCHAR(255) NOT NULL,
     is_admin VARCHAR(1) DEFAULT '0',
-    cookie_token VARCHAR(255)
+    cookie_token VARCHAR(255),
+    display_name VARCHAR(64) NOT NULL DEFAULT '',
+    bio VARCHAR(280) NOT NULL DEFAULT '',
+    location VARCHAR(64) NOT NULL DEFAULT '',
+    website VARCHAR(255) NOT NULL DEFAULT '',
+    avatar_key VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'active',
+    suspended_until TIMESTAMP NULL,
+    created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP,
+    email_verified_at TIMESTAMP NULL,
+    FULLTEXT KEY ft_users_names (username, firstname, surname)
 );
 
 -- For FK on user_id, we let ON DELETE CASCADE to delete all the posts of the user when the user is deleted.
@@ -20,27 +31,409 @@ CREATE TABLE IF NOT EXISTS posts (
     message TEXT NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+    hidden_at TIMESTAMP NULL,
+    -- public, followers, private (only the author) or draft (unpublished)
+    visibility VARCHAR(16) NOT NULL DEFAULT 'public',
+    -- Set while the post is scheduled, cleared when the publisher publishes it
+    publish_at TIMESTAMP NULL,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    INDEX idx_posts_visibility (visibility),
+    INDEX idx_posts_publish_at (publish_at),
+    FULLTEXT KEY ft_posts_message (message)
 );
 
--- We need to create a table for comments and user first before create CommentUser table
--- because many-to-many relationship is created by creating a new table that contains the PK of both tables.
+-- Each comment records its author directly, deleting the user or the post deletes the comment.
 CREATE TABLE IF NOT EXISTS comments(
     comment_id INT AUTO_INCREMENT PRIMARY KEY,
     post_id INT NOT NULL,
+    author_id INT NOT NULL,
     comment_msg TEXT NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    hidden_at TIMESTAMP NULL,
+    INDEX idx_comments_author_id (author_id),
+    FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+    CONSTRAINT fk_comments_author FOREIGN KEY (author_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FULLTEXT KEY ft_comments_comment_msg (comment_msg)
+);
+
+-- Reactions use a composite PK so a user can leave each reaction type only once per post/comment.
+-- The *_reaction_counts tables are denormalized counters kept in sync by the reaction handlers,
+-- so the feed does not need to COUNT(*) the reaction tables on every read.
+CREATE TABLE IF NOT EXISTS post_reactions(
+    post_id INT NOT NULL,
+    user_id INT NOT NULL,
+    reaction_type VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, user_id, reaction_type),
+    FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS comment_reactions(
+    comment_id INT NOT NULL,
+    user_id INT NOT NULL,
+    reaction_type VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (comment_id, user_id, reaction_type),
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS post_reaction_counts(
+    post_id INT NOT NULL,
+    reaction_type VARCHAR(16) NOT NULL,
+    total BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (post_id, reaction_type),
     FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE
 );
 
-CREATE TABLE IF NOT EXISTS comment_users(
+CREATE TABLE IF NOT EXISTS comment_reaction_counts(
+    comment_id INT NOT NULL,
+    reaction_type VARCHAR(16) NOT NULL,
+    total BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (comment_id, reaction_type),
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE
+);
+
+-- Hashtags are stored once (lowercase) and linked to posts/comments, mentions link posts/comments to users.
+-- created_at on the link tables is the time the tag was used, it drives the trending window.
+CREATE TABLE IF NOT EXISTS hashtags(
+    hashtag_id INT AUTO_INCREMENT PRIMARY KEY,
+    tag VARCHAR(64) UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS post_hashtags(
+    post_id INT NOT NULL,
+    hashtag_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, hashtag_id),
+    INDEX idx_post_hashtags_trending (created_at, hashtag_id),
+    FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (hashtag_id) REFERENCES hashtags(hashtag_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS comment_hashtags(
+    comment_id INT NOT NULL,
+    hashtag_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (comment_id, hashtag_id),
+    INDEX idx_comment_hashtags_trending (created_at, hashtag_id),
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+    FOREIGN KEY (hashtag_id) REFERENCES hashtags(hashtag_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS post_mentions(
+    post_id INT NOT NULL,
+    user_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, user_id),
+    FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS comment_mentions(
     comment_id INT NOT NULL,
     user_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     PRIMARY KEY (comment_id, user_id),
     FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
     FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
 );
 
--- On the other hands, when we delete table, we need to delete a table that link to 
--- many-to-many relationship table first.
+CREATE TABLE IF NOT EXISTS follows(
+    follower_id INT NOT NULL,
+    followee_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    INDEX idx_follows_followee_id (followee_id),
+    FOREIGN KEY (follower_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (followee_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS blocks(
+    blocker_id INT NOT NULL,
+    blocked_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    INDEX idx_blocks_blocked_id (blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS mutes(
+    muter_id INT NOT NULL,
+    muted_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (muter_id, muted_id),
+    INDEX idx_mutes_muted_id (muted_id),
+    FOREIGN KEY (muter_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (muted_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- user_id is the recipient, actor_id the user who caused the notification.
+-- read_at stays NULL until the recipient reads it.
+CREATE TABLE IF NOT EXISTS notifications(
+    notification_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    actor_id INT NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    post_id INT NULL,
+    comment_id INT NULL,
+    read_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_notifications_user_read (user_id, read_at),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE
+);
+
+-- A missing row means the notification type is enabled for the user.
+CREATE TABLE IF NOT EXISTS notification_preferences(
+    user_id INT NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, type),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Images attached to a post or a comment (exactly one of post_id and comment_id is set).
+-- The bytes live in the blob store, storage_key and thumbnail_key point at them.
+CREATE TABLE IF NOT EXISTS attachments(
+    attachment_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    post_id INT NULL,
+    comment_id INT NULL,
+    content_type VARCHAR(64) NOT NULL,
+    size BIGINT NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    thumbnail_key VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_attachments_post_id (post_id),
+    INDEX idx_attachments_comment_id (comment_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE
+);
+
+-- target_id points at posts, comments or users depending on target_type, so it has no FK.
+-- Reports outlive deleted content as part of the moderation history.
+-- reporter_id is NULL for content held by the automated content filter.
+CREATE TABLE IF NOT EXISTS reports(
+    report_id INT AUTO_INCREMENT PRIMARY KEY,
+    reporter_id INT NULL,
+    target_type VARCHAR(16) NOT NULL,
+    target_id INT NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    details VARCHAR(1000) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'open',
+    assigned_to VARCHAR(64) NOT NULL DEFAULT '',
+    resolved_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_reports_target (target_type, target_id),
+    INDEX idx_reports_status_created (status, created_at),
+    FOREIGN KEY (reporter_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Audit trail of moderator decisions. user_id is the user the action applies to.
+CREATE TABLE IF NOT EXISTS moderation_actions(
+    moderation_action_id INT AUTO_INCREMENT PRIMARY KEY,
+    report_id INT NULL,
+    moderator VARCHAR(64) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    target_type VARCHAR(16) NOT NULL,
+    target_id INT NOT NULL,
+    user_id INT NULL,
+    reason VARCHAR(1000) NOT NULL,
+    suspended_until TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_moderation_actions_report_id (report_id),
+    INDEX idx_moderation_actions_user_id (user_id),
+    FOREIGN KEY (report_id) REFERENCES reports(report_id) ON DELETE SET NULL,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Posts and comments the automated content filter rejected or held for review.
+-- Rejected content is never stored, so target_id is NULL and the excerpt is all that is kept.
+CREATE TABLE IF NOT EXISTS filter_events(
+    filter_event_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    target_type VARCHAR(16) NOT NULL,
+    target_id INT NULL,
+    verdict VARCHAR(16) NOT NULL,
+    filter VARCHAR(32) NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    excerpt VARCHAR(1000) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_filter_events_user_id (user_id),
+    INDEX idx_filter_events_verdict_created (verdict, created_at),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Personal data exports. storage_key points at the ZIP archive in the blob store while status is ready,
+-- it is cleared once expires_at has passed and the archive is deleted.
+CREATE TABLE IF NOT EXISTS export_jobs(
+    export_job_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    storage_key VARCHAR(255) NOT NULL DEFAULT '',
+    size BIGINT NOT NULL DEFAULT 0,
+    error VARCHAR(255) NOT NULL DEFAULT '',
+    completed_at TIMESTAMP NULL,
+    expires_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_export_jobs_user_id (user_id),
+    INDEX idx_export_jobs_expires_at (expires_at),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Append-only audit log of admin actions, authentication events and account changes.
+-- There are no foreign keys so entries outlive deleted users, and the triggers refuse any update or delete.
+CREATE TABLE IF NOT EXISTS audit_logs(
+    audit_log_id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    actor_type VARCHAR(16) NOT NULL,
+    actor_id INT NULL,
+    actor_name VARCHAR(64) NOT NULL DEFAULT '',
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(16) NOT NULL DEFAULT '',
+    target_id VARCHAR(64) NOT NULL DEFAULT '',
+    details VARCHAR(255) NOT NULL DEFAULT '',
+    changes TEXT NULL,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_audit_logs_actor (actor_type, actor_id),
+    INDEX idx_audit_logs_action (action),
+    INDEX idx_audit_logs_target (target_type, target_id),
+    INDEX idx_audit_logs_request_id (request_id),
+    INDEX idx_audit_logs_created_at (created_at)
+);
+
+CREATE TRIGGER audit_logs_no_update BEFORE UPDATE ON audit_logs
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_logs is append-only';
+
+CREATE TRIGGER audit_logs_no_delete BEFORE DELETE ON audit_logs
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_logs is append-only';
+
+-- Durable background job queue. Workers claim due pending jobs (SELECT ... FOR UPDATE SKIP LOCKED),
+-- failed attempts are retried with backoff and jobs out of attempts are kept as dead until an admin retries them.
+CREATE TABLE IF NOT EXISTS jobs(
+    job_id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    type VARCHAR(64) NOT NULL,
+    payload TEXT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 5,
+    run_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_by VARCHAR(128) NOT NULL DEFAULT '',
+    locked_until TIMESTAMP NULL,
+    last_error TEXT NULL,
+    completed_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_jobs_claim (status, type, run_at)
+);
+
+-- Outgoing webhooks. Events are delivered as HMAC-SHA256 signed JSON POSTs through the job queue,
+-- every attempt is kept in webhook_deliveries.
+CREATE TABLE IF NOT EXISTS webhooks(
+    webhook_id INT AUTO_INCREMENT PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    events VARCHAR(255) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries(
+    webhook_delivery_id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    webhook_id INT NOT NULL,
+    event_id VARCHAR(64) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    attempt INT NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    success BOOLEAN NOT NULL,
+    error VARCHAR(255) NOT NULL DEFAULT '',
+    response_body TEXT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_webhook_deliveries_webhook_id (webhook_id),
+    INDEX idx_webhook_deliveries_event_id (event_id),
+    FOREIGN KEY (webhook_id) REFERENCES webhooks(webhook_id) ON DELETE CASCADE
+);
+
+-- Personal API keys. Only the SHA-256 of a key is stored, scopes is a comma separated list of read, post and comment.
+CREATE TABLE IF NOT EXISTS api_keys(
+    api_key_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL,
+    scopes VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NULL,
+    last_used_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_api_keys_key_hash (key_hash),
+    INDEX idx_api_keys_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Logins with external OpenID Connect providers. user_identities links an account to the subject of a provider,
+-- oidc_login_states keeps the nonce and PKCE verifier of logins in progress.
+CREATE TABLE IF NOT EXISTS user_identities(
+    user_identity_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    provider VARCHAR(32) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    last_login_at TIMESTAMP NULL,
+    authenticated_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_user_identities_subject (provider, subject),
+    INDEX idx_user_identities_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS oidc_login_states(
+    state_hash CHAR(64) PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    user_id INT NULL,
+    reauthenticate BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_oidc_login_states_expires_at (expires_at)
+);
+
+-- Keys the server signs its JWTs with. The newest key whose not_before has passed signs, older keys stay until
+-- the tokens they signed expired. private_key is PEM encoded PKCS #8.
+CREATE TABLE IF NOT EXISTS signing_keys(
+    signing_key_id INT AUTO_INCREMENT PRIMARY KEY,
+    kid VARCHAR(64) NOT NULL,
+    algorithm VARCHAR(16) NOT NULL,
+    private_key TEXT NOT NULL,
+    not_before TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_signing_keys_kid (kid),
+    UNIQUE INDEX idx_signing_keys_not_before (not_before)
+);
+
+-- Hashes of the last passwords of each user, a new password cannot be one of them (PASSWORD_HISTORY)
+CREATE TABLE IF NOT EXISTS password_histories(
+    password_history_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_password_histories_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
@@ -21,6 +22,9 @@ import (
 
 var DB *gorm.DB
 
+// migrationDSN is the DSN of ConnectDatabase with multiStatements, only RunMigration connects with it
+var migrationDSN string
+
 func ConnectDatabase() {
 	// Variable to store the environment variables
 	username := os.Getenv("DB_USERNAME")
@@ -31,6 +35,8 @@ func ConnectDatabase() {
 
 	// DSN : data source name, used to open a database
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", username, password, host, port, database)
+	// Migration files contain more than one statement, the connection of the requests never accepts them
+	migrationDSN = dsn + "&multiStatements=true"
 
 	db, err := gorm.Open(gormSQL.Open(dsn), &gorm.Config{})
 
@@ -46,6 +52,8 @@ func RunMigration(c echo.Context) error {
 	if err := helpers.BindAndValidateRequest(c, &req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid request"})
 	}
+	// Read back by the handler for the audit log
+	c.Set("migrationID", req.MigrationID)
 
 	migrationPath := "./migrations/" + req.MigrationID + ".sql"
 	if _, err := os.Stat(migrationPath); os.IsNotExist(err) {
@@ -58,7 +66,13 @@ func RunMigration(c echo.Context) error {
 	}
 	sqlQuery := string(sqlBytes)
 
-	if res := DB.Exec(sqlQuery); res.Error != nil {
+	migrationDB, err := sql.Open("mysql", migrationDSN)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Error running migration"})
+	}
+	defer migrationDB.Close()
+
+	if _, err := migrationDB.Exec(sqlQuery); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Error running migration"})
 	}
 
@@ -0,0 +1,98 @@
+// Package dataloader batches the lookups of a request by key, so resolving a list of N items with a relation costs one
+// query instead of N. Load only queues the key and returns a thunk, the first thunk called fetches every queued key.
+// This fits executors that resolve a whole level of fields before calling thunks, like graphql-go.
+package dataloader
+
+import "sync"
+
+// MaxBatch is the most keys passed to one call of the batch function, larger batches are split
+const MaxBatch = 500
+
+// BatchFunc returns the values of the keys, keys without a value are left out of the map
+type BatchFunc[K comparable, V any] func(keys []K) (map[K]V, error)
+
+// Loader caches the values it loaded, use one loader per request so nothing outlives the request
+type Loader[K comparable, V any] struct {
+	batch BatchFunc[K, V]
+
+	mu      sync.Mutex
+	results map[K]*result[V]
+	pending []K
+	batches int
+}
+
+type result[V any] struct {
+	value  V
+	found  bool
+	err    error
+	loaded bool
+}
+
+// New returns a loader calling batch for the keys that are not cached yet
+func New[K comparable, V any](batch BatchFunc[K, V]) *Loader[K, V] {
+	return &Loader[K, V]{batch: batch, results: make(map[K]*result[V])}
+}
+
+// Load queues the key. The thunk returns its value, found is false when the batch function returned none.
+func (l *Loader[K, V]) Load(key K) func() (value V, found bool, err error) {
+	l.mu.Lock()
+	entry, ok := l.results[key]
+	if !ok {
+		entry = &result[V]{}
+		l.results[key] = entry
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, bool, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if !entry.loaded {
+			l.flush()
+		}
+		return entry.value, entry.found, entry.err
+	}
+}
+
+// Prime caches a value loaded some other way, such as the result of a mutation
+func (l *Loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if entry, ok := l.results[key]; ok {
+		entry.value, entry.found, entry.err, entry.loaded = value, true, nil, true
+		return
+	}
+	l.results[key] = &result[V]{value: value, found: true, loaded: true}
+}
+
+// Batches returns how many times the batch function was called
+func (l *Loader[K, V]) Batches() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.batches
+}
+
+// flush loads every pending key, the caller holds the lock
+func (l *Loader[K, V]) flush() {
+	pending := l.pending
+	l.pending = nil
+	for len(pending) > 0 {
+		keys := pending
+		if len(keys) > MaxBatch {
+			keys = keys[:MaxBatch]
+		}
+		pending = pending[len(keys):]
+
+		l.batches++
+		values, err := l.batch(keys)
+		for _, key := range keys {
+			entry := l.results[key]
+			entry.loaded = true
+			if err != nil {
+				entry.err = err
+				continue
+			}
+			entry.value, entry.found = values[key]
+		}
+	}
+}
@@ -38,6 +38,26 @@ const docTemplate = `{
                 }
             }
         },
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "Public keys (JWKS, RFC 7517) that verify the tokens of this server, by kid. The next key is listed before it signs.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get the JWT signing keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/jwks.Set"
+                        }
+                    }
+                }
+            }
+        },
         "/admin/main": {
             "get": {
                 "description": "This is the main admin page accessible only to authenticated users",
@@ -70,9 +90,126 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/admin/get-migrations": {
+        "/api/v1/admin/audit-logs": {
             "get": {
-                "description": "Get a list of all available migrations with their titles and descriptions",
+                "description": "List admin actions, authentication events and account changes, newest first.\nWith format csv or jsonl every matching entry is downloaded instead of one page",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "Audit"
+                ],
+                "summary": "List or export the audit log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user, admin or anonymous",
+                        "name": "actor_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User ID of the actor",
+                        "name": "actor_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Action, such as auth.login or user.update",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target type, such as user, report or migration",
+                        "name": "target_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target ID",
+                        "name": "target_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Request ID (X-Request-Id)",
+                        "name": "request_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client IP",
+                        "name": "ip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Oldest entry (RFC 3339)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Newest entry (RFC 3339)",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json (default), csv or jsonl",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entries per page (max 50, default 20)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Audit log entries",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AuditLogResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get audit logs",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/comments": {
+            "get": {
+                "description": "Get all comments. With a valid token, comments by users the viewer blocked or muted are left out.\nThe post must be visible to the viewer",
                 "consumes": [
                     "application/json"
                 ],
@@ -80,21 +217,21 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Migrations"
+                    "comments"
                 ],
-                "summary": "Retrieve all available migrations",
+                "summary": "Get all comments",
                 "responses": {
                     "200": {
-                        "description": "List of migrations",
+                        "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.GetMigrationListRequest"
+                                "$ref": "#/definitions/models.Comment"
                             }
                         }
                     },
                     "500": {
-                        "description": "Failed to load migrations",
+                        "description": "Failed to retrieve comments",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -105,9 +242,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/admin/run-migrations": {
-            "post": {
-                "description": "Execute a specific database migration identified by its ID",
+        "/api/v1/admin/filter-events": {
+            "get": {
+                "description": "List the posts and comments the automated content filter rejected or held for review, newest first.\nHeld content is hidden and also waits in the moderation queue as a report with reason \"filter\"",
                 "consumes": [
                     "application/json"
                 ],
@@ -115,32 +252,47 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Migrations"
+                    "Moderation"
                 ],
-                "summary": "Execute a migration",
+                "summary": "List content filter events",
                 "parameters": [
                     {
-                        "description": "Migration ID to run",
-                        "name": "migration_id",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.RunMigrationRequest"
-                        }
+                        "type": "string",
+                        "description": "hold or reject",
+                        "name": "verdict",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter name: banned_words, review_words, links or spam",
+                        "name": "filter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Events per page (max 50, default 20)",
+                        "name": "page_size",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Migration ran successfully",
+                        "description": "Filter events",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.FilterEventResponse"
                             }
                         }
                     },
                     "400": {
-                        "description": "Invalid request",
+                        "description": "Invalid input",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -148,17 +300,43 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "404": {
-                        "description": "Migration not found",
+                    "500": {
+                        "description": "Failed to get filter events",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
                         }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/get-migrations": {
+            "get": {
+                "description": "Get a list of all available migrations with their titles and descriptions",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Migrations"
+                ],
+                "summary": "Retrieve all available migrations",
+                "responses": {
+                    "200": {
+                        "description": "List of migrations",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.GetMigrationListRequest"
+                            }
+                        }
                     },
                     "500": {
-                        "description": "Error running migration",
+                        "description": "Failed to load migrations",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -169,9 +347,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/admin/users": {
+        "/api/v1/admin/jobs": {
             "get": {
-                "description": "Retrieve all users or a specific user if User ID is provided in the query parameter",
+                "description": "List the jobs of the background queue, newest first. Dead jobs ran out of attempts and can be retried",
                 "consumes": [
                     "application/json"
                 ],
@@ -179,22 +357,43 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Jobs"
                 ],
-                "summary": "Get all users or a specific user by ID",
+                "summary": "List background jobs",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "pending, running, succeeded or dead",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Job type, such as export.build",
+                        "name": "type",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
-                        "description": "User ID",
-                        "name": "uid",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Jobs per page (max 50, default 20)",
+                        "name": "page_size",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Details of a specific user",
+                        "description": "Jobs",
                         "schema": {
-                            "$ref": "#/definitions/models.User"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.JobResponse"
+                            }
                         }
                     },
                     "400": {
@@ -206,8 +405,49 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "404": {
-                        "description": "User not found",
+                    "500": {
+                        "description": "Failed to get jobs",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/jobs/{jid}": {
+            "get": {
+                "description": "Get a job of the background queue with its payload, attempts and last error",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Jobs"
+                ],
+                "summary": "Get a background job",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Job ID",
+                        "name": "jid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job",
+                        "schema": {
+                            "$ref": "#/definitions/models.JobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -215,8 +455,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "500": {
-                        "description": "Failed to retrieve users",
+                    "404": {
+                        "description": "Job not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -227,9 +467,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/login": {
+        "/api/v1/admin/jobs/{jid}/retry": {
             "post": {
-                "description": "Authenticate a user and return a JWT token",
+                "description": "Put a dead job back in the queue with a fresh set of attempts",
                 "consumes": [
                     "application/json"
                 ],
@@ -237,23 +477,27 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Jobs"
                 ],
-                "summary": "Log in a user",
+                "summary": "Retry a dead background job",
                 "parameters": [
                     {
-                        "description": "User login details",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.LoginUserRequest"
-                        }
+                        "type": "integer",
+                        "description": "Job ID",
+                        "name": "jid",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Login successful, token returned",
+                        "description": "Job, pending again",
+                        "schema": {
+                            "$ref": "#/definitions/models.JobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -261,8 +505,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "400": {
-                        "description": "Invalid input",
+                    "404": {
+                        "description": "Job not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -270,8 +514,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "401": {
-                        "description": "Invalid username, email, or password",
+                    "409": {
+                        "description": "Only dead jobs can be retried",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -280,7 +524,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Failed to generate token",
+                        "description": "Failed to retry job",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -291,9 +535,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/logout": {
-            "post": {
-                "description": "This route logs out the user by clearing the JWT cookie",
+        "/api/v1/admin/reports": {
+            "get": {
+                "description": "List reports, oldest first for open reports and newest first otherwise, with a preview of the reported content",
                 "consumes": [
                     "application/json"
                 ],
@@ -301,12 +545,68 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "logout"
+                    "Moderation"
                 ],
-                "summary": "Logout user by clearing cookie",
-                "responses": {
+                "summary": "List reports (moderation queue)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "open (default), resolved, dismissed or all",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "post, comment or user",
+                        "name": "target_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Report reason",
+                        "name": "reason",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Moderator the report is assigned to, - for unassigned reports",
+                        "name": "assigned_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Reports per page (max 50, default 20)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
                     "200": {
-                        "description": "Logout successful",
+                        "description": "Reports",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ReportResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get reports",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -317,9 +617,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/posts": {
-            "get": {
-                "description": "Get all posts with associated user details (username, firstname, surname)",
+        "/api/v1/admin/reports/{rid}/actions": {
+            "post": {
+                "description": "Apply a moderator decision: hide, unhide or delete the reported post or comment, warn or suspend its author\n(or the reported user), or dismiss the report. The decision is recorded with its reason, and every open\nreport on the same target is closed with it. Unhiding content the filter held publishes it like new content",
                 "consumes": [
                     "application/json"
                 ],
@@ -327,21 +627,63 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Posts"
+                    "Moderation"
+                ],
+                "summary": "Act on a report",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Report ID",
+                        "name": "rid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Decision",
+                        "name": "action",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ModerationActionRequest"
+                        }
+                    }
                 ],
-                "summary": "Retrieve all posts",
                 "responses": {
                     "200": {
-                        "description": "List of posts with user details",
+                        "description": "Closed report",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.GetPublicPostsRequest"
+                            "$ref": "#/definitions/models.ReportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Report or reported content not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Report already closed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
                             }
                         }
                     },
                     "500": {
-                        "description": "Failed to retrieve posts",
+                        "description": "Failed to apply action",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -352,9 +694,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/restricted/main": {
-            "get": {
-                "description": "This route is restricted and requires a valid JWT token to access",
+        "/api/v1/admin/reports/{rid}/assign": {
+            "put": {
+                "description": "Assign a report to a moderator, an empty assigned_to unassigns it",
                 "consumes": [
                     "application/json"
                 ],
@@ -362,18 +704,54 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "restricted"
+                    "Moderation"
+                ],
+                "summary": "Assign a report to a moderator",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Report ID",
+                        "name": "rid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Moderator",
+                        "name": "assignment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AssignReportRequest"
+                        }
+                    }
                 ],
-                "summary": "Restricted route with JWT authentication",
                 "responses": {
                     "200": {
-                        "description": "Welcome [username]!",
+                        "description": "Updated report",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.ReportResponse"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Report not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to assign report",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -384,9 +762,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/restricted/posts": {
+        "/api/v1/admin/run-migrations": {
             "post": {
-                "description": "Create a new post by an authenticated user",
+                "description": "Execute a specific database migration identified by its ID",
                 "consumes": [
                     "application/json"
                 ],
@@ -394,29 +772,32 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Posts"
+                    "Migrations"
                 ],
-                "summary": "Create a new post",
+                "summary": "Execute a migration",
                 "parameters": [
                     {
-                        "description": "Post object that needs to be created",
-                        "name": "post",
+                        "description": "Migration ID to run",
+                        "name": "migration_id",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Post"
+                            "$ref": "#/definitions/models.RunMigrationRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Newly created post",
+                    "200": {
+                        "description": "Migration ran successfully",
                         "schema": {
-                            "$ref": "#/definitions/models.Post"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Invalid input",
+                        "description": "Invalid request",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -424,8 +805,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "404": {
+                        "description": "Migration not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -434,7 +815,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Failed to create post",
+                        "description": "Error running migration",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -445,9 +826,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/restricted/users-update-password/{uid}": {
-            "put": {
-                "description": "Change the password of an existing user by ID",
+        "/api/v1/admin/users": {
+            "get": {
+                "description": "Retrieve all users or a specific user if User ID is provided in the query parameter",
                 "consumes": [
                     "application/json"
                 ],
@@ -457,33 +838,20 @@ const docTemplate = `{
                 "tags": [
                     "Users"
                 ],
-                "summary": "Change a user's password",
+                "summary": "Get all users or a specific user by ID",
                 "parameters": [
                     {
                         "type": "integer",
                         "description": "User ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "New password",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.UpdateUserPasswordRequest"
-                        }
+                        "name": "uid",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Password updated successfully",
+                        "description": "Details of a specific user",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.User"
                         }
                     },
                     "400": {
@@ -505,7 +873,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Failed to update password",
+                        "description": "Failed to retrieve users",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -516,9 +884,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/restricted/users/{uid}": {
+        "/api/v1/admin/users/{uid}/status": {
             "put": {
-                "description": "Update the details of an existing user by ID",
+                "description": "Activate, suspend, ban or deactivate an account. Existing tokens of the account stop working right away\nunless it is activated. The change is recorded in the moderation audit trail with its reason",
                 "consumes": [
                     "application/json"
                 ],
@@ -526,30 +894,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Moderation"
                 ],
-                "summary": "Update an existing user",
+                "summary": "Change the status of an account",
                 "parameters": [
                     {
                         "type": "integer",
                         "description": "User ID",
-                        "name": "id",
+                        "name": "uid",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Updated user details",
-                        "name": "user",
+                        "description": "New status",
+                        "name": "status",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.UpdateUserRequest"
+                            "$ref": "#/definitions/models.UpdateAccountStatusRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updated user details",
+                        "description": "Updated user",
                         "schema": {
                             "$ref": "#/definitions/models.User"
                         }
@@ -573,7 +941,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Failed to update user",
+                        "description": "Failed to update account status",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -584,9 +952,42 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users": {
+        "/api/v1/admin/webhooks": {
+            "get": {
+                "description": "List the registered webhook endpoints",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "List webhooks",
+                "responses": {
+                    "200": {
+                        "description": "Webhooks",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.WebhookResponse"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get webhooks",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Register a new user with the provided details",
+                "description": "Register an endpoint that receives the subscribed events as signed JSON POST requests.\nThe secret used for the signatures is only returned in this response",
                 "consumes": [
                     "application/json"
                 ],
@@ -594,29 +995,29 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Webhooks"
                 ],
-                "summary": "Create a new user",
+                "summary": "Register a webhook",
                 "parameters": [
                     {
-                        "description": "User registration details",
-                        "name": "user",
+                        "description": "Endpoint and events",
+                        "name": "webhook",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.CreateUserRequest"
+                            "$ref": "#/definitions/models.CreateWebhookRequest"
                         }
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "Newly created user details",
+                        "description": "Webhook with its secret",
                         "schema": {
-                            "$ref": "#/definitions/models.User"
+                            "$ref": "#/definitions/models.WebhookResponse"
                         }
                     },
-                    "409": {
-                        "description": "Username or email already exists",
+                    "400": {
+                        "description": "Invalid input",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -625,7 +1026,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Failed to create user",
+                        "description": "Failed to create webhook",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -636,9 +1037,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/comments": {
+        "/api/v1/admin/webhooks/{wid}": {
             "get": {
-                "description": "Get all comments",
+                "description": "Get a registered webhook endpoint",
                 "consumes": [
                     "application/json"
                 ],
@@ -646,22 +1047,38 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "comments"
+                    "Webhooks"
+                ],
+                "summary": "Get a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "wid",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get all comments",
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Webhook",
                         "schema": {
-                            "$ref": "#/definitions/models.Comment"
+                            "$ref": "#/definitions/models.WebhookResponse"
                         }
-                    }
-                }
-            }
-        },
-        "/commentuser": {
-            "get": {
-                "description": "Get all user comments",
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Change the URL, events, description of a webhook or pause it. Deliveries already queued for an inactive webhook are dropped",
                 "consumes": [
                     "application/json"
                 ],
@@ -669,22 +1086,118 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "commentUser"
+                    "Webhooks"
+                ],
+                "summary": "Update a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "wid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Changes",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateWebhookRequest"
+                        }
+                    }
                 ],
-                "summary": "Get all user comments",
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Updated webhook",
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update webhook",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a webhook with its delivery log. Deliveries already queued are dropped",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Delete a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "wid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Webhook deleted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete webhook",
                         "schema": {
-                            "$ref": "#/definitions/models.CommentUser"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
         },
-        "/cookie-page": {
+        "/api/v1/admin/webhooks/{wid}/deliveries": {
             "get": {
-                "description": "This page is used for debugging cookies",
+                "description": "List the delivery attempts of a webhook, newest first",
                 "consumes": [
                     "application/json"
                 ],
@@ -692,22 +1205,73 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "debug"
+                    "Webhooks"
+                ],
+                "summary": "List the deliveries of a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "wid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Deliveries per page (max 50, default 20)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Cookie debug page",
                 "responses": {
                     "200": {
-                        "description": "Welcome to the cookie page",
+                        "description": "Deliveries",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.WebhookDeliveryResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get deliveries",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
         },
-        "/jwt-page": {
-            "get": {
-                "description": "This page is used for debugging JWT tokens",
+        "/api/v1/admin/webhooks/{wid}/ping": {
+            "post": {
+                "description": "Deliver a signed ping event right away, without retries, and return the delivery. Works on inactive webhooks too",
                 "consumes": [
                     "application/json"
                 ],
@@ -715,181 +1279,4586 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "debug"
+                    "Webhooks"
+                ],
+                "summary": "Send a test ping to a webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Webhook ID",
+                        "name": "wid",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "JWT debug page",
                 "responses": {
                     "200": {
-                        "description": "Welcome to the JWT page",
+                        "description": "Delivery, success tells whether the receiver accepted it",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.WebhookDeliveryResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to ping webhook",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.Comment": {
-            "description": "Represents a comment made by users",
-            "type": "object",
-            "properties": {
-                "commentID": {
-                    "type": "integer"
-                },
-                "commentMSG": {
-                    "type": "string"
+        },
+        "/api/v1/attachments/{aid}": {
+            "get": {
+                "description": "Download an attachment or its JPEG thumbnail through a signed URL returned with the post or comment.\nThe viewer must still be allowed to read the post or comment, so a link to a followers only post needs the token of a follower",
+                "produces": [
+                    "image/jpeg",
+                    "image/png",
+                    "image/gif"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Download an attachment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Attachment ID",
+                        "name": "aid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "original (default) or thumbnail",
+                        "name": "variant",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry of the signed URL (unix seconds)",
+                        "name": "expires",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of the URL",
+                        "name": "signature",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Image",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired signature",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Attachment not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get attachment",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/oidc": {
+            "get": {
+                "description": "List the OpenID Connect providers users can log in with",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "List external login providers",
+                "responses": {
+                    "200": {
+                        "description": "Provider names",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/oidc/{provider}": {
+            "get": {
+                "description": "Start the authorization code flow with PKCE: redirects to the provider, which sends the browser back to the callback.\nWith reauthenticate the provider prompts for the credentials again, accounts without a password do this\nbefore deleting or deactivating the account",
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Log in with an external provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Make the provider prompt for the credentials",
+                        "name": "reauthenticate",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Redirect to the provider"
+                    },
+                    "404": {
+                        "description": "Unknown provider",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "502": {
+                        "description": "The provider cannot be reached",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/oidc/{provider}/callback": {
+            "get": {
+                "description": "The provider redirects here with an authorization code. The account is found by the provider identity,\nlinked to an existing account whose email is verified, or created. An account registered with a password\nis only linked when its owner starts the login from /api/v1/restricted/auth/oidc/{provider}/link.\nReturns a JWT like /api/v1/login",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Finish a login with an external provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Authorization code",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "State of the login",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Login successful, token returned",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid or expired login",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Account deleted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Account suspended or banned, the email is not verified, or it belongs to an account that has to link the provider itself",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown provider",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "The identity is linked to another account",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to log in",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/exports/{jid}": {
+            "get": {
+                "description": "Download the ZIP archive of a ready export through the signed URL returned by the status endpoint",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Download a personal data export",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Export job ID",
+                        "name": "jid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry of the signed URL (unix seconds)",
+                        "name": "expires",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of the URL",
+                        "name": "signature",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ZIP archive",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired link, also for unknown exports",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Export not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "410": {
+                        "description": "Export expired",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/graphql": {
+            "post": {
+                "description": "Query users, posts and comments with their relations, or create and edit them with mutations.\nWithout a token only public content can be queried. Mutations need a valid token and go through\nthe same validation, content filter and notifications as the REST routes; their errors carry the\nHTTP status of the REST route in extensions.status. Queries deeper than GRAPHQL_MAX_DEPTH (8) or more\ncomplex than GRAPHQL_MAX_COMPLEXITY (1000, every field multiplied by the size of its lists) are rejected",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "GraphQL"
+                ],
+                "summary": "GraphQL endpoint",
+                "parameters": [
+                    {
+                        "description": "GraphQL query",
+                        "name": "query",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.GraphQLRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Data and field errors",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query or query over the limits",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/login": {
+            "post": {
+                "description": "Authenticate a user and return a JWT token. Logging in reactivates a deactivated account",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Log in a user",
+                "parameters": [
+                    {
+                        "description": "User login details",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LoginUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Login successful, token returned",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Account deleted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Account suspended or banned",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to generate token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/logout": {
+            "post": {
+                "description": "This route logs out the user by clearing the JWT cookie",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "logout"
+                ],
+                "summary": "Logout user by clearing cookie",
+                "responses": {
+                    "200": {
+                        "description": "Logout successful",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/posts": {
+            "get": {
+                "description": "Get all posts with associated user details (username, firstname, surname)\nReaction counts are included for every post, and my_reactions is filled when a valid token is sent.\nAnonymous viewers only get public posts. With a valid token, the viewer also gets their own posts and the\nfollowers-only posts of the users they follow, and posts by users the viewer blocked or muted are left out of the list.\nDrafts are only returned to their author, by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Posts"
+                ],
+                "summary": "Retrieve all posts",
+                "responses": {
+                    "200": {
+                        "description": "List of posts with user details",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.GetPublicPostsRequest"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Post not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve posts",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/account": {
+            "delete": {
+                "description": "Delete the authenticated user's account after confirming the password, accounts without one log in with their\nprovider again first. Depending on ACCOUNT_DELETION_POLICY,\nposts and comments are deleted with the account (cascade, the default) or kept under a placeholder name (anonymize).\nFollows, blocks, mutes, notifications, data exports and the avatar are removed either way",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Delete my account",
+                "parameters": [
+                    {
+                        "description": "Current password",
+                        "name": "confirmation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DeleteAccountRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Account deleted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid password, or no recent login with the provider",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete account",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/account/deactivate": {
+            "post": {
+                "description": "Deactivate the authenticated user's account after confirming the password. Accounts without a password log in\nwith their provider again first. Existing tokens stop working, and logging in again reactivates the account",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Deactivate my account",
+                "parameters": [
+                    {
+                        "description": "Current password",
+                        "name": "confirmation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DeactivateAccountRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Account deactivated",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid password, or no recent login with the provider",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to deactivate account",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/api-keys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the API keys of the authenticated user, revoked and expired keys included. The keys themselves are never returned",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API keys"
+                ],
+                "summary": "List my API keys",
+                "responses": {
+                    "200": {
+                        "description": "API keys",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.APIKeyResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get API keys",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a personal API key for scripts and bots. The key is only returned in this response, send it as\n\"Authorization: ApiKey \u003ckey\u003e\" or in the X-API-Key header. read allows reading posts, comments, profiles and\nnotifications (not exports or API keys), post and comment allow\ncreating and editing posts and comments",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API keys"
+                ],
+                "summary": "Create an API key",
+                "parameters": [
+                    {
+                        "description": "Name, scopes and expiry",
+                        "name": "key",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "API key with the key itself",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Too many API keys",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create API key",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/api-keys/{kid}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke one of my API keys, it stops working right away. Revoking a revoked key is a no-op",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "API keys"
+                ],
+                "summary": "Revoke an API key",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "API key ID",
+                        "name": "kid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Revoked API key",
+                        "schema": {
+                            "$ref": "#/definitions/models.APIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "API key not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to revoke API key",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/auth/oidc/{provider}/link": {
+            "post": {
+                "description": "Start a login with the provider after confirming the password (accounts without one log in with their provider\nagain first). When the browser comes back to the callback, the identity is linked to the authenticated user's\naccount and can log in to it from then on",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Link an external provider to my account",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Current password",
+                        "name": "confirmation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LinkOIDCProviderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Where to send the browser",
+                        "schema": {
+                            "$ref": "#/definitions/models.OIDCRedirectResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid password, or no recent login with the provider",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown provider",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to start login",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "502": {
+                        "description": "The provider cannot be reached",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/blocks": {
+            "get": {
+                "description": "Get the users blocked by the authenticated user, most recently blocked first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Blocks"
+                ],
+                "summary": "List blocked users",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Users per page (max 50, default 20)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Blocked users",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.UserRelationResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get blocked users",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/blocks/{uid}": {
+            "put": {
+                "description": "Block another user. They can no longer follow you or comment on your posts, follows in both directions\nare removed, and their posts, comments and notifications are hidden from you. Blocking someone twice has no effect",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Blocks"
+                ],
+                "summary": "Block a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID to block",
+                        "name": "uid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Blocked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to block user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Unblock a user. Unblocking someone you did not block has no effect",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Blocks"
+                ],
+                "summary": "Unblock a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID to unblock",
+                        "name": "uid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Unblocked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to unblock user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/comments": {
+            "post": {
+                "description": "Create a new comment. The content filter may reject the comment,\nor accept it hidden until a moderator reviews it (202 with HiddenAt set)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "comments"
+                ],
+                "summary": "Create a comment",
+                "parameters": [
+                    {
+                        "description": "Comment object that needs to be created",
+                        "name": "comment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Comment"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Comment"
+                        }
+                    },
+                    "202": {
+                        "description": "Comment held for review",
+                        "schema": {
+                            "$ref": "#/definitions/models.Comment"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Blocked by the author of the post",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Rejected by the content filter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create comment",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/comments/{cid}": {
+            "put": {
+                "description": "Edit the message of a comment written by the authenticated user. Hashtags and mentions are extracted again.\nThe new message goes through the content filter like a new comment, a held comment is hidden until reviewed",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "comments"
+                ],
+                "summary": "Edit a comment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Comment ID",
+                        "name": "cid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New message",
+                        "name": "comment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateCommentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Comment"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not the author of the comment",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Comment not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Rejected by the content filter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update comment",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/comments/{cid}/attachments": {
+            "post": {
+                "description": "Upload a JPEG, PNG or GIF image (max 10 MB, max 4 per comment) to a comment owned by the authenticated user.\nThe content type is detected from the file content and a thumbnail is generated",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Attach an image to a comment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Comment ID",
+                        "name": "cid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Image file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Uploaded attachment",
+                        "schema": {
+                            "$ref": "#/definitions/models.AttachmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not the author of the comment",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Comment not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "File is too large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported file type",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to store attachment",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/comments/{cid}/reactions/{type}": {
+            "put": {
+                "description": "Add a reaction of the given type to a comment. Adding the same reaction twice has no effect",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Reactions"
+                ],
+                "summary": "React to a comment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Comment ID",
+                        "name": "cid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reaction type (like, love, laugh, wow, sad, angry)",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reactions of the comment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ReactionSummaryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Comment not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to add reaction",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a reaction of the given type from a comment. Removing a missing reaction has no effect",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Reactions"
+                ],
+                "summary": "Remove a reaction from a comment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Comment ID",
+                        "name": "cid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reaction type (like, love, laugh, wow, sad, angry)",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reactions of the comment",
+                        "schema": {
+                            "$ref": "#/definitions/models.ReactionSummaryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to remove reaction",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/drafts": {
+            "get": {
+                "description": "List the unpublished posts of the authenticated user. Publish a draft by editing it with another visibility",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Posts"
+                ],
+                "summary": "List my drafts",
+                "responses": {
+                    "200": {
+                        "description": "Drafts",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.GetPublicPostsRequest"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get drafts",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/follows/{uid}": {
+            "put": {
+                "description": "Follow another user. Following someone twice has no effect",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Follows"
+                ],
+                "summary": "Follow a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID to follow",
+                        "name": "uid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Following",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Blocked by the user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to follow user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stop following a user. Unfollowing someone you do not follow has no effect",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Follows"
+                ],
+                "summary": "Unfollow a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID to unfollow",
+                        "name": "uid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Unfollowed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to unfollow user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/main": {
+            "get": {
+                "description": "This route is restricted and requires a valid JWT token to access",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "restricted"
+                ],
+                "summary": "Restricted route with JWT authentication",
+                "responses": {
+                    "200": {
+                        "description": "Welcome [username]!",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/me/export": {
+            "post": {
+                "description": "Start building a ZIP archive with the profile, posts, comments, reactions and follows of the authenticated user.\nThe archive is built in the background, poll the returned job until its status is ready.\nWhile a job is pending or running it is returned instead of starting a new one",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Export my personal data",
+                "responses": {
+                    "202": {
+                        "description": "Export job",
+                        "schema": {
+                            "$ref": "#/definitions/models.ExportJobResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to start export",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/me/export/{jid}": {
+            "get": {
+                "description": "Poll an export job of the authenticated user. Once ready, the response contains a signed download URL valid until expires_at",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get the status of a personal data export",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Export job ID",
+                        "name": "jid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Export job",
+                        "schema": {
+                            "$ref": "#/definitions/models.ExportJobResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Export not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/mutes": {
+            "get": {
+                "description": "Get the users muted by the authenticated user, most recently muted first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Blocks"
+                ],
+                "summary": "List muted users",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Users per page (max 50, default 20)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Muted users",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.UserRelationResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get muted users",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/mutes/{uid}": {
+            "put": {
+                "description": "Mute another user. Their posts, comments and notifications are hidden from you, they are not told\nand can still follow you and comment. Muting someone twice has no effect",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Blocks"
+                ],
+                "summary": "Mute a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID to mute",
+                        "name": "uid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Muted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to mute user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Unmute a user. Unmuting someone you did not mute has no effect",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Blocks"
+                ],
+                "summary": "Unmute a user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID to unmute",
+                        "name": "uid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Unmuted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to unmute user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/notification-preferences": {
+            "get": {
+                "description": "Get which notification types the authenticated user receives",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Get notification preferences",
+                "responses": {
+                    "200": {
+                        "description": "Notification type to enabled flag",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get preferences",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Turn notification types on or off. Types that are not in the body keep their current setting",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Update notification preferences",
+                "parameters": [
+                    {
+                        "description": "Notification type to enabled flag",
+                        "name": "preferences",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Notification type to enabled flag",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update preferences",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/notifications": {
+            "get": {
+                "description": "Get the notifications of the authenticated user, newest first. Notifications caused by users\nthe authenticated user blocked or muted are left out",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "List notifications",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Only unread notifications",
+                        "name": "unread",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Notifications per page (max 50, default 20)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Notifications",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.NotificationResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get notifications",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/notifications/read-all": {
+            "put": {
+                "description": "Mark every unread notification of the authenticated user as read",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Mark all notifications as read",
+                "responses": {
+                    "200": {
+                        "description": "Notifications marked as read",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update notifications",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/notifications/unread-count": {
+            "get": {
+                "description": "Get the number of unread notifications of the authenticated user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Count unread notifications",
+                "responses": {
+                    "200": {
+                        "description": "Unread count",
+                        "schema": {
+                            "$ref": "#/definitions/models.UnreadCountResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to count notifications",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/notifications/{nid}/read": {
+            "put": {
+                "description": "Mark one notification of the authenticated user as read",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Mark a notification as read",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Notification ID",
+                        "name": "nid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Notification marked as read",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Notification not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update notification",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/posts": {
+            "post": {
+                "description": "Create a new post by an authenticated user. The content filter may reject the post,\nor accept it hidden until a moderator reviews it (202 with HiddenAt set).\nVisibility is public (default), followers, private or draft. Drafts notify nobody until they are published.\nWith publish_at the post is scheduled: only the author sees it until a background worker publishes it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Posts"
+                ],
+                "summary": "Create a new post",
+                "parameters": [
+                    {
+                        "description": "Post object that needs to be created",
+                        "name": "post",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Post"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Newly created post",
+                        "schema": {
+                            "$ref": "#/definitions/models.Post"
+                        }
+                    },
+                    "202": {
+                        "description": "Post held for review",
+                        "schema": {
+                            "$ref": "#/definitions/models.Post"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Rejected by the content filter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create post",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/posts/{pid}": {
+            "put": {
+                "description": "Edit the message of a post owned by the authenticated user. Hashtags and mentions are extracted again.\nThe new message goes through the content filter like a new post, a held post is hidden until reviewed.\nChanging the visibility of a draft publishes it, a published post cannot become a draft again",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Posts"
+                ],
+                "summary": "Edit a post",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Post ID",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New message",
+                        "name": "post",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdatePostRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated post",
+                        "schema": {
+                            "$ref": "#/definitions/models.Post"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not the author of the post",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Post not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Rejected by the content filter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update post",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/posts/{pid}/attachments": {
+            "post": {
+                "description": "Upload a JPEG, PNG or GIF image (max 10 MB, max 4 per post) to a post owned by the authenticated user.\nThe content type is detected from the file content and a thumbnail is generated",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Attachments"
+                ],
+                "summary": "Attach an image to a post",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Post ID",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Image file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Uploaded attachment",
+                        "schema": {
+                            "$ref": "#/definitions/models.AttachmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not the author of the post",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Post not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "File is too large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported file type",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to store attachment",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/posts/{pid}/reactions/{type}": {
+            "put": {
+                "description": "Add a reaction of the given type to a post. Adding the same reaction twice has no effect",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Reactions"
+                ],
+                "summary": "React to a post",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Post ID",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reaction type (like, love, laugh, wow, sad, angry)",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reactions of the post",
+                        "schema": {
+                            "$ref": "#/definitions/models.ReactionSummaryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Post not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to add reaction",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a reaction of the given type from a post. Removing a missing reaction has no effect",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Reactions"
+                ],
+                "summary": "Remove a reaction from a post",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Post ID",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reaction type (like, love, laugh, wow, sad, angry)",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reactions of the post",
+                        "schema": {
+                            "$ref": "#/definitions/models.ReactionSummaryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to remove reaction",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/profile": {
+            "put": {
+                "description": "Replace the display name, bio, location and website of the authenticated user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Update the authenticated user's profile",
+                "parameters": [
+                    {
+                        "description": "Profile fields",
+                        "name": "profile",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateProfileRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated profile",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserProfileResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update profile",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/profile/avatar": {
+            "put": {
+                "description": "Upload a JPEG, PNG or GIF image (max 5 MB) as the avatar of the authenticated user. It is cropped to a square and resized to 256x256",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Upload an avatar",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Image file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated profile",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserProfileResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "File is too large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported file type",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to store avatar",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove the avatar of the authenticated user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Remove the avatar",
+                "responses": {
+                    "200": {
+                        "description": "Updated profile",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserProfileResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to remove avatar",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/reports": {
+            "post": {
+                "description": "Flag content for the moderators. A user can only have one open report per target",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Moderation"
+                ],
+                "summary": "Report a post, comment or user",
+                "parameters": [
+                    {
+                        "description": "Report",
+                        "name": "report",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateReportRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Report submitted",
+                        "schema": {
+                            "$ref": "#/definitions/models.ReportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Reported content not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Already reported",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to submit report",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/scheduled-posts": {
+            "get": {
+                "description": "List the posts of the authenticated user that are waiting to be published, the next one first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Posts"
+                ],
+                "summary": "List my scheduled posts",
+                "responses": {
+                    "200": {
+                        "description": "Scheduled posts",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.GetPublicPostsRequest"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get scheduled posts",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/scheduled-posts/{pid}": {
+            "put": {
+                "description": "Change the publication time of a scheduled post of the authenticated user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Posts"
+                ],
+                "summary": "Reschedule a post",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Post ID",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New publication time",
+                        "name": "schedule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ReschedulePostRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rescheduled post",
+                        "schema": {
+                            "$ref": "#/definitions/models.Post"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Scheduled post not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "The post is already being published",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to reschedule post",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stop a scheduled post of the authenticated user from being published. The post is kept as a draft",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Posts"
+                ],
+                "summary": "Cancel a scheduled post",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Post ID",
+                        "name": "pid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The post, now a draft",
+                        "schema": {
+                            "$ref": "#/definitions/models.Post"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Scheduled post not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "The post is already being published",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to cancel scheduled post",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/users-update-password/{uid}": {
+            "put": {
+                "description": "Change the password of an existing user by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Change a user's password",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New password",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateUserPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Password updated successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update password",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/users/{uid}": {
+            "put": {
+                "description": "Update the details of an existing user by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Update an existing user",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated user details",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated user details",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/restricted/warnings": {
+            "get": {
+                "description": "List the warnings moderators issued to the authenticated user, newest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Moderation"
+                ],
+                "summary": "List my warnings",
+                "responses": {
+                    "200": {
+                        "description": "Warnings",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.WarningResponse"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get warnings",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/search": {
+            "get": {
+                "description": "Full-text search over post messages, comment text and usernames/names, ordered by relevance.\nOnly public posts and the comments on them are searched.\nSnippets are HTML escaped and matching words are wrapped in \u003cmark\u003e.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Search"
+                ],
+                "summary": "Search posts, comments and users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search text",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated list of post, comment, user (default: all)",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Results per page (max 50, default 20)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Search results",
+                        "schema": {
+                            "$ref": "#/definitions/models.SearchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to search",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stream": {
+            "get": {
+                "description": "Server-Sent Events stream of new posts, new comments on the subscribed posts and the notifications of the authenticated user.\nEventSource cannot send headers, so the JWT can also be passed in the token query parameter.\nPosts and comments by users the viewer blocked or muted are not sent, nor posts the viewer cannot read.\nSubscribing to the comments of a post the viewer cannot read fails with 404",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Stream"
+                ],
+                "summary": "Stream feed updates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "JWT, when the Authorization header cannot be used",
+                        "name": "token",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Post IDs to receive new comments for",
+                        "name": "post",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Post not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tags/{tag}": {
+            "get": {
+                "description": "Get the posts whose message contains the hashtag, newest first. Only the posts the viewer can read are listed.\nWith a valid token, posts by users the viewer blocked or muted are left out",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tags"
+                ],
+                "summary": "List posts by hashtag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hashtag, with or without the leading #",
+                        "name": "tag",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Posts per page (max 50, default 20)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Posts using the hashtag",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.GetPublicPostsRequest"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get posts",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/trending/tags": {
+            "get": {
+                "description": "Get the hashtags used most often in public posts and their comments during the sliding window ending now",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tags"
+                ],
+                "summary": "List trending hashtags",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Window length as a duration, e.g. 1h or 24h (default 24h, max 168h)",
+                        "name": "window",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of tags (max 50, default 10)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Trending hashtags",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.TrendingTagResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get trending tags",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users": {
+            "post": {
+                "description": "Register a new user with the provided details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Create a new user",
+                "parameters": [
+                    {
+                        "description": "User registration details",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Newly created user details",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    },
+                    "409": {
+                        "description": "Username or email already exists",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create user",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{username}": {
+            "get": {
+                "description": "Get the public profile of a user with post and follower counts. The email and admin flag are never returned.\nBanned and deleted accounts are not found",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get a user's public profile",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Public profile",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserProfileResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to get profile",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{username}/avatar": {
+            "get": {
+                "description": "Download the 256x256 JPEG avatar of a user. The v query parameter of avatar_url changes with every upload, so that URL can be cached forever",
+                "produces": [
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Download a user's avatar",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Avatar version",
+                        "name": "v",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Avatar",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Avatar not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/cookie-page": {
+            "get": {
+                "description": "This page is used for debugging cookies",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "debug"
+                ],
+                "summary": "Cookie debug page",
+                "responses": {
+                    "200": {
+                        "description": "Welcome to the cookie page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jwt-page": {
+            "get": {
+                "description": "This page is used for debugging JWT tokens",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "debug"
+                ],
+                "summary": "JWT debug page",
+                "responses": {
+                    "200": {
+                        "description": "Welcome to the JWT page",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "jwks.Key": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "description": "EC and OKP",
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "description": "RSA",
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                },
+                "y": {
+                    "type": "string"
+                }
+            }
+        },
+        "jwks.Set": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jwks.Key"
+                    }
+                }
+            }
+        },
+        "models.APIKeyResponse": {
+            "description": "API key, the key itself is only returned when it is created",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "key_id": {
+                    "type": "integer"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.AssignReportRequest": {
+            "description": "Request model for assigning a report, an empty value unassigns it",
+            "type": "object",
+            "properties": {
+                "assigned_to": {
+                    "type": "string",
+                    "maxLength": 64
+                }
+            }
+        },
+        "models.AttachmentResponse": {
+            "description": "Attachment metadata, URL and ThumbnailURL are signed and stop working after ExpiresAt",
+            "type": "object",
+            "properties": {
+                "attachment_id": {
+                    "type": "integer"
+                },
+                "content_type": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "thumbnail_url": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.AuditLogResponse": {
+            "description": "Audit log entry, changes is omitted when the action did not change any field",
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "actor_id": {
+                    "type": "integer"
+                },
+                "actor_name": {
+                    "type": "string"
+                },
+                "actor_type": {
+                    "type": "string"
+                },
+                "audit_log_id": {
+                    "type": "integer"
+                },
+                "changes": {
+                    "type": "object"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "target_id": {
+                    "type": "string"
+                },
+                "target_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Comment": {
+            "description": "Represents a comment made by users",
+            "type": "object",
+            "properties": {
+                "authorID": {
+                    "type": "integer"
+                },
+                "commentID": {
+                    "type": "integer"
+                },
+                "commentMSG": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "entities": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TextEntity"
+                    }
+                },
+                "hiddenAt": {
+                    "type": "string"
+                },
+                "postID": {
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.CreateAPIKeyRequest": {
+            "description": "Request model for creating an API key. Without expires_at the key never expires",
+            "type": "object",
+            "required": [
+                "name",
+                "scopes"
+            ],
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 64
+                },
+                "scopes": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.CreateReportRequest": {
+            "description": "Request model for reporting a post, comment or user",
+            "type": "object",
+            "required": [
+                "reason",
+                "target_id",
+                "target_type"
+            ],
+            "properties": {
+                "details": {
+                    "type": "string",
+                    "maxLength": 1000
+                },
+                "reason": {
+                    "type": "string",
+                    "enum": [
+                        "spam",
+                        "harassment",
+                        "hate",
+                        "violence",
+                        "nudity",
+                        "misinformation",
+                        "other"
+                    ]
+                },
+                "target_id": {
+                    "type": "integer"
+                },
+                "target_type": {
+                    "type": "string",
+                    "enum": [
+                        "post",
+                        "comment",
+                        "user"
+                    ]
+                }
+            }
+        },
+        "models.CreateUserRequest": {
+            "description": "Request model for creating a user",
+            "type": "object",
+            "required": [
+                "email",
+                "firstname",
+                "password",
+                "surname",
+                "username"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "firstname": {
+                    "type": "string"
+                },
+                "password": {
+                    "description": "Length and the other rules are the password policy, checked by the handler",
+                    "type": "string"
+                },
+                "surname": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string",
+                    "maxLength": 32,
+                    "minLength": 3
+                }
+            }
+        },
+        "models.CreateWebhookRequest": {
+            "description": "Request model for registering a webhook. Events are user.created, post.created and comment.created",
+            "type": "object",
+            "required": [
+                "events",
+                "url"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "events": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "url": {
+                    "type": "string",
+                    "maxLength": 2048
+                }
+            }
+        },
+        "models.DeactivateAccountRequest": {
+            "description": "Request model for deactivating the authenticated user's account, logging in again reactivates it",
+            "type": "object",
+            "properties": {
+                "password": {
+                    "description": "Password is left out by accounts created by an external provider, they log in with it again instead",
+                    "type": "string"
+                }
+            }
+        },
+        "models.DeleteAccountRequest": {
+            "description": "Request model for deleting the authenticated user's account",
+            "type": "object",
+            "properties": {
+                "password": {
+                    "description": "Password is left out by accounts created by an external provider, they log in with it again instead",
+                    "type": "string"
+                }
+            }
+        },
+        "models.ExportJobResponse": {
+            "description": "DownloadURL is only set while the archive is ready, it is signed and stops working after ExpiresAt",
+            "type": "object",
+            "properties": {
+                "completed_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "download_url": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "export_id": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.FilterEventResponse": {
+            "description": "Filter event with the author's username",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "excerpt": {
+                    "type": "string"
+                },
+                "filter": {
+                    "type": "string"
+                },
+                "filter_event_id": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "target_id": {
+                    "type": "integer"
+                },
+                "target_type": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string"
+                },
+                "verdict": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.GetMigrationListRequest": {
+            "description": "Response model for retrieving migration information",
+            "type": "object",
+            "properties": {
+                "migration_id": {
+                    "type": "string"
+                },
+                "migration_title": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.GetPublicPostsRequest": {
+            "description": "Response model for retrieving public posts",
+            "type": "object",
+            "properties": {
+                "attachments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AttachmentResponse"
+                    }
+                },
+                "entities": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TextEntity"
+                    }
+                },
+                "firstname": {
+                    "type": "string"
+                },
+                "my_reactions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "post_created_at": {
+                    "type": "string"
+                },
+                "post_id": {
+                    "type": "integer"
+                },
+                "post_message": {
+                    "type": "string"
+                },
+                "post_updated_at": {
+                    "type": "string"
+                },
+                "publish_at": {
+                    "description": "PublishAt is only set on scheduled posts",
+                    "type": "string"
+                },
+                "reactions": {
+                    "description": "Reactions, MyReactions, Entities and Attachments are filled in after the query, MyReactions is empty for anonymous viewers",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "surname": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                },
+                "visibility": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.GraphQLRequest": {
+            "description": "Request model of the GraphQL endpoint, variables are optional",
+            "type": "object",
+            "required": [
+                "query"
+            ],
+            "properties": {
+                "operationName": {
+                    "type": "string"
+                },
+                "query": {
+                    "type": "string",
+                    "maxLength": 20000
+                },
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": true
+                }
+            }
+        },
+        "models.JobResponse": {
+            "description": "Background job with its payload, attempts and last error",
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "completed_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "locked_by": {
+                    "type": "string"
+                },
+                "locked_until": {
+                    "type": "string"
+                },
+                "max_attempts": {
+                    "type": "integer"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "run_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.LinkOIDCProviderRequest": {
+            "description": "Request model for linking an OpenID Connect provider to the authenticated user's account",
+            "type": "object",
+            "properties": {
+                "password": {
+                    "description": "Password is left out by accounts created by an external provider, they log in with it again instead",
+                    "type": "string"
+                }
+            }
+        },
+        "models.LoginUserRequest": {
+            "description": "Request model for user login",
+            "type": "object",
+            "required": [
+                "identifier",
+                "password"
+            ],
+            "properties": {
+                "identifier": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ModerationActionRequest": {
+            "description": "hide, unhide and delete apply to reported posts and comments, warn and suspend to the author or the reported user. dismiss closes the report without any action. SuspendHours is required for suspend",
+            "type": "object",
+            "required": [
+                "action",
+                "reason"
+            ],
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "hide",
+                        "unhide",
+                        "delete",
+                        "warn",
+                        "suspend",
+                        "dismiss"
+                    ]
+                },
+                "reason": {
+                    "type": "string",
+                    "maxLength": 1000
+                },
+                "suspend_hours": {
+                    "type": "integer",
+                    "maximum": 8760,
+                    "minimum": 1
+                }
+            }
+        },
+        "models.NotificationResponse": {
+            "description": "Response model for a notification",
+            "type": "object",
+            "properties": {
+                "actor_id": {
+                    "type": "integer"
+                },
+                "actor_username": {
+                    "type": "string"
+                },
+                "comment_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "notification_id": {
+                    "type": "integer"
+                },
+                "post_id": {
+                    "type": "integer"
+                },
+                "read_at": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.OIDCRedirectResponse": {
+            "description": "The browser comes back to the callback of the provider, which finishes the link and returns a JWT",
+            "type": "object",
+            "properties": {
+                "redirect_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Post": {
+            "description": "Represents a post created by a user",
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "entities": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TextEntity"
+                    }
+                },
+                "hiddenAt": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "postID": {
+                    "type": "integer"
+                },
+                "publishAt": {
+                    "description": "PublishAt is set while the post is scheduled, only the author can see it until it is published",
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "userID": {
+                    "type": "integer"
+                },
+                "visibility": {
+                    "description": "Visibility is public, followers, private (only the author) or draft (unpublished)",
+                    "type": "string"
+                }
+            }
+        },
+        "models.ReactionSummaryResponse": {
+            "description": "Response model for reaction counts and the reactions of the current user",
+            "type": "object",
+            "properties": {
+                "my_reactions": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "reactions": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "models.ReportResponse": {
+            "description": "Report with the reporter's username and a preview of the reported content",
+            "type": "object",
+            "properties": {
+                "assigned_to": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "report_id": {
+                    "type": "integer"
+                },
+                "reporter_id": {
+                    "type": "integer"
+                },
+                "reporter_username": {
+                    "type": "string"
+                },
+                "resolved_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "target_id": {
+                    "type": "integer"
+                },
+                "target_preview": {
+                    "type": "string"
+                },
+                "target_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ReschedulePostRequest": {
+            "description": "Request model for rescheduling a post",
+            "type": "object",
+            "required": [
+                "publish_at"
+            ],
+            "properties": {
+                "publish_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RunMigrationRequest": {
+            "description": "Request model for running a migration",
+            "type": "object",
+            "properties": {
+                "migration_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SearchResponse": {
+            "description": "Response model for search results ordered by relevance",
+            "type": "object",
+            "properties": {
+                "page": {
+                    "type": "integer"
                 },
-                "createdAt": {
-                    "type": "string"
+                "page_size": {
+                    "type": "integer"
                 },
-                "updatedAt": {
+                "query": {
                     "type": "string"
                 },
-                "users": {
-                    "description": "Many-to-Many relationship (belongs to) | Many users can have many comments which lead to CommentUser table",
+                "results": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/models.User"
+                        "$ref": "#/definitions/models.SearchResult"
                     }
+                },
+                "total": {
+                    "type": "integer"
                 }
             }
         },
-        "models.CommentUser": {
-            "description": "Represents the many-to-many relationship between comments and users",
+        "models.SearchResult": {
+            "description": "A post, comment or user matching the search query. Snippet is HTML escaped with matches wrapped in \u003cmark\u003e",
             "type": "object",
             "properties": {
-                "commentID": {
-                    "description": "Composite primary key",
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
                     "type": "integer"
                 },
-                "userID": {
-                    "description": "Composite primary key",
+                "post_id": {
                     "type": "integer"
+                },
+                "score": {
+                    "type": "number"
+                },
+                "snippet": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
                 }
             }
         },
-        "models.CreateUserRequest": {
-            "description": "Request model for creating a user",
+        "models.TextEntity": {
+            "description": "Hashtag or @mention with its position in the text. Start and End are character (code point) offsets, End is exclusive and both include the # or @ sign",
             "type": "object",
-            "required": [
-                "email",
-                "firstname",
-                "password",
-                "surname",
-                "username"
-            ],
             "properties": {
-                "email": {
-                    "type": "string"
+                "end": {
+                    "type": "integer"
                 },
-                "firstname": {
+                "start": {
+                    "type": "integer"
+                },
+                "type": {
                     "type": "string"
                 },
-                "password": {
-                    "type": "string",
-                    "minLength": 8
+                "user_id": {
+                    "type": "integer"
                 },
-                "surname": {
+                "value": {
                     "type": "string"
-                },
-                "username": {
-                    "type": "string",
-                    "maxLength": 32,
-                    "minLength": 3
                 }
             }
         },
-        "models.GetMigrationListRequest": {
-            "description": "Response model for retrieving migration information",
+        "models.TrendingTagResponse": {
+            "description": "Response model for trending hashtags",
             "type": "object",
             "properties": {
-                "migration_id": {
+                "tag": {
                     "type": "string"
                 },
-                "migration_title": {
-                    "type": "string"
+                "uses": {
+                    "type": "integer"
                 }
             }
         },
-        "models.GetPublicPostsRequest": {
-            "description": "Response model for retrieving public posts",
+        "models.UnreadCountResponse": {
+            "description": "Response model for the unread notification count",
             "type": "object",
             "properties": {
-                "firstname": {
-                    "type": "string"
-                },
-                "post_created_at": {
-                    "type": "string"
-                },
-                "post_id": {
+                "unread": {
                     "type": "integer"
+                }
+            }
+        },
+        "models.UpdateAccountStatusRequest": {
+            "description": "Request model for changing an account status. SuspendHours is required for suspended",
+            "type": "object",
+            "required": [
+                "reason",
+                "status"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "maxLength": 1000
                 },
-                "post_message": {
-                    "type": "string"
-                },
-                "post_updated_at": {
-                    "type": "string"
-                },
-                "surname": {
-                    "type": "string"
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "active",
+                        "suspended",
+                        "banned",
+                        "deactivated"
+                    ]
                 },
-                "username": {
-                    "type": "string"
+                "suspend_hours": {
+                    "type": "integer",
+                    "maximum": 8760,
+                    "minimum": 1
                 }
             }
         },
-        "models.LoginUserRequest": {
-            "description": "Request model for user login",
+        "models.UpdateCommentRequest": {
+            "description": "Request model for editing a comment",
             "type": "object",
             "required": [
-                "identifier",
-                "password"
+                "comment_msg"
             ],
             "properties": {
-                "identifier": {
-                    "type": "string"
-                },
-                "password": {
+                "comment_msg": {
                     "type": "string"
                 }
             }
         },
-        "models.Post": {
-            "description": "Represents a post created by a user",
+        "models.UpdatePostRequest": {
+            "description": "Request model for editing a post",
             "type": "object",
+            "required": [
+                "message"
+            ],
             "properties": {
-                "createdAt": {
-                    "type": "string"
-                },
                 "message": {
                     "type": "string"
                 },
-                "postID": {
-                    "type": "integer"
-                },
-                "updatedAt": {
-                    "type": "string"
-                },
-                "user": {
-                    "description": "One-to-One relationship (belongs to) | One post can have one user",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/models.User"
-                        }
+                "visibility": {
+                    "description": "Visibility is kept when empty. A published post cannot go back to draft",
+                    "type": "string",
+                    "enum": [
+                        "public",
+                        "followers",
+                        "private",
+                        "draft"
                     ]
-                },
-                "userID": {
-                    "type": "integer"
                 }
             }
         },
-        "models.RunMigrationRequest": {
-            "description": "Request model for running a migration",
+        "models.UpdateProfileRequest": {
+            "description": "Request model for updating the authenticated user's profile, every field is replaced",
             "type": "object",
             "properties": {
-                "migration_id": {
-                    "type": "string"
+                "bio": {
+                    "type": "string",
+                    "maxLength": 280
+                },
+                "display_name": {
+                    "type": "string",
+                    "maxLength": 64
+                },
+                "location": {
+                    "type": "string",
+                    "maxLength": 64
+                },
+                "website": {
+                    "type": "string",
+                    "maxLength": 255
                 }
             }
         },
@@ -901,8 +5870,8 @@ const docTemplate = `{
             ],
             "properties": {
                 "password": {
-                    "type": "string",
-                    "minLength": 8
+                    "description": "Length and the other rules are the password policy, checked by the handler",
+                    "type": "string"
                 }
             }
         },
@@ -928,33 +5897,134 @@ const docTemplate = `{
                 }
             }
         },
+        "models.UpdateWebhookRequest": {
+            "description": "Request model for updating a webhook",
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "description": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "events": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "url": {
+                    "type": "string",
+                    "maxLength": 2048
+                }
+            }
+        },
         "models.User": {
             "description": "Represents a user with associated posts and comments",
             "type": "object",
+            "required": [
+                "email",
+                "firstname",
+                "surname",
+                "username"
+            ],
             "properties": {
+                "bio": {
+                    "type": "string"
+                },
                 "comments": {
-                    "description": "Many-to-Many relationship (has many) | One user can have many comments",
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/models.Comment"
                     }
                 },
+                "created_at": {
+                    "description": "CreatedAt is unknown for accounts registered before it was recorded",
+                    "type": "string"
+                },
+                "display_name": {
+                    "type": "string"
+                },
                 "email": {
                     "type": "string"
                 },
+                "email_verified_at": {
+                    "description": "EmailVerifiedAt is set when an identity provider vouched for the email of an account it created.\nAccounts registered with a password have none, so an external login is never linked to them by email.",
+                    "type": "string"
+                },
                 "firstname": {
                     "type": "string"
                 },
                 "is_admin": {
                     "type": "string"
                 },
+                "location": {
+                    "type": "string"
+                },
                 "posts": {
-                    "description": "One-to-Many relationship (has many) | One user can have many posts",
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/models.Post"
                     }
                 },
+                "status": {
+                    "description": "Status is active, suspended, banned, deactivated or deleted (an anonymized account)",
+                    "type": "string"
+                },
+                "surname": {
+                    "type": "string"
+                },
+                "suspended_until": {
+                    "description": "SuspendedUntil is set by moderators, the user cannot log in before that time",
+                    "type": "string"
+                },
+                "uid": {
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string",
+                    "maxLength": 32,
+                    "minLength": 3
+                },
+                "website": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UserProfileResponse": {
+            "description": "Public profile of a user, it never contains the email or the admin flag",
+            "type": "object",
+            "properties": {
+                "avatar_url": {
+                    "type": "string"
+                },
+                "bio": {
+                    "type": "string"
+                },
+                "display_name": {
+                    "type": "string"
+                },
+                "firstname": {
+                    "type": "string"
+                },
+                "follower_count": {
+                    "type": "integer"
+                },
+                "following": {
+                    "description": "Following is true when the authenticated viewer follows this user",
+                    "type": "boolean"
+                },
+                "following_count": {
+                    "type": "integer"
+                },
+                "location": {
+                    "type": "string"
+                },
+                "post_count": {
+                    "type": "integer"
+                },
                 "surname": {
                     "type": "string"
                 },
@@ -963,6 +6033,111 @@ const docTemplate = `{
                 },
                 "username": {
                     "type": "string"
+                },
+                "website": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UserRelationResponse": {
+            "description": "User with the time the relation was created",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "uid": {
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.WarningResponse": {
+            "description": "Warning issued by a moderator about a post, comment or the account itself",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "target_id": {
+                    "type": "integer"
+                },
+                "target_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.WebhookDeliveryResponse": {
+            "description": "Delivery attempt with the response of the receiver, the response body is truncated",
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivery_id": {
+                    "type": "integer"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "string"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "response_body": {
+                    "type": "string"
+                },
+                "status_code": {
+                    "type": "integer"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.WebhookResponse": {
+            "description": "Webhook endpoint, secret is only returned when the webhook is created",
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "webhook_id": {
+                    "type": "integer"
                 }
             }
         }
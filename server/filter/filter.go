@@ -0,0 +1,114 @@
+package filter
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Content kinds
+const (
+	KindPost    = "post"
+	KindComment = "comment"
+)
+
+// Verdict is the outcome of a filter, ordered by severity
+type Verdict int
+
+const (
+	Allow Verdict = iota
+	Hold
+	Reject
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Hold:
+		return "hold"
+	case Reject:
+		return "reject"
+	}
+	return "allow"
+}
+
+// Content is the text about to be stored. ID is 0 while the post or comment is being created.
+type Content struct {
+	Kind   string
+	ID     uint
+	UserID uint
+	Text   string
+}
+
+// Result is the verdict of the pipeline with the filter that produced it
+type Result struct {
+	Verdict Verdict
+	Filter  string
+	Reason  string
+}
+
+// Filter inspects content before it is stored. Reason is shown to the author on reject
+// and to the moderators on hold, so it should not repeat the offending text.
+type Filter interface {
+	Name() string
+	Check(content Content) (Verdict, string, error)
+}
+
+// Pipeline runs filters in order and keeps the most severe verdict, stopping at the first reject
+type Pipeline []Filter
+
+// Run applies the pipeline. A filter that fails is logged and skipped, so a database hiccup
+// in a heuristic does not block posting.
+func (p Pipeline) Run(content Content) Result {
+	result := Result{Verdict: Allow}
+	for _, filter := range p {
+		verdict, reason, err := filter.Check(content)
+		if err != nil {
+			log.Printf("Content filter %s failed: %v", filter.Name(), err)
+			continue
+		}
+		if verdict > result.Verdict {
+			result = Result{Verdict: verdict, Filter: filter.Name(), Reason: reason}
+		}
+		if verdict == Reject {
+			break
+		}
+	}
+	return result
+}
+
+// Default is the pipeline used by the handlers, set by Init
+var Default Pipeline
+
+// Init builds the default pipeline from the environment:
+//
+//	FILTER_REJECT_WORDS  comma separated words that reject the content
+//	FILTER_HOLD_WORDS    comma separated words that hold the content for review
+//	FILTER_LINKS         allow (default), block, or allowlist
+//	FILTER_LINK_DOMAINS  comma separated domains allowed when FILTER_LINKS=allowlist
+//
+// The spam heuristics are always on.
+func Init(db *gorm.DB) {
+	Default = Pipeline{
+		NewBannedWords("banned_words", envList("FILTER_REJECT_WORDS"), Reject),
+		NewBannedWords("review_words", envList("FILTER_HOLD_WORDS"), Hold),
+		NewLinkFilter(os.Getenv("FILTER_LINKS"), envList("FILTER_LINK_DOMAINS")),
+		NewSpamFilter(db),
+	}
+}
+
+// Run applies the default pipeline
+func Run(content Content) Result {
+	return Default.Run(content)
+}
+
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
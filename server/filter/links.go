@@ -0,0 +1,73 @@
+package filter
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Link modes
+const (
+	LinksAllow     = "allow"
+	LinksBlock     = "block"
+	LinksAllowlist = "allowlist"
+)
+
+// linkPattern finds URLs with a scheme or starting with www., which is what clients turn into links
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// Links returns the URLs found in the text
+func Links(text string) []string {
+	return linkPattern.FindAllString(text, -1)
+}
+
+// LinkFilter rejects links, either all of them or those outside an allow-list of domains
+type LinkFilter struct {
+	mode    string
+	domains []string
+}
+
+func NewLinkFilter(mode string, domains []string) *LinkFilter {
+	filter := &LinkFilter{mode: mode}
+	for _, domain := range domains {
+		filter.domains = append(filter.domains, strings.TrimPrefix(strings.ToLower(domain), "."))
+	}
+	return filter
+}
+
+func (f *LinkFilter) Name() string {
+	return "links"
+}
+
+func (f *LinkFilter) Check(content Content) (Verdict, string, error) {
+	if f.mode != LinksBlock && f.mode != LinksAllowlist {
+		return Allow, "", nil
+	}
+	for _, link := range Links(content.Text) {
+		if f.mode == LinksBlock {
+			return Reject, "Links are not allowed", nil
+		}
+		if !f.allowed(link) {
+			return Reject, "Links to this site are not allowed", nil
+		}
+	}
+	return Allow, "", nil
+}
+
+// allowed reports whether the link points to an allowed domain or one of its subdomains
+func (f *LinkFilter) allowed(link string) bool {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range f.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SpamFilter holds content that looks automated: the same text posted again,
+// messages that are mostly links, and new accounts posting in bursts
+type SpamFilter struct {
+	db *gorm.DB
+
+	RepeatWindow    time.Duration // identical text by the same author within the window is rejected
+	MaxLinks        int           // more links than this is held
+	NewAccountAge   time.Duration // accounts younger than this are rate checked
+	NewAccountBurst int           // posts and comments allowed to a new account within BurstWindow
+	BurstWindow     time.Duration
+}
+
+func NewSpamFilter(db *gorm.DB) *SpamFilter {
+	return &SpamFilter{
+		db:              db,
+		RepeatWindow:    24 * time.Hour,
+		MaxLinks:        3,
+		NewAccountAge:   24 * time.Hour,
+		NewAccountBurst: 5,
+		BurstWindow:     10 * time.Minute,
+	}
+}
+
+func (f *SpamFilter) Name() string {
+	return "spam"
+}
+
+func (f *SpamFilter) Check(content Content) (Verdict, string, error) {
+	repeated, err := f.repeated(content)
+	if err != nil {
+		return Allow, "", err
+	}
+	if repeated {
+		return Reject, "You already posted this", nil
+	}
+
+	links := len(Links(content.Text))
+	if links > f.MaxLinks || (links > 1 && links*2 > len(strings.Fields(content.Text))) {
+		return Hold, "Mostly links", nil
+	}
+
+	burst, err := f.newAccountBurst(content.UserID)
+	if err != nil {
+		return Allow, "", err
+	}
+	if burst {
+		return Hold, "New account posting in a burst", nil
+	}
+
+	return Allow, "", nil
+}
+
+// repeated reports whether the author posted the same text recently, ignoring the content itself when it is edited
+func (f *SpamFilter) repeated(content Content) (bool, error) {
+	since := time.Now().Add(-f.RepeatWindow)
+	text := strings.TrimSpace(content.Text)
+
+	var count int64
+	query := f.db.Table("posts").Where("user_id = ? AND message = ? AND created_at >= ?", content.UserID, text, since)
+	if content.Kind == KindPost && content.ID != 0 {
+		query = query.Where("post_id <> ?", content.ID)
+	}
+	if err := query.Count(&count).Error; err != nil || count > 0 {
+		return count > 0, err
+	}
+
+	query = f.db.Table("comments").Where("author_id = ? AND comment_msg = ? AND created_at >= ?", content.UserID, text, since)
+	if content.Kind == KindComment && content.ID != 0 {
+		query = query.Where("comment_id <> ?", content.ID)
+	}
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+// newAccountBurst reports whether a new account already used up its allowance for the burst window
+func (f *SpamFilter) newAccountBurst(userID uint) (bool, error) {
+	// Accounts created before users.created_at existed have no creation time and are not new
+	var createdAt []*time.Time
+	if err := f.db.Table("users").Where("user_id = ?", userID).Pluck("created_at", &createdAt).Error; err != nil {
+		return false, err
+	}
+	if len(createdAt) == 0 || createdAt[0] == nil || time.Since(*createdAt[0]) > f.NewAccountAge {
+		return false, nil
+	}
+
+	since := time.Now().Add(-f.BurstWindow)
+	var posts, comments int64
+	if err := f.db.Table("posts").Where("user_id = ? AND created_at >= ?", userID, since).Count(&posts).Error; err != nil {
+		return false, err
+	}
+	if err := f.db.Table("comments").Where("author_id = ? AND created_at >= ?", userID, since).Count(&comments).Error; err != nil {
+		return false, err
+	}
+	return posts+comments >= int64(f.NewAccountBurst), nil
+}
@@ -0,0 +1,78 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Common look-alike characters mapped back to the letter they stand for
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// Normalize lowercases the text, strips accents and maps look-alike characters,
+// then splits it into words. Runs of single characters are joined as well,
+// so "b a d" and "b.a.d" also yield "bad".
+func Normalize(text string) []string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(strings.ToLower(text)) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+
+	var words, run []string
+	flush := func() {
+		if len(run) > 1 {
+			words = append(words, strings.Join(run, ""))
+		}
+		run = run[:0]
+	}
+	for _, field := range strings.Fields(b.String()) {
+		for _, word := range strings.FieldsFunc(leetReplacer.Replace(field), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			words = append(words, word)
+			if len([]rune(word)) == 1 {
+				run = append(run, word)
+			} else {
+				flush()
+			}
+		}
+	}
+	flush()
+	return words
+}
+
+// BannedWords matches whole words of the normalized text against a word list
+type BannedWords struct {
+	name    string
+	words   map[string]bool
+	verdict Verdict
+}
+
+func NewBannedWords(name string, words []string, verdict Verdict) *BannedWords {
+	filter := &BannedWords{name: name, words: make(map[string]bool), verdict: verdict}
+	for _, word := range words {
+		for _, normalized := range Normalize(word) {
+			filter.words[normalized] = true
+		}
+	}
+	return filter
+}
+
+func (f *BannedWords) Name() string {
+	return f.name
+}
+
+func (f *BannedWords) Check(content Content) (Verdict, string, error) {
+	if len(f.words) == 0 {
+		return Allow, "", nil
+	}
+	for _, word := range Normalize(content.Text) {
+		if f.words[word] {
+			return f.verdict, "Contains a word that is not allowed", nil
+		}
+	}
+	return Allow, "", nil
+}
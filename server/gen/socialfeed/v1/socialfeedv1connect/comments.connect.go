@@ -0,0 +1,181 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: socialfeed/v1/comments.proto
+
+package socialfeedv1connect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	http "net/http"
+	v1 "server/gen/socialfeed/v1"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// CommentServiceName is the fully-qualified name of the CommentService service.
+	CommentServiceName = "socialfeed.v1.CommentService"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// CommentServiceListCommentsProcedure is the fully-qualified name of the CommentService's
+	// ListComments RPC.
+	CommentServiceListCommentsProcedure = "/socialfeed.v1.CommentService/ListComments"
+	// CommentServiceCreateCommentProcedure is the fully-qualified name of the CommentService's
+	// CreateComment RPC.
+	CommentServiceCreateCommentProcedure = "/socialfeed.v1.CommentService/CreateComment"
+	// CommentServiceUpdateCommentProcedure is the fully-qualified name of the CommentService's
+	// UpdateComment RPC.
+	CommentServiceUpdateCommentProcedure = "/socialfeed.v1.CommentService/UpdateComment"
+)
+
+// These variables are the protoreflect.Descriptor objects for the RPCs defined in this package.
+var (
+	commentServiceServiceDescriptor             = v1.File_socialfeed_v1_comments_proto.Services().ByName("CommentService")
+	commentServiceListCommentsMethodDescriptor  = commentServiceServiceDescriptor.Methods().ByName("ListComments")
+	commentServiceCreateCommentMethodDescriptor = commentServiceServiceDescriptor.Methods().ByName("CreateComment")
+	commentServiceUpdateCommentMethodDescriptor = commentServiceServiceDescriptor.Methods().ByName("UpdateComment")
+)
+
+// CommentServiceClient is a client for the socialfeed.v1.CommentService service.
+type CommentServiceClient interface {
+	// ListComments returns the comments of a post the caller may read, without the comments by users they blocked or muted.
+	ListComments(context.Context, *connect.Request[v1.ListCommentsRequest]) (*connect.Response[v1.ListCommentsResponse], error)
+	// CreateComment comments on a published post. The content filter may reject the comment or hold it for review.
+	CreateComment(context.Context, *connect.Request[v1.CreateCommentRequest]) (*connect.Response[v1.Comment], error)
+	// UpdateComment edits a comment of the authenticated user. The new message goes through the content filter again.
+	UpdateComment(context.Context, *connect.Request[v1.UpdateCommentRequest]) (*connect.Response[v1.Comment], error)
+}
+
+// NewCommentServiceClient constructs a client for the socialfeed.v1.CommentService service. By
+// default, it uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses,
+// and sends uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the
+// connect.WithGRPC() or connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewCommentServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) CommentServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &commentServiceClient{
+		listComments: connect.NewClient[v1.ListCommentsRequest, v1.ListCommentsResponse](
+			httpClient,
+			baseURL+CommentServiceListCommentsProcedure,
+			connect.WithSchema(commentServiceListCommentsMethodDescriptor),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+			connect.WithClientOptions(opts...),
+		),
+		createComment: connect.NewClient[v1.CreateCommentRequest, v1.Comment](
+			httpClient,
+			baseURL+CommentServiceCreateCommentProcedure,
+			connect.WithSchema(commentServiceCreateCommentMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+		updateComment: connect.NewClient[v1.UpdateCommentRequest, v1.Comment](
+			httpClient,
+			baseURL+CommentServiceUpdateCommentProcedure,
+			connect.WithSchema(commentServiceUpdateCommentMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// commentServiceClient implements CommentServiceClient.
+type commentServiceClient struct {
+	listComments  *connect.Client[v1.ListCommentsRequest, v1.ListCommentsResponse]
+	createComment *connect.Client[v1.CreateCommentRequest, v1.Comment]
+	updateComment *connect.Client[v1.UpdateCommentRequest, v1.Comment]
+}
+
+// ListComments calls socialfeed.v1.CommentService.ListComments.
+func (c *commentServiceClient) ListComments(ctx context.Context, req *connect.Request[v1.ListCommentsRequest]) (*connect.Response[v1.ListCommentsResponse], error) {
+	return c.listComments.CallUnary(ctx, req)
+}
+
+// CreateComment calls socialfeed.v1.CommentService.CreateComment.
+func (c *commentServiceClient) CreateComment(ctx context.Context, req *connect.Request[v1.CreateCommentRequest]) (*connect.Response[v1.Comment], error) {
+	return c.createComment.CallUnary(ctx, req)
+}
+
+// UpdateComment calls socialfeed.v1.CommentService.UpdateComment.
+func (c *commentServiceClient) UpdateComment(ctx context.Context, req *connect.Request[v1.UpdateCommentRequest]) (*connect.Response[v1.Comment], error) {
+	return c.updateComment.CallUnary(ctx, req)
+}
+
+// CommentServiceHandler is an implementation of the socialfeed.v1.CommentService service.
+type CommentServiceHandler interface {
+	// ListComments returns the comments of a post the caller may read, without the comments by users they blocked or muted.
+	ListComments(context.Context, *connect.Request[v1.ListCommentsRequest]) (*connect.Response[v1.ListCommentsResponse], error)
+	// CreateComment comments on a published post. The content filter may reject the comment or hold it for review.
+	CreateComment(context.Context, *connect.Request[v1.CreateCommentRequest]) (*connect.Response[v1.Comment], error)
+	// UpdateComment edits a comment of the authenticated user. The new message goes through the content filter again.
+	UpdateComment(context.Context, *connect.Request[v1.UpdateCommentRequest]) (*connect.Response[v1.Comment], error)
+}
+
+// NewCommentServiceHandler builds an HTTP handler from the service implementation. It returns the
+// path on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewCommentServiceHandler(svc CommentServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	commentServiceListCommentsHandler := connect.NewUnaryHandler(
+		CommentServiceListCommentsProcedure,
+		svc.ListComments,
+		connect.WithSchema(commentServiceListCommentsMethodDescriptor),
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithHandlerOptions(opts...),
+	)
+	commentServiceCreateCommentHandler := connect.NewUnaryHandler(
+		CommentServiceCreateCommentProcedure,
+		svc.CreateComment,
+		connect.WithSchema(commentServiceCreateCommentMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	commentServiceUpdateCommentHandler := connect.NewUnaryHandler(
+		CommentServiceUpdateCommentProcedure,
+		svc.UpdateComment,
+		connect.WithSchema(commentServiceUpdateCommentMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/socialfeed.v1.CommentService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case CommentServiceListCommentsProcedure:
+			commentServiceListCommentsHandler.ServeHTTP(w, r)
+		case CommentServiceCreateCommentProcedure:
+			commentServiceCreateCommentHandler.ServeHTTP(w, r)
+		case CommentServiceUpdateCommentProcedure:
+			commentServiceUpdateCommentHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedCommentServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedCommentServiceHandler struct{}
+
+func (UnimplementedCommentServiceHandler) ListComments(context.Context, *connect.Request[v1.ListCommentsRequest]) (*connect.Response[v1.ListCommentsResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("socialfeed.v1.CommentService.ListComments is not implemented"))
+}
+
+func (UnimplementedCommentServiceHandler) CreateComment(context.Context, *connect.Request[v1.CreateCommentRequest]) (*connect.Response[v1.Comment], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("socialfeed.v1.CommentService.CreateComment is not implemented"))
+}
+
+func (UnimplementedCommentServiceHandler) UpdateComment(context.Context, *connect.Request[v1.UpdateCommentRequest]) (*connect.Response[v1.Comment], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("socialfeed.v1.CommentService.UpdateComment is not implemented"))
+}
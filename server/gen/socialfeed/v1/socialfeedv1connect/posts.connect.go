@@ -0,0 +1,213 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: socialfeed/v1/posts.proto
+
+package socialfeedv1connect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	http "net/http"
+	v1 "server/gen/socialfeed/v1"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// PostServiceName is the fully-qualified name of the PostService service.
+	PostServiceName = "socialfeed.v1.PostService"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// PostServiceListPostsProcedure is the fully-qualified name of the PostService's ListPosts RPC.
+	PostServiceListPostsProcedure = "/socialfeed.v1.PostService/ListPosts"
+	// PostServiceGetPostProcedure is the fully-qualified name of the PostService's GetPost RPC.
+	PostServiceGetPostProcedure = "/socialfeed.v1.PostService/GetPost"
+	// PostServiceCreatePostProcedure is the fully-qualified name of the PostService's CreatePost RPC.
+	PostServiceCreatePostProcedure = "/socialfeed.v1.PostService/CreatePost"
+	// PostServiceUpdatePostProcedure is the fully-qualified name of the PostService's UpdatePost RPC.
+	PostServiceUpdatePostProcedure = "/socialfeed.v1.PostService/UpdatePost"
+)
+
+// These variables are the protoreflect.Descriptor objects for the RPCs defined in this package.
+var (
+	postServiceServiceDescriptor          = v1.File_socialfeed_v1_posts_proto.Services().ByName("PostService")
+	postServiceListPostsMethodDescriptor  = postServiceServiceDescriptor.Methods().ByName("ListPosts")
+	postServiceGetPostMethodDescriptor    = postServiceServiceDescriptor.Methods().ByName("GetPost")
+	postServiceCreatePostMethodDescriptor = postServiceServiceDescriptor.Methods().ByName("CreatePost")
+	postServiceUpdatePostMethodDescriptor = postServiceServiceDescriptor.Methods().ByName("UpdatePost")
+)
+
+// PostServiceClient is a client for the socialfeed.v1.PostService service.
+type PostServiceClient interface {
+	// ListPosts returns the feed of the caller: public posts, and with credentials their own posts and the
+	// followers-only posts of the users they follow, without the posts by users they blocked or muted.
+	ListPosts(context.Context, *connect.Request[v1.ListPostsRequest]) (*connect.Response[v1.ListPostsResponse], error)
+	// GetPost returns a post the caller may read, their own drafts and scheduled posts included.
+	GetPost(context.Context, *connect.Request[v1.GetPostRequest]) (*connect.Response[v1.Post], error)
+	// CreatePost creates a post. The content filter may reject it, or accept it hidden until a moderator reviews it.
+	CreatePost(context.Context, *connect.Request[v1.CreatePostRequest]) (*connect.Response[v1.Post], error)
+	// UpdatePost edits a post of the authenticated user. The new message goes through the content filter again.
+	UpdatePost(context.Context, *connect.Request[v1.UpdatePostRequest]) (*connect.Response[v1.Post], error)
+}
+
+// NewPostServiceClient constructs a client for the socialfeed.v1.PostService service. By default,
+// it uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and
+// sends uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC()
+// or connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewPostServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) PostServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &postServiceClient{
+		listPosts: connect.NewClient[v1.ListPostsRequest, v1.ListPostsResponse](
+			httpClient,
+			baseURL+PostServiceListPostsProcedure,
+			connect.WithSchema(postServiceListPostsMethodDescriptor),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+			connect.WithClientOptions(opts...),
+		),
+		getPost: connect.NewClient[v1.GetPostRequest, v1.Post](
+			httpClient,
+			baseURL+PostServiceGetPostProcedure,
+			connect.WithSchema(postServiceGetPostMethodDescriptor),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+			connect.WithClientOptions(opts...),
+		),
+		createPost: connect.NewClient[v1.CreatePostRequest, v1.Post](
+			httpClient,
+			baseURL+PostServiceCreatePostProcedure,
+			connect.WithSchema(postServiceCreatePostMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+		updatePost: connect.NewClient[v1.UpdatePostRequest, v1.Post](
+			httpClient,
+			baseURL+PostServiceUpdatePostProcedure,
+			connect.WithSchema(postServiceUpdatePostMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// postServiceClient implements PostServiceClient.
+type postServiceClient struct {
+	listPosts  *connect.Client[v1.ListPostsRequest, v1.ListPostsResponse]
+	getPost    *connect.Client[v1.GetPostRequest, v1.Post]
+	createPost *connect.Client[v1.CreatePostRequest, v1.Post]
+	updatePost *connect.Client[v1.UpdatePostRequest, v1.Post]
+}
+
+// ListPosts calls socialfeed.v1.PostService.ListPosts.
+func (c *postServiceClient) ListPosts(ctx context.Context, req *connect.Request[v1.ListPostsRequest]) (*connect.Response[v1.ListPostsResponse], error) {
+	return c.listPosts.CallUnary(ctx, req)
+}
+
+// GetPost calls socialfeed.v1.PostService.GetPost.
+func (c *postServiceClient) GetPost(ctx context.Context, req *connect.Request[v1.GetPostRequest]) (*connect.Response[v1.Post], error) {
+	return c.getPost.CallUnary(ctx, req)
+}
+
+// CreatePost calls socialfeed.v1.PostService.CreatePost.
+func (c *postServiceClient) CreatePost(ctx context.Context, req *connect.Request[v1.CreatePostRequest]) (*connect.Response[v1.Post], error) {
+	return c.createPost.CallUnary(ctx, req)
+}
+
+// UpdatePost calls socialfeed.v1.PostService.UpdatePost.
+func (c *postServiceClient) UpdatePost(ctx context.Context, req *connect.Request[v1.UpdatePostRequest]) (*connect.Response[v1.Post], error) {
+	return c.updatePost.CallUnary(ctx, req)
+}
+
+// PostServiceHandler is an implementation of the socialfeed.v1.PostService service.
+type PostServiceHandler interface {
+	// ListPosts returns the feed of the caller: public posts, and with credentials their own posts and the
+	// followers-only posts of the users they follow, without the posts by users they blocked or muted.
+	ListPosts(context.Context, *connect.Request[v1.ListPostsRequest]) (*connect.Response[v1.ListPostsResponse], error)
+	// GetPost returns a post the caller may read, their own drafts and scheduled posts included.
+	GetPost(context.Context, *connect.Request[v1.GetPostRequest]) (*connect.Response[v1.Post], error)
+	// CreatePost creates a post. The content filter may reject it, or accept it hidden until a moderator reviews it.
+	CreatePost(context.Context, *connect.Request[v1.CreatePostRequest]) (*connect.Response[v1.Post], error)
+	// UpdatePost edits a post of the authenticated user. The new message goes through the content filter again.
+	UpdatePost(context.Context, *connect.Request[v1.UpdatePostRequest]) (*connect.Response[v1.Post], error)
+}
+
+// NewPostServiceHandler builds an HTTP handler from the service implementation. It returns the path
+// on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewPostServiceHandler(svc PostServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	postServiceListPostsHandler := connect.NewUnaryHandler(
+		PostServiceListPostsProcedure,
+		svc.ListPosts,
+		connect.WithSchema(postServiceListPostsMethodDescriptor),
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithHandlerOptions(opts...),
+	)
+	postServiceGetPostHandler := connect.NewUnaryHandler(
+		PostServiceGetPostProcedure,
+		svc.GetPost,
+		connect.WithSchema(postServiceGetPostMethodDescriptor),
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		connect.WithHandlerOptions(opts...),
+	)
+	postServiceCreatePostHandler := connect.NewUnaryHandler(
+		PostServiceCreatePostProcedure,
+		svc.CreatePost,
+		connect.WithSchema(postServiceCreatePostMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	postServiceUpdatePostHandler := connect.NewUnaryHandler(
+		PostServiceUpdatePostProcedure,
+		svc.UpdatePost,
+		connect.WithSchema(postServiceUpdatePostMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/socialfeed.v1.PostService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case PostServiceListPostsProcedure:
+			postServiceListPostsHandler.ServeHTTP(w, r)
+		case PostServiceGetPostProcedure:
+			postServiceGetPostHandler.ServeHTTP(w, r)
+		case PostServiceCreatePostProcedure:
+			postServiceCreatePostHandler.ServeHTTP(w, r)
+		case PostServiceUpdatePostProcedure:
+			postServiceUpdatePostHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedPostServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedPostServiceHandler struct{}
+
+func (UnimplementedPostServiceHandler) ListPosts(context.Context, *connect.Request[v1.ListPostsRequest]) (*connect.Response[v1.ListPostsResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("socialfeed.v1.PostService.ListPosts is not implemented"))
+}
+
+func (UnimplementedPostServiceHandler) GetPost(context.Context, *connect.Request[v1.GetPostRequest]) (*connect.Response[v1.Post], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("socialfeed.v1.PostService.GetPost is not implemented"))
+}
+
+func (UnimplementedPostServiceHandler) CreatePost(context.Context, *connect.Request[v1.CreatePostRequest]) (*connect.Response[v1.Post], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("socialfeed.v1.PostService.CreatePost is not implemented"))
+}
+
+func (UnimplementedPostServiceHandler) UpdatePost(context.Context, *connect.Request[v1.UpdatePostRequest]) (*connect.Response[v1.Post], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("socialfeed.v1.PostService.UpdatePost is not implemented"))
+}
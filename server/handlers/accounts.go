@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"server/passwords"
+	"server/search"
+	"server/storage"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Account statuses
+const (
+	AccountActive      = "active"
+	AccountSuspended   = "suspended"
+	AccountBanned      = "banned"
+	AccountDeactivated = "deactivated"
+	AccountDeleted     = "deleted"
+)
+
+// unlistedAccountStatuses are left out of profiles, search and the GraphQL users: banned accounts and the anonymized
+// ones of deleted users. Their posts and comments stay where the other rules allow them.
+var unlistedAccountStatuses = []string{AccountBanned, AccountDeleted}
+
+// Account deletion policies, picked with ACCOUNT_DELETION_POLICY
+const (
+	// DeletionCascade removes the user with their posts, comments and everything else that belongs to them
+	DeletionCascade = "cascade"
+	// DeletionAnonymize keeps posts and comments under a placeholder name and removes everything personal
+	DeletionAnonymize = "anonymize"
+)
+
+// Moderation action recorded for each status an admin can set
+var accountStatusActions = map[string]string{
+	AccountActive:      "activate",
+	AccountSuspended:   "suspend",
+	AccountBanned:      "ban",
+	AccountDeactivated: "deactivate",
+}
+
+// accountRestriction returns the status code and message refusing the account, or 0 when it can be used.
+// A suspension ends on its own once SuspendedUntil has passed.
+func accountRestriction(user models.User) (int, string) {
+	switch user.Status {
+	case AccountBanned:
+		return http.StatusForbidden, "Account banned"
+	case AccountDeleted:
+		return http.StatusUnauthorized, "Account deleted"
+	case AccountDeactivated:
+		return http.StatusUnauthorized, "Account deactivated, log in again to reactivate it"
+	}
+	if user.SuspendedUntil != nil && user.SuspendedUntil.After(time.Now()) {
+		return http.StatusForbidden, "Account suspended until " + user.SuspendedUntil.UTC().Format(time.RFC3339)
+	}
+	return 0, ""
+}
+
+// ActiveAccount rejects tokens of accounts that were suspended, banned, deactivated or deleted after the token was issued.
+// It runs after the JWT middleware.
+func ActiveAccount(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, ok := helpers.CurrentUserID(c)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Unauthorized"})
+		}
+
+		status, message, err := checkAccount(userID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to check account"})
+		}
+		if status != 0 {
+			return c.JSON(status, map[string]string{"message": message})
+		}
+
+		return next(c)
+	}
+}
+
+// OptionalActiveAccount is ActiveAccount for the public routes, where a token only tells the handlers who the viewer is:
+// the token of an account that cannot be used is dropped, the request goes on as an anonymous one.
+// It runs after the optional JWT middleware.
+func OptionalActiveAccount(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, ok := helpers.CurrentUserID(c)
+		if !ok {
+			return next(c)
+		}
+
+		status, _, err := checkAccount(userID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to check account"})
+		}
+		if status != 0 {
+			c.Set("user", nil)
+		}
+
+		return next(c)
+	}
+}
+
+// checkAccount returns the status code and message refusing the account of userID, or 0 when it can be used
+func checkAccount(userID uint) (int, string, error) {
+	var user models.User
+	result := config.DB.Select("user_id, status, suspended_until").Limit(1).Find(&user, userID)
+	if result.Error != nil {
+		return 0, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return http.StatusUnauthorized, "Account deleted", nil
+	}
+	status, message := accountRestriction(user)
+	return status, message, nil
+}
+
+// UpdateAccountStatus godoc
+// @Summary Change the status of an account
+// @Description Activate, suspend, ban or deactivate an account. Existing tokens of the account stop working right away
+// @Description unless it is activated. The change is recorded in the moderation audit trail with its reason
+// @Tags Moderation
+// @Accept json
+// @Produce json
+// @Param uid path int true "User ID"
+// @Param status body models.UpdateAccountStatusRequest true "New status"
+// @Success 200 {object} models.User "Updated user"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Failed to update account status"
+// @Router /api/v1/admin/users/{uid}/status [put]
+func UpdateAccountStatus(c echo.Context) error {
+	request := new(models.UpdateAccountStatusRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	userID, err := strconv.Atoi(c.Param("uid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+	if request.Status == AccountSuspended && request.SuspendHours == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "suspend_hours is required to suspend a user"})
+	}
+
+	var user models.User
+	if result := config.DB.First(&user, userID); result.Error != nil || user.Status == AccountDeleted {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+
+	before := user
+	user.Status = request.Status
+	user.SuspendedUntil = nil
+	if request.Status == AccountSuspended {
+		until := time.Now().Add(time.Duration(request.SuspendHours) * time.Hour)
+		user.SuspendedUntil = &until
+	}
+
+	action := models.ModerationAction{
+		Moderator:      moderatorName(c),
+		Action:         accountStatusActions[request.Status],
+		TargetType:     ReportTargetUser,
+		TargetID:       user.UserID,
+		UserID:         &user.UserID,
+		Reason:         request.Reason,
+		SuspendedUntil: user.SuspendedUntil,
+	}
+	entry := auditEntry(c, AuditAccountStatusChange, ReportTargetUser, user.UserID)
+	entry.Details = request.Reason
+	entry.Changes = auditChanges(before, user)
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"status": user.Status, "suspended_until": user.SuspendedUntil}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&action).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, entry)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update account status"})
+	}
+
+	indexUserForSearch(user)
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// DeactivateAccount godoc
+// @Summary Deactivate my account
+// @Description Deactivate the authenticated user's account after confirming the password. Accounts without a password log in
+// @Description with their provider again first. Existing tokens stop working, and logging in again reactivates the account
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param confirmation body models.DeactivateAccountRequest true "Current password"
+// @Success 200 {object} map[string]string "Account deactivated"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Invalid password, or no recent login with the provider"
+// @Failure 500 {object} map[string]string "Failed to deactivate account"
+// @Router /api/v1/restricted/account/deactivate [post]
+func DeactivateAccount(c echo.Context) error {
+	request := new(models.DeactivateAccountRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	user, err := confirmIdentity(c, request.Password)
+	if err != nil {
+		return err
+	}
+
+	if result := config.DB.Model(&user).Updates(map[string]interface{}{"status": AccountDeactivated, "cookie_token": ""}); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to deactivate account"})
+	}
+	audit(auditEntry(c, AuditAccountDeactivate, ReportTargetUser, user.UserID))
+
+	clearLogInCookie(c)
+	return c.JSON(http.StatusOK, map[string]string{"message": "Account deactivated"})
+}
+
+// DeleteAccount godoc
+// @Summary Delete my account
+// @Description Delete the authenticated user's account after confirming the password, accounts without one log in with their
+// @Description provider again first. Depending on ACCOUNT_DELETION_POLICY,
+// @Description posts and comments are deleted with the account (cascade, the default) or kept under a placeholder name (anonymize).
+// @Description Follows, blocks, mutes, notifications, data exports and the avatar are removed either way
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param confirmation body models.DeleteAccountRequest true "Current password"
+// @Success 200 {object} map[string]string "Account deleted"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Invalid password, or no recent login with the provider"
+// @Failure 500 {object} map[string]string "Failed to delete account"
+// @Router /api/v1/restricted/account [delete]
+func DeleteAccount(c echo.Context) error {
+	request := new(models.DeleteAccountRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	user, err := confirmIdentity(c, request.Password)
+	if err != nil {
+		return err
+	}
+
+	if deletionPolicy() == DeletionAnonymize {
+		err = anonymizeAccount(config.DB, user)
+	} else {
+		err = deleteAccount(config.DB, user)
+	}
+	if err != nil {
+		log.Println("Failed to delete account:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete account"})
+	}
+	entry := auditEntry(c, AuditAccountDelete, ReportTargetUser, user.UserID)
+	entry.ActorName = user.Username
+	entry.Details = "Policy: " + deletionPolicy()
+	audit(entry)
+
+	clearLogInCookie(c)
+	return c.JSON(http.StatusOK, map[string]string{"message": "Account deleted"})
+}
+
+// Accounts without a password confirm who they are with a sign-in at their provider no older than this
+const providerReauthWindow = 5 * time.Minute
+
+// confirmIdentity loads the authenticated user and checks their password. Accounts created by an external provider have none,
+// they log in with it again first (GET /api/v1/auth/oidc/{provider}?reauthenticate=true) and are confirmed for a few minutes.
+func confirmIdentity(c echo.Context, password string) (models.User, error) {
+	userID, _ := helpers.CurrentUserID(c)
+
+	var user models.User
+	if result := config.DB.First(&user, userID); result.Error != nil {
+		return user, echo.NewHTTPError(http.StatusUnauthorized, map[string]string{"message": "Unauthorized"})
+	}
+	if user.Password == "" {
+		var recent int64
+		if err := config.DB.Model(&models.UserIdentity{}).Where("user_id = ? AND authenticated_at > ?", user.UserID, time.Now().Add(-providerReauthWindow)).Count(&recent).Error; err != nil {
+			return user, echo.NewHTTPError(http.StatusInternalServerError, map[string]string{"message": "Failed to confirm your identity"})
+		}
+		if recent == 0 {
+			return user, echo.NewHTTPError(http.StatusUnauthorized, map[string]string{"message": "Log in with your provider again to confirm"})
+		}
+		return user, nil
+	}
+	if passwords.Default.TooLong(password) {
+		return user, echo.NewHTTPError(http.StatusUnauthorized, map[string]string{"message": "Invalid password"})
+	}
+	if matches, _ := passwords.Default.Verify(user.Password, password); !matches {
+		return user, echo.NewHTTPError(http.StatusUnauthorized, map[string]string{"message": "Invalid password"})
+	}
+	return user, nil
+}
+
+func deletionPolicy() string {
+	if os.Getenv("ACCOUNT_DELETION_POLICY") == DeletionAnonymize {
+		return DeletionAnonymize
+	}
+	return DeletionCascade
+}
+
+// deleteAccount removes the user row and lets ON DELETE CASCADE remove what belongs to it.
+// The IDs of the user's comments and of the comments on their posts are collected first, to clean up what the cascade does not reach.
+func deleteAccount(db *gorm.DB, user models.User) error {
+	var postIDs, commentIDs []uint
+	var blobKeys []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Post{}).Where("user_id = ?", user.UserID).Pluck("post_id", &postIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("comments").Where("author_id = ? OR post_id IN (?)",
+			user.UserID, tx.Table("posts").Select("post_id").Where("user_id = ?", user.UserID)).
+			Pluck("comment_id", &commentIDs).Error; err != nil {
+			return err
+		}
+
+		var attachments []models.Attachment
+		if err := tx.Where("user_id = ?", user.UserID).Find(&attachments).Error; err != nil {
+			return err
+		}
+		for _, attachment := range attachments {
+			blobKeys = append(blobKeys, attachment.StorageKey, attachment.ThumbnailKey)
+		}
+		exportKeys, err := exportBlobKeys(tx, user.UserID)
+		if err != nil {
+			return err
+		}
+		blobKeys = append(blobKeys, exportKeys...)
+
+		if len(commentIDs) > 0 {
+			if err := tx.Where("comment_id IN ?", commentIDs).Delete(&models.Comment{}).Error; err != nil {
+				return err
+			}
+		}
+		// The cascade would remove the reactions of the user but leave them counted on other users' content
+		if err := removeUserReactions(tx, user.UserID); err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, user.UserID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	search.Remove(search.TypeUser, user.UserID)
+	for _, postID := range postIDs {
+		search.Remove(search.TypePost, postID)
+	}
+	for _, commentID := range commentIDs {
+		search.Remove(search.TypeComment, commentID)
+	}
+	for _, key := range blobKeys {
+		if err := storage.Default.Delete(key); err != nil {
+			log.Println("Failed to delete attachment:", err)
+		}
+	}
+	deleteUnusedAvatar(user.AvatarKey)
+	return nil
+}
+
+// anonymizeAccount keeps the user row so posts and comments stay in place, but replaces everything
+// that identifies the person and removes their relationships, reactions and attachments. The password is cleared, so nobody can log in.
+func anonymizeAccount(db *gorm.DB, user models.User) error {
+	placeholder := fmt.Sprintf("deleted-%d", user.UserID)
+	var blobKeys []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var attachments []models.Attachment
+		if err := tx.Where("user_id = ?", user.UserID).Find(&attachments).Error; err != nil {
+			return err
+		}
+		for _, attachment := range attachments {
+			blobKeys = append(blobKeys, attachment.StorageKey, attachment.ThumbnailKey)
+		}
+		exportKeys, err := exportBlobKeys(tx, user.UserID)
+		if err != nil {
+			return err
+		}
+		blobKeys = append(blobKeys, exportKeys...)
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"username":          placeholder,
+			"firstname":         "Deleted",
+			"surname":           "User",
+			"email":             placeholder + "@deleted.invalid",
+			"email_verified_at": nil,
+			"password":          "",
+			"cookie_token":      "",
+			"display_name":      "",
+			"bio":               "",
+			"location":          "",
+			"website":           "",
+			"avatar_key":        "",
+			"status":            AccountDeleted,
+			"suspended_until":   nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		cleanups := []struct {
+			model interface{}
+			where string
+		}{
+			{&models.Follow{}, "follower_id = ? OR followee_id = ?"},
+			{&models.Block{}, "blocker_id = ? OR blocked_id = ?"},
+			{&models.Mute{}, "muter_id = ? OR muted_id = ?"},
+			{&models.Notification{}, "user_id = ? OR actor_id = ?"},
+		}
+		for _, cleanup := range cleanups {
+			if err := tx.Where(cleanup.where, user.UserID, user.UserID).Delete(cleanup.model).Error; err != nil {
+				return err
+			}
+		}
+		if err := removeUserReactions(tx, user.UserID); err != nil {
+			return err
+		}
+		owned := []interface{}{
+			&models.Attachment{},
+			&models.ExportJob{},
+			&models.APIKey{},
+			&models.UserIdentity{},
+			&models.PasswordHistory{},
+			&models.NotificationPreference{},
+		}
+		for _, model := range owned {
+			if err := tx.Where("user_id = ?", user.UserID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	search.Remove(search.TypeUser, user.UserID)
+	for _, key := range blobKeys {
+		if err := storage.Default.Delete(key); err != nil {
+			log.Println("Failed to delete attachment:", err)
+		}
+	}
+	deleteUnusedAvatar(user.AvatarKey)
+	return nil
+}
+
+// exportBlobKeys returns the archives of the user's personal data exports that are still stored
+func exportBlobKeys(db *gorm.DB, userID uint) ([]string, error) {
+	var keys []string
+	err := db.Model(&models.ExportJob{}).Where("user_id = ? AND storage_key <> ''", userID).Pluck("storage_key", &keys).Error
+	return keys, err
+}
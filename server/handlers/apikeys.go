@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// API key scopes. Reading covers every GET of the restricted routes, writing is limited to posts and comments
+const (
+	APIKeyScopeRead    = "read"
+	APIKeyScopePost    = "post"
+	APIKeyScopeComment = "comment"
+)
+
+// Every key starts with this, so leaked keys are easy to recognize
+const apiKeyPrefix = "ak_"
+
+// Active keys a user may have at once
+const maxAPIKeys = 20
+
+// apiKeyWriteScopes maps the restricted routes an API key may change to the scope it needs.
+// Account, profile, follows, reactions and API key management need a JWT.
+var apiKeyWriteScopes = map[string]string{
+	"/api/v1/restricted/posts":                     APIKeyScopePost,
+	"/api/v1/restricted/posts/:pid":                APIKeyScopePost,
+	"/api/v1/restricted/posts/:pid/attachments":    APIKeyScopePost,
+	"/api/v1/restricted/scheduled-posts/:pid":      APIKeyScopePost,
+	"/api/v1/restricted/comments":                  APIKeyScopeComment,
+	"/api/v1/restricted/comments/:cid":             APIKeyScopeComment,
+	"/api/v1/restricted/comments/:cid/attachments": APIKeyScopeComment,
+}
+
+// apiKeyReadRoutes are the routes a key with the read scope may get.
+// Exports and the API keys themselves need a JWT, a leaked key must not hand out a copy of the whole account.
+var apiKeyReadRoutes = map[string]bool{
+	"/api/v1/posts":                                 true,
+	"/api/v1/posts/:pid":                            true,
+	"/api/v1/comments/:pid":                         true,
+	"/api/v1/tags/:tag":                             true,
+	"/api/v1/users/:username":                       true,
+	"/api/v1/attachments/:aid":                      true,
+	"/api/v1/restricted/main":                       true,
+	"/api/v1/restricted/drafts":                     true,
+	"/api/v1/restricted/scheduled-posts":            true,
+	"/api/v1/restricted/warnings":                   true,
+	"/api/v1/restricted/blocks":                     true,
+	"/api/v1/restricted/mutes":                      true,
+	"/api/v1/restricted/notifications":              true,
+	"/api/v1/restricted/notifications/unread-count": true,
+	"/api/v1/restricted/notification-preferences":   true,
+}
+
+// apiKeyScope returns the scope a request needs, or "" when API keys cannot make it
+func apiKeyScope(c echo.Context) string {
+	switch c.Request().Method {
+	case http.MethodGet, http.MethodHead:
+		if apiKeyReadRoutes[c.Path()] {
+			return APIKeyScopeRead
+		}
+		return ""
+	}
+	return apiKeyWriteScopes[c.Path()]
+}
+
+func apiKeyHasScope(apiKey models.APIKey, scope string) bool {
+	for _, granted := range strings.Split(apiKey.Scopes, ",") {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Create a personal API key for scripts and bots. The key is only returned in this response, send it as
+// @Description "Authorization: ApiKey <key>" or in the X-API-Key header. read allows reading posts, comments, profiles and
+// @Description notifications (not exports or API keys), post and comment allow
+// @Description creating and editing posts and comments
+// @Tags API keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param key body models.CreateAPIKeyRequest true "Name, scopes and expiry"
+// @Success 201 {object} models.APIKeyResponse "API key with the key itself"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 409 {object} map[string]string "Too many API keys"
+// @Failure 500 {object} map[string]string "Failed to create API key"
+// @Router /api/v1/restricted/api-keys [post]
+func CreateAPIKey(c echo.Context) error {
+	request := new(models.CreateAPIKeyRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+	userID, ok := helpers.CurrentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Unauthorized"})
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "expires_at must be in the future"})
+	}
+
+	var active int64
+	if result := config.DB.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Count(&active); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create API key"})
+	}
+	if active >= maxAPIKeys {
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Too many API keys, revoke one first"})
+	}
+
+	random, err := randomHex(24)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create API key"})
+	}
+	key := apiKeyPrefix + random
+
+	scopes := []string{}
+	for _, scope := range []string{APIKeyScopeRead, APIKeyScopePost, APIKeyScopeComment} {
+		for _, requested := range request.Scopes {
+			if requested == scope {
+				scopes = append(scopes, scope)
+				break
+			}
+		}
+	}
+	apiKey := models.APIKey{
+		UserID:    userID,
+		Name:      request.Name,
+		Prefix:    key[:len(apiKeyPrefix)+8],
+		KeyHash:   hashAPIKey(key),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: request.ExpiresAt,
+	}
+	if result := config.DB.Create(&apiKey); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create API key"})
+	}
+	entry := auditEntry(c, AuditAPIKeyCreate, "api_key", apiKey.APIKeyID)
+	entry.Details = apiKey.Name + " (" + apiKey.Scopes + ")"
+	audit(entry)
+
+	response := apiKeyResponse(apiKey)
+	response.Key = key
+	return c.JSON(http.StatusCreated, response)
+}
+
+// GetAPIKeys godoc
+// @Summary List my API keys
+// @Description List the API keys of the authenticated user, revoked and expired keys included. The keys themselves are never returned
+// @Tags API keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.APIKeyResponse "API keys"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Failed to get API keys"
+// @Router /api/v1/restricted/api-keys [get]
+func GetAPIKeys(c echo.Context) error {
+	userID, ok := helpers.CurrentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Unauthorized"})
+	}
+
+	var apiKeys []models.APIKey
+	if result := config.DB.Where("user_id = ?", userID).Order("created_at DESC, api_key_id DESC").Find(&apiKeys); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get API keys"})
+	}
+
+	responses := make([]models.APIKeyResponse, len(apiKeys))
+	for i, apiKey := range apiKeys {
+		responses[i] = apiKeyResponse(apiKey)
+	}
+	return c.JSON(http.StatusOK, responses)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revoke one of my API keys, it stops working right away. Revoking a revoked key is a no-op
+// @Tags API keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param kid path int true "API key ID"
+// @Success 200 {object} models.APIKeyResponse "Revoked API key"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "API key not found"
+// @Failure 500 {object} map[string]string "Failed to revoke API key"
+// @Router /api/v1/restricted/api-keys/{kid} [delete]
+func RevokeAPIKey(c echo.Context) error {
+	userID, ok := helpers.CurrentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Unauthorized"})
+	}
+	keyID, err := strconv.Atoi(c.Param("kid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	var apiKey models.APIKey
+	if result := config.DB.Where("api_key_id = ? AND user_id = ?", keyID, userID).First(&apiKey); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "API key not found"})
+	}
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		if result := config.DB.Model(&apiKey).Update("revoked_at", now); result.Error != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to revoke API key"})
+		}
+		apiKey.RevokedAt = &now
+
+		entry := auditEntry(c, AuditAPIKeyRevoke, "api_key", apiKey.APIKeyID)
+		entry.Details = apiKey.Name
+		audit(entry)
+	}
+
+	return c.JSON(http.StatusOK, apiKeyResponse(apiKey))
+}
+
+func apiKeyResponse(apiKey models.APIKey) models.APIKeyResponse {
+	return models.APIKeyResponse{
+		APIKeyID:   apiKey.APIKeyID,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		Scopes:     strings.Split(apiKey.Scopes, ","),
+		ExpiresAt:  apiKey.ExpiresAt,
+		LastUsedAt: apiKey.LastUsedAt,
+		RevokedAt:  apiKey.RevokedAt,
+		CreatedAt:  apiKey.CreatedAt,
+	}
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"net/url"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"server/storage"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	maxAttachmentSize       = 10 << 20
+	maxAttachmentsPerTarget = 4
+	thumbnailSize           = 320
+	// Signed URLs stay the same for a whole window so browsers and CDNs can cache them
+	attachmentURLWindow = 24 * time.Hour
+)
+
+// UploadPostAttachment godoc
+// @Summary Attach an image to a post
+// @Description Upload a JPEG, PNG or GIF image (max 10 MB, max 4 per post) to a post owned by the authenticated user.
+// @Description The content type is detected from the file content and a thumbnail is generated
+// @Tags Attachments
+// @Accept multipart/form-data
+// @Produce json
+// @Param pid path int true "Post ID"
+// @Param file formData file true "Image file"
+// @Success 201 {object} models.AttachmentResponse "Uploaded attachment"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Not the author of the post"
+// @Failure 404 {object} map[string]string "Post not found"
+// @Failure 413 {object} map[string]string "File is too large"
+// @Failure 415 {object} map[string]string "Unsupported file type"
+// @Failure 500 {object} map[string]string "Failed to store attachment"
+// @Router /api/v1/restricted/posts/{pid}/attachments [post]
+func UploadPostAttachment(c echo.Context) error {
+	postID, err := strconv.Atoi(c.Param("pid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	var post models.Post
+	if result := config.DB.First(&post, postID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Post not found"})
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	if post.UserID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You can only add attachments to your own posts"})
+	}
+
+	return uploadAttachment(c, models.Attachment{UserID: userID, PostID: &post.PostID}, "post_id", post.PostID)
+}
+
+// UploadCommentAttachment godoc
+// @Summary Attach an image to a comment
+// @Description Upload a JPEG, PNG or GIF image (max 10 MB, max 4 per comment) to a comment owned by the authenticated user.
+// @Description The content type is detected from the file content and a thumbnail is generated
+// @Tags Attachments
+// @Accept multipart/form-data
+// @Produce json
+// @Param cid path int true "Comment ID"
+// @Param file formData file true "Image file"
+// @Success 201 {object} models.AttachmentResponse "Uploaded attachment"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Not the author of the comment"
+// @Failure 404 {object} map[string]string "Comment not found"
+// @Failure 413 {object} map[string]string "File is too large"
+// @Failure 415 {object} map[string]string "Unsupported file type"
+// @Failure 500 {object} map[string]string "Failed to store attachment"
+// @Router /api/v1/restricted/comments/{cid}/attachments [post]
+func UploadCommentAttachment(c echo.Context) error {
+	commentID, err := strconv.Atoi(c.Param("cid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	var comment models.Comment
+	if result := config.DB.First(&comment, commentID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Comment not found"})
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	if comment.AuthorID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You can only add attachments to your own comments"})
+	}
+
+	return uploadAttachment(c, models.Attachment{UserID: userID, CommentID: &comment.CommentID}, "comment_id", comment.CommentID)
+}
+
+// uploadAttachment validates the uploaded image, stores it with its thumbnail and records the metadata.
+// Blobs are keyed by their SHA-256, so the same image uploaded twice is stored once.
+func uploadAttachment(c echo.Context, attachment models.Attachment, idColumn string, targetID uint) error {
+	data, contentType, imageConfig, err := readImageUpload(c, maxAttachmentSize)
+	if err != nil {
+		return err
+	}
+
+	var existing int64
+	if result := config.DB.Model(&models.Attachment{}).Where(idColumn+" = ?", targetID).Count(&existing); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to store attachment"})
+	}
+	if existing >= maxAttachmentsPerTarget {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": fmt.Sprintf("At most %d attachments are allowed", maxAttachmentsPerTarget)})
+	}
+
+	thumbnail, err := helpers.Thumbnail(data, thumbnailSize)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid image"})
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	attachment.ContentType = contentType
+	attachment.Size = int64(len(data))
+	attachment.Width = imageConfig.Width
+	attachment.Height = imageConfig.Height
+	attachment.SHA256 = hash
+	attachment.StorageKey = "attachments/" + hash[:2] + "/" + hash
+	attachment.ThumbnailKey = "thumbnails/" + hash[:2] + "/" + hash + ".jpg"
+
+	if err := storage.Default.Put(attachment.StorageKey, bytes.NewReader(data)); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to store attachment"})
+	}
+	if err := storage.Default.Put(attachment.ThumbnailKey, bytes.NewReader(thumbnail)); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to store attachment"})
+	}
+
+	if result := config.DB.Create(&attachment); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to store attachment"})
+	}
+
+	return c.JSON(http.StatusCreated, attachmentResponse(baseURL(c), attachment))
+}
+
+// readImageUpload reads the image in the "file" form field and checks its size and type.
+// The content type is sniffed from the bytes, the name and type sent by the client are ignored.
+func readImageUpload(c echo.Context, maxSize int64) ([]byte, string, image.Config, error) {
+	// Leave room for the multipart framing around the file
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxSize+1<<20)
+
+	tooLarge := echo.NewHTTPError(http.StatusRequestEntityTooLarge, map[string]string{"message": "File is too large"})
+	invalidFile := echo.NewHTTPError(http.StatusBadRequest, map[string]string{"message": "Invalid file"})
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return nil, "", image.Config{}, tooLarge
+		}
+		return nil, "", image.Config{}, echo.NewHTTPError(http.StatusBadRequest, map[string]string{"message": "Missing file"})
+	}
+	if fileHeader.Size > maxSize {
+		return nil, "", image.Config{}, tooLarge
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, "", image.Config{}, invalidFile
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, "", image.Config{}, invalidFile
+	}
+	if int64(len(data)) > maxSize {
+		return nil, "", image.Config{}, tooLarge
+	}
+
+	contentType, imageConfig, err := helpers.ProbeImage(data)
+	if errors.Is(err, helpers.ErrUnsupportedImage) {
+		return nil, "", image.Config{}, echo.NewHTTPError(http.StatusUnsupportedMediaType, map[string]string{"message": "Only JPEG, PNG and GIF images are supported"})
+	}
+	if err != nil {
+		return nil, "", image.Config{}, echo.NewHTTPError(http.StatusBadRequest, map[string]string{"message": "Invalid image"})
+	}
+
+	return data, contentType, imageConfig, nil
+}
+
+// GetAttachment godoc
+// @Summary Download an attachment
+// @Description Download an attachment or its JPEG thumbnail through a signed URL returned with the post or comment.
+// @Description The viewer must still be allowed to read the post or comment, so a link to a followers only post needs the token of a follower
+// @Tags Attachments
+// @Produce image/jpeg,image/png,image/gif
+// @Param aid path int true "Attachment ID"
+// @Param variant query string false "original (default) or thumbnail"
+// @Param expires query int true "Expiry of the signed URL (unix seconds)"
+// @Param signature query string true "Signature of the URL"
+// @Success 200 {file} file "Image"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Invalid or expired signature"
+// @Failure 404 {object} map[string]string "Attachment not found"
+// @Failure 500 {object} map[string]string "Failed to get attachment"
+// @Router /api/v1/attachments/{aid} [get]
+func GetAttachment(c echo.Context) error {
+	request := new(models.GetAttachmentRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	attachmentID, err := strconv.Atoi(c.Param("aid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	var attachment models.Attachment
+	if result := config.DB.First(&attachment, attachmentID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Attachment not found"})
+	}
+
+	key, contentType, etag := attachment.StorageKey, attachment.ContentType, `"`+attachment.SHA256+`"`
+	if request.Variant == "thumbnail" {
+		key, contentType, etag = attachment.ThumbnailKey, "image/jpeg", `"`+attachment.SHA256+`-thumbnail"`
+	}
+
+	remaining := time.Until(time.Unix(request.Expires, 0))
+	if !storage.ValidSignature(key, request.Expires, request.Signature) || remaining <= 0 {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Invalid or expired link"})
+	}
+
+	// A link can outlive the viewer's access: the post may have been hidden, made private or its author may block the viewer since
+	viewerID, _ := helpers.CurrentUserID(c)
+	post, err := findAttachmentPost(config.DB, attachment, viewerID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Attachment not found"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get attachment"})
+	}
+
+	// The blob behind a key never changes, so the response can be cached until the link expires.
+	// Only attachments of public posts may be kept by shared caches.
+	cacheScope := "private"
+	if post.Visibility == VisibilityPublic && post.PublishAt == nil {
+		cacheScope = "public"
+	}
+	header := c.Response().Header()
+	header.Set(echo.HeaderCacheControl, fmt.Sprintf("%s, max-age=%d, immutable", cacheScope, int(remaining.Seconds())))
+	header.Set("ETag", etag)
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	blob, err := storage.Default.Open(key)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Attachment not found"})
+	}
+	defer blob.Close()
+
+	return c.Stream(http.StatusOK, contentType, blob)
+}
+
+// findAttachmentPost loads the post of an attachment when the viewer may read the post, and the comment of a comment attachment.
+// An attachment the viewer may not see is reported as not found.
+func findAttachmentPost(db *gorm.DB, attachment models.Attachment, viewerID uint) (models.Post, error) {
+	var postID uint
+	switch {
+	case attachment.CommentID != nil:
+		var comment models.Comment
+		if err := db.Where("hidden_at IS NULL").First(&comment, *attachment.CommentID).Error; err != nil {
+			return models.Post{}, err
+		}
+		postID = comment.PostID
+	case attachment.PostID != nil:
+		postID = *attachment.PostID
+	default:
+		return models.Post{}, gorm.ErrRecordNotFound
+	}
+
+	post, err := findVisiblePost(db, postID, viewerID)
+	if err != nil {
+		return post, err
+	}
+	// Blocks count both ways: the viewer blocked or muted the uploader, or the uploader blocked the viewer
+	if viewerID != 0 && viewerID != attachment.UserID {
+		hidden, err := hidesUser(db, viewerID, attachment.UserID)
+		if err != nil {
+			return post, err
+		}
+		blocked, err := isBlocked(db, attachment.UserID, viewerID)
+		if err != nil {
+			return post, err
+		}
+		if hidden || blocked {
+			return post, gorm.ErrRecordNotFound
+		}
+	}
+	return post, nil
+}
+
+// attachmentResponse adds signed URLs to the attachment metadata
+func attachmentResponse(baseURL string, attachment models.Attachment) models.AttachmentResponse {
+	// Round the expiry up to the end of the next window, every URL signed in the same window is identical
+	window := int64(attachmentURLWindow.Seconds())
+	expires := (time.Now().Unix()/window + 2) * window
+
+	return models.AttachmentResponse{
+		AttachmentID: attachment.AttachmentID,
+		ContentType:  attachment.ContentType,
+		Size:         attachment.Size,
+		Width:        attachment.Width,
+		Height:       attachment.Height,
+		SHA256:       attachment.SHA256,
+		URL:          signedAttachmentURL(baseURL, attachment.AttachmentID, "original", attachment.StorageKey, expires),
+		ThumbnailURL: signedAttachmentURL(baseURL, attachment.AttachmentID, "thumbnail", attachment.ThumbnailKey, expires),
+		ExpiresAt:    time.Unix(expires, 0).UTC(),
+	}
+}
+
+func signedAttachmentURL(baseURL string, attachmentID uint, variant string, key string, expires int64) string {
+	query := url.Values{}
+	query.Set("variant", variant)
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", storage.Sign(key, expires))
+	return fmt.Sprintf("%s/api/v1/attachments/%d?%s", baseURL, attachmentID, query.Encode())
+}
+
+// loadAttachments returns the signed attachments of every target, oldest first.
+// Targets without attachments get an empty slice so the JSON output never contains null.
+func loadAttachments(baseURL string, db *gorm.DB, idColumn string, targetIDs []uint) (map[uint][]models.AttachmentResponse, error) {
+	responses := make(map[uint][]models.AttachmentResponse, len(targetIDs))
+	for _, id := range targetIDs {
+		responses[id] = []models.AttachmentResponse{}
+	}
+	if len(targetIDs) == 0 {
+		return responses, nil
+	}
+
+	var attachments []models.Attachment
+	if err := db.Where(idColumn+" IN ?", targetIDs).Order("attachment_id").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	for _, attachment := range attachments {
+		targetID := attachment.CommentID
+		if idColumn == "post_id" {
+			targetID = attachment.PostID
+		}
+		if targetID != nil {
+			responses[*targetID] = append(responses[*targetID], attachmentResponse(baseURL, attachment))
+		}
+	}
+
+	return responses, nil
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Audit actor types
+const (
+	AuditActorUser      = "user"
+	AuditActorAdmin     = "admin"
+	AuditActorAnonymous = "anonymous"
+)
+
+// Audited actions
+const (
+	AuditLogin               = "auth.login"
+	AuditLoginFailed         = "auth.login_failed"
+	AuditUserRegister        = "user.register"
+	AuditUserUpdate          = "user.update"
+	AuditPasswordChange      = "user.password_change"
+	AuditProfileUpdate       = "user.profile_update"
+	AuditAPIKeyCreate        = "user.api_key_create"
+	AuditAPIKeyRevoke        = "user.api_key_revoke"
+	AuditAccountReactivate   = "account.reactivate"
+	AuditAccountDeactivate   = "account.deactivate"
+	AuditAccountDelete       = "account.delete"
+	AuditAccountStatusChange = "account.status_change"
+	AuditDataExport          = "account.data_export"
+	AuditMigrationRun        = "admin.migration_run"
+	AuditReportAssign        = "admin.report_assign"
+	AuditReportAction        = "admin.report_action"
+	AuditJobRetry            = "admin.job_retry"
+	AuditWebhookCreate       = "admin.webhook_create"
+	AuditWebhookUpdate       = "admin.webhook_update"
+	AuditWebhookDelete       = "admin.webhook_delete"
+)
+
+// Sizes of the audit log columns, in characters
+const (
+	maxAuditActorNameLength = 64
+	maxAuditTargetIDLength  = 64
+	maxAuditDetailsLength   = 255
+	maxAuditIPLength        = 45
+	maxAuditRequestIDLength = 64
+)
+
+// auditEntry starts an audit log entry for the request. The actor is the JWT user, or the admin of a basic auth request.
+// Public routes such as login name the actor themselves.
+func auditEntry(c echo.Context, action string, targetType string, targetID interface{}) models.AuditLog {
+	entry := ServiceFor(c).auditEntry(action, targetType, targetID)
+	if _, _, ok := c.Request().BasicAuth(); ok && entry.ActorID == nil {
+		entry.ActorType = AuditActorAdmin
+		entry.ActorName = moderatorName(c)
+	}
+	return entry
+}
+
+// requestID returns the ID set by the RequestID middleware, or the one sent by the client when the middleware is not in use
+func requestID(c echo.Context) string {
+	if id := c.Response().Header().Get(echo.HeaderXRequestID); id != "" {
+		return id
+	}
+	return c.Request().Header.Get(echo.HeaderXRequestID)
+}
+
+// auditChanges returns the JSON fields that differ between before and after as {"field": {"from": ..., "to": ...}},
+// or an empty string when nothing changed. Fields hidden from JSON (such as the password) never appear.
+func auditChanges(before interface{}, after interface{}) string {
+	from, to := map[string]interface{}{}, map[string]interface{}{}
+	for _, pair := range []struct {
+		value  interface{}
+		fields *map[string]interface{}
+	}{{before, &from}, {after, &to}} {
+		data, err := json.Marshal(pair.value)
+		if err != nil || json.Unmarshal(data, pair.fields) != nil {
+			return ""
+		}
+	}
+
+	changes := map[string]map[string]interface{}{}
+	for field, value := range to {
+		if !reflect.DeepEqual(from[field], value) {
+			changes[field] = map[string]interface{}{"from": from[field], "to": value}
+		}
+	}
+	for field, value := range from {
+		if _, ok := to[field]; !ok {
+			changes[field] = map[string]interface{}{"from": value, "to": nil}
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// recordAudit appends an entry. Pass the transaction when the audited change runs in one, so both are kept or neither.
+func recordAudit(db *gorm.DB, entry models.AuditLog) error {
+	entry.ActorName = truncateRunes(entry.ActorName, maxAuditActorNameLength)
+	entry.TargetID = truncateRunes(entry.TargetID, maxAuditTargetIDLength)
+	entry.Details = truncateRunes(entry.Details, maxAuditDetailsLength)
+	// Both can come from request headers
+	entry.IP = truncateRunes(entry.IP, maxAuditIPLength)
+	entry.RequestID = truncateRunes(entry.RequestID, maxAuditRequestIDLength)
+	return db.Create(&entry).Error
+}
+
+// truncateRunes cuts s to at most max characters. Cutting bytes could split a character, and MySQL rejects the invalid UTF-8.
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
+
+// audit appends an entry after the change it describes was made. A failure is logged, the request has already succeeded.
+func audit(entry models.AuditLog) {
+	if err := recordAudit(config.DB, entry); err != nil {
+		log.Println("Failed to record audit log:", err)
+	}
+}
+
+// GetAuditLogs godoc
+// @Summary List or export the audit log
+// @Description List admin actions, authentication events and account changes, newest first.
+// @Description With format csv or jsonl every matching entry is downloaded instead of one page
+// @Tags Audit
+// @Produce json,text/csv,application/x-ndjson
+// @Param actor_type query string false "user, admin or anonymous"
+// @Param actor_id query int false "User ID of the actor"
+// @Param action query string false "Action, such as auth.login or user.update"
+// @Param target_type query string false "Target type, such as user, report or migration"
+// @Param target_id query string false "Target ID"
+// @Param request_id query string false "Request ID (X-Request-Id)"
+// @Param ip query string false "Client IP"
+// @Param since query string false "Oldest entry (RFC 3339)"
+// @Param until query string false "Newest entry (RFC 3339)"
+// @Param format query string false "json (default), csv or jsonl"
+// @Param page query int false "Page number, starting at 1"
+// @Param page_size query int false "Entries per page (max 50, default 20)"
+// @Success 200 {array} models.AuditLogResponse "Audit log entries"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to get audit logs"
+// @Router /api/v1/admin/audit-logs [get]
+func GetAuditLogs(c echo.Context) error {
+	request := new(models.GetAuditLogsRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	query := config.DB.Model(&models.AuditLog{})
+	filters := []struct {
+		column string
+		value  string
+	}{
+		{"actor_type", request.ActorType},
+		{"action", request.Action},
+		{"target_type", request.TargetType},
+		{"target_id", request.TargetID},
+		{"request_id", request.RequestID},
+		{"ip", request.IP},
+	}
+	for _, condition := range filters {
+		if condition.value != "" {
+			query = query.Where(condition.column+" = ?", condition.value)
+		}
+	}
+	if request.ActorID != 0 {
+		query = query.Where("actor_id = ?", request.ActorID)
+	}
+	if request.Since != "" {
+		since, _ := time.Parse(time.RFC3339, request.Since)
+		query = query.Where("created_at >= ?", since)
+	}
+	if request.Until != "" {
+		until, _ := time.Parse(time.RFC3339, request.Until)
+		query = query.Where("created_at <= ?", until)
+	}
+	query = query.Order("created_at DESC, audit_log_id DESC")
+
+	if request.Format == "csv" || request.Format == "jsonl" {
+		return exportAuditLogs(c, query, request.Format)
+	}
+
+	page, pageSize := pageAndSize(request.PaginationRequest)
+	var entries []models.AuditLog
+	if result := query.Limit(pageSize).Offset((page - 1) * pageSize).Find(&entries); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get audit logs"})
+	}
+
+	responses := make([]models.AuditLogResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = auditLogResponse(entry)
+	}
+	return c.JSON(http.StatusOK, responses)
+}
+
+// exportAuditLogs streams every matching entry as CSV or JSON Lines, one row at a time
+func exportAuditLogs(c echo.Context, query *gorm.DB, format string) error {
+	rows, err := query.Rows()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get audit logs"})
+	}
+	defer rows.Close()
+
+	response := c.Response()
+	contentType := "text/csv"
+	if format == "jsonl" {
+		contentType = "application/x-ndjson"
+	}
+	response.Header().Set(echo.HeaderContentType, contentType)
+	response.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="audit-logs.%s"`, format))
+	response.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(response)
+	encoder := json.NewEncoder(response)
+	if format == "csv" {
+		writer.Write([]string{"audit_log_id", "created_at", "actor_type", "actor_id", "actor_name", "action", "target_type", "target_id", "details", "changes", "ip", "request_id"})
+	}
+
+	for rows.Next() {
+		var entry models.AuditLog
+		if err := config.DB.ScanRows(rows, &entry); err != nil {
+			return err
+		}
+
+		if format == "jsonl" {
+			if err := encoder.Encode(auditLogResponse(entry)); err != nil {
+				return err
+			}
+			continue
+		}
+		actorID := ""
+		if entry.ActorID != nil {
+			actorID = strconv.FormatUint(uint64(*entry.ActorID), 10)
+		}
+		writer.Write([]string{
+			strconv.FormatUint(uint64(entry.AuditLogID), 10),
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+			entry.ActorType,
+			actorID,
+			csvCell(entry.ActorName),
+			entry.Action,
+			entry.TargetType,
+			csvCell(entry.TargetID),
+			csvCell(entry.Details),
+			csvCell(entry.Changes),
+			entry.IP,
+			csvCell(entry.RequestID),
+		})
+		if err := writer.Error(); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell keeps a spreadsheet from reading a user supplied value as a formula: values starting with a formula character
+// are prefixed with a quote
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func auditLogResponse(entry models.AuditLog) models.AuditLogResponse {
+	response := models.AuditLogResponse{
+		AuditLogID: entry.AuditLogID,
+		ActorType:  entry.ActorType,
+		ActorID:    entry.ActorID,
+		ActorName:  entry.ActorName,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Details:    entry.Details,
+		IP:         entry.IP,
+		RequestID:  entry.RequestID,
+		CreatedAt:  entry.CreatedAt,
+	}
+	if entry.Changes != "" {
+		response.Changes = json.RawMessage(entry.Changes)
+	}
+	return response
+}
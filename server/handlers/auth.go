@@ -1,17 +1,134 @@
 package handlers
 
 import (
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/models"
+	"server/tokens"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// API keys are sent as "Authorization: ApiKey <key>" or in the X-API-Key header
+const (
+	APIKeyHeader = "X-API-Key"
+	APIKeyScheme = "ApiKey"
 )
 
-func JWTAPIMiddleware(secret string) echo.MiddlewareFunc {
-	return nil
+// APIKeyContextKey is where the authenticating API key is kept in the context, JWT requests do not set it
+const APIKeyContextKey = "api_key"
+
+// JWTAPIMiddleware authenticates the restricted routes with a JWT (Authorization: Bearer) or a personal API key.
+// Either way the handlers find the user in the "user" token of the context, so helpers.CurrentUserID works unchanged.
+// jwtConfig is the configuration the other JWT protected routes use.
+func JWTAPIMiddleware(jwtConfig echojwt.Config) echo.MiddlewareFunc {
+	jwtMiddleware := echojwt.WithConfig(jwtConfig)
+
+	// References: https://echo.labstack.com/docs/middleware/key-auth
+	// For a valid key it calls the next handler, for an unknown, revoked or expired key it answers 401.
+	keyMiddleware := middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		KeyLookup: "header:" + APIKeyHeader + ",header:" + echo.HeaderAuthorization + ":" + APIKeyScheme + " ",
+		Validator: validateAPIKey,
+		ErrorHandler: func(err error, c echo.Context) error {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid API key"})
+		},
+	})
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withJWT := jwtMiddleware(next)
+		withAPIKey := keyMiddleware(requireAPIKeyScope(next))
+		return func(c echo.Context) error {
+			if usesAPIKey(c.Request()) {
+				return withAPIKey(c)
+			}
+			return withJWT(c)
+		}
+	}
+}
+
+func usesAPIKey(request *http.Request) bool {
+	return request.Header.Get(APIKeyHeader) != "" || strings.HasPrefix(request.Header.Get(echo.HeaderAuthorization), APIKeyScheme+" ")
 }
 
-// References: https://echo.labstack.com/docs/middleware/key-auth
+// validateAPIKey looks the key up by its hash and stands in for the JWT the handlers expect
+func validateAPIKey(key string, c echo.Context) (bool, error) {
+	apiKey, found, err := LookupAPIKey(key)
+	if err != nil || !found {
+		return false, err
+	}
+	c.Set(APIKeyContextKey, apiKey)
+	c.Set("user", APIKeyToken(apiKey))
+	return true, nil
+}
+
+// LookupAPIKey finds an active API key with its user by the key itself, found is false for unknown, revoked and expired keys
+func LookupAPIKey(key string) (apiKey models.APIKey, found bool, err error) {
+	now := time.Now()
+	result := config.DB.Preload("User").
+		Where("key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", hashAPIKey(key), now).
+		Limit(1).Find(&apiKey)
+	if result.Error != nil {
+		return apiKey, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return apiKey, false, nil
+	}
+
+	// Recording every request would turn reads into writes, a minute is precise enough
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > time.Minute {
+		config.DB.Model(&apiKey).UpdateColumn("last_used_at", now)
+	}
+	return apiKey, true, nil
+}
 
-// Key auth middleware provides a key based authentication.
+// APIKeyToken is the token that stands in for the JWT of the owner of an API key
+func APIKeyToken(apiKey models.APIKey) *jwt.Token {
+	return &jwt.Token{
+		Valid: true,
+		Claims: &models.JWTClaims{
+			UserID:    apiKey.User.UserID,
+			Username:  apiKey.User.Username,
+			Firstname: apiKey.User.Firstname,
+			Surname:   apiKey.User.Surname,
+			Admin:     apiKey.User.IsAdmin,
+		},
+	}
+}
+
+// requireAPIKeyScope rejects requests the scopes of the key do not cover
+func requireAPIKeyScope(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		apiKey, _ := c.Get(APIKeyContextKey).(models.APIKey)
+		scope := apiKeyScope(c)
+		if scope == "" {
+			return c.JSON(http.StatusForbidden, map[string]string{"message": "API keys cannot be used for this request"})
+		}
+		if !apiKeyHasScope(apiKey, scope) {
+			return c.JSON(http.StatusForbidden, map[string]string{"message": "The API key is missing the " + scope + " scope"})
+		}
+		return next(c)
+	}
+}
 
-// For valid key it calls the next handler.
-// For invalid key, it sends "401 - Unauthorized" response.
-// For missing key, it sends "400 - Bad Request" response.
+// GetJWKS godoc
+// @Summary Get the JWT signing keys
+// @Description Public keys (JWKS, RFC 7517) that verify the tokens of this server, by kid. The next key is listed before it signs.
+// @Tags Users
+// @Produce json
+// @Success 200 {object} jwks.Set
+// @Router /.well-known/jwks.json [get]
+func GetJWKS(c echo.Context) error {
+	// Verifiers may cache the set as long as a new key stays unused after it is published
+	maxAge := time.Hour
+	if prepublish := tokens.Default.Config().Prepublish / 2; prepublish < maxAge {
+		maxAge = prepublish
+	}
+	c.Response().Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	return c.JSON(http.StatusOK, tokens.Default.JWKS())
+}
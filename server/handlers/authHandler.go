@@ -92,7 +92,13 @@ func WriteLogInCookie(c echo.Context, sessionToken string) {
 // @Success 200 {object} map[string]string "Logout successful"
 // @Router /api/v1/logout [post]
 func Logout(c echo.Context) error {
-	// Clear cookie
+	clearLogInCookie(c)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Logout successful"})
+}
+
+// clearLogInCookie expires the cookie written by WriteLogInCookie
+func clearLogInCookie(c echo.Context) {
 	cookie := new(http.Cookie)
 	cookie.Name = "JWTCookie"
 	cookie.Value = ""
@@ -100,8 +106,6 @@ func Logout(c echo.Context) error {
 	cookie.HttpOnly = true
 	cookie.Secure = true
 	c.SetCookie(cookie)
-
-	return c.JSON(http.StatusOK, map[string]string{"message": "Logout successful"})
 }
 
 // For debug
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// hiddenAuthorsQuery selects the users the viewer blocked or muted
+const hiddenAuthorsQuery = "SELECT blocked_id FROM blocks WHERE blocker_id = ? UNION SELECT muted_id FROM mutes WHERE muter_id = ?"
+
+// excludeHiddenAuthors leaves out rows whose author column is a user the viewer blocked or muted.
+// Anonymous viewers (viewerID 0) see everything.
+func excludeHiddenAuthors(query *gorm.DB, column string, viewerID uint) *gorm.DB {
+	if viewerID == 0 {
+		return query
+	}
+	return query.Where(column+" NOT IN ("+hiddenAuthorsQuery+")", viewerID, viewerID)
+}
+
+// hiddenAuthorIDs returns the users the viewer blocked or muted
+func hiddenAuthorIDs(db *gorm.DB, viewerID uint) (map[uint]bool, error) {
+	var ids []uint
+	if err := db.Raw(hiddenAuthorsQuery, viewerID, viewerID).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+	hidden := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		hidden[id] = true
+	}
+	return hidden, nil
+}
+
+// hidesUser reports whether the viewer blocked or muted the user
+func hidesUser(db *gorm.DB, viewerID uint, userID uint) (bool, error) {
+	var count int64
+	err := db.Raw("SELECT COUNT(*) FROM ("+hiddenAuthorsQuery+") AS hidden WHERE hidden.blocked_id = ?", viewerID, viewerID, userID).Scan(&count).Error
+	return count > 0, err
+}
+
+// isBlocked reports whether blockerID blocked blockedID
+func isBlocked(db *gorm.DB, blockerID uint, blockedID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.Block{}).Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).Count(&count).Error
+	return count > 0, err
+}
+
+// BlockUser godoc
+// @Summary Block a user
+// @Description Block another user. They can no longer follow you or comment on your posts, follows in both directions
+// @Description are removed, and their posts, comments and notifications are hidden from you. Blocking someone twice has no effect
+// @Tags Blocks
+// @Accept json
+// @Produce json
+// @Param uid path int true "User ID to block"
+// @Success 200 {object} map[string]string "Blocked"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Failed to block user"
+// @Router /api/v1/restricted/blocks/{uid} [put]
+func BlockUser(c echo.Context) error {
+	blockedID, err := strconv.Atoi(c.Param("uid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	if uint(blockedID) == userID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "You cannot block yourself"})
+	}
+
+	var blocked models.User
+	if result := config.DB.First(&blocked, blockedID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		block := models.Block{BlockerID: userID, BlockedID: blocked.UserID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&block).Error; err != nil {
+			return err
+		}
+		return tx.Where("(follower_id = ? AND followee_id = ?) OR (follower_id = ? AND followee_id = ?)", userID, blocked.UserID, blocked.UserID, userID).
+			Delete(&models.Follow{}).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to block user"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Blocked " + blocked.Username})
+}
+
+// UnblockUser godoc
+// @Summary Unblock a user
+// @Description Unblock a user. Unblocking someone you did not block has no effect
+// @Tags Blocks
+// @Accept json
+// @Produce json
+// @Param uid path int true "User ID to unblock"
+// @Success 200 {object} map[string]string "Unblocked"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to unblock user"
+// @Router /api/v1/restricted/blocks/{uid} [delete]
+func UnblockUser(c echo.Context) error {
+	blockedID, err := strconv.Atoi(c.Param("uid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	if result := config.DB.Where("blocker_id = ? AND blocked_id = ?", userID, blockedID).Delete(&models.Block{}); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to unblock user"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Unblocked"})
+}
+
+// GetBlocks godoc
+// @Summary List blocked users
+// @Description Get the users blocked by the authenticated user, most recently blocked first
+// @Tags Blocks
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number, starting at 1"
+// @Param page_size query int false "Users per page (max 50, default 20)"
+// @Success 200 {array} models.UserRelationResponse "Blocked users"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to get blocked users"
+// @Router /api/v1/restricted/blocks [get]
+func GetBlocks(c echo.Context) error {
+	return listUserRelations(c, "blocks", "blocker_id", "blocked_id", "Failed to get blocked users")
+}
+
+// MuteUser godoc
+// @Summary Mute a user
+// @Description Mute another user. Their posts, comments and notifications are hidden from you, they are not told
+// @Description and can still follow you and comment. Muting someone twice has no effect
+// @Tags Blocks
+// @Accept json
+// @Produce json
+// @Param uid path int true "User ID to mute"
+// @Success 200 {object} map[string]string "Muted"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Failed to mute user"
+// @Router /api/v1/restricted/mutes/{uid} [put]
+func MuteUser(c echo.Context) error {
+	mutedID, err := strconv.Atoi(c.Param("uid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	if uint(mutedID) == userID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "You cannot mute yourself"})
+	}
+
+	var muted models.User
+	if result := config.DB.First(&muted, mutedID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+
+	mute := models.Mute{MuterID: userID, MutedID: muted.UserID}
+	if result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&mute); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to mute user"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Muted " + muted.Username})
+}
+
+// UnmuteUser godoc
+// @Summary Unmute a user
+// @Description Unmute a user. Unmuting someone you did not mute has no effect
+// @Tags Blocks
+// @Accept json
+// @Produce json
+// @Param uid path int true "User ID to unmute"
+// @Success 200 {object} map[string]string "Unmuted"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to unmute user"
+// @Router /api/v1/restricted/mutes/{uid} [delete]
+func UnmuteUser(c echo.Context) error {
+	mutedID, err := strconv.Atoi(c.Param("uid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	if result := config.DB.Where("muter_id = ? AND muted_id = ?", userID, mutedID).Delete(&models.Mute{}); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to unmute user"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Unmuted"})
+}
+
+// GetMutes godoc
+// @Summary List muted users
+// @Description Get the users muted by the authenticated user, most recently muted first
+// @Tags Blocks
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number, starting at 1"
+// @Param page_size query int false "Users per page (max 50, default 20)"
+// @Success 200 {array} models.UserRelationResponse "Muted users"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to get muted users"
+// @Router /api/v1/restricted/mutes [get]
+func GetMutes(c echo.Context) error {
+	return listUserRelations(c, "mutes", "muter_id", "muted_id", "Failed to get muted users")
+}
+
+// listUserRelations pages through the blocks or mutes of the authenticated user
+func listUserRelations(c echo.Context, table string, ownerColumn string, userColumn string, failure string) error {
+	request := new(models.PaginationRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+	page, pageSize := pageAndSize(*request)
+	userID, _ := helpers.CurrentUserID(c)
+
+	users := []models.UserRelationResponse{}
+	if result := config.DB.Table(table).Select("users.user_id, users.username, "+table+".created_at").
+		Joins("inner join users on users.user_id = "+table+"."+userColumn).
+		Where(table+"."+ownerColumn+" = ?", userID).
+		Order(table + ".created_at DESC, users.user_id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).
+		Scan(&users); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": failure})
+	}
+
+	return c.JSON(http.StatusOK, users)
+}
@@ -3,16 +3,20 @@ package handlers
 import (
 	"net/http"
 	"server/config"
+	"server/filter"
 	"server/helpers"
 	"server/models"
+	"server/unitofwork"
+	"strconv"
+	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
 // GetComments godoc
 // @Summary Get all comments
-// @Description Get all comments
+// @Description Get all comments. With a valid token, comments by users the viewer blocked or muted are left out.
+// @Description The post must be visible to the viewer
 // @Tags comments
 // @Accept json
 // @Produce json
@@ -20,30 +24,84 @@ import (
 // @Failure 500 {object} map[string]string "Failed to retrieve comments"
 // @Router /api/v1/admin/comments [get]
 func GetComments(c echo.Context) error {
-	postID := c.Param("pid")
-
-	var post models.Post
-	if err := config.DB.First(&post, postID).Error; err != nil {
+	postID, err := strconv.ParseUint(c.Param("pid"), 10, 0)
+	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Post does not exist"})
 	}
 
+	comments, err := ServiceFor(c).ListComments(uint(postID))
+	if err != nil {
+		return serviceError(c, err)
+	}
+	return c.JSON(http.StatusOK, comments)
+}
+
+// ListComments returns the comments of a post the caller may read, see GetComments
+func (s Service) ListComments(postID uint) ([]models.GetCommentRequest, error) {
+	if _, err := findVisiblePost(config.DB, postID, s.UserID); err != nil {
+		return nil, &ServiceError{Status: http.StatusBadRequest, Message: "Post does not exist"}
+	}
+
 	var comments []models.GetCommentRequest
-	if err := config.DB.Table("comments").Select("users.username, comments.comment_msg").Joins("inner join comment_users on comment_users.comment_id = comments.comment_id").Joins("inner join users on users.user_id = comment_users.user_id").Where("comments.post_id = ?", postID).Scan(&comments).Error; err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get comments"})
+	query := config.DB.Table("comments").Select("comments.comment_id, users.username, comments.comment_msg").
+		Joins("inner join users on users.user_id = comments.author_id").
+		Where("comments.post_id = ? AND comments.hidden_at IS NULL", postID).Order("comments.comment_id")
+	if err := excludeHiddenAuthors(query, "comments.author_id", s.UserID).Scan(&comments).Error; err != nil {
+		return nil, failed("Failed to get comments", err)
 	}
 
-	return c.JSON(http.StatusOK, comments)
+	if err := s.decorateComments(comments); err != nil {
+		return nil, failed("Failed to get comments", err)
+	}
+	return comments, nil
+}
+
+// decorateComments attaches reaction counts, hashtag/mention entities, attachments, and the reactions of the caller
+// when it is signed in
+func (s Service) decorateComments(comments []models.GetCommentRequest) error {
+	commentIDs := make([]uint, len(comments))
+	messages := make([]string, len(comments))
+	for i, comment := range comments {
+		commentIDs[i] = comment.CommentID
+		messages[i] = comment.CommentMSG
+	}
+
+	counts, mine, err := loadReactionSummaries(config.DB, commentReactionTables, commentIDs, s.UserID)
+	if err != nil {
+		return err
+	}
+	entities, err := resolveEntities(config.DB, messages)
+	if err != nil {
+		return err
+	}
+	attachments, err := loadAttachments(s.BaseURL, config.DB, "comment_id", commentIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		comments[i].Reactions = counts[comments[i].CommentID]
+		comments[i].MyReactions = mine[comments[i].CommentID]
+		comments[i].Entities = entities[i]
+		comments[i].Attachments = attachments[comments[i].CommentID]
+	}
+	return nil
 }
 
 // PostComment godoc
 // @Summary Create a comment
-// @Description Create a new comment
+// @Description Create a new comment. The content filter may reject the comment,
+// @Description or accept it hidden until a moderator reviews it (202 with HiddenAt set)
 // @Tags comments
 // @Accept json
 // @Produce json
 // @Param comment body models.Comment true "Comment object that needs to be created"
 // @Success 201 {object} models.Comment
-// @Failure 400 {object} map[string]string "Invalid input or failed to create comment"
+// @Success 202 {object} models.Comment "Comment held for review"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Blocked by the author of the post"
+// @Failure 422 {object} map[string]string "Rejected by the content filter"
+// @Failure 500 {object} map[string]string "Failed to create comment"
 // @Router /api/v1/restricted/comments [post]
 func CreateComment(c echo.Context) error {
 	request := new(models.CreateCommentRequest)
@@ -51,34 +109,172 @@ func CreateComment(c echo.Context) error {
 		return err
 	}
 
-	var post models.Post
-	if result := config.DB.First(&post, request.PostID); result.Error != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Post does not exist"})
+	comment, err := ServiceFor(c).CreateComment(*request)
+	if err != nil {
+		return serviceError(c, err)
 	}
 
-	user := c.Get("user")
-	token := user.(*jwt.Token)
-	claims := token.Claims.(*models.JWTClaims)
+	if comment.HiddenAt != nil {
+		return c.JSON(http.StatusAccepted, comment)
+	}
+	return c.JSON(http.StatusCreated, comment)
+}
 
-	userID := claims.UserID
+// CreateComment comments on a post for the caller, see CreateComment. A comment held for review comes back with HiddenAt set.
+func (s Service) CreateComment(request models.CreateCommentRequest) (models.Comment, error) {
+	if err := validateRequest(request); err != nil {
+		return models.Comment{}, err
+	}
+
+	userID := s.UserID
+
+	// Drafts and scheduled posts are not open for comments until they are published
+	post, err := findVisiblePost(config.DB, request.PostID, userID)
+	if err != nil || post.Visibility == VisibilityDraft || post.PublishAt != nil {
+		return models.Comment{}, &ServiceError{Status: http.StatusBadRequest, Message: "Post does not exist"}
+	}
+
+	blocked, err := isBlocked(config.DB, post.UserID, userID)
+	if err != nil {
+		return models.Comment{}, failed("Failed to create comment", err)
+	}
+	if blocked {
+		return models.Comment{}, &ServiceError{Status: http.StatusForbidden, Message: "You cannot comment on this post"}
+	}
+
+	content := filter.Content{Kind: filter.KindComment, UserID: userID, Text: request.CommentMSG}
+	screened := filter.Run(content)
+	if screened.Verdict == filter.Reject {
+		return models.Comment{}, rejectContent(content, screened)
+	}
 
 	comment := models.Comment{
 		PostID:     request.PostID,
+		AuthorID:   userID,
 		CommentMSG: request.CommentMSG,
 	}
+	if screened.Verdict == filter.Hold {
+		now := time.Now()
+		comment.HiddenAt = &now
+	}
+
+	// The comment, its hashtags, mentions and review report are stored together or not at all
+	err = unitofwork.Run(config.DB, func(unit *unitofwork.Unit) error {
+		if err := unit.Tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		mentioned, err := syncEntities(unit.Tx, commentEntityTables, comment.CommentID, comment.CommentMSG)
+		if err != nil {
+			return err
+		}
+		comment.Entities = entitiesOf(unit.Tx, comment.CommentMSG)
 
-	if result := config.DB.Create(&comment); result.Error != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Failed to create comment"})
+		if comment.HiddenAt != nil {
+			content.ID = comment.CommentID
+			return holdForReview(unit.Tx, content, screened)
+		}
+		unit.AfterCommit(func() {
+			notifyReply(config.DB, post, comment, mentioned)
+			indexCommentForSearch(config.DB, comment)
+			publishCommentCreated(config.DB, comment)
+		})
+		return nil
+	})
+	if err != nil {
+		return models.Comment{}, failed("Failed to create comment", err)
 	}
+	return comment, nil
+}
 
-	commentUser := models.CommentUser{
-		CommentID: comment.CommentID,
-		UserID:    userID,
+// UpdateComment godoc
+// @Summary Edit a comment
+// @Description Edit the message of a comment written by the authenticated user. Hashtags and mentions are extracted again.
+// @Description The new message goes through the content filter like a new comment, a held comment is hidden until reviewed
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param cid path int true "Comment ID"
+// @Param comment body models.UpdateCommentRequest true "New message"
+// @Success 200 {object} models.Comment
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Not the author of the comment"
+// @Failure 404 {object} map[string]string "Comment not found"
+// @Failure 422 {object} map[string]string "Rejected by the content filter"
+// @Failure 500 {object} map[string]string "Failed to update comment"
+// @Router /api/v1/restricted/comments/{cid} [put]
+func UpdateComment(c echo.Context) error {
+	request := new(models.UpdateCommentRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
 	}
 
-	if result := config.DB.Create(&commentUser); result.Error != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Failed to create comment and user data"})
+	commentID, err := strconv.ParseUint(c.Param("cid"), 10, 0)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
 	}
 
-	return c.JSON(http.StatusCreated, comment)
+	comment, err := ServiceFor(c).UpdateComment(uint(commentID), *request)
+	if err != nil {
+		return serviceError(c, err)
+	}
+	return c.JSON(http.StatusOK, comment)
+}
+
+// UpdateComment edits a comment of the caller, see UpdateComment
+func (s Service) UpdateComment(commentID uint, request models.UpdateCommentRequest) (models.Comment, error) {
+	if err := validateRequest(request); err != nil {
+		return models.Comment{}, err
+	}
+
+	var comment models.Comment
+	if result := config.DB.First(&comment, commentID); result.Error != nil {
+		return comment, &ServiceError{Status: http.StatusNotFound, Message: "Comment not found"}
+	}
+
+	userID := s.UserID
+	if comment.AuthorID != userID {
+		return comment, &ServiceError{Status: http.StatusForbidden, Message: "You can only edit your own comments"}
+	}
+
+	content := filter.Content{Kind: filter.KindComment, ID: comment.CommentID, UserID: userID, Text: request.CommentMSG}
+	screened := filter.Run(content)
+	if screened.Verdict == filter.Reject {
+		return comment, rejectContent(content, screened)
+	}
+
+	comment.CommentMSG = request.CommentMSG
+	updates := map[string]interface{}{"comment_msg": comment.CommentMSG}
+	if screened.Verdict == filter.Hold {
+		now := time.Now()
+		comment.HiddenAt = &now
+		updates["hidden_at"] = now
+	}
+	err := unitofwork.Run(config.DB, func(unit *unitofwork.Unit) error {
+		if err := unit.Tx.Model(&comment).Updates(updates).Error; err != nil {
+			return err
+		}
+		mentioned, err := syncEntities(unit.Tx, commentEntityTables, comment.CommentID, comment.CommentMSG)
+		if err != nil {
+			return err
+		}
+		comment.Entities = entitiesOf(unit.Tx, comment.CommentMSG)
+
+		if screened.Verdict == filter.Hold {
+			if err := holdForReview(unit.Tx, content, screened); err != nil {
+				return err
+			}
+		} else if comment.HiddenAt == nil {
+			unit.AfterCommit(func() {
+				notifyMentions(config.DB, mentioned, userID, &comment.PostID, &comment.CommentID)
+			})
+		}
+		unit.AfterCommit(func() {
+			indexCommentForSearch(config.DB, comment)
+		})
+		return nil
+	})
+	if err != nil {
+		return comment, failed("Failed to update comment", err)
+	}
+	return comment, nil
 }
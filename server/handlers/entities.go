@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"server/helpers"
+	"server/models"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// entityTables describes where the hashtags and mentions of a kind of target are stored
+type entityTables struct {
+	hashtags     string
+	mentions     string
+	idColumn     string
+	hashtagModel func(targetID uint, hashtagID uint) interface{}
+	mentionModel func(targetID uint, userID uint) interface{}
+}
+
+var (
+	postEntityTables = entityTables{
+		hashtags: "post_hashtags",
+		mentions: "post_mentions",
+		idColumn: "post_id",
+		hashtagModel: func(targetID uint, hashtagID uint) interface{} {
+			return &models.PostHashtag{PostID: targetID, HashtagID: hashtagID}
+		},
+		mentionModel: func(targetID uint, userID uint) interface{} {
+			return &models.PostMention{PostID: targetID, UserID: userID}
+		},
+	}
+	commentEntityTables = entityTables{
+		hashtags: "comment_hashtags",
+		mentions: "comment_mentions",
+		idColumn: "comment_id",
+		hashtagModel: func(targetID uint, hashtagID uint) interface{} {
+			return &models.CommentHashtag{CommentID: targetID, HashtagID: hashtagID}
+		},
+		mentionModel: func(targetID uint, userID uint) interface{} {
+			return &models.CommentMention{CommentID: targetID, UserID: userID}
+		},
+	}
+)
+
+// syncEntities stores the hashtags and mentions found in text for a post or comment, replacing the previous ones.
+// Rows that did not change keep their created_at, so editing a post does not bump its tags in the trending window.
+// It returns the IDs of users that are mentioned for the first time.
+func syncEntities(db *gorm.DB, tables entityTables, targetID uint, text string) ([]uint, error) {
+	entities := helpers.ExtractEntities(text)
+	tags := helpers.HashtagsOf(entities)
+	usernames := helpers.MentionsOf(entities)
+
+	var newMentions []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Hashtags
+		var hashtagIDs []uint
+		if len(tags) > 0 {
+			hashtags := make([]models.Hashtag, len(tags))
+			for i, tag := range tags {
+				hashtags[i] = models.Hashtag{Tag: tag}
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&hashtags).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Hashtag{}).Where("tag IN ?", tags).Pluck("hashtag_id", &hashtagIDs).Error; err != nil {
+				return err
+			}
+		}
+		if err := deleteStaleEntities(tx, tables.hashtags, tables.idColumn, "hashtag_id", targetID, hashtagIDs); err != nil {
+			return err
+		}
+		for _, hashtagID := range hashtagIDs {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(tables.hashtagModel(targetID, hashtagID)).Error; err != nil {
+				return err
+			}
+		}
+
+		// Mentions, only usernames that exist count as a mention
+		var userIDs []uint
+		if len(usernames) > 0 {
+			if err := tx.Model(&models.User{}).Where("username IN ?", usernames).Pluck("user_id", &userIDs).Error; err != nil {
+				return err
+			}
+		}
+		var existing []uint
+		if err := tx.Table(tables.mentions).Where(tables.idColumn+" = ?", targetID).Pluck("user_id", &existing).Error; err != nil {
+			return err
+		}
+		if err := deleteStaleEntities(tx, tables.mentions, tables.idColumn, "user_id", targetID, userIDs); err != nil {
+			return err
+		}
+		alreadyMentioned := make(map[uint]bool, len(existing))
+		for _, userID := range existing {
+			alreadyMentioned[userID] = true
+		}
+		for _, userID := range userIDs {
+			if alreadyMentioned[userID] {
+				continue
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(tables.mentionModel(targetID, userID)).Error; err != nil {
+				return err
+			}
+			newMentions = append(newMentions, userID)
+		}
+
+		return nil
+	})
+
+	return newMentions, err
+}
+
+func deleteStaleEntities(tx *gorm.DB, table string, idColumn string, valueColumn string, targetID uint, keep []uint) error {
+	if len(keep) == 0 {
+		return tx.Exec("DELETE FROM "+table+" WHERE "+idColumn+" = ?", targetID).Error
+	}
+	return tx.Exec("DELETE FROM "+table+" WHERE "+idColumn+" = ? AND "+valueColumn+" NOT IN ?", targetID, keep).Error
+}
+
+// resolveEntities parses every text and fills in the user ID of mentions with a single query.
+// Mentions of usernames that do not exist are dropped.
+func resolveEntities(db *gorm.DB, texts []string) ([][]models.TextEntity, error) {
+	parsed := make([][]models.TextEntity, len(texts))
+	usernames := []string{}
+	for i, text := range texts {
+		parsed[i] = helpers.ExtractEntities(text)
+		usernames = append(usernames, helpers.MentionsOf(parsed[i])...)
+	}
+
+	userIDs := make(map[string]uint)
+	if len(usernames) > 0 {
+		var users []models.User
+		if err := db.Select("user_id, username").Where("username IN ?", usernames).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			// Usernames compare case-insensitively in MySQL, do the same here
+			userIDs[strings.ToLower(user.Username)] = user.UserID
+		}
+	}
+
+	for i, entities := range parsed {
+		resolved := []models.TextEntity{}
+		for _, entity := range entities {
+			if entity.Type == helpers.EntityMention {
+				userID, ok := userIDs[strings.ToLower(entity.Value)]
+				if !ok {
+					continue
+				}
+				entity.UserID = userID
+			}
+			resolved = append(resolved, entity)
+		}
+		parsed[i] = resolved
+	}
+
+	return parsed, nil
+}
+
+// entitiesOf resolves the entities of a single text, for create and edit responses
+func entitiesOf(db *gorm.DB, text string) []models.TextEntity {
+	resolved, err := resolveEntities(db, []string{text})
+	if err != nil {
+		return []models.TextEntity{}
+	}
+	return resolved[0]
+}
@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"server/config"
+	"server/helpers"
+	"server/jobs"
+	"server/models"
+	"server/storage"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Export job statuses
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+	ExportExpired = "expired"
+)
+
+// How long a finished archive can be downloaded, EXPORT_TTL overrides it
+var exportTTL = 24 * time.Hour
+
+// Jobs still pending or running after this long are given up on (the job queue is down or stuck) and are marked failed
+var exportStaleAfter = time.Hour
+
+func init() {
+	if ttl, err := time.ParseDuration(os.Getenv("EXPORT_TTL")); err == nil && ttl > 0 {
+		exportTTL = ttl
+	}
+}
+
+// RequestExport godoc
+// @Summary Export my personal data
+// @Description Start building a ZIP archive with the profile, posts, comments, reactions and follows of the authenticated user.
+// @Description The archive is built in the background, poll the returned job until its status is ready.
+// @Description While a job is pending or running it is returned instead of starting a new one
+// @Tags Users
+// @Produce json
+// @Success 202 {object} models.ExportJobResponse "Export job"
+// @Failure 500 {object} map[string]string "Failed to start export"
+// @Router /api/v1/restricted/me/export [post]
+func RequestExport(c echo.Context) error {
+	userID, _ := helpers.CurrentUserID(c)
+
+	var job models.ExportJob
+	result := config.DB.Where("user_id = ? AND status IN ?", userID, []string{ExportPending, ExportRunning}).Limit(1).Find(&job)
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start export"})
+	}
+	if result.RowsAffected > 0 {
+		return c.JSON(http.StatusAccepted, exportJobResponse(c, job))
+	}
+
+	job = models.ExportJob{UserID: userID, Status: ExportPending}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&job).Error; err != nil {
+			return err
+		}
+		_, err := jobs.Enqueue(tx, JobExport, exportJobPayload{ExportJobID: job.ExportJobID})
+		return err
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to start export"})
+	}
+	audit(auditEntry(c, AuditDataExport, "export", job.ExportJobID))
+
+	return c.JSON(http.StatusAccepted, exportJobResponse(c, job))
+}
+
+// GetExport godoc
+// @Summary Get the status of a personal data export
+// @Description Poll an export job of the authenticated user. Once ready, the response contains a signed download URL valid until expires_at
+// @Tags Users
+// @Produce json
+// @Param jid path int true "Export job ID"
+// @Success 200 {object} models.ExportJobResponse "Export job"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Export not found"
+// @Router /api/v1/restricted/me/export/{jid} [get]
+func GetExport(c echo.Context) error {
+	jobID, err := strconv.Atoi(c.Param("jid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	var job models.ExportJob
+	if result := config.DB.Where("user_id = ?", userID).First(&job, jobID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Export not found"})
+	}
+
+	return c.JSON(http.StatusOK, exportJobResponse(c, job))
+}
+
+// DownloadExport godoc
+// @Summary Download a personal data export
+// @Description Download the ZIP archive of a ready export through the signed URL returned by the status endpoint
+// @Tags Users
+// @Produce application/zip
+// @Param jid path int true "Export job ID"
+// @Param expires query int true "Expiry of the signed URL (unix seconds)"
+// @Param signature query string true "Signature of the URL"
+// @Success 200 {file} file "ZIP archive"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Invalid or expired link, also for unknown exports"
+// @Failure 404 {object} map[string]string "Export not found"
+// @Failure 410 {object} map[string]string "Export expired"
+// @Router /api/v1/exports/{jid} [get]
+func DownloadExport(c echo.Context) error {
+	request := new(models.GetExportRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	jobID, err := strconv.Atoi(c.Param("jid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	// The link is checked before anything about the job is told, so unsigned requests cannot probe for jobs
+	var job models.ExportJob
+	found := config.DB.First(&job, jobID).Error == nil
+	if !found || job.StorageKey == "" || !storage.ValidSignature(job.StorageKey, request.Expires, request.Signature) || time.Now().Unix() >= request.Expires {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "Invalid or expired link"})
+	}
+	if exportExpired(job) {
+		return c.JSON(http.StatusGone, map[string]string{"message": "Export expired"})
+	}
+	if job.Status != ExportReady {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Export not found"})
+	}
+
+	blob, err := storage.Default.Open(job.StorageKey)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Export not found"})
+	}
+	defer blob.Close()
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="export-%d.zip"`, job.ExportJobID))
+	header.Set(echo.HeaderCacheControl, "private, no-store")
+	return c.Stream(http.StatusOK, "application/zip", blob)
+}
+
+// exportJobResponse adds the signed download URL to a ready job
+func exportJobResponse(c echo.Context, job models.ExportJob) models.ExportJobResponse {
+	if exportExpired(job) {
+		job.Status = ExportExpired
+	}
+
+	response := models.ExportJobResponse{
+		ExportJobID: job.ExportJobID,
+		Status:      job.Status,
+		Size:        job.Size,
+		Error:       job.Error,
+		CreatedAt:   job.CreatedAt,
+		CompletedAt: job.CompletedAt,
+		ExpiresAt:   job.ExpiresAt,
+	}
+	if job.Status == ExportReady {
+		expires := job.ExpiresAt.Unix()
+		query := url.Values{}
+		query.Set("expires", strconv.FormatInt(expires, 10))
+		query.Set("signature", storage.Sign(job.StorageKey, expires))
+		response.DownloadURL = fmt.Sprintf("%s://%s/api/v1/exports/%d?%s", c.Scheme(), c.Request().Host, job.ExportJobID, query.Encode())
+	}
+	return response
+}
+
+// exportExpired reports whether a finished archive is past its expiry, even if the cleanup has not run yet
+func exportExpired(job models.ExportJob) bool {
+	return job.Status == ExportExpired || (job.Status == ExportReady && job.ExpiresAt != nil && !job.ExpiresAt.After(time.Now()))
+}
+
+type exportJobPayload struct {
+	ExportJobID uint `json:"export_job_id"`
+}
+
+// runExportJob is the queue handler of JobExport. The export is only marked failed once the queue gives up on it.
+func runExportJob(db *gorm.DB) jobs.Handler {
+	return func(ctx context.Context, queued models.Job) error {
+		var payload exportJobPayload
+		if err := jobs.Decode(queued, &payload); err != nil {
+			return jobs.Permanent(err)
+		}
+
+		var job models.ExportJob
+		if err := db.First(&job, payload.ExportJobID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			// The account was deleted in the meantime
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if job.Status != ExportPending && job.Status != ExportRunning {
+			// Already built by an earlier attempt, or failed as stale
+			return nil
+		}
+
+		err := runExport(db, job)
+		if err != nil && jobs.LastAttempt(queued) {
+			db.Model(&job).Updates(map[string]interface{}{"status": ExportFailed, "error": "Failed to build the archive"})
+		}
+		return err
+	}
+}
+
+// runExport builds the archive of a job and stores it, the job records the outcome
+func runExport(db *gorm.DB, job models.ExportJob) error {
+	if err := db.Model(&job).Update("status", ExportRunning).Error; err != nil {
+		return err
+	}
+
+	data, err := buildExport(db, job.UserID)
+	if err == nil {
+		job.StorageKey, err = exportKey(job)
+	}
+	if err == nil {
+		err = storage.Default.Put(job.StorageKey, bytes.NewReader(data))
+	}
+	if err != nil {
+		log.Println("Failed to build export:", err)
+		return err
+	}
+
+	now := time.Now()
+	expires := now.Add(exportTTL)
+	if err := db.Model(&job).Updates(map[string]interface{}{
+		"status":       ExportReady,
+		"storage_key":  job.StorageKey,
+		"size":         len(data),
+		"completed_at": now,
+		"expires_at":   expires,
+	}).Error; err != nil {
+		log.Println("Failed to finish export:", err)
+		if err := storage.Default.Delete(job.StorageKey); err != nil {
+			log.Println("Failed to delete export:", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// exportKey returns an unguessable blob key, the signature is what protects the download but the key should not leak the job either
+func exportKey(job models.ExportJob) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("exports/%d/%d-%s.zip", job.UserID, job.ExportJobID, hex.EncodeToString(random)), nil
+}
+
+// buildExport writes one JSON file per kind of data into a ZIP archive.
+// Hidden posts and comments are included, they still belong to the user.
+func buildExport(db *gorm.DB, userID uint) ([]byte, error) {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	profile := models.ExportProfile{
+		UserID:         user.UserID,
+		Username:       user.Username,
+		Firstname:      user.Firstname,
+		Surname:        user.Surname,
+		Email:          user.Email,
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		Location:       user.Location,
+		Website:        user.Website,
+		Status:         user.Status,
+		SuspendedUntil: user.SuspendedUntil,
+		CreatedAt:      user.CreatedAt,
+	}
+
+	posts := []models.Post{}
+	if err := db.Where("user_id = ?", userID).Order("post_id").Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	comments := []models.Comment{}
+	if err := db.Where("author_id = ?", userID).Order("comment_id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	reactions := []models.ExportReaction{}
+	var postReactions []models.PostReaction
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&postReactions).Error; err != nil {
+		return nil, err
+	}
+	for _, reaction := range postReactions {
+		reactions = append(reactions, models.ExportReaction{TargetType: "post", TargetID: reaction.PostID, ReactionType: reaction.ReactionType, CreatedAt: reaction.CreatedAt})
+	}
+	var commentReactions []models.CommentReaction
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&commentReactions).Error; err != nil {
+		return nil, err
+	}
+	for _, reaction := range commentReactions {
+		reactions = append(reactions, models.ExportReaction{TargetType: "comment", TargetID: reaction.CommentID, ReactionType: reaction.ReactionType, CreatedAt: reaction.CreatedAt})
+	}
+
+	follows := models.ExportFollows{Following: []models.UserRelationResponse{}, Followers: []models.UserRelationResponse{}}
+	if err := db.Table("follows").Select("users.user_id, users.username, follows.created_at").
+		Joins("inner join users on users.user_id = follows.followee_id").
+		Where("follows.follower_id = ?", userID).Order("follows.created_at").Scan(&follows.Following).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Table("follows").Select("users.user_id, users.username, follows.created_at").
+		Joins("inner join users on users.user_id = follows.follower_id").
+		Where("follows.followee_id = ?", userID).Order("follows.created_at").Scan(&follows.Followers).Error; err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", profile},
+		{"posts.json", posts},
+		{"comments.json", comments},
+		{"reactions.json", reactions},
+		{"follows.json", follows},
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// PurgeExpiredExports deletes the archives of expired exports and fails jobs that were interrupted
+func PurgeExpiredExports(db *gorm.DB) error {
+	var expired []models.ExportJob
+	if err := db.Where("status = ? AND expires_at <= ?", ExportReady, time.Now()).Find(&expired).Error; err != nil {
+		return err
+	}
+	for _, job := range expired {
+		if err := storage.Default.Delete(job.StorageKey); err != nil {
+			log.Println("Failed to delete export:", err)
+			continue
+		}
+		if err := db.Model(&job).Updates(map[string]interface{}{"status": ExportExpired, "storage_key": ""}).Error; err != nil {
+			return err
+		}
+	}
+
+	return db.Model(&models.ExportJob{}).
+		Where("status IN ? AND created_at <= ?", []string{ExportPending, ExportRunning}, time.Now().Add(-exportStaleAfter)).
+		Updates(map[string]interface{}{"status": ExportFailed, "error": "The export was interrupted, please request a new one"}).Error
+}
+
+// StartExportCleanup runs PurgeExpiredExports every interval for the lifetime of the process
+func StartExportCleanup(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := PurgeExpiredExports(db); err != nil {
+				log.Println("Failed to purge expired exports:", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"server/config"
+	"server/filter"
+	"server/helpers"
+	"server/models"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const maxFilterExcerptLength = 1000
+
+// GetFilterEvents godoc
+// @Summary List content filter events
+// @Description List the posts and comments the automated content filter rejected or held for review, newest first.
+// @Description Held content is hidden and also waits in the moderation queue as a report with reason "filter"
+// @Tags Moderation
+// @Accept json
+// @Produce json
+// @Param verdict query string false "hold or reject"
+// @Param filter query string false "Filter name: banned_words, review_words, links or spam"
+// @Param page query int false "Page number, starting at 1"
+// @Param page_size query int false "Events per page (max 50, default 20)"
+// @Success 200 {array} models.FilterEventResponse "Filter events"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to get filter events"
+// @Router /api/v1/admin/filter-events [get]
+func GetFilterEvents(c echo.Context) error {
+	request := new(models.GetFilterEventsRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+	page, pageSize := pageAndSize(request.PaginationRequest)
+
+	query := config.DB.Table("filter_events").Select("filter_events.filter_event_id, filter_events.user_id, users.username, filter_events.target_type, filter_events.target_id, filter_events.verdict, filter_events.filter, filter_events.reason, filter_events.excerpt, filter_events.created_at").
+		Joins("inner join users on users.user_id = filter_events.user_id")
+	if request.Verdict != "" {
+		query = query.Where("filter_events.verdict = ?", request.Verdict)
+	}
+	if request.Filter != "" {
+		query = query.Where("filter_events.filter = ?", request.Filter)
+	}
+
+	events := []models.FilterEventResponse{}
+	if result := query.Order("filter_events.created_at DESC, filter_events.filter_event_id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).
+		Scan(&events); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get filter events"})
+	}
+
+	return c.JSON(http.StatusOK, events)
+}
+
+// rejectContent records a rejected post or comment and returns the error with the reason
+func rejectContent(content filter.Content, result filter.Result) error {
+	if err := recordFilterEvent(config.DB, content, result); err != nil {
+		log.Println("Failed to record content filter event:", err)
+	}
+	return &ServiceError{Status: http.StatusUnprocessableEntity, Message: result.Reason}
+}
+
+// holdForReview records a held post or comment and opens a report for it, so it shows up in the moderation queue.
+// The content itself is stored hidden by the caller; unhiding it from the queue publishes it.
+func holdForReview(db *gorm.DB, content filter.Content, result filter.Result) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := recordFilterEvent(tx, content, result); err != nil {
+			return err
+		}
+		report := models.Report{
+			TargetType: content.Kind,
+			TargetID:   content.ID,
+			Reason:     ReportReasonFilter,
+			Details:    result.Filter + ": " + result.Reason,
+			Status:     ReportOpen,
+		}
+		return tx.Create(&report).Error
+	})
+}
+
+func recordFilterEvent(db *gorm.DB, content filter.Content, result filter.Result) error {
+	excerpt := []rune(content.Text)
+	if len(excerpt) > maxFilterExcerptLength {
+		excerpt = excerpt[:maxFilterExcerptLength]
+	}
+
+	event := models.FilterEvent{
+		UserID:     content.UserID,
+		TargetType: content.Kind,
+		Verdict:    result.Verdict.String(),
+		Filter:     result.Filter,
+		Reason:     result.Reason,
+		Excerpt:    string(excerpt),
+	}
+	if content.ID != 0 {
+		event.TargetID = &content.ID
+	}
+	return db.Create(&event).Error
+}
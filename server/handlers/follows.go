@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm/clause"
+)
+
+// FollowUser godoc
+// @Summary Follow a user
+// @Description Follow another user. Following someone twice has no effect
+// @Tags Follows
+// @Accept json
+// @Produce json
+// @Param uid path int true "User ID to follow"
+// @Success 200 {object} map[string]string "Following"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Blocked by the user"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Failed to follow user"
+// @Router /api/v1/restricted/follows/{uid} [put]
+func FollowUser(c echo.Context) error {
+	followeeID, err := strconv.Atoi(c.Param("uid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	if uint(followeeID) == userID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "You cannot follow yourself"})
+	}
+
+	var followee models.User
+	if result := config.DB.First(&followee, followeeID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+
+	blocked, err := isBlocked(config.DB, followee.UserID, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to follow user"})
+	}
+	if blocked {
+		return c.JSON(http.StatusForbidden, map[string]string{"message": "You cannot follow this user"})
+	}
+
+	follow := models.Follow{FollowerID: userID, FolloweeID: followee.UserID}
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&follow)
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to follow user"})
+	}
+	if result.RowsAffected > 0 {
+		notify(config.DB, followee.UserID, userID, NotificationFollow, nil, nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Following " + followee.Username})
+}
+
+// UnfollowUser godoc
+// @Summary Unfollow a user
+// @Description Stop following a user. Unfollowing someone you do not follow has no effect
+// @Tags Follows
+// @Accept json
+// @Produce json
+// @Param uid path int true "User ID to unfollow"
+// @Success 200 {object} map[string]string "Unfollowed"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to unfollow user"
+// @Router /api/v1/restricted/follows/{uid} [delete]
+func UnfollowUser(c echo.Context) error {
+	followeeID, err := strconv.Atoi(c.Param("uid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	if result := config.DB.Where("follower_id = ? AND followee_id = ?", userID, followeeID).Delete(&models.Follow{}); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to unfollow user"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Unfollowed"})
+}
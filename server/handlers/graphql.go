@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/labstack/echo/v4"
+)
+
+// Limits of a GraphQL query, GRAPHQL_MAX_DEPTH and GRAPHQL_MAX_COMPLEXITY override them.
+// The complexity counts every field, multiplied by the size of the lists it is in.
+var (
+	graphMaxDepth      = 8
+	graphMaxComplexity = 1000
+)
+
+// graphDefaultListSize is the size of a list field without a first or pageSize argument
+const graphDefaultListSize = 20
+
+func init() {
+	if depth, err := strconv.Atoi(os.Getenv("GRAPHQL_MAX_DEPTH")); err == nil && depth > 0 {
+		graphMaxDepth = depth
+	}
+	if complexity, err := strconv.Atoi(os.Getenv("GRAPHQL_MAX_COMPLEXITY")); err == nil && complexity > 0 {
+		graphMaxComplexity = complexity
+	}
+}
+
+// graphContext is the state of one GraphQL request, available to the resolvers
+type graphContext struct {
+	service  Service
+	viewerID uint
+	loaders  *graphLoaders
+}
+
+type graphContextKey struct{}
+
+func graphContextOf(ctx context.Context) *graphContext {
+	return ctx.Value(graphContextKey{}).(*graphContext)
+}
+
+// graphError is an error of a resolver, its HTTP status is reported in the extensions of the error
+type graphError struct {
+	status  int
+	message string
+}
+
+func (e graphError) Error() string {
+	return e.message
+}
+
+func (e graphError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":   strings.ToUpper(strings.ReplaceAll(http.StatusText(e.status), " ", "_")),
+		"status": e.status,
+	}
+}
+
+var _ gqlerrors.ExtendedError = graphError{}
+
+// GraphQL godoc
+// @Summary GraphQL endpoint
+// @Description Query users, posts and comments with their relations, or create and edit them with mutations.
+// @Description Without a token only public content can be queried. Mutations need a valid token and go through
+// @Description the same validation, content filter and notifications as the REST routes; their errors carry the
+// @Description HTTP status of the REST route in extensions.status. Queries deeper than GRAPHQL_MAX_DEPTH (8) or more
+// @Description complex than GRAPHQL_MAX_COMPLEXITY (1000, every field multiplied by the size of its lists) are rejected
+// @Tags GraphQL
+// @Accept json
+// @Produce json
+// @Param query body models.GraphQLRequest true "GraphQL query"
+// @Success 200 {object} map[string]interface{} "Data and field errors"
+// @Failure 400 {object} map[string]interface{} "Invalid query or query over the limits"
+// @Router /api/v1/graphql [post]
+func GraphQL(c echo.Context) error {
+	request := new(models.GraphQLRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(request.Query), Name: "GraphQL request"})})
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+	}
+	if validation := graphql.ValidateDocument(&graphSchema, document, nil); !validation.IsValid {
+		return c.JSON(http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+	}
+	if err := checkGraphLimits(document, request.OperationName, request.Variables); err != nil {
+		return c.JSON(http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+	}
+
+	viewerID, _ := helpers.CurrentUserID(c)
+	state := &graphContext{service: ServiceFor(c), viewerID: viewerID, loaders: newGraphLoaders(config.DB, viewerID)}
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        graphSchema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       context.WithValue(c.Request().Context(), graphContextKey{}, state),
+	})
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// checkGraphLimits rejects an operation nested deeper or more complex than the limits, before anything is resolved.
+// The document is already validated, so its fragments exist and do not form cycles.
+func checkGraphLimits(document *ast.Document, operationName string, variables map[string]interface{}) error {
+	fragments := map[string]*ast.FragmentDefinition{}
+	var operation *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operation == nil || (definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+	if operation == nil {
+		return nil
+	}
+
+	limits := graphLimits{fragments: fragments, variables: variables}
+	depth, complexity := limits.measure(operation.SelectionSet, 1)
+	if depth > graphMaxDepth {
+		return graphError{status: http.StatusBadRequest, message: fmt.Sprintf("Query is nested %d levels deep, the limit is %d", depth, graphMaxDepth)}
+	}
+	if complexity > graphMaxComplexity {
+		return graphError{status: http.StatusBadRequest, message: fmt.Sprintf("Query has a complexity of %d, the limit is %d", complexity, graphMaxComplexity)}
+	}
+	return nil
+}
+
+type graphLimits struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// measure returns the depth and the complexity of the fields selected at the given depth. Introspection fields are not counted.
+func (l graphLimits) measure(selections *ast.SelectionSet, depth int) (int, int) {
+	if selections == nil {
+		return depth - 1, 0
+	}
+	deepest, complexity := depth, 0
+	for _, selection := range selections.Selections {
+		var nestedDepth, nestedComplexity int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			nestedDepth, nestedComplexity = l.measure(selection.SelectionSet, depth+1)
+			nestedComplexity = 1 + l.listSize(selection)*nestedComplexity
+		case *ast.InlineFragment:
+			nestedDepth, nestedComplexity = l.measure(selection.SelectionSet, depth)
+		case *ast.FragmentSpread:
+			if fragment, ok := l.fragments[selection.Name.Value]; ok {
+				nestedDepth, nestedComplexity = l.measure(fragment.SelectionSet, depth)
+			}
+		}
+		if nestedDepth > deepest {
+			deepest = nestedDepth
+		}
+		complexity += nestedComplexity
+	}
+	return deepest, complexity
+}
+
+// listSize returns how many items a field returns at most: its first or pageSize argument, the default size of lists
+// for the list fields without one, and 1 for the other fields
+func (l graphLimits) listSize(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "first" && argument.Name.Value != "pageSize" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if size, err := strconv.Atoi(value.Value); err == nil && size > 0 {
+				return size
+			}
+		case *ast.Variable:
+			if size, ok := l.variables[value.Name.Value].(float64); ok && size > 0 {
+				return int(size)
+			}
+		}
+		return graphDefaultListSize
+	}
+	if graphListFields[field.Name.Value] {
+		return graphDefaultListSize
+	}
+	return 1
+}
+
+// mutationService returns the Service of the viewer for a mutation, mutations need an active account like the restricted REST routes
+func (g *graphContext) mutationService() (Service, error) {
+	service := g.service
+	if err := service.Authorize(true, ""); err != nil {
+		return service, graphErrorOf(err)
+	}
+	return service, nil
+}
+
+// graphErrorOf returns the error of a Service call with its HTTP status
+func graphErrorOf(err error) error {
+	var serviceError *ServiceError
+	if errors.As(err, &serviceError) {
+		return graphError{status: serviceError.Status, message: serviceError.Message}
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"server/dataloader"
+	"server/models"
+
+	"gorm.io/gorm"
+)
+
+// graphListKey asks for the first items of a list owned by ID, such as the latest posts of a user
+type graphListKey struct {
+	ID    uint
+	First int
+}
+
+// graphLoaders batch the lookups of one GraphQL request, so a list of N posts with their authors, comments
+// and reactions costs one query per relation instead of N. Every lookup applies the visibility rules of the viewer.
+type graphLoaders struct {
+	users         *dataloader.Loader[uint, models.User]
+	posts         *dataloader.Loader[uint, models.Post]
+	userPosts     *dataloader.Loader[graphListKey, []models.Post]
+	postComments  *dataloader.Loader[graphListKey, []models.Comment]
+	commentCounts *dataloader.Loader[uint, int64]
+	reactions     *dataloader.Loader[uint, models.ReactionSummaryResponse]
+}
+
+func newGraphLoaders(db *gorm.DB, viewerID uint) *graphLoaders {
+	return &graphLoaders{
+		users: dataloader.New(func(ids []uint) (map[uint]models.User, error) {
+			var users []models.User
+			if err := db.Where("user_id IN ? AND status NOT IN ?", ids, unlistedAccountStatuses).Find(&users).Error; err != nil {
+				return nil, err
+			}
+			byID := make(map[uint]models.User, len(users))
+			for _, user := range users {
+				byID[user.UserID] = user
+			}
+			return byID, nil
+		}),
+
+		// Like findVisiblePost: the own drafts and scheduled posts of the viewer are included
+		posts: dataloader.New(func(ids []uint) (map[uint]models.Post, error) {
+			var posts []models.Post
+			query := db.Model(&models.Post{}).Where("posts.post_id IN ? AND posts.hidden_at IS NULL", ids)
+			if err := visiblePosts(query, viewerID).Find(&posts).Error; err != nil {
+				return nil, err
+			}
+			byID := make(map[uint]models.Post, len(posts))
+			for _, post := range posts {
+				byID[post.PostID] = post
+			}
+			return byID, nil
+		}),
+
+		// Like the feed: no drafts or scheduled posts, nothing by authors the viewer blocked or muted
+		userPosts: dataloader.New(func(keys []graphListKey) (map[graphListKey][]models.Post, error) {
+			lists := make(map[graphListKey][]models.Post, len(keys))
+			for first, ids := range groupListKeys(keys) {
+				var posts []models.Post
+				ranked := db.Model(&models.Post{}).
+					Select("posts.*, ROW_NUMBER() OVER (PARTITION BY posts.user_id ORDER BY posts.created_at DESC, posts.post_id DESC) AS position").
+					Where("posts.user_id IN ? AND posts.hidden_at IS NULL", ids)
+				ranked = excludeHiddenAuthors(feedPosts(ranked, viewerID), "posts.user_id", viewerID)
+				if err := db.Table("(?) AS ranked", ranked).Where("position <= ?", first).Order("user_id, position").Find(&posts).Error; err != nil {
+					return nil, err
+				}
+				for _, id := range ids {
+					lists[graphListKey{ID: id, First: first}] = []models.Post{}
+				}
+				for _, post := range posts {
+					key := graphListKey{ID: post.UserID, First: first}
+					lists[key] = append(lists[key], post)
+				}
+			}
+			return lists, nil
+		}),
+
+		// Like GetComments, the post itself was checked by the resolver that loaded it
+		postComments: dataloader.New(func(keys []graphListKey) (map[graphListKey][]models.Comment, error) {
+			lists := make(map[graphListKey][]models.Comment, len(keys))
+			for first, ids := range groupListKeys(keys) {
+				var comments []models.Comment
+				ranked := db.Table("comments").
+					Select("comments.comment_id, comments.post_id, comments.author_id, comments.comment_msg, comments.created_at, comments.updated_at, comments.hidden_at, "+
+						"ROW_NUMBER() OVER (PARTITION BY comments.post_id ORDER BY comments.created_at, comments.comment_id) AS position").
+					Where("comments.post_id IN ? AND comments.hidden_at IS NULL", ids)
+				ranked = excludeHiddenAuthors(ranked, "comments.author_id", viewerID)
+				if err := db.Table("(?) AS ranked", ranked).Where("position <= ?", first).Order("post_id, position").Scan(&comments).Error; err != nil {
+					return nil, err
+				}
+				for _, id := range ids {
+					lists[graphListKey{ID: id, First: first}] = []models.Comment{}
+				}
+				for _, comment := range comments {
+					key := graphListKey{ID: comment.PostID, First: first}
+					lists[key] = append(lists[key], comment)
+				}
+			}
+			return lists, nil
+		}),
+
+		commentCounts: dataloader.New(func(ids []uint) (map[uint]int64, error) {
+			var rows []struct {
+				PostID uint
+				Total  int64
+			}
+			query := db.Table("comments").Select("comments.post_id, COUNT(*) AS total").
+				Where("comments.post_id IN ? AND comments.hidden_at IS NULL", ids)
+			if err := excludeHiddenAuthors(query, "comments.author_id", viewerID).Group("comments.post_id").Scan(&rows).Error; err != nil {
+				return nil, err
+			}
+			counts := make(map[uint]int64, len(ids))
+			for _, id := range ids {
+				counts[id] = 0
+			}
+			for _, row := range rows {
+				counts[row.PostID] = row.Total
+			}
+			return counts, nil
+		}),
+
+		reactions: dataloader.New(func(ids []uint) (map[uint]models.ReactionSummaryResponse, error) {
+			counts, mine, err := loadReactionSummaries(db, postReactionTables, ids, viewerID)
+			if err != nil {
+				return nil, err
+			}
+			summaries := make(map[uint]models.ReactionSummaryResponse, len(ids))
+			for _, id := range ids {
+				summaries[id] = models.ReactionSummaryResponse{Reactions: counts[id], MyReactions: mine[id]}
+			}
+			return summaries, nil
+		}),
+	}
+}
+
+// groupListKeys groups the owner IDs by list length, each length is one query
+func groupListKeys(keys []graphListKey) map[int][]uint {
+	groups := map[int][]uint{}
+	for _, key := range keys {
+		groups[key.First] = append(groups[key.First], key.ID)
+	}
+	return groups
+}
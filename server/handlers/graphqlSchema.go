@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"server/config"
+	"server/models"
+	"sort"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// graphSchema is the schema of the GraphQL endpoint, users, posts and comments with their relations
+var graphSchema graphql.Schema
+
+// graphListFields are the list fields taking a page size, see graphLimits
+var graphListFields = map[string]bool{"posts": true, "comments": true}
+
+// graphMaxListSize is the largest page of a list field, like the REST pagination
+const graphMaxListSize = 50
+
+func init() {
+	schema, err := newGraphSchema()
+	if err != nil {
+		log.Fatalf("Invalid GraphQL schema: %v", err)
+	}
+	graphSchema = schema
+}
+
+// graphReactionCount is how many times a post got a reaction type
+type graphReactionCount struct {
+	Type  string
+	Count int64
+}
+
+func newGraphSchema() (graphql.Schema, error) {
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "User",
+		Description: "Public profile of a user, the email and admin flag are never exposed",
+		Fields: graphql.Fields{
+			"uid":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: graphField(func(user models.User) interface{} { return user.UserID })},
+			"username":    &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: graphField(func(user models.User) interface{} { return user.Username })},
+			"firstname":   &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: graphField(func(user models.User) interface{} { return user.Firstname })},
+			"surname":     &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: graphField(func(user models.User) interface{} { return user.Surname })},
+			"displayName": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: graphField(func(user models.User) interface{} { return user.DisplayName })},
+			"bio":         &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: graphField(func(user models.User) interface{} { return user.Bio })},
+			"location":    &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: graphField(func(user models.User) interface{} { return user.Location })},
+			"website":     &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: graphField(func(user models.User) interface{} { return user.Website })},
+			"createdAt":   &graphql.Field{Type: graphql.DateTime, Resolve: graphField(func(user models.User) interface{} { return user.CreatedAt })},
+			"avatarUrl": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Versioned avatar URL, null when the user has no avatar",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					user, ok := p.Source.(models.User)
+					if !ok || user.AvatarKey == "" {
+						return nil, nil
+					}
+					return avatarURL(graphContextOf(p.Context).service.BaseURL, user), nil
+				},
+			},
+		},
+	})
+
+	reactionCountType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ReactionCount",
+		Fields: graphql.Fields{
+			"type":  &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: graphField(func(reaction graphReactionCount) interface{} { return reaction.Type })},
+			"count": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: graphField(func(reaction graphReactionCount) interface{} { return reaction.Count })},
+		},
+	})
+
+	postType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Post",
+		Description: "A post the viewer may read",
+		Fields: graphql.Fields{
+			"id":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: graphField(func(post models.Post) interface{} { return post.PostID })},
+			"message":    &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: graphField(func(post models.Post) interface{} { return post.Message })},
+			"visibility": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: graphField(func(post models.Post) interface{} { return post.Visibility })},
+			"publishAt":  &graphql.Field{Type: graphql.DateTime, Description: "Only set on scheduled posts", Resolve: graphField(func(post models.Post) interface{} { return post.PublishAt })},
+			"createdAt":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: graphField(func(post models.Post) interface{} { return post.CreatedAt })},
+			"updatedAt":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: graphField(func(post models.Post) interface{} { return post.UpdatedAt })},
+			"hidden":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Description: "Held by the content filter until a moderator reviews it", Resolve: graphField(func(post models.Post) interface{} { return post.HiddenAt != nil })},
+			"commentCount": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Comments the viewer can read",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					post, _ := p.Source.(models.Post)
+					return graphLoad(graphContextOf(p.Context).loaders.commentCounts.Load(post.PostID)), nil
+				},
+			},
+			"reactions": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(reactionCountType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					post, _ := p.Source.(models.Post)
+					load := graphLoad(graphContextOf(p.Context).loaders.reactions.Load(post.PostID))
+					return func() (interface{}, error) {
+						summary, err := load()
+						if err != nil || summary == nil {
+							return []graphReactionCount{}, err
+						}
+						counts := []graphReactionCount{}
+						for reaction, count := range summary.(models.ReactionSummaryResponse).Reactions {
+							counts = append(counts, graphReactionCount{Type: reaction, Count: count})
+						}
+						sort.Slice(counts, func(i, j int) bool { return counts[i].Type < counts[j].Type })
+						return counts, nil
+					}, nil
+				},
+			},
+			"myReactions": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Description: "Reactions of the viewer, empty for anonymous viewers",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					post, _ := p.Source.(models.Post)
+					load := graphLoad(graphContextOf(p.Context).loaders.reactions.Load(post.PostID))
+					return func() (interface{}, error) {
+						summary, err := load()
+						if err != nil || summary == nil || summary.(models.ReactionSummaryResponse).MyReactions == nil {
+							return []string{}, err
+						}
+						return summary.(models.ReactionSummaryResponse).MyReactions, nil
+					}, nil
+				},
+			},
+		},
+	})
+
+	commentType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Comment",
+		Description: "A comment on a post",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: graphField(func(comment models.Comment) interface{} { return comment.CommentID })},
+			"message":   &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: graphField(func(comment models.Comment) interface{} { return comment.CommentMSG })},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: graphField(func(comment models.Comment) interface{} { return comment.CreatedAt })},
+			"updatedAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: graphField(func(comment models.Comment) interface{} { return comment.UpdatedAt })},
+			"hidden":    &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Description: "Held by the content filter until a moderator reviews it", Resolve: graphField(func(comment models.Comment) interface{} { return comment.HiddenAt != nil })},
+			"author": &graphql.Field{
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					comment, _ := p.Source.(models.Comment)
+					return graphLoad(graphContextOf(p.Context).loaders.users.Load(comment.AuthorID)), nil
+				},
+			},
+			"post": &graphql.Field{
+				Type: postType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					comment, _ := p.Source.(models.Comment)
+					return graphLoad(graphContextOf(p.Context).loaders.posts.Load(comment.PostID)), nil
+				},
+			},
+		},
+	})
+
+	// The relations between the types, added once every type exists
+	userType.AddFieldConfig("posts", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postType))),
+		Description: "Latest published posts of the user that the viewer may read",
+		Args:        graphql.FieldConfigArgument{"first": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 10}},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			user, _ := p.Source.(models.User)
+			first, err := graphListSize(p.Args, "first")
+			if err != nil {
+				return nil, err
+			}
+			return graphLoad(graphContextOf(p.Context).loaders.userPosts.Load(graphListKey{ID: user.UserID, First: first})), nil
+		},
+	})
+	postType.AddFieldConfig("author", &graphql.Field{
+		Type: userType,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			post, _ := p.Source.(models.Post)
+			return graphLoad(graphContextOf(p.Context).loaders.users.Load(post.UserID)), nil
+		},
+	})
+	postType.AddFieldConfig("comments", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(commentType))),
+		Description: "Oldest comments first, without the comments by users the viewer blocked or muted",
+		Args:        graphql.FieldConfigArgument{"first": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: graphDefaultListSize}},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			post, _ := p.Source.(models.Post)
+			first, err := graphListSize(p.Args, "first")
+			if err != nil {
+				return nil, err
+			}
+			return graphLoad(graphContextOf(p.Context).loaders.postComments.Load(graphListKey{ID: post.PostID, First: first})), nil
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    graphQueryType(userType, postType),
+		Mutation: graphMutationType(userType, postType, commentType),
+	})
+}
+
+func graphQueryType(userType *graphql.Object, postType *graphql.Object) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"viewer": &graphql.Field{
+				Type:        userType,
+				Description: "The authenticated user, null without a token",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					g := graphContextOf(p.Context)
+					if g.viewerID == 0 {
+						return nil, nil
+					}
+					return graphLoad(g.loaders.users.Load(g.viewerID)), nil
+				},
+			},
+			"user": &graphql.Field{
+				Type:        userType,
+				Description: "A user by uid or username",
+				Args: graphql.FieldConfigArgument{
+					"uid":      &graphql.ArgumentConfig{Type: graphql.Int},
+					"username": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					g := graphContextOf(p.Context)
+					if uid, ok := p.Args["uid"].(int); ok {
+						return graphLoad(g.loaders.users.Load(uint(uid))), nil
+					}
+					username, ok := p.Args["username"].(string)
+					if !ok {
+						return nil, graphError{status: http.StatusBadRequest, message: "uid or username is required"}
+					}
+					var user models.User
+					result := config.DB.Where("username = ? AND status NOT IN ?", username, unlistedAccountStatuses).Limit(1).Find(&user)
+					if result.Error != nil {
+						return nil, graphError{status: http.StatusInternalServerError, message: "Failed to get user"}
+					} else if result.RowsAffected == 0 {
+						return nil, nil
+					}
+					g.loaders.users.Prime(user.UserID, user)
+					return user, nil
+				},
+			},
+			"post": &graphql.Field{
+				Type:        postType,
+				Description: "A post by ID, the own drafts and scheduled posts of the viewer included",
+				Args:        graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, _ := p.Args["id"].(int)
+					return graphLoad(graphContextOf(p.Context).loaders.posts.Load(uint(id))), nil
+				},
+			},
+			"posts": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postType))),
+				Description: "The feed of the viewer, latest first. Posts by users the viewer blocked or muted are left out",
+				Args: graphql.FieldConfigArgument{
+					"page":     &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+					"pageSize": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: graphDefaultListSize},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					g := graphContextOf(p.Context)
+					page, _ := p.Args["page"].(int)
+					if page < 1 {
+						return nil, graphError{status: http.StatusBadRequest, message: "page must be at least 1"}
+					}
+					pageSize, err := graphListSize(p.Args, "pageSize")
+					if err != nil {
+						return nil, err
+					}
+
+					var posts []models.Post
+					query := feedPosts(config.DB.Model(&models.Post{}).Where("posts.hidden_at IS NULL"), g.viewerID)
+					query = excludeHiddenAuthors(query, "posts.user_id", g.viewerID)
+					if err := query.Order("posts.created_at DESC, posts.post_id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&posts).Error; err != nil {
+						return nil, graphError{status: http.StatusInternalServerError, message: "Failed to get posts"}
+					}
+					for _, post := range posts {
+						g.loaders.posts.Prime(post.PostID, post)
+					}
+					return posts, nil
+				},
+			},
+		},
+	})
+}
+
+// graphMutationType runs the operations of Service like the REST routes. Mutations need a token of an active account.
+func graphMutationType(userType *graphql.Object, postType *graphql.Object, commentType *graphql.Object) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createPost": &graphql.Field{
+				Type:        postType,
+				Description: "Create a post like POST /api/v1/restricted/posts, visibility defaults to public and publishAt schedules it",
+				Args: graphql.FieldConfigArgument{
+					"message":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"visibility": &graphql.ArgumentConfig{Type: graphql.String},
+					"publishAt":  &graphql.ArgumentConfig{Type: graphql.DateTime},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					service, err := graphContextOf(p.Context).mutationService()
+					if err != nil {
+						return nil, err
+					}
+					request := models.CreatePostRequest{Message: p.Args["message"].(string)}
+					request.Visibility, _ = p.Args["visibility"].(string)
+					if publishAt, ok := p.Args["publishAt"].(time.Time); ok {
+						request.PublishAt = &publishAt
+					}
+					post, err := service.CreatePost(request)
+					if err != nil {
+						return nil, graphErrorOf(err)
+					}
+					return post, nil
+				},
+			},
+			"updatePost": &graphql.Field{
+				Type:        postType,
+				Description: "Edit a post like PUT /api/v1/restricted/posts/{pid}",
+				Args: graphql.FieldConfigArgument{
+					"id":         &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"message":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"visibility": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					service, err := graphContextOf(p.Context).mutationService()
+					if err != nil {
+						return nil, err
+					}
+					request := models.UpdatePostRequest{Message: p.Args["message"].(string)}
+					request.Visibility, _ = p.Args["visibility"].(string)
+					post, err := service.UpdatePost(graphID(p.Args, "id"), request)
+					if err != nil {
+						return nil, graphErrorOf(err)
+					}
+					return post, nil
+				},
+			},
+			"createComment": &graphql.Field{
+				Type:        commentType,
+				Description: "Comment on a post like POST /api/v1/restricted/comments",
+				Args: graphql.FieldConfigArgument{
+					"postId":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"message": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					service, err := graphContextOf(p.Context).mutationService()
+					if err != nil {
+						return nil, err
+					}
+					comment, err := service.CreateComment(models.CreateCommentRequest{PostID: graphID(p.Args, "postId"), CommentMSG: p.Args["message"].(string)})
+					if err != nil {
+						return nil, graphErrorOf(err)
+					}
+					return comment, nil
+				},
+			},
+			"updateComment": &graphql.Field{
+				Type:        commentType,
+				Description: "Edit a comment like PUT /api/v1/restricted/comments/{cid}",
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"message": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					service, err := graphContextOf(p.Context).mutationService()
+					if err != nil {
+						return nil, err
+					}
+					comment, err := service.UpdateComment(graphID(p.Args, "id"), models.UpdateCommentRequest{CommentMSG: p.Args["message"].(string)})
+					if err != nil {
+						return nil, graphErrorOf(err)
+					}
+					return comment, nil
+				},
+			},
+			"updateProfile": &graphql.Field{
+				Type:        userType,
+				Description: "Replace the profile of the viewer like PUT /api/v1/restricted/profile, omitted fields are cleared",
+				Args: graphql.FieldConfigArgument{
+					"displayName": &graphql.ArgumentConfig{Type: graphql.String},
+					"bio":         &graphql.ArgumentConfig{Type: graphql.String},
+					"location":    &graphql.ArgumentConfig{Type: graphql.String},
+					"website":     &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					g := graphContextOf(p.Context)
+					service, err := g.mutationService()
+					if err != nil {
+						return nil, err
+					}
+					var request models.UpdateProfileRequest
+					request.DisplayName, _ = p.Args["displayName"].(string)
+					request.Bio, _ = p.Args["bio"].(string)
+					request.Location, _ = p.Args["location"].(string)
+					request.Website, _ = p.Args["website"].(string)
+					if _, err := service.UpdateProfile(request); err != nil {
+						return nil, graphErrorOf(err)
+					}
+					var user models.User
+					if err := config.DB.First(&user, g.viewerID).Error; err != nil {
+						return nil, graphError{status: http.StatusInternalServerError, message: "Failed to get profile"}
+					}
+					g.loaders.users.Prime(user.UserID, user)
+					return user, nil
+				},
+			},
+		},
+	})
+}
+
+// graphField resolves a field of the source with get, the source is null for anything else than a T
+func graphField[T any](get func(T) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		source, ok := p.Source.(T)
+		if !ok {
+			return nil, nil
+		}
+		return get(source), nil
+	}
+}
+
+// graphLoad turns a loader thunk into a resolver thunk, the value is null when the loader found none.
+// The executor calls thunks once it resolved a whole level of fields, so the level is loaded in one batch.
+func graphLoad[V any](load func() (V, bool, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		value, found, err := load()
+		if err != nil {
+			log.Println("Failed to load GraphQL data:", err)
+			return nil, graphError{status: http.StatusInternalServerError, message: "Failed to load data"}
+		}
+		if !found {
+			return nil, nil
+		}
+		return value, nil
+	}
+}
+
+// graphListSize returns the page size argument of a list field
+func graphListSize(args map[string]interface{}, name string) (int, error) {
+	size, _ := args[name].(int)
+	if size < 1 || size > graphMaxListSize {
+		return 0, graphError{status: http.StatusBadRequest, message: fmt.Sprintf("%s must be between 1 and %d", name, graphMaxListSize)}
+	}
+	return size, nil
+}
+
+// graphID returns an ID argument, IDs below 1 match nothing
+func graphID(args map[string]interface{}, name string) uint {
+	if id, ok := args[name].(int); ok && id > 0 {
+		return uint(id)
+	}
+	return 0
+}
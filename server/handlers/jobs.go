@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/jobs"
+	"server/models"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Job types of the background queue
+const (
+	// JobExport builds the archive of a personal data export
+	JobExport = "export.build"
+	// JobWebhookDelivery sends one event to one webhook endpoint
+	JobWebhookDelivery = "webhook.deliver"
+)
+
+// RegisterJobs registers the handlers of every job type with the queue
+func RegisterJobs(queue *jobs.Queue, db *gorm.DB) {
+	// Archives are built in memory, a couple at a time is enough
+	queue.Register(JobExport, runExportJob(db), jobs.Options{Concurrency: 2, MaxAttempts: 3})
+	// Deliveries wait on remote receivers that may be down for a while, so they get more attempts
+	queue.Register(JobWebhookDelivery, runWebhookDelivery(db), jobs.Options{Concurrency: 4, MaxAttempts: 8, Timeout: 30 * time.Second})
+}
+
+// GetJobs godoc
+// @Summary List background jobs
+// @Description List the jobs of the background queue, newest first. Dead jobs ran out of attempts and can be retried
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Param status query string false "pending, running, succeeded or dead"
+// @Param type query string false "Job type, such as export.build"
+// @Param page query int false "Page number, starting at 1"
+// @Param page_size query int false "Jobs per page (max 50, default 20)"
+// @Success 200 {array} models.JobResponse "Jobs"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to get jobs"
+// @Router /api/v1/admin/jobs [get]
+func GetJobs(c echo.Context) error {
+	request := new(models.GetJobsRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	query := config.DB.Model(&models.Job{})
+	if request.Status != "" {
+		query = query.Where("status = ?", request.Status)
+	}
+	if request.Type != "" {
+		query = query.Where("type = ?", request.Type)
+	}
+
+	page, pageSize := pageAndSize(request.PaginationRequest)
+	var queued []models.Job
+	if result := query.Order("created_at DESC, job_id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Find(&queued); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get jobs"})
+	}
+
+	responses := make([]models.JobResponse, len(queued))
+	for i, job := range queued {
+		responses[i] = jobResponse(job)
+	}
+	return c.JSON(http.StatusOK, responses)
+}
+
+// GetJob godoc
+// @Summary Get a background job
+// @Description Get a job of the background queue with its payload, attempts and last error
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Param jid path int true "Job ID"
+// @Success 200 {object} models.JobResponse "Job"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Job not found"
+// @Router /api/v1/admin/jobs/{jid} [get]
+func GetJob(c echo.Context) error {
+	jobID, err := strconv.Atoi(c.Param("jid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	var job models.Job
+	if result := config.DB.First(&job, jobID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Job not found"})
+	}
+
+	return c.JSON(http.StatusOK, jobResponse(job))
+}
+
+// RetryJob godoc
+// @Summary Retry a dead background job
+// @Description Put a dead job back in the queue with a fresh set of attempts
+// @Tags Jobs
+// @Accept json
+// @Produce json
+// @Param jid path int true "Job ID"
+// @Success 200 {object} models.JobResponse "Job, pending again"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Job not found"
+// @Failure 409 {object} map[string]string "Only dead jobs can be retried"
+// @Failure 500 {object} map[string]string "Failed to retry job"
+// @Router /api/v1/admin/jobs/{jid}/retry [post]
+func RetryJob(c echo.Context) error {
+	jobID, err := strconv.Atoi(c.Param("jid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	if err := jobs.Retry(config.DB, uint(jobID)); errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Job not found"})
+	} else if errors.Is(err, jobs.ErrNotRetryable) {
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Only dead jobs can be retried"})
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to retry job"})
+	}
+
+	var job models.Job
+	if result := config.DB.First(&job, jobID); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to retry job"})
+	}
+	entry := auditEntry(c, AuditJobRetry, "job", job.JobID)
+	entry.Details = job.Type
+	audit(entry)
+
+	return c.JSON(http.StatusOK, jobResponse(job))
+}
+
+func jobResponse(job models.Job) models.JobResponse {
+	response := models.JobResponse{
+		JobID:       job.JobID,
+		Type:        job.Type,
+		Status:      job.Status,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt,
+		LockedBy:    job.LockedBy,
+		LockedUntil: job.LockedUntil,
+		LastError:   job.LastError,
+		CompletedAt: job.CompletedAt,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+	}
+	if json.Valid([]byte(job.Payload)) {
+		response.Payload = json.RawMessage(job.Payload)
+	}
+	return response
+}
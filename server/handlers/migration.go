@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"fmt"
+	"net/http"
 	"server/config"
 
 	"github.com/labstack/echo/v4"
@@ -19,7 +21,15 @@ import (
 // @Failure 500 {object} map[string]string "Error running migration"
 // @Router /api/v1/admin/run-migrations [post]
 func RunMigration(c echo.Context) error {
-	return config.RunMigration(c)
+	err := config.RunMigration(c)
+
+	// Every attempt is audited, including failed ones
+	if migrationID, ok := c.Get("migrationID").(string); ok {
+		entry := auditEntry(c, AuditMigrationRun, "migration", migrationID)
+		entry.Details = fmt.Sprintf("%d %s", c.Response().Status, http.StatusText(c.Response().Status))
+		audit(entry)
+	}
+	return err
 }
 
 // GetMigration godoc
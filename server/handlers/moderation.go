@@ -0,0 +1,594 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"server/search"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Report statuses
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+// Report target types
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetUser    = "user"
+)
+
+// ReportReasonFilter is the reason of the reports the content filter opens for the content it holds
+const ReportReasonFilter = "filter"
+
+const maxReportPreviewLength = 140
+
+var errReportTargetNotFound = errors.New("reported content not found")
+
+// CreateReport godoc
+// @Summary Report a post, comment or user
+// @Description Flag content for the moderators. A user can only have one open report per target
+// @Tags Moderation
+// @Accept json
+// @Produce json
+// @Param report body models.CreateReportRequest true "Report"
+// @Success 201 {object} models.ReportResponse "Report submitted"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Reported content not found"
+// @Failure 409 {object} map[string]string "Already reported"
+// @Failure 500 {object} map[string]string "Failed to submit report"
+// @Router /api/v1/restricted/reports [post]
+func CreateReport(c echo.Context) error {
+	request := new(models.CreateReportRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	if request.TargetType == ReportTargetUser && request.TargetID == userID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "You cannot report yourself"})
+	}
+
+	_, err := reportTargetUserID(config.DB, request.TargetType, request.TargetID)
+	if err == nil {
+		err = checkReportTargetVisible(config.DB, request.TargetType, request.TargetID, userID)
+	}
+	if err != nil {
+		if errors.Is(err, errReportTargetNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Reported content not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to submit report"})
+	}
+
+	var existing int64
+	if result := config.DB.Model(&models.Report{}).Where("reporter_id = ? AND target_type = ? AND target_id = ? AND status = ?", userID, request.TargetType, request.TargetID, ReportOpen).Count(&existing); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to submit report"})
+	}
+	if existing > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"message": "You already reported this"})
+	}
+
+	report := models.Report{
+		ReporterID: &userID,
+		TargetType: request.TargetType,
+		TargetID:   request.TargetID,
+		Reason:     request.Reason,
+		Details:    request.Details,
+		Status:     ReportOpen,
+	}
+	if result := config.DB.Create(&report); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to submit report"})
+	}
+
+	response, err := loadReport(report.ReportID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to submit report"})
+	}
+
+	return c.JSON(http.StatusCreated, response)
+}
+
+// GetReports godoc
+// @Summary List reports (moderation queue)
+// @Description List reports, oldest first for open reports and newest first otherwise, with a preview of the reported content
+// @Tags Moderation
+// @Accept json
+// @Produce json
+// @Param status query string false "open (default), resolved, dismissed or all"
+// @Param target_type query string false "post, comment or user"
+// @Param reason query string false "Report reason"
+// @Param assigned_to query string false "Moderator the report is assigned to, - for unassigned reports"
+// @Param page query int false "Page number, starting at 1"
+// @Param page_size query int false "Reports per page (max 50, default 20)"
+// @Success 200 {array} models.ReportResponse "Reports"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to get reports"
+// @Router /api/v1/admin/reports [get]
+func GetReports(c echo.Context) error {
+	request := new(models.GetReportsRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+	page, pageSize := pageAndSize(request.PaginationRequest)
+
+	status := request.Status
+	if status == "" {
+		status = ReportOpen
+	}
+
+	query := reportQuery(config.DB)
+	if status != "all" {
+		query = query.Where("reports.status = ?", status)
+	}
+	if request.TargetType != "" {
+		query = query.Where("reports.target_type = ?", request.TargetType)
+	}
+	if request.Reason != "" {
+		query = query.Where("reports.reason = ?", request.Reason)
+	}
+	if request.AssignedTo == "-" {
+		query = query.Where("reports.assigned_to = ''")
+	} else if request.AssignedTo != "" {
+		query = query.Where("reports.assigned_to = ?", request.AssignedTo)
+	}
+	if status == ReportOpen {
+		query = query.Order("reports.created_at ASC, reports.report_id ASC")
+	} else {
+		query = query.Order("reports.created_at DESC, reports.report_id DESC")
+	}
+
+	reports := []models.ReportResponse{}
+	if result := query.Limit(pageSize).Offset((page - 1) * pageSize).Scan(&reports); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get reports"})
+	}
+	if err := fillReportPreviews(config.DB, reports); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get reports"})
+	}
+
+	return c.JSON(http.StatusOK, reports)
+}
+
+// AssignReport godoc
+// @Summary Assign a report to a moderator
+// @Description Assign a report to a moderator, an empty assigned_to unassigns it
+// @Tags Moderation
+// @Accept json
+// @Produce json
+// @Param rid path int true "Report ID"
+// @Param assignment body models.AssignReportRequest true "Moderator"
+// @Success 200 {object} models.ReportResponse "Updated report"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Report not found"
+// @Failure 500 {object} map[string]string "Failed to assign report"
+// @Router /api/v1/admin/reports/{rid}/assign [put]
+func AssignReport(c echo.Context) error {
+	request := new(models.AssignReportRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	reportID, err := strconv.Atoi(c.Param("rid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	var report models.Report
+	if result := config.DB.First(&report, reportID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Report not found"})
+	}
+
+	before := report
+	report.AssignedTo = request.AssignedTo
+	if result := config.DB.Model(&report).Update("assigned_to", request.AssignedTo); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to assign report"})
+	}
+	entry := auditEntry(c, AuditReportAssign, "report", report.ReportID)
+	entry.Changes = auditChanges(before, report)
+	audit(entry)
+
+	response, err := loadReport(report.ReportID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to assign report"})
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// ActOnReport godoc
+// @Summary Act on a report
+// @Description Apply a moderator decision: hide, unhide or delete the reported post or comment, warn or suspend its author
+// @Description (or the reported user), or dismiss the report. The decision is recorded with its reason, and every open
+// @Description report on the same target is closed with it. Unhiding content the filter held publishes it like new content
+// @Tags Moderation
+// @Accept json
+// @Produce json
+// @Param rid path int true "Report ID"
+// @Param action body models.ModerationActionRequest true "Decision"
+// @Success 200 {object} models.ReportResponse "Closed report"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Report or reported content not found"
+// @Failure 409 {object} map[string]string "Report already closed"
+// @Failure 500 {object} map[string]string "Failed to apply action"
+// @Router /api/v1/admin/reports/{rid}/actions [post]
+func ActOnReport(c echo.Context) error {
+	request := new(models.ModerationActionRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	reportID, err := strconv.Atoi(c.Param("rid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	var report models.Report
+	if result := config.DB.First(&report, reportID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Report not found"})
+	}
+	if report.Status != ReportOpen {
+		return c.JSON(http.StatusConflict, map[string]string{"message": "Report is already closed"})
+	}
+
+	contentAction := request.Action == "hide" || request.Action == "unhide" || request.Action == "delete"
+	if contentAction && report.TargetType == ReportTargetUser {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Only posts and comments can be hidden or deleted"})
+	}
+	if request.Action == "suspend" && request.SuspendHours == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "suspend_hours is required to suspend a user"})
+	}
+
+	var targetUserID *uint
+	if request.Action != "dismiss" {
+		userID, err := reportTargetUserID(config.DB, report.TargetType, report.TargetID)
+		if errors.Is(err, errReportTargetNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Reported content no longer exists"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to apply action"})
+		}
+		targetUserID = &userID
+	}
+
+	now := time.Now()
+	action := models.ModerationAction{
+		ReportID:   &report.ReportID,
+		Moderator:  moderatorName(c),
+		Action:     request.Action,
+		TargetType: report.TargetType,
+		TargetID:   report.TargetID,
+		UserID:     targetUserID,
+		Reason:     request.Reason,
+	}
+	if request.Action == "suspend" {
+		until := now.Add(time.Duration(request.SuspendHours) * time.Hour)
+		action.SuspendedUntil = &until
+	}
+
+	entry := auditEntry(c, AuditReportAction, "report", report.ReportID)
+	entry.Details = request.Action + ": " + request.Reason
+	// The comments of a deleted post go with it through the foreign key, they have to leave the search index as well
+	var deletedComments []uint
+	// Approving content the filter held publishes it, it was never announced when it was written
+	var held int64
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if action.Action == "unhide" {
+			if err := tx.Model(&models.Report{}).Where("target_type = ? AND target_id = ? AND status = ? AND reason = ?", report.TargetType, report.TargetID, ReportOpen, ReportReasonFilter).Count(&held).Error; err != nil {
+				return err
+			}
+		}
+		if action.Action == "delete" && action.TargetType == ReportTargetPost {
+			if err := tx.Model(&models.Comment{}).Where("post_id = ?", action.TargetID).Pluck("comment_id", &deletedComments).Error; err != nil {
+				return err
+			}
+		}
+		if err := applyModerationAction(tx, action, now); err != nil {
+			return err
+		}
+		if err := tx.Create(&action).Error; err != nil {
+			return err
+		}
+		if err := recordAudit(tx, entry); err != nil {
+			return err
+		}
+
+		status := ReportResolved
+		if request.Action == "dismiss" {
+			status = ReportDismissed
+		}
+		return tx.Model(&models.Report{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", report.TargetType, report.TargetID, ReportOpen).
+			Updates(map[string]interface{}{"status": status, "resolved_at": now}).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to apply action"})
+	}
+	refreshSearchIndex(action, deletedComments)
+	if held > 0 {
+		announceApprovedContent(config.DB, action.TargetType, action.TargetID)
+	}
+
+	response, err := loadReport(report.ReportID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get report"})
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetWarnings godoc
+// @Summary List my warnings
+// @Description List the warnings moderators issued to the authenticated user, newest first
+// @Tags Moderation
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.WarningResponse "Warnings"
+// @Failure 500 {object} map[string]string "Failed to get warnings"
+// @Router /api/v1/restricted/warnings [get]
+func GetWarnings(c echo.Context) error {
+	userID, _ := helpers.CurrentUserID(c)
+
+	warnings := []models.WarningResponse{}
+	if result := config.DB.Model(&models.ModerationAction{}).Select("target_type, target_id, reason, created_at").
+		Where("user_id = ? AND action = ?", userID, "warn").
+		Order("created_at DESC, moderation_action_id DESC").
+		Scan(&warnings); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get warnings"})
+	}
+
+	return c.JSON(http.StatusOK, warnings)
+}
+
+// applyModerationAction changes the reported content or user. warn and dismiss only leave the audit record.
+func applyModerationAction(tx *gorm.DB, action models.ModerationAction, now time.Time) error {
+	table := "posts"
+	idColumn := "post_id"
+	if action.TargetType == ReportTargetComment {
+		table, idColumn = "comments", "comment_id"
+	}
+
+	switch action.Action {
+	case "hide":
+		return tx.Table(table).Where(idColumn+" = ?", action.TargetID).Update("hidden_at", now).Error
+	case "unhide":
+		return tx.Table(table).Where(idColumn+" = ?", action.TargetID).Update("hidden_at", nil).Error
+	case "delete":
+		return tx.Exec("DELETE FROM "+table+" WHERE "+idColumn+" = ?", action.TargetID).Error
+	case "suspend":
+		return tx.Model(&models.User{}).Where("user_id = ?", *action.UserID).Updates(map[string]interface{}{"status": AccountSuspended, "suspended_until": action.SuspendedUntil}).Error
+	}
+	return nil
+}
+
+// refreshSearchIndex keeps the in-memory search index in line with hidden and deleted content.
+// deletedComments are the comments deleted along with a post.
+func refreshSearchIndex(action models.ModerationAction, deletedComments []uint) {
+	docType := search.TypePost
+	if action.TargetType == ReportTargetComment {
+		docType = search.TypeComment
+	}
+
+	switch action.Action {
+	case "delete":
+		search.Remove(docType, action.TargetID)
+		for _, commentID := range deletedComments {
+			search.Remove(search.TypeComment, commentID)
+		}
+	case "hide", "unhide":
+		// Indexing drops hidden content, and the comments of a hidden post with it
+		if docType == search.TypePost {
+			var post models.Post
+			if config.DB.First(&post, action.TargetID).Error == nil {
+				indexPostForSearch(config.DB, post)
+			}
+		} else {
+			var comment models.Comment
+			if config.DB.First(&comment, action.TargetID).Error == nil {
+				indexCommentForSearch(config.DB, comment)
+			}
+		}
+	}
+}
+
+// announceApprovedContent runs the side effects of creating a post or comment for held content a moderator approved:
+// the notifications, and the events for streams and webhooks. Users already notified about the content are left out,
+// so approving a held edit does not notify them again.
+func announceApprovedContent(db *gorm.DB, targetType string, targetID uint) {
+	if targetType == ReportTargetPost {
+		var post models.Post
+		if err := db.First(&post, targetID).Error; err != nil {
+			log.Println("Failed to announce approved post:", err)
+			return
+		}
+		// Drafts and scheduled posts are announced when they are published
+		if post.HiddenAt != nil || post.Visibility == VisibilityDraft || post.PublishAt != nil {
+			return
+		}
+		notifyMentions(db, unnotifiedMentions(db, postEntityTables, post.PostID), post.UserID, &post.PostID, nil)
+		post.Entities = entitiesOf(db, post.Message)
+		publishPostCreated(db, post)
+		return
+	}
+
+	var comment models.Comment
+	var post models.Post
+	if err := db.First(&comment, targetID).Error; err != nil {
+		log.Println("Failed to announce approved comment:", err)
+		return
+	}
+	if err := db.First(&post, comment.PostID).Error; err != nil {
+		log.Println("Failed to announce approved comment:", err)
+		return
+	}
+	if comment.HiddenAt != nil {
+		return
+	}
+
+	mentioned := unnotifiedMentions(db, commentEntityTables, comment.CommentID)
+	var replies int64
+	if err := db.Model(&models.Notification{}).Where("type = ? AND comment_id = ?", NotificationReply, comment.CommentID).Count(&replies).Error; err != nil {
+		log.Println("Failed to announce approved comment:", err)
+		return
+	}
+	if replies == 0 {
+		notifyReply(db, post, comment, mentioned)
+	} else {
+		notifyMentions(db, mentioned, comment.AuthorID, &post.PostID, &comment.CommentID)
+	}
+	comment.Entities = entitiesOf(db, comment.CommentMSG)
+	publishCommentCreated(db, comment)
+}
+
+// unnotifiedMentions returns the users mentioned in a post or comment that have no mention notification for it yet
+func unnotifiedMentions(db *gorm.DB, tables entityTables, targetID uint) []uint {
+	notified := db.Model(&models.Notification{}).Select("user_id").Where("type = ? AND "+tables.idColumn+" = ?", NotificationMention, targetID)
+	if tables.idColumn == "post_id" {
+		notified = notified.Where("comment_id IS NULL")
+	}
+
+	var userIDs []uint
+	if err := db.Table(tables.mentions).Where(tables.idColumn+" = ? AND user_id NOT IN (?)", targetID, notified).Pluck("user_id", &userIDs).Error; err != nil {
+		log.Println("Failed to read mentions:", err)
+	}
+	return userIDs
+}
+
+// reportTargetUserID returns the user responsible for the target: the author of a post or comment, or the user itself
+func reportTargetUserID(db *gorm.DB, targetType string, targetID uint) (uint, error) {
+	var userIDs []uint
+	var err error
+	switch targetType {
+	case ReportTargetPost:
+		err = db.Model(&models.Post{}).Where("post_id = ?", targetID).Pluck("user_id", &userIDs).Error
+	case ReportTargetComment:
+		err = db.Model(&models.Comment{}).Where("comment_id = ?", targetID).Pluck("author_id", &userIDs).Error
+	case ReportTargetUser:
+		err = db.Model(&models.User{}).Where("user_id = ?", targetID).Pluck("user_id", &userIDs).Error
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(userIDs) == 0 {
+		return 0, errReportTargetNotFound
+	}
+	return userIDs[0], nil
+}
+
+// checkReportTargetVisible reports a post or comment the reporter cannot read as not found
+func checkReportTargetVisible(db *gorm.DB, targetType string, targetID uint, reporterID uint) error {
+	var post models.Post
+	var err error
+	switch targetType {
+	case ReportTargetPost:
+		err = db.First(&post, targetID).Error
+	case ReportTargetComment:
+		err = db.Joins("inner join comments on comments.post_id = posts.post_id").Where("comments.comment_id = ?", targetID).First(&post).Error
+	default:
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errReportTargetNotFound
+	} else if err != nil {
+		return err
+	}
+
+	visible, err := canViewPost(db, post, reporterID)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return errReportTargetNotFound
+	}
+	return nil
+}
+
+func reportQuery(db *gorm.DB) *gorm.DB {
+	return db.Table("reports").Select("reports.report_id, reports.reporter_id, COALESCE(users.username, '') AS reporter_username, reports.target_type, reports.target_id, reports.reason, reports.details, reports.status, reports.assigned_to, reports.resolved_at, reports.created_at").
+		Joins("left join users on users.user_id = reports.reporter_id")
+}
+
+func loadReport(reportID uint) (models.ReportResponse, error) {
+	var reports []models.ReportResponse
+	if err := reportQuery(config.DB).Where("reports.report_id = ?", reportID).Scan(&reports).Error; err != nil {
+		return models.ReportResponse{}, err
+	}
+	if len(reports) == 0 {
+		return models.ReportResponse{}, gorm.ErrRecordNotFound
+	}
+	if err := fillReportPreviews(config.DB, reports); err != nil {
+		return models.ReportResponse{}, err
+	}
+	return reports[0], nil
+}
+
+// fillReportPreviews sets a short excerpt of the reported post or comment, or the reported username.
+// Content that was deleted in the meantime keeps an empty preview.
+func fillReportPreviews(db *gorm.DB, reports []models.ReportResponse) error {
+	ids := map[string][]uint{}
+	for _, report := range reports {
+		ids[report.TargetType] = append(ids[report.TargetType], report.TargetID)
+	}
+
+	previews := map[string]map[uint]string{
+		ReportTargetPost:    {},
+		ReportTargetComment: {},
+		ReportTargetUser:    {},
+	}
+	var rows []struct {
+		ID   uint
+		Text string
+	}
+	if len(ids[ReportTargetPost]) > 0 {
+		if err := db.Table("posts").Select("post_id AS id, message AS text").Where("post_id IN ?", ids[ReportTargetPost]).Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			previews[ReportTargetPost][row.ID] = row.Text
+		}
+	}
+	if len(ids[ReportTargetComment]) > 0 {
+		rows = nil
+		if err := db.Table("comments").Select("comment_id AS id, comment_msg AS text").Where("comment_id IN ?", ids[ReportTargetComment]).Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			previews[ReportTargetComment][row.ID] = row.Text
+		}
+	}
+	if len(ids[ReportTargetUser]) > 0 {
+		rows = nil
+		if err := db.Table("users").Select("user_id AS id, username AS text").Where("user_id IN ?", ids[ReportTargetUser]).Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			previews[ReportTargetUser][row.ID] = row.Text
+		}
+	}
+
+	for i := range reports {
+		preview := []rune(previews[reports[i].TargetType][reports[i].TargetID])
+		if len(preview) > maxReportPreviewLength {
+			preview = append(preview[:maxReportPreviewLength], '…')
+		}
+		reports[i].TargetPreview = string(preview)
+	}
+	return nil
+}
+
+// moderatorName is the admin account that made the request, admin routes use basic auth
+func moderatorName(c echo.Context) string {
+	if username, _, ok := c.Request().BasicAuth(); ok && username != "" {
+		return username
+	}
+	return "admin"
+}
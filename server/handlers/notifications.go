@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Notification types
+const (
+	NotificationMention  = "mention"
+	NotificationReply    = "reply"
+	NotificationFollow   = "follow"
+	NotificationReaction = "reaction"
+)
+
+var notificationTypes = []string{NotificationMention, NotificationReply, NotificationFollow, NotificationReaction}
+
+// notify records a notification for recipientID unless the actor is the recipient, the recipient
+// blocked or muted the actor, or the recipient turned this notification type off. Failures are logged
+// and never fail the write that caused the event.
+func notify(db *gorm.DB, recipientID uint, actorID uint, notificationType string, postID *uint, commentID *uint) {
+	if recipientID == 0 || recipientID == actorID {
+		return
+	}
+
+	hidden, err := hidesUser(db, recipientID, actorID)
+	if err != nil {
+		log.Println("Failed to read blocks and mutes:", err)
+		return
+	}
+	if hidden {
+		return
+	}
+
+	// Nobody is told about a post they cannot read
+	if postID != nil {
+		if _, err := findVisiblePost(db, *postID, recipientID); err != nil {
+			return
+		}
+	}
+
+	var preference models.NotificationPreference
+	result := db.Where("user_id = ? AND type = ?", recipientID, notificationType).Limit(1).Find(&preference)
+	if result.Error != nil {
+		log.Println("Failed to read notification preference:", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 && !preference.Enabled {
+		return
+	}
+
+	notification := models.Notification{
+		UserID:    recipientID,
+		ActorID:   actorID,
+		Type:      notificationType,
+		PostID:    postID,
+		CommentID: commentID,
+	}
+	if err := db.Create(&notification).Error; err != nil {
+		log.Println("Failed to create notification:", err)
+		return
+	}
+	publishNotification(db, notification)
+}
+
+// notifyMentions notifies every newly mentioned user of a post or comment
+func notifyMentions(db *gorm.DB, userIDs []uint, actorID uint, postID *uint, commentID *uint) {
+	for _, userID := range userIDs {
+		notify(db, userID, actorID, NotificationMention, postID, commentID)
+	}
+}
+
+// notifyReply notifies the author of the post about a new comment, and the users mentioned in it.
+// The author is told about the reply already, a mention of them in the comment is not notified again.
+func notifyReply(db *gorm.DB, post models.Post, comment models.Comment, mentioned []uint) {
+	notify(db, post.UserID, comment.AuthorID, NotificationReply, &post.PostID, &comment.CommentID)
+	for _, userID := range mentioned {
+		if userID != post.UserID {
+			notify(db, userID, comment.AuthorID, NotificationMention, &post.PostID, &comment.CommentID)
+		}
+	}
+}
+
+// GetNotifications godoc
+// @Summary List notifications
+// @Description Get the notifications of the authenticated user, newest first. Notifications caused by users
+// @Description the authenticated user blocked or muted are left out
+// @Tags Notifications
+// @Accept json
+// @Produce json
+// @Param unread query bool false "Only unread notifications"
+// @Param page query int false "Page number, starting at 1"
+// @Param page_size query int false "Notifications per page (max 50, default 20)"
+// @Success 200 {array} models.NotificationResponse "Notifications"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to get notifications"
+// @Router /api/v1/restricted/notifications [get]
+func GetNotifications(c echo.Context) error {
+	request := new(models.GetNotificationsRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+	page, pageSize := pageAndSize(request.PaginationRequest)
+	userID, _ := helpers.CurrentUserID(c)
+
+	query := config.DB.Table("notifications").Select("notifications.notification_id, notifications.type, notifications.actor_id, users.username AS actor_username, notifications.post_id, notifications.comment_id, notifications.read_at, notifications.created_at").
+		Joins("inner join users on users.user_id = notifications.actor_id").
+		Where("notifications.user_id = ?", userID)
+	query = excludeHiddenAuthors(query, "notifications.actor_id", userID)
+	if request.UnreadOnly {
+		query = query.Where("notifications.read_at IS NULL")
+	}
+
+	notifications := []models.NotificationResponse{}
+	if result := query.Order("notifications.created_at DESC, notifications.notification_id DESC").Limit(pageSize).Offset((page - 1) * pageSize).Scan(&notifications); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get notifications"})
+	}
+
+	return c.JSON(http.StatusOK, notifications)
+}
+
+// GetUnreadNotificationCount godoc
+// @Summary Count unread notifications
+// @Description Get the number of unread notifications of the authenticated user
+// @Tags Notifications
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.UnreadCountResponse "Unread count"
+// @Failure 500 {object} map[string]string "Failed to count notifications"
+// @Router /api/v1/restricted/notifications/unread-count [get]
+func GetUnreadNotificationCount(c echo.Context) error {
+	userID, _ := helpers.CurrentUserID(c)
+
+	var unread int64
+	query := config.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if result := excludeHiddenAuthors(query, "actor_id", userID).Count(&unread); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to count notifications"})
+	}
+
+	return c.JSON(http.StatusOK, models.UnreadCountResponse{Unread: unread})
+}
+
+// MarkNotificationRead godoc
+// @Summary Mark a notification as read
+// @Description Mark one notification of the authenticated user as read
+// @Tags Notifications
+// @Accept json
+// @Produce json
+// @Param nid path int true "Notification ID"
+// @Success 200 {object} map[string]string "Notification marked as read"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Notification not found"
+// @Failure 500 {object} map[string]string "Failed to update notification"
+// @Router /api/v1/restricted/notifications/{nid}/read [put]
+func MarkNotificationRead(c echo.Context) error {
+	notificationID, err := strconv.Atoi(c.Param("nid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+	userID, _ := helpers.CurrentUserID(c)
+
+	var notification models.Notification
+	if result := config.DB.Where("notification_id = ? AND user_id = ?", notificationID, userID).First(&notification); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Notification not found"})
+	}
+
+	if notification.ReadAt == nil {
+		if result := config.DB.Model(&notification).Update("read_at", time.Now()); result.Error != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update notification"})
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Notification marked as read"})
+}
+
+// MarkAllNotificationsRead godoc
+// @Summary Mark all notifications as read
+// @Description Mark every unread notification of the authenticated user as read
+// @Tags Notifications
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]string "Notifications marked as read"
+// @Failure 500 {object} map[string]string "Failed to update notifications"
+// @Router /api/v1/restricted/notifications/read-all [put]
+func MarkAllNotificationsRead(c echo.Context) error {
+	userID, _ := helpers.CurrentUserID(c)
+
+	if result := config.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", time.Now()); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update notifications"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Notifications marked as read"})
+}
+
+// GetNotificationPreferences godoc
+// @Summary Get notification preferences
+// @Description Get which notification types the authenticated user receives
+// @Tags Notifications
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]bool "Notification type to enabled flag"
+// @Failure 500 {object} map[string]string "Failed to get preferences"
+// @Router /api/v1/restricted/notification-preferences [get]
+func GetNotificationPreferences(c echo.Context) error {
+	userID, _ := helpers.CurrentUserID(c)
+
+	preferences, err := loadNotificationPreferences(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get preferences"})
+	}
+
+	return c.JSON(http.StatusOK, preferences)
+}
+
+// UpdateNotificationPreferences godoc
+// @Summary Update notification preferences
+// @Description Turn notification types on or off. Types that are not in the body keep their current setting
+// @Tags Notifications
+// @Accept json
+// @Produce json
+// @Param preferences body map[string]bool true "Notification type to enabled flag"
+// @Success 200 {object} map[string]bool "Notification type to enabled flag"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to update preferences"
+// @Router /api/v1/restricted/notification-preferences [put]
+func UpdateNotificationPreferences(c echo.Context) error {
+	// A map cannot go through the struct validator, so the keys are checked by hand
+	request := map[string]bool{}
+	if err := c.Bind(&request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]string{"message": "Invalid request data"})
+	}
+
+	known := make(map[string]bool, len(notificationTypes))
+	for _, notificationType := range notificationTypes {
+		known[notificationType] = true
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	preferences := []models.NotificationPreference{}
+	for notificationType, enabled := range request {
+		if !known[notificationType] {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Unknown notification type: " + notificationType})
+		}
+		preferences = append(preferences, models.NotificationPreference{UserID: userID, Type: notificationType, Enabled: enabled})
+	}
+
+	if len(preferences) > 0 {
+		if result := config.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enabled"}),
+		}).Create(&preferences); result.Error != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update preferences"})
+		}
+	}
+
+	current, err := loadNotificationPreferences(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get preferences"})
+	}
+
+	return c.JSON(http.StatusOK, current)
+}
+
+func loadNotificationPreferences(userID uint) (map[string]bool, error) {
+	preferences := make(map[string]bool, len(notificationTypes))
+	for _, notificationType := range notificationTypes {
+		preferences[notificationType] = true
+	}
+
+	var stored []models.NotificationPreference
+	if err := config.DB.Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	for _, preference := range stored {
+		preferences[preference.Type] = preference.Enabled
+	}
+
+	return preferences, nil
+}
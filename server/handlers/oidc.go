@@ -0,0 +1,463 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"server/oidc"
+	"server/search"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// The browser has this long to log in with the provider and come back
+const oidcLoginTTL = 10 * time.Minute
+
+// oidcStateCookie binds a login to the browser that started it, so a callback URL cannot be replayed in another browser
+const oidcStateCookie = "OIDCState"
+
+// Usernames of new accounts are cut to this length, the longest username a mention matches
+const maxUsernameLength = 32
+
+// Reasons an external login cannot be matched to an account
+var (
+	errOIDCNoEmail         = errors.New("oidc: no email address")
+	errOIDCEmailUnverified = errors.New("oidc: email address not verified")
+	errOIDCAccountExists   = errors.New("oidc: an account with an unverified email has the address")
+)
+
+var oidcLoginRefusals = map[error]string{
+	errOIDCNoEmail:         "The provider did not share an email address",
+	errOIDCEmailUnverified: "The email address is not verified by the provider",
+	errOIDCAccountExists:   "An account with this email address exists, log in to it and link the provider from there",
+}
+
+// errOIDCLinkedElsewhere is returned when linking a provider identity that already belongs to another account
+var errOIDCLinkedElsewhere = errors.New("oidc: identity linked to another account")
+
+// GetOIDCProviders godoc
+// @Summary List external login providers
+// @Description List the OpenID Connect providers users can log in with
+// @Tags Users
+// @Produce json
+// @Success 200 {array} string "Provider names"
+// @Router /api/v1/auth/oidc [get]
+func GetOIDCProviders(c echo.Context) error {
+	return c.JSON(http.StatusOK, oidc.Names())
+}
+
+// OIDCLogin godoc
+// @Summary Log in with an external provider
+// @Description Start the authorization code flow with PKCE: redirects to the provider, which sends the browser back to the callback.
+// @Description With reauthenticate the provider prompts for the credentials again, accounts without a password do this
+// @Description before deleting or deactivating the account
+// @Tags Users
+// @Param provider path string true "Provider name"
+// @Param reauthenticate query bool false "Make the provider prompt for the credentials"
+// @Success 302 "Redirect to the provider"
+// @Failure 404 {object} map[string]string "Unknown provider"
+// @Failure 502 {object} map[string]string "The provider cannot be reached"
+// @Router /api/v1/auth/oidc/{provider} [get]
+func OIDCLogin(c echo.Context) error {
+	provider, err := oidc.Get(c.Param("provider"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Unknown provider"})
+	}
+
+	redirect, err := startOIDCLogin(c, provider, nil, c.QueryParam("reauthenticate") == "true")
+	if err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusFound, redirect)
+}
+
+// LinkOIDCProvider godoc
+// @Summary Link an external provider to my account
+// @Description Start a login with the provider after confirming the password (accounts without one log in with their provider
+// @Description again first). When the browser comes back to the callback, the identity is linked to the authenticated user's
+// @Description account and can log in to it from then on
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param confirmation body models.LinkOIDCProviderRequest true "Current password"
+// @Success 200 {object} models.OIDCRedirectResponse "Where to send the browser"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Invalid password, or no recent login with the provider"
+// @Failure 404 {object} map[string]string "Unknown provider"
+// @Failure 500 {object} map[string]string "Failed to start login"
+// @Failure 502 {object} map[string]string "The provider cannot be reached"
+// @Router /api/v1/restricted/auth/oidc/{provider}/link [post]
+func LinkOIDCProvider(c echo.Context) error {
+	provider, err := oidc.Get(c.Param("provider"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Unknown provider"})
+	}
+
+	request := new(models.LinkOIDCProviderRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+	user, err := confirmIdentity(c, request.Password)
+	if err != nil {
+		return err
+	}
+
+	redirect, err := startOIDCLogin(c, provider, &user.UserID, false)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, models.OIDCRedirectResponse{RedirectURL: redirect})
+}
+
+// startOIDCLogin stores a new login state, sets its cookie and returns the authorization URL of the provider.
+// userID is set when a logged in user links the provider to their account, reauthenticate makes the provider prompt for the credentials.
+func startOIDCLogin(c echo.Context, provider *oidc.Provider, userID *uint, reauthenticate bool) (string, error) {
+	var secrets [3]string
+	var err error
+	for i := range secrets {
+		if secrets[i], err = oidc.RandomString(); err != nil {
+			return "", echo.NewHTTPError(http.StatusInternalServerError, map[string]string{"message": "Failed to start login"})
+		}
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+
+	redirect, err := provider.AuthCodeURL(c.Request().Context(), state, nonce, verifier, reauthenticate)
+	if err != nil {
+		log.Println("Failed to reach OIDC provider:", err)
+		return "", echo.NewHTTPError(http.StatusBadGateway, map[string]string{"message": "The provider cannot be reached"})
+	}
+
+	now := time.Now()
+	if result := config.DB.Where("expires_at < ?", now).Delete(&models.OIDCLoginState{}); result.Error != nil {
+		log.Println("Failed to delete expired login states:", result.Error)
+	}
+	login := models.OIDCLoginState{
+		StateHash:      oidc.HashState(state),
+		Provider:       provider.Name,
+		Nonce:          nonce,
+		CodeVerifier:   verifier,
+		UserID:         userID,
+		Reauthenticate: reauthenticate,
+		ExpiresAt:      now.Add(oidcLoginTTL),
+	}
+	if result := config.DB.Create(&login); result.Error != nil {
+		return "", echo.NewHTTPError(http.StatusInternalServerError, map[string]string{"message": "Failed to start login"})
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   int(oidcLoginTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		// Lax, the cookie has to come along when the provider redirects back
+		SameSite: http.SameSiteLaxMode,
+	})
+	return redirect, nil
+}
+
+// OIDCCallback godoc
+// @Summary Finish a login with an external provider
+// @Description The provider redirects here with an authorization code. The account is found by the provider identity,
+// @Description linked to an existing account whose email is verified, or created. An account registered with a password
+// @Description is only linked when its owner starts the login from /api/v1/restricted/auth/oidc/{provider}/link.
+// @Description Returns a JWT like /api/v1/login
+// @Tags Users
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State of the login"
+// @Success 200 {object} map[string]string "Login successful, token returned"
+// @Failure 400 {object} map[string]string "Invalid or expired login"
+// @Failure 401 {object} map[string]string "The provider refused the login or the ID token is invalid"
+// @Failure 401 {object} map[string]string "Account deleted"
+// @Failure 403 {object} map[string]string "Account suspended or banned, the email is not verified, or it belongs to an account that has to link the provider itself"
+// @Failure 404 {object} map[string]string "Unknown provider"
+// @Failure 409 {object} map[string]string "The identity is linked to another account"
+// @Failure 500 {object} map[string]string "Failed to log in"
+// @Router /api/v1/auth/oidc/{provider}/callback [get]
+func OIDCCallback(c echo.Context) error {
+	provider, err := oidc.Get(c.Param("provider"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Unknown provider"})
+	}
+	if reason := c.QueryParam("error"); reason != "" {
+		audit(loginAudit(c, AuditLoginFailed, models.User{}, "oidc:"+provider.Name, "Provider error: "+reason))
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "The provider refused the login: " + reason})
+	}
+
+	state, code := c.QueryParam("state"), c.QueryParam("code")
+	cookie, err := c.Cookie(oidcStateCookie)
+	if state == "" || code == "" || err != nil || cookie.Value != state {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid or expired login"})
+	}
+	c.SetCookie(&http.Cookie{Name: oidcStateCookie, Path: "/api/v1/auth/oidc", MaxAge: -1, HttpOnly: true, Secure: true})
+
+	// A state is used once, deleting it is what claims it
+	var login models.OIDCLoginState
+	if result := config.DB.Where("state_hash = ?", oidc.HashState(state)).Limit(1).Find(&login); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log in"})
+	}
+	claimed := config.DB.Where("state_hash = ?", login.StateHash).Delete(&models.OIDCLoginState{})
+	if login.StateHash == "" || claimed.Error != nil || claimed.RowsAffected != 1 || login.Provider != provider.Name || time.Now().After(login.ExpiresAt) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid or expired login"})
+	}
+
+	claims, err := provider.Exchange(c.Request().Context(), code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		log.Println("Failed OIDC code exchange:", err)
+		audit(loginAudit(c, AuditLoginFailed, models.User{}, "oidc:"+provider.Name, "Code exchange failed"))
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "The provider refused the login"})
+	}
+
+	identifier := "oidc:" + provider.Name + ":" + claims.Subject
+	var user models.User
+	created := false
+	if login.UserID != nil {
+		user, err = linkOIDCIdentity(config.DB, provider.Name, claims, *login.UserID)
+	} else {
+		user, created, err = oidcUser(config.DB, provider.Name, claims)
+	}
+	if errors.Is(err, errOIDCLinkedElsewhere) {
+		audit(loginAudit(c, AuditLoginFailed, models.User{}, identifier, "Identity linked to another account"))
+		return c.JSON(http.StatusConflict, map[string]string{"message": "This provider account is linked to another account"})
+	} else if message, refused := oidcLoginRefusals[err]; refused {
+		audit(loginAudit(c, AuditLoginFailed, models.User{}, identifier, message))
+		return c.JSON(http.StatusForbidden, map[string]string{"message": message})
+	} else if err != nil {
+		log.Println("Failed to resolve OIDC account:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to log in"})
+	}
+
+	// A fresh sign-in at the provider confirms the user like a password does, see confirmIdentity
+	var authenticatedAt *time.Time
+	if claims.AuthTime != nil {
+		authenticatedAt = &claims.AuthTime.Time
+	} else if login.Reauthenticate {
+		now := time.Now()
+		authenticatedAt = &now
+	}
+	if authenticatedAt != nil {
+		result := config.DB.Model(&models.UserIdentity{}).Where("provider = ? AND subject = ?", provider.Name, claims.Subject).Update("authenticated_at", *authenticatedAt)
+		if result.Error != nil {
+			log.Println("Failed to record the provider sign-in:", result.Error)
+		}
+	}
+
+	if created {
+		search.IndexUser(user)
+		registered := models.UserRelationResponse{UserID: user.UserID, Username: user.Username}
+		if user.CreatedAt != nil {
+			registered.CreatedAt = *user.CreatedAt
+		}
+		emitWebhookEvent(config.DB, WebhookUserCreated, registered)
+
+		entry := auditEntry(c, AuditUserRegister, ReportTargetUser, user.UserID)
+		entry.ActorType, entry.ActorID, entry.ActorName = AuditActorUser, &user.UserID, user.Username
+		entry.Details = "oidc:" + provider.Name
+		audit(entry)
+	}
+
+	// Same rules as a password login
+	if user.Status == AccountDeactivated {
+		if result := config.DB.Model(&user).Update("status", AccountActive); result.Error != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to reactivate account"})
+		}
+		user.Status = AccountActive
+		audit(loginAudit(c, AuditAccountReactivate, user, identifier, ""))
+	}
+	if status, message := accountRestriction(user); status != 0 {
+		audit(loginAudit(c, AuditLoginFailed, user, identifier, message))
+		return c.JSON(status, map[string]string{"message": message})
+	}
+
+	token, err := helpers.GenerateJWTToken(user)
+	if err != nil {
+		log.Println("Error creating JWT token:", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to generate token"})
+	}
+	WriteLogInCookie(c, token)
+	if result := config.DB.Model(&user).Update("cookie_token", token); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update user session token"})
+	}
+	audit(loginAudit(c, AuditLogin, user, identifier, "oidc:"+provider.Name))
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Login successful",
+		"token":   token,
+	})
+}
+
+// oidcUser finds the account of a provider identity. An unknown identity is linked to the account with the same email
+// when the provider verified that email and so did the account, otherwise anyone could take over an account by claiming
+// its address, or register one with somebody else's address and wait for them to log in. Without such an account one is created.
+func oidcUser(db *gorm.DB, provider string, claims *oidc.Claims) (models.User, bool, error) {
+	var user models.User
+	var identity models.UserIdentity
+	now := time.Now()
+
+	result := db.Preload("User").Where("provider = ? AND subject = ?", provider, claims.Subject).Limit(1).Find(&identity)
+	if result.Error != nil {
+		return user, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		updates := map[string]interface{}{"last_login_at": now}
+		if claims.Email != "" {
+			updates["email"] = claims.Email
+		}
+		if err := db.Model(&identity).Updates(updates).Error; err != nil {
+			log.Println("Failed to update identity:", err)
+		}
+		return identity.User, false, nil
+	}
+
+	if claims.Email == "" {
+		return user, false, errOIDCNoEmail
+	}
+	if !claims.EmailVerified {
+		return user, false, errOIDCEmailUnverified
+	}
+
+	created := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("email = ?", claims.Email).Limit(1).Find(&user)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var err error
+			if user, err = provisionOIDCUser(tx, claims); err != nil {
+				return err
+			}
+			created = true
+		} else if user.EmailVerifiedAt == nil {
+			return errOIDCAccountExists
+		}
+		return tx.Create(&models.UserIdentity{
+			UserID:      user.UserID,
+			Provider:    provider,
+			Subject:     claims.Subject,
+			Email:       claims.Email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	return user, created, err
+}
+
+// linkOIDCIdentity links a provider identity to the account of the user who started the login, whose password was confirmed then.
+// Linking an identity again is a login, an identity of another account is refused.
+func linkOIDCIdentity(db *gorm.DB, provider string, claims *oidc.Claims, userID uint) (models.User, error) {
+	var user models.User
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		var identity models.UserIdentity
+		result := tx.Where("provider = ? AND subject = ?", provider, claims.Subject).Limit(1).Find(&identity)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			if identity.UserID != userID {
+				return errOIDCLinkedElsewhere
+			}
+			return tx.Model(&identity).Update("last_login_at", now).Error
+		}
+		return tx.Create(&models.UserIdentity{
+			UserID:      userID,
+			Provider:    provider,
+			Subject:     claims.Subject,
+			Email:       claims.Email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	return user, err
+}
+
+// provisionOIDCUser creates an account for a new external user. The password is empty, so it cannot be used to log in.
+// The provider verified the email, so the account has a verified email.
+func provisionOIDCUser(tx *gorm.DB, claims *oidc.Claims) (models.User, error) {
+	now := time.Now()
+	firstname, surname := claims.GivenName, claims.FamilyName
+	if firstname == "" && surname == "" {
+		firstname, surname, _ = strings.Cut(strings.TrimSpace(claims.Name), " ")
+	}
+	base := oidcUsernameBase(claims)
+	if firstname == "" {
+		firstname = base
+	}
+
+	for attempt := 1; attempt <= 100; attempt++ {
+		username := base
+		if attempt > 1 {
+			suffix := fmt.Sprint(attempt)
+			if attempt > 20 {
+				random, err := randomHex(3)
+				if err != nil {
+					return models.User{}, err
+				}
+				suffix = random
+			}
+			if len(username)+len(suffix) > maxUsernameLength {
+				username = username[:maxUsernameLength-len(suffix)]
+			}
+			username += suffix
+		}
+
+		var taken int64
+		if err := tx.Model(&models.User{}).Where("username = ?", username).Count(&taken).Error; err != nil {
+			return models.User{}, err
+		}
+		if taken > 0 {
+			continue
+		}
+
+		user := models.User{
+			Username:        username,
+			Firstname:       firstname,
+			Surname:         surname,
+			Email:           claims.Email,
+			IsAdmin:         "0",
+			EmailVerifiedAt: &now,
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return user, err
+		}
+		return user, nil
+	}
+	return models.User{}, errors.New("no free username")
+}
+
+// oidcUsernameBase turns the preferred username, or the start of the email, into a username that can be mentioned
+func oidcUsernameBase(claims *oidc.Claims) string {
+	candidate := claims.PreferredUsername
+	if candidate == "" || strings.Contains(candidate, "@") {
+		candidate, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	var username strings.Builder
+	for _, r := range candidate {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-') {
+			username.WriteRune(r)
+		}
+	}
+	base := strings.Trim(username.String(), ".-")
+	if len(base) > maxUsernameLength {
+		base = base[:maxUsernameLength]
+	}
+	if len(base) < 3 {
+		base = "user" + base
+	}
+	return base
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"server/models"
+	"server/passwords"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// checkNewPassword applies the password policy to a new password of the user (not created yet when UserID is 0)
+func checkNewPassword(db *gorm.DB, user models.User, password string) error {
+	var previous []string
+	if user.UserID != 0 && passwords.Default.History > 0 {
+		if err := db.Model(&models.PasswordHistory{}).Where("user_id = ?", user.UserID).
+			Order("created_at DESC, password_history_id DESC").Limit(passwords.Default.History).
+			Pluck("password", &previous).Error; err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, map[string]string{"message": "Failed to check password"})
+		}
+		// Accounts older than the history only have their current password
+		if len(previous) == 0 && user.Password != "" {
+			previous = []string{user.Password}
+		}
+	}
+
+	err := passwords.Default.Check(password, user.Username, previous)
+	var violation passwords.Violation
+	if errors.As(err, &violation) {
+		return echo.NewHTTPError(http.StatusBadRequest, map[string]string{"message": violation.Error()})
+	}
+	if err != nil {
+		log.Println("Failed to check password:", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, map[string]string{"message": "Failed to check password"})
+	}
+	return nil
+}
+
+// recordPassword adds a newly set password hash to the history of the user and forgets the ones beyond the policy
+func recordPassword(db *gorm.DB, userID uint, hash string) error {
+	if passwords.Default.History <= 0 {
+		return nil
+	}
+	if err := db.Create(&models.PasswordHistory{UserID: userID, Password: hash}).Error; err != nil {
+		return err
+	}
+
+	var expired []uint
+	if err := db.Model(&models.PasswordHistory{}).Where("user_id = ?", userID).
+		Order("created_at DESC, password_history_id DESC").Offset(passwords.Default.History).Limit(1000).
+		Pluck("password_history_id", &expired).Error; err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	return db.Delete(&models.PasswordHistory{}, expired).Error
+}
+
+// rehashPassword replaces a hash with outdated parameters after the password was verified. It only updates the row
+// when the hash did not change in the meantime, a failure is logged and the old hash keeps working.
+func rehashPassword(db *gorm.DB, user *models.User, password string) {
+	hash, err := passwords.Default.Hash(password)
+	if err != nil {
+		log.Println("Failed to rehash password:", err)
+		return
+	}
+	result := db.Model(&models.User{}).Where("user_id = ? AND password = ?", user.UserID, user.Password).Update("password", hash)
+	if result.Error != nil {
+		log.Println("Failed to rehash password:", result.Error)
+		return
+	}
+	if result.RowsAffected == 1 {
+		user.Password = hash
+	}
+}
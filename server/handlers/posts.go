@@ -1,60 +1,136 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"server/config"
+	"server/filter"
 	"server/helpers"
 	"server/models"
+	"server/unitofwork"
 	"strconv"
+	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 // GetPosts godoc
 // @Summary Retrieve all posts
 // @Description Get all posts with associated user details (username, firstname, surname)
+// @Description Reaction counts are included for every post, and my_reactions is filled when a valid token is sent.
+// @Description Anonymous viewers only get public posts. With a valid token, the viewer also gets their own posts and the
+// @Description followers-only posts of the users they follow, and posts by users the viewer blocked or muted are left out of the list.
+// @Description Drafts are only returned to their author, by ID
 // @Tags Posts
 // @Accept json
 // @Produce json
 // @Success 200 {array} models.GetPublicPostsRequest "List of posts with user details"
+// @Failure 404 {object} map[string]string "Post not found"
 // @Failure 500 {object} map[string]string "Failed to retrieve posts"
 // @Router /api/v1/posts [get]
 func GetPosts(c echo.Context) error {
-	postID := c.QueryParam("pid")
+	service := ServiceFor(c)
 
-	if postID != "" {
-		postID, err := strconv.Atoi(postID)
+	if pid := c.QueryParam("pid"); pid != "" {
+		postID, err := strconv.ParseUint(pid, 10, 0)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
 		}
 
-		var post models.Post
-		if result := config.DB.First(&post, postID); result.Error != nil {
-			return c.JSON(http.StatusNotFound, map[string]string{"message": "Post not found"})
+		post, err := service.GetPost(uint(postID))
+		if err != nil {
+			return serviceError(c, err)
 		}
-
 		return c.JSON(http.StatusOK, post)
 	}
 
+	posts, err := service.ListPosts()
+	if err != nil {
+		return serviceError(c, err)
+	}
+	return c.JSON(http.StatusOK, posts)
+}
+
+// ListPosts returns the feed of the caller, see GetPosts
+func (s Service) ListPosts() ([]models.GetPublicPostsRequest, error) {
 	var posts []models.GetPublicPostsRequest
-	if result := config.DB.Table("posts").Select("posts.post_id, users.username, users.firstname, users.surname, posts.message, posts.created_at, posts.updated_at").Joins("inner join users on users.user_id = posts.user_id").Scan(&posts); result.Error != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get posts"})
+	query := feedPosts(postListQuery(config.DB), s.UserID)
+	if result := excludeHiddenAuthors(query, "posts.user_id", s.UserID).Scan(&posts); result.Error != nil {
+		return nil, failed("Failed to get posts", result.Error)
 	}
 
-	return c.JSON(http.StatusOK, posts)
+	if err := s.decoratePosts(posts); err != nil {
+		return nil, failed("Failed to get posts", err)
+	}
+	return posts, nil
+}
+
+// GetPost returns a post the caller may read
+func (s Service) GetPost(postID uint) (models.Post, error) {
+	post, err := findVisiblePost(config.DB, postID, s.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return post, &ServiceError{Status: http.StatusNotFound, Message: "Post not found"}
+	} else if err != nil {
+		return post, failed("Failed to get post", err)
+	}
+	return post, nil
+}
+
+// postListQuery selects the posts that are not hidden by moderators with their author, in the shape of GetPublicPostsRequest
+func postListQuery(db *gorm.DB) *gorm.DB {
+	return db.Table("posts").Select("posts.post_id, users.username, users.firstname, users.surname, posts.message, posts.visibility, posts.publish_at, posts.created_at, posts.updated_at").
+		Joins("inner join users on users.user_id = posts.user_id").
+		Where("posts.hidden_at IS NULL")
+}
+
+// decoratePosts attaches reaction counts, hashtag/mention entities, attachments, and the reactions of the caller
+// when it is signed in
+func (s Service) decoratePosts(posts []models.GetPublicPostsRequest) error {
+	postIDs := make([]uint, len(posts))
+	messages := make([]string, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.PostID
+		messages[i] = post.Message
+	}
+
+	counts, mine, err := loadReactionSummaries(config.DB, postReactionTables, postIDs, s.UserID)
+	if err != nil {
+		return err
+	}
+	entities, err := resolveEntities(config.DB, messages)
+	if err != nil {
+		return err
+	}
+	attachments, err := loadAttachments(s.BaseURL, config.DB, "post_id", postIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Reactions = counts[posts[i].PostID]
+		posts[i].MyReactions = mine[posts[i].PostID]
+		posts[i].Entities = entities[i]
+		posts[i].Attachments = attachments[posts[i].PostID]
+	}
+	return nil
 }
 
 // CreatePost godoc
 // @Summary Create a new post
-// @Description Create a new post by an authenticated user
+// @Description Create a new post by an authenticated user. The content filter may reject the post,
+// @Description or accept it hidden until a moderator reviews it (202 with HiddenAt set).
+// @Description Visibility is public (default), followers, private or draft. Drafts notify nobody until they are published.
+// @Description With publish_at the post is scheduled: only the author sees it until a background worker publishes it
 // @Tags Posts
 // @Accept json
 // @Produce json
 // @Param post body models.Post true "Post object that needs to be created"
 // @Success 201 {object} models.Post "Newly created post"
+// @Success 202 {object} models.Post "Post held for review"
 // @Failure 400 {object} map[string]string "Invalid input"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 422 {object} map[string]string "Rejected by the content filter"
 // @Failure 500 {object} map[string]string "Failed to create post"
 // @Router /api/v1/restricted/posts [post]
 func CreatePost(c echo.Context) error {
@@ -63,21 +139,190 @@ func CreatePost(c echo.Context) error {
 		return err
 	}
 
-	// Reference: https://github.com/labstack/echo/issues/1504
-	user := c.Get(("user"))
-	token := user.(*jwt.Token)
-	claims := token.Claims.(*models.JWTClaims)
+	post, err := ServiceFor(c).CreatePost(*request)
+	if err != nil {
+		return serviceError(c, err)
+	}
 
-	userID := claims.UserID
+	if post.HiddenAt != nil {
+		return c.JSON(http.StatusAccepted, post)
+	}
+	return c.JSON(http.StatusCreated, post)
+}
+
+// CreatePost creates a post of the caller, see CreatePost. A post held for review comes back with HiddenAt set.
+func (s Service) CreatePost(request models.CreatePostRequest) (models.Post, error) {
+	if err := validateRequest(request); err != nil {
+		return models.Post{}, err
+	}
+
+	if request.PublishAt != nil {
+		if request.Visibility == VisibilityDraft {
+			return models.Post{}, &ServiceError{Status: http.StatusBadRequest, Message: "A draft cannot be scheduled"}
+		}
+		if !request.PublishAt.After(time.Now()) {
+			return models.Post{}, &ServiceError{Status: http.StatusBadRequest, Message: "publish_at must be in the future"}
+		}
+	}
+
+	userID := s.UserID
+	content := filter.Content{Kind: filter.KindPost, UserID: userID, Text: request.Message}
+	screened := filter.Run(content)
+	if screened.Verdict == filter.Reject {
+		return models.Post{}, rejectContent(content, screened)
+	}
 
 	post := models.Post{
-		Message: request.Message,
-		UserID:  userID,
+		Message:    request.Message,
+		UserID:     userID,
+		Visibility: request.Visibility,
+		PublishAt:  request.PublishAt,
+	}
+	if post.Visibility == "" {
+		post.Visibility = VisibilityPublic
+	}
+	if screened.Verdict == filter.Hold {
+		now := time.Now()
+		post.HiddenAt = &now
 	}
 
-	if result := config.DB.Create(&post); result.Error != nil {
-		result.Debug()
+	// The post, its hashtags, mentions and review report are stored together or not at all
+	err := unitofwork.Run(config.DB, func(unit *unitofwork.Unit) error {
+		if err := unit.Tx.Create(&post).Error; err != nil {
+			return err
+		}
+		mentioned, err := syncEntities(unit.Tx, postEntityTables, post.PostID, post.Message)
+		if err != nil {
+			return err
+		}
+		post.Entities = entitiesOf(unit.Tx, post.Message)
+
+		if post.HiddenAt != nil {
+			content.ID = post.PostID
+			return holdForReview(unit.Tx, content, screened)
+		}
+		if post.Visibility != VisibilityDraft && post.PublishAt == nil {
+			unit.AfterCommit(func() {
+				notifyMentions(config.DB, mentioned, userID, &post.PostID, nil)
+				indexPostForSearch(config.DB, post)
+				publishPostCreated(config.DB, post)
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Post{}, failed("Failed to create post", err)
 	}
+	return post, nil
+}
 
-	return c.JSON(http.StatusCreated, post)
+// UpdatePost godoc
+// @Summary Edit a post
+// @Description Edit the message of a post owned by the authenticated user. Hashtags and mentions are extracted again.
+// @Description The new message goes through the content filter like a new post, a held post is hidden until reviewed.
+// @Description Changing the visibility of a draft publishes it, a published post cannot become a draft again
+// @Tags Posts
+// @Accept json
+// @Produce json
+// @Param pid path int true "Post ID"
+// @Param post body models.UpdatePostRequest true "New message"
+// @Success 200 {object} models.Post "Updated post"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Not the author of the post"
+// @Failure 404 {object} map[string]string "Post not found"
+// @Failure 422 {object} map[string]string "Rejected by the content filter"
+// @Failure 500 {object} map[string]string "Failed to update post"
+// @Router /api/v1/restricted/posts/{pid} [put]
+func UpdatePost(c echo.Context) error {
+	request := new(models.UpdatePostRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	postID, err := strconv.ParseUint(c.Param("pid"), 10, 0)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	post, err := ServiceFor(c).UpdatePost(uint(postID), *request)
+	if err != nil {
+		return serviceError(c, err)
+	}
+	return c.JSON(http.StatusOK, post)
+}
+
+// UpdatePost edits a post of the caller, see UpdatePost
+func (s Service) UpdatePost(postID uint, request models.UpdatePostRequest) (models.Post, error) {
+	if err := validateRequest(request); err != nil {
+		return models.Post{}, err
+	}
+
+	var post models.Post
+	if result := config.DB.First(&post, postID); result.Error != nil {
+		return post, &ServiceError{Status: http.StatusNotFound, Message: "Post not found"}
+	}
+
+	userID := s.UserID
+	if post.UserID != userID {
+		return post, &ServiceError{Status: http.StatusForbidden, Message: "You can only edit your own posts"}
+	}
+
+	content := filter.Content{Kind: filter.KindPost, ID: post.PostID, UserID: userID, Text: request.Message}
+	screened := filter.Run(content)
+	if screened.Verdict == filter.Reject {
+		return post, rejectContent(content, screened)
+	}
+
+	wasDraft := post.Visibility == VisibilityDraft
+	post.Message = request.Message
+	updates := map[string]interface{}{"message": post.Message}
+	if request.Visibility != "" && request.Visibility != post.Visibility {
+		if request.Visibility == VisibilityDraft {
+			return post, &ServiceError{Status: http.StatusBadRequest, Message: "A published post cannot go back to draft"}
+		}
+		post.Visibility = request.Visibility
+		updates["visibility"] = post.Visibility
+	}
+	if screened.Verdict == filter.Hold {
+		now := time.Now()
+		post.HiddenAt = &now
+		updates["hidden_at"] = now
+	}
+	err := unitofwork.Run(config.DB, func(unit *unitofwork.Unit) error {
+		if err := unit.Tx.Model(&post).Updates(updates).Error; err != nil {
+			return err
+		}
+		mentioned, err := syncEntities(unit.Tx, postEntityTables, post.PostID, post.Message)
+		if err != nil {
+			return err
+		}
+		post.Entities = entitiesOf(unit.Tx, post.Message)
+
+		if screened.Verdict == filter.Hold {
+			if err := holdForReview(unit.Tx, content, screened); err != nil {
+				return err
+			}
+		} else if post.HiddenAt == nil && post.Visibility != VisibilityDraft && post.PublishAt == nil {
+			if wasDraft {
+				// Nobody was told about the draft, every mention is new once it is published
+				if err := unit.Tx.Table(postEntityTables.mentions).Where("post_id = ?", post.PostID).Pluck("user_id", &mentioned).Error; err != nil {
+					return err
+				}
+			}
+			unit.AfterCommit(func() {
+				notifyMentions(config.DB, mentioned, userID, &post.PostID, nil)
+				if wasDraft {
+					publishPostCreated(config.DB, post)
+				}
+			})
+		}
+		unit.AfterCommit(func() {
+			indexPostForSearch(config.DB, post)
+		})
+		return nil
+	})
+	if err != nil {
+		return post, failed("Failed to update post", err)
+	}
+	return post, nil
 }
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"server/storage"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxAvatarSize = 5 << 20
+	avatarSize    = 256
+)
+
+// GetUserProfile godoc
+// @Summary Get a user's public profile
+// @Description Get the public profile of a user with post and follower counts. The email and admin flag are never returned.
+// @Description Banned and deleted accounts are not found
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param username path string true "Username"
+// @Success 200 {object} models.UserProfileResponse "Public profile"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Failed to get profile"
+// @Router /api/v1/users/{username} [get]
+func GetUserProfile(c echo.Context) error {
+	profile, err := ServiceFor(c).GetProfile(c.Param("username"))
+	if err != nil {
+		return serviceError(c, err)
+	}
+	return c.JSON(http.StatusOK, profile)
+}
+
+// GetProfile returns the public profile of a user, see GetUserProfile
+func (s Service) GetProfile(username string) (models.UserProfileResponse, error) {
+	var user models.User
+	if result := config.DB.Where("username = ? AND status NOT IN ?", username, unlistedAccountStatuses).First(&user); result.Error != nil {
+		return models.UserProfileResponse{}, &ServiceError{Status: http.StatusNotFound, Message: "User not found"}
+	}
+
+	profile, err := s.loadUserProfile(user)
+	if err != nil {
+		return profile, failed("Failed to get profile", err)
+	}
+	return profile, nil
+}
+
+// UpdateProfile godoc
+// @Summary Update the authenticated user's profile
+// @Description Replace the display name, bio, location and website of the authenticated user
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param profile body models.UpdateProfileRequest true "Profile fields"
+// @Success 200 {object} models.UserProfileResponse "Updated profile"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Failed to update profile"
+// @Router /api/v1/restricted/profile [put]
+func UpdateProfile(c echo.Context) error {
+	request := new(models.UpdateProfileRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	profile, err := ServiceFor(c).UpdateProfile(*request)
+	if err != nil {
+		return serviceError(c, err)
+	}
+	return c.JSON(http.StatusOK, profile)
+}
+
+// UpdateProfile replaces the profile of the caller, see UpdateProfile
+func (s Service) UpdateProfile(request models.UpdateProfileRequest) (models.UserProfileResponse, error) {
+	if err := validateRequest(request); err != nil {
+		return models.UserProfileResponse{}, err
+	}
+
+	var user models.User
+	if result := config.DB.First(&user, s.UserID); result.Error != nil {
+		return models.UserProfileResponse{}, &ServiceError{Status: http.StatusNotFound, Message: "User not found"}
+	}
+
+	before := user
+	user.DisplayName = strings.TrimSpace(request.DisplayName)
+	user.Bio = strings.TrimSpace(request.Bio)
+	user.Location = strings.TrimSpace(request.Location)
+	user.Website = strings.TrimSpace(request.Website)
+
+	updatedStruct := map[string]interface{}{
+		"display_name": user.DisplayName,
+		"bio":          user.Bio,
+		"location":     user.Location,
+		"website":      user.Website,
+	}
+
+	if result := config.DB.Model(&user).Updates(updatedStruct); result.Error != nil {
+		return models.UserProfileResponse{}, failed("Failed to update profile", result.Error)
+	}
+
+	entry := s.auditEntry(AuditProfileUpdate, ReportTargetUser, user.UserID)
+	entry.Changes = auditChanges(before, user)
+	audit(entry)
+
+	profile, err := s.loadUserProfile(user)
+	if err != nil {
+		return profile, failed("Failed to get profile", err)
+	}
+	return profile, nil
+}
+
+// UploadAvatar godoc
+// @Summary Upload an avatar
+// @Description Upload a JPEG, PNG or GIF image (max 5 MB) as the avatar of the authenticated user. It is cropped to a square and resized to 256x256
+// @Tags Users
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Image file"
+// @Success 200 {object} models.UserProfileResponse "Updated profile"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 413 {object} map[string]string "File is too large"
+// @Failure 415 {object} map[string]string "Unsupported file type"
+// @Failure 500 {object} map[string]string "Failed to store avatar"
+// @Router /api/v1/restricted/profile/avatar [put]
+func UploadAvatar(c echo.Context) error {
+	data, _, _, err := readImageUpload(c, maxAvatarSize)
+	if err != nil {
+		return err
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	var user models.User
+	if result := config.DB.First(&user, userID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+
+	avatar, err := helpers.Avatar(data, avatarSize)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid image"})
+	}
+
+	sum := sha256.Sum256(avatar)
+	hash := hex.EncodeToString(sum[:])
+	key := "avatars/" + hash[:2] + "/" + hash + ".jpg"
+	if err := storage.Default.Put(key, bytes.NewReader(avatar)); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to store avatar"})
+	}
+
+	previousKey := user.AvatarKey
+	user.AvatarKey = key
+	if result := config.DB.Model(&user).Update("avatar_key", user.AvatarKey); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to store avatar"})
+	}
+	deleteUnusedAvatar(previousKey)
+
+	profile, err := ServiceFor(c).loadUserProfile(user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get profile"})
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+// DeleteAvatar godoc
+// @Summary Remove the avatar
+// @Description Remove the avatar of the authenticated user
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.UserProfileResponse "Updated profile"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Failed to remove avatar"
+// @Router /api/v1/restricted/profile/avatar [delete]
+func DeleteAvatar(c echo.Context) error {
+	userID, _ := helpers.CurrentUserID(c)
+	var user models.User
+	if result := config.DB.First(&user, userID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "User not found"})
+	}
+
+	previousKey := user.AvatarKey
+	user.AvatarKey = ""
+	if result := config.DB.Model(&user).Update("avatar_key", user.AvatarKey); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to remove avatar"})
+	}
+	deleteUnusedAvatar(previousKey)
+
+	profile, err := ServiceFor(c).loadUserProfile(user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get profile"})
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+// GetAvatar godoc
+// @Summary Download a user's avatar
+// @Description Download the 256x256 JPEG avatar of a user. The v query parameter of avatar_url changes with every upload, so that URL can be cached forever
+// @Tags Users
+// @Produce image/jpeg
+// @Param username path string true "Username"
+// @Param v query string false "Avatar version"
+// @Success 200 {file} file "Avatar"
+// @Failure 404 {object} map[string]string "Avatar not found"
+// @Router /api/v1/users/{username}/avatar [get]
+func GetAvatar(c echo.Context) error {
+	var user models.User
+	if result := config.DB.Select("user_id, avatar_key").Where("username = ?", c.Param("username")).First(&user); result.Error != nil || user.AvatarKey == "" {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Avatar not found"})
+	}
+
+	version := avatarVersion(user.AvatarKey)
+	header := c.Response().Header()
+	if c.QueryParam("v") == version {
+		header.Set(echo.HeaderCacheControl, "public, max-age=31536000, immutable")
+	} else {
+		header.Set(echo.HeaderCacheControl, "public, max-age=300")
+	}
+	etag := `"` + version + `"`
+	header.Set("ETag", etag)
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	blob, err := storage.Default.Open(user.AvatarKey)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Avatar not found"})
+	}
+	defer blob.Close()
+
+	return c.Stream(http.StatusOK, "image/jpeg", blob)
+}
+
+// loadUserProfile builds the public profile of user with its counts, and whether the caller (if signed in) follows the user
+func (s Service) loadUserProfile(user models.User) (models.UserProfileResponse, error) {
+	profile := models.UserProfileResponse{
+		UserID:      user.UserID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Firstname:   user.Firstname,
+		Surname:     user.Surname,
+		Bio:         user.Bio,
+		Location:    user.Location,
+		Website:     user.Website,
+		AvatarURL:   avatarURL(s.BaseURL, user),
+	}
+
+	// Only the posts the caller can read are counted
+	viewerID := s.UserID
+	posts := config.DB.Model(&models.Post{}).Where("posts.user_id = ? AND posts.hidden_at IS NULL", user.UserID)
+	if err := feedPosts(posts, viewerID).Count(&profile.PostCount).Error; err != nil {
+		return profile, err
+	}
+	if err := config.DB.Model(&models.Follow{}).Where("followee_id = ?", user.UserID).Count(&profile.FollowerCount).Error; err != nil {
+		return profile, err
+	}
+	if err := config.DB.Model(&models.Follow{}).Where("follower_id = ?", user.UserID).Count(&profile.FollowingCount).Error; err != nil {
+		return profile, err
+	}
+
+	if viewerID != 0 && viewerID != user.UserID {
+		var following int64
+		if err := config.DB.Model(&models.Follow{}).Where("follower_id = ? AND followee_id = ?", viewerID, user.UserID).Count(&following).Error; err != nil {
+			return profile, err
+		}
+		profile.Following = following > 0
+	}
+
+	return profile, nil
+}
+
+// avatarURL returns the versioned avatar URL of user, or an empty string when the user has no avatar
+func avatarURL(baseURL string, user models.User) string {
+	if user.AvatarKey == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/api/v1/users/%s/avatar?v=%s", baseURL, url.PathEscape(user.Username), avatarVersion(user.AvatarKey))
+}
+
+func avatarVersion(key string) string {
+	version := strings.TrimSuffix(path.Base(key), ".jpg")
+	if len(version) > 16 {
+		version = version[:16]
+	}
+	return version
+}
+
+// deleteUnusedAvatar removes an avatar blob that no user points at anymore.
+// Avatars are keyed by content, so two users uploading the same picture share one blob.
+func deleteUnusedAvatar(key string) {
+	if key == "" {
+		return
+	}
+
+	var users int64
+	if err := config.DB.Model(&models.User{}).Where("avatar_key = ?", key).Count(&users).Error; err != nil || users > 0 {
+		return
+	}
+	if err := storage.Default.Delete(key); err != nil {
+		log.Println("Failed to delete avatar:", err)
+	}
+}
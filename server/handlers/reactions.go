@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Allowed reaction types, kept small on purpose so the client can render a fixed set of emoji
+var reactionTypes = map[string]bool{
+	"like":  true,
+	"love":  true,
+	"laugh": true,
+	"wow":   true,
+	"sad":   true,
+	"angry": true,
+}
+
+// reactionTables describes where reactions and their counters are stored for a kind of target
+type reactionTables struct {
+	reactions string
+	counts    string
+	idColumn  string
+}
+
+var (
+	postReactionTables    = reactionTables{reactions: "post_reactions", counts: "post_reaction_counts", idColumn: "post_id"}
+	commentReactionTables = reactionTables{reactions: "comment_reactions", counts: "comment_reaction_counts", idColumn: "comment_id"}
+)
+
+// AddPostReaction godoc
+// @Summary React to a post
+// @Description Add a reaction of the given type to a post. Adding the same reaction twice has no effect
+// @Tags Reactions
+// @Accept json
+// @Produce json
+// @Param pid path int true "Post ID"
+// @Param type path string true "Reaction type (like, love, laugh, wow, sad, angry)"
+// @Success 200 {object} models.ReactionSummaryResponse "Reactions of the post"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Post not found"
+// @Failure 500 {object} map[string]string "Failed to add reaction"
+// @Router /api/v1/restricted/posts/{pid}/reactions/{type} [put]
+func AddPostReaction(c echo.Context) error {
+	postID, reactionType, err := parseReactionParams(c, "pid")
+	if err != nil {
+		return err
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	post, err := findVisiblePost(config.DB, postID, userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Post not found"})
+	}
+
+	reaction := models.PostReaction{PostID: postID, UserID: userID, ReactionType: reactionType}
+	counter := models.PostReactionCount{PostID: postID, ReactionType: reactionType, Total: 1}
+	added, err := addReaction(config.DB, &reaction, &counter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to add reaction"})
+	}
+	if added {
+		notify(config.DB, post.UserID, userID, NotificationReaction, &post.PostID, nil)
+	}
+
+	return reactionSummaryResponse(c, postReactionTables, postID, userID)
+}
+
+// RemovePostReaction godoc
+// @Summary Remove a reaction from a post
+// @Description Remove a reaction of the given type from a post. Removing a missing reaction has no effect
+// @Tags Reactions
+// @Accept json
+// @Produce json
+// @Param pid path int true "Post ID"
+// @Param type path string true "Reaction type (like, love, laugh, wow, sad, angry)"
+// @Success 200 {object} models.ReactionSummaryResponse "Reactions of the post"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to remove reaction"
+// @Router /api/v1/restricted/posts/{pid}/reactions/{type} [delete]
+func RemovePostReaction(c echo.Context) error {
+	postID, reactionType, err := parseReactionParams(c, "pid")
+	if err != nil {
+		return err
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	if err := removeReaction(config.DB, postReactionTables, postID, userID, reactionType); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to remove reaction"})
+	}
+
+	return reactionSummaryResponse(c, postReactionTables, postID, userID)
+}
+
+// AddCommentReaction godoc
+// @Summary React to a comment
+// @Description Add a reaction of the given type to a comment. Adding the same reaction twice has no effect
+// @Tags Reactions
+// @Accept json
+// @Produce json
+// @Param cid path int true "Comment ID"
+// @Param type path string true "Reaction type (like, love, laugh, wow, sad, angry)"
+// @Success 200 {object} models.ReactionSummaryResponse "Reactions of the comment"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Comment not found"
+// @Failure 500 {object} map[string]string "Failed to add reaction"
+// @Router /api/v1/restricted/comments/{cid}/reactions/{type} [put]
+func AddCommentReaction(c echo.Context) error {
+	commentID, reactionType, err := parseReactionParams(c, "cid")
+	if err != nil {
+		return err
+	}
+
+	var comment models.Comment
+	if result := config.DB.Where("hidden_at IS NULL").First(&comment, commentID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Comment not found"})
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	if _, err := findVisiblePost(config.DB, comment.PostID, userID); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Comment not found"})
+	}
+	reaction := models.CommentReaction{CommentID: commentID, UserID: userID, ReactionType: reactionType}
+	counter := models.CommentReactionCount{CommentID: commentID, ReactionType: reactionType, Total: 1}
+	added, err := addReaction(config.DB, &reaction, &counter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to add reaction"})
+	}
+	if added {
+		notify(config.DB, comment.AuthorID, userID, NotificationReaction, &comment.PostID, &comment.CommentID)
+	}
+
+	return reactionSummaryResponse(c, commentReactionTables, commentID, userID)
+}
+
+// RemoveCommentReaction godoc
+// @Summary Remove a reaction from a comment
+// @Description Remove a reaction of the given type from a comment. Removing a missing reaction has no effect
+// @Tags Reactions
+// @Accept json
+// @Produce json
+// @Param cid path int true "Comment ID"
+// @Param type path string true "Reaction type (like, love, laugh, wow, sad, angry)"
+// @Success 200 {object} models.ReactionSummaryResponse "Reactions of the comment"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to remove reaction"
+// @Router /api/v1/restricted/comments/{cid}/reactions/{type} [delete]
+func RemoveCommentReaction(c echo.Context) error {
+	commentID, reactionType, err := parseReactionParams(c, "cid")
+	if err != nil {
+		return err
+	}
+
+	userID, _ := helpers.CurrentUserID(c)
+	if err := removeReaction(config.DB, commentReactionTables, commentID, userID, reactionType); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to remove reaction"})
+	}
+
+	return reactionSummaryResponse(c, commentReactionTables, commentID, userID)
+}
+
+func parseReactionParams(c echo.Context, idParam string) (uint, string, error) {
+	targetID, err := strconv.Atoi(c.Param(idParam))
+	if err != nil || targetID <= 0 {
+		return 0, "", echo.NewHTTPError(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	reactionType := c.Param("type")
+	if !reactionTypes[reactionType] {
+		return 0, "", echo.NewHTTPError(http.StatusBadRequest, map[string]string{"message": "Unknown reaction type"})
+	}
+
+	return uint(targetID), reactionType, nil
+}
+
+// addReaction inserts the reaction and bumps its counter in one transaction.
+// The primary key on the reaction table makes a second insert a no-op, so the counter
+// is only incremented when a row was really added, even with concurrent requests.
+// It reports whether the reaction is new.
+func addReaction(db *gorm.DB, reaction interface{}, counter interface{}) (bool, error) {
+	added := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(reaction)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		added = true
+
+		return tx.Clauses(clause.OnConflict{
+			DoUpdates: clause.Assignments(map[string]interface{}{"total": gorm.Expr("total + 1")}),
+		}).Create(counter).Error
+	})
+	return added && err == nil, err
+}
+
+// removeReaction deletes the reaction and decrements its counter in one transaction
+func removeReaction(db *gorm.DB, tables reactionTables, targetID uint, userID uint, reactionType string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("DELETE FROM "+tables.reactions+" WHERE "+tables.idColumn+" = ? AND user_id = ? AND reaction_type = ?", targetID, userID, reactionType)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		return tx.Exec("UPDATE "+tables.counts+" SET total = total - 1 WHERE "+tables.idColumn+" = ? AND reaction_type = ? AND total > 0", targetID, reactionType).Error
+	})
+}
+
+// removeUserReactions deletes every reaction of a user and takes them off the counters of the posts and comments they were on.
+// A user has at most one reaction of each type on a target, so each matching counter goes down by one.
+func removeUserReactions(tx *gorm.DB, userID uint) error {
+	for _, tables := range []reactionTables{postReactionTables, commentReactionTables} {
+		if err := tx.Exec("UPDATE "+tables.counts+" SET total = total - 1 WHERE total > 0 AND EXISTS (SELECT 1 FROM "+tables.reactions+
+			" WHERE "+tables.reactions+"."+tables.idColumn+" = "+tables.counts+"."+tables.idColumn+
+			" AND "+tables.reactions+".reaction_type = "+tables.counts+".reaction_type AND "+tables.reactions+".user_id = ?)", userID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM "+tables.reactions+" WHERE user_id = ?", userID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func reactionSummaryResponse(c echo.Context, tables reactionTables, targetID uint, userID uint) error {
+	counts, mine, err := loadReactionSummaries(config.DB, tables, []uint{targetID}, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get reactions"})
+	}
+
+	return c.JSON(http.StatusOK, models.ReactionSummaryResponse{
+		Reactions:   counts[targetID],
+		MyReactions: mine[targetID],
+	})
+}
+
+// loadReactionSummaries returns the reaction counts of every target and, when viewerID is set,
+// the reaction types the viewer used. Targets without reactions get an empty map/slice so the
+// JSON output never contains null.
+func loadReactionSummaries(db *gorm.DB, tables reactionTables, targetIDs []uint, viewerID uint) (map[uint]map[string]int64, map[uint][]string, error) {
+	counts := make(map[uint]map[string]int64, len(targetIDs))
+	mine := make(map[uint][]string, len(targetIDs))
+	for _, id := range targetIDs {
+		counts[id] = map[string]int64{}
+		mine[id] = []string{}
+	}
+	if len(targetIDs) == 0 {
+		return counts, mine, nil
+	}
+
+	var countRows []struct {
+		TargetID     uint
+		ReactionType string
+		Total        int64
+	}
+	if err := db.Table(tables.counts).Select(tables.idColumn+" AS target_id, reaction_type, total").Where(tables.idColumn+" IN ? AND total > 0", targetIDs).Scan(&countRows).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, row := range countRows {
+		counts[row.TargetID][row.ReactionType] = row.Total
+	}
+
+	if viewerID == 0 {
+		return counts, mine, nil
+	}
+
+	var mineRows []struct {
+		TargetID     uint
+		ReactionType string
+	}
+	if err := db.Table(tables.reactions).Select(tables.idColumn+" AS target_id, reaction_type").Where(tables.idColumn+" IN ? AND user_id = ?", targetIDs, viewerID).Order("reaction_type").Scan(&mineRows).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, row := range mineRows {
+		mine[row.TargetID] = append(mine[row.TargetID], row.ReactionType)
+	}
+
+	return counts, mine, nil
+}
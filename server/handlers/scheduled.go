@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"server/unitofwork"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Number of due posts published per run of the publisher, the rest are picked up by the next run
+var scheduledPublishBatch = 100
+
+// GetScheduledPosts godoc
+// @Summary List my scheduled posts
+// @Description List the posts of the authenticated user that are waiting to be published, the next one first
+// @Tags Posts
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.GetPublicPostsRequest "Scheduled posts"
+// @Failure 500 {object} map[string]string "Failed to get scheduled posts"
+// @Router /api/v1/restricted/scheduled-posts [get]
+func GetScheduledPosts(c echo.Context) error {
+	userID, _ := helpers.CurrentUserID(c)
+
+	posts := []models.GetPublicPostsRequest{}
+	if result := postListQuery(config.DB).Where("posts.user_id = ? AND posts.publish_at IS NOT NULL", userID).
+		Order("posts.publish_at ASC, posts.post_id ASC").Scan(&posts); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get scheduled posts"})
+	}
+
+	if err := ServiceFor(c).decoratePosts(posts); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get scheduled posts"})
+	}
+
+	return c.JSON(http.StatusOK, posts)
+}
+
+// ReschedulePost godoc
+// @Summary Reschedule a post
+// @Description Change the publication time of a scheduled post of the authenticated user
+// @Tags Posts
+// @Accept json
+// @Produce json
+// @Param pid path int true "Post ID"
+// @Param schedule body models.ReschedulePostRequest true "New publication time"
+// @Success 200 {object} models.Post "Rescheduled post"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Scheduled post not found"
+// @Failure 409 {object} map[string]string "The post is already being published"
+// @Failure 500 {object} map[string]string "Failed to reschedule post"
+// @Router /api/v1/restricted/scheduled-posts/{pid} [put]
+func ReschedulePost(c echo.Context) error {
+	request := new(models.ReschedulePostRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+	if !request.PublishAt.After(time.Now()) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "publish_at must be in the future"})
+	}
+
+	return updateScheduledPost(c, map[string]interface{}{"publish_at": request.PublishAt}, "Failed to reschedule post")
+}
+
+// CancelScheduledPost godoc
+// @Summary Cancel a scheduled post
+// @Description Stop a scheduled post of the authenticated user from being published. The post is kept as a draft
+// @Tags Posts
+// @Accept json
+// @Produce json
+// @Param pid path int true "Post ID"
+// @Success 200 {object} models.Post "The post, now a draft"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Scheduled post not found"
+// @Failure 409 {object} map[string]string "The post is already being published"
+// @Failure 500 {object} map[string]string "Failed to cancel scheduled post"
+// @Router /api/v1/restricted/scheduled-posts/{pid} [delete]
+func CancelScheduledPost(c echo.Context) error {
+	return updateScheduledPost(c, map[string]interface{}{"publish_at": nil, "visibility": VisibilityDraft}, "Failed to cancel scheduled post")
+}
+
+// updateScheduledPost applies updates to a scheduled post of the authenticated user that is not due yet.
+// A due post belongs to the publisher, changing it now could race with its publication.
+func updateScheduledPost(c echo.Context, updates map[string]interface{}, failure string) error {
+	postID, err := strconv.Atoi(c.Param("pid"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+	userID, _ := helpers.CurrentUserID(c)
+
+	var post models.Post
+	if result := config.DB.Where("user_id = ? AND publish_at IS NOT NULL", userID).First(&post, postID); result.Error != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"message": "Scheduled post not found"})
+	}
+
+	result := config.DB.Model(&post).Where("publish_at > ?", time.Now()).Updates(updates)
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": failure})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"message": "The post is already being published"})
+	}
+
+	if err := config.DB.First(&post, post.PostID).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": failure})
+	}
+	post.Entities = entitiesOf(config.DB, post.Message)
+	return c.JSON(http.StatusOK, post)
+}
+
+// PublishDuePosts publishes the scheduled posts whose time has come and returns how many it published.
+// Each post is claimed with a conditional update, in the same transaction as its mention notifications, so a post
+// is published once even with several instances running, and a failed attempt is retried by the next run.
+func PublishDuePosts(db *gorm.DB) (int, error) {
+	var due []models.Post
+	if err := db.Where("publish_at IS NOT NULL AND publish_at <= ?", time.Now()).
+		Order("publish_at ASC, post_id ASC").Limit(scheduledPublishBatch).Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, post := range due {
+		if err := publishScheduledPost(db, post); errors.Is(err, errAlreadyPublished) {
+			continue
+		} else if err != nil {
+			log.Printf("Failed to publish scheduled post %d: %v", post.PostID, err)
+			continue
+		}
+		published++
+	}
+	return published, nil
+}
+
+var errAlreadyPublished = errors.New("post already published or rescheduled")
+
+func publishScheduledPost(db *gorm.DB, post models.Post) error {
+	now := time.Now()
+	return unitofwork.Run(db, func(unit *unitofwork.Unit) error {
+		// The post goes out now, so it takes its place at the top of the feeds
+		result := unit.Tx.Model(&models.Post{}).Where("post_id = ? AND publish_at IS NOT NULL AND publish_at <= ?", post.PostID, now).
+			Updates(map[string]interface{}{"publish_at": nil, "created_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyPublished
+		}
+		if err := unit.Tx.Model(&models.PostHashtag{}).Where("post_id = ?", post.PostID).Update("created_at", now).Error; err != nil {
+			return err
+		}
+
+		post.PublishAt = nil
+		post.CreatedAt = now
+		var mentioned []uint
+		if post.HiddenAt == nil {
+			if err := unit.Tx.Table(postEntityTables.mentions).Where("post_id = ?", post.PostID).Pluck("user_id", &mentioned).Error; err != nil {
+				return err
+			}
+		}
+		unit.AfterCommit(func() {
+			indexPostForSearch(db, post)
+			if post.HiddenAt == nil {
+				notifyMentions(db, mentioned, post.UserID, &post.PostID, nil)
+				post.Entities = entitiesOf(db, post.Message)
+				publishPostCreated(db, post)
+			}
+		})
+		return nil
+	})
+}
+
+// StartScheduledPublisher runs PublishDuePosts right away, to catch up on posts that fell due while the server was down,
+// and then every interval for the lifetime of the process
+func StartScheduledPublisher(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := PublishDuePosts(db); err != nil {
+				log.Println("Failed to publish scheduled posts:", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
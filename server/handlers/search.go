@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"server/helpers"
+	"server/models"
+	"server/search"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Search godoc
+// @Summary Search posts, comments and users
+// @Description Full-text search over post messages, comment text and usernames/names, ordered by relevance.
+// @Description Only public posts and the comments on them are searched.
+// @Description Snippets are HTML escaped and matching words are wrapped in <mark>.
+// @Tags Search
+// @Accept json
+// @Produce json
+// @Param q query string true "Search text"
+// @Param type query string false "Comma separated list of post, comment, user (default: all)"
+// @Param page query int false "Page number, starting at 1"
+// @Param page_size query int false "Results per page (max 50, default 20)"
+// @Success 200 {object} models.SearchResponse "Search results"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to search"
+// @Router /api/v1/search [get]
+func Search(c echo.Context) error {
+	request := new(models.SearchRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	query := search.Query{
+		Text:     strings.TrimSpace(request.Query),
+		Page:     request.Page,
+		PageSize: request.PageSize,
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.PageSize == 0 {
+		query.PageSize = 20
+	}
+	if request.Types != "" {
+		for _, docType := range strings.Split(request.Types, ",") {
+			docType = strings.TrimSpace(docType)
+			if docType != search.TypePost && docType != search.TypeComment && docType != search.TypeUser {
+				return c.JSON(http.StatusBadRequest, map[string]string{"message": "Unknown search type: " + docType})
+			}
+			query.Types = append(query.Types, docType)
+		}
+	}
+
+	if search.Default == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Search is not available"})
+	}
+
+	results, total, err := search.Default.Search(query)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to search"})
+	}
+
+	return c.JSON(http.StatusOK, models.SearchResponse{
+		Query:    query.Text,
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Total:    total,
+		Results:  results,
+	})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"server/helpers"
+	"server/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ServiceError is an error of a Service call with the HTTP status the REST routes answer it with.
+// Failures of the database and such are logged and reported with a generic message.
+type ServiceError struct {
+	Status  int
+	Message string
+}
+
+func (e *ServiceError) Error() string {
+	return e.Message
+}
+
+// Service runs the user, post and comment operations for a caller. The REST handlers, GraphQL and gRPC all go
+// through it, so every API shares the validation, visibility rules, content filter, notifications and audit.
+type Service struct {
+	// UserID is the user the operations run for, 0 for anonymous callers
+	UserID uint
+	// APIKey is the key the caller authenticated with, nil otherwise. See Authorize.
+	APIKey *models.APIKey
+	// IP and RequestID of the call are recorded in the audit log
+	IP        string
+	RequestID string
+	// BaseURL is the scheme and host attachment and avatar URLs start with, they are relative when it is empty
+	BaseURL string
+}
+
+// ServiceFor returns the Service of a REST request, the middleware of its route already checked the caller
+func ServiceFor(c echo.Context) Service {
+	userID, _ := helpers.CurrentUserID(c)
+	service := Service{
+		UserID:    userID,
+		IP:        c.RealIP(),
+		RequestID: requestID(c),
+		BaseURL:   baseURL(c),
+	}
+	if apiKey, ok := c.Get(APIKeyContextKey).(models.APIKey); ok {
+		service.APIKey = &apiKey
+	}
+	return service
+}
+
+// Authorize checks the caller like the middleware of the REST routes, for the APIs without it. Restricted operations
+// need an active account, the others drop the user of an account that cannot be used and run for an anonymous
+// caller. With an API key the operation needs scope, "" for the operations API keys cannot run.
+func (s *Service) Authorize(restricted bool, scope string) error {
+	if s.UserID == 0 {
+		if restricted {
+			return &ServiceError{Status: http.StatusUnauthorized, Message: "Unauthorized"}
+		}
+		return nil
+	}
+
+	status, message, err := checkAccount(s.UserID)
+	if err != nil {
+		log.Println("Failed to check account:", err)
+		return &ServiceError{Status: http.StatusInternalServerError, Message: "Failed to check account"}
+	}
+	if status != 0 {
+		if restricted {
+			return &ServiceError{Status: status, Message: message}
+		}
+		s.UserID = 0
+		s.APIKey = nil
+		return nil
+	}
+
+	if s.APIKey != nil {
+		if scope == "" {
+			return &ServiceError{Status: http.StatusForbidden, Message: "API keys cannot be used for this request"}
+		}
+		if !apiKeyHasScope(*s.APIKey, scope) {
+			return &ServiceError{Status: http.StatusForbidden, Message: "The API key is missing the " + scope + " scope"}
+		}
+	}
+	return nil
+}
+
+// auditEntry starts an audit log entry for the caller
+func (s Service) auditEntry(action string, targetType string, targetID interface{}) models.AuditLog {
+	entry := models.AuditLog{
+		ActorType:  AuditActorAnonymous,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprint(targetID),
+		IP:         s.IP,
+		RequestID:  s.RequestID,
+	}
+	if s.UserID != 0 {
+		userID := s.UserID
+		entry.ActorType = AuditActorUser
+		entry.ActorID = &userID
+	}
+	return entry
+}
+
+// validateRequest checks the validate tags of a request like the REST routes do when they bind it
+func validateRequest(request interface{}) error {
+	if err := helpers.ValidateStruct(request); err != nil {
+		return &ServiceError{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+	return nil
+}
+
+// failed logs err and returns the generic error of an operation
+func failed(message string, err error) error {
+	log.Println(message+":", err)
+	return &ServiceError{Status: http.StatusInternalServerError, Message: message}
+}
+
+// serviceError answers a REST request with the error of a Service call
+func serviceError(c echo.Context, err error) error {
+	var serviceError *ServiceError
+	if errors.As(err, &serviceError) {
+		return c.JSON(serviceError.Status, map[string]string{"message": serviceError.Message})
+	}
+	return err
+}
+
+// baseURL returns the scheme and host of the request
+func baseURL(c echo.Context) string {
+	return c.Scheme() + "://" + c.Request().Host
+}
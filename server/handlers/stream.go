@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"server/pubsub"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// How often a comment line is sent so proxies do not close an idle stream
+var streamHeartbeat = 25 * time.Second
+
+// Stream godoc
+// @Summary Stream feed updates
+// @Description Server-Sent Events stream of new posts, new comments on the subscribed posts and the notifications of the authenticated user.
+// @Description EventSource cannot send headers, so the JWT can also be passed in the token query parameter.
+// @Description Posts and comments by users the viewer blocked or muted are not sent, nor posts the viewer cannot read.
+// @Description Subscribing to the comments of a post the viewer cannot read fails with 404
+// @Tags Stream
+// @Produce text/event-stream
+// @Param token query string false "JWT, when the Authorization header cannot be used"
+// @Param post query []int false "Post IDs to receive new comments for" collectionFormat(multi)
+// @Success 200 {string} string "Event stream"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 401 {object} map[string]string "Missing or invalid token"
+// @Failure 404 {object} map[string]string "Post not found"
+// @Router /api/v1/stream [get]
+func Stream(c echo.Context) error {
+	userID, _ := helpers.CurrentUserID(c)
+
+	// Refreshed with every heartbeat, so blocking, muting or following someone applies to open streams shortly after
+	hidden, err := hiddenAuthorIDs(config.DB, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to open stream"})
+	}
+	following, err := followingIDs(config.DB, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to open stream"})
+	}
+
+	topics := []string{pubsub.TopicPosts, pubsub.UserTopic(userID)}
+	for _, value := range c.QueryParams()["post"] {
+		postID, err := strconv.Atoi(value)
+		if err != nil || postID <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid post ID"})
+		}
+		if _, err := findVisiblePost(config.DB, postID, userID); errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"message": "Post not found"})
+		} else if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to open stream"})
+		}
+		topics = append(topics, pubsub.PostTopic(uint(postID)))
+	}
+
+	subscription := pubsub.Default.Subscribe(topics...)
+	defer subscription.Close()
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, "text/event-stream")
+	response.Header().Set(echo.HeaderCacheControl, "no-cache")
+	response.Header().Set(echo.HeaderConnection, "keep-alive")
+	response.Header().Set("X-Accel-Buffering", "no")
+	response.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(response, ": connected\n\n")
+	response.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-heartbeat.C:
+			fmt.Fprint(response, ": ping\n\n")
+			response.Flush()
+			if refreshed, err := hiddenAuthorIDs(config.DB, userID); err == nil {
+				hidden = refreshed
+			}
+			if refreshed, err := followingIDs(config.DB, userID); err == nil {
+				following = refreshed
+			}
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return nil
+			}
+			if hidden[event.AuthorID] || !streamVisible(event, userID, following) {
+				continue
+			}
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(response, "event: %s\ndata: %s\n\n", event.Type, data)
+			response.Flush()
+		}
+	}
+}
+
+// streamVisible reports whether the viewer may read the post an event is about, like canViewPost without a query
+func streamVisible(event pubsub.Event, viewerID uint, following map[uint]bool) bool {
+	switch event.Visibility {
+	case "", VisibilityPublic:
+		return true
+	case VisibilityFollowers:
+		return viewerID != 0 && (event.AuthorID == viewerID || following[event.AuthorID])
+	}
+	return viewerID != 0 && event.AuthorID == viewerID
+}
+
+// publishPostCreated pushes a new post to every stream, in the same shape as GET /api/v1/posts
+func publishPostCreated(db *gorm.DB, post models.Post) {
+	var author models.User
+	if err := db.Select("user_id, username, firstname, surname").First(&author, post.UserID).Error; err != nil {
+		log.Println("Failed to publish post:", err)
+		return
+	}
+
+	data := models.GetPublicPostsRequest{
+		PostID:      post.PostID,
+		Username:    author.Username,
+		Firstname:   author.Firstname,
+		Surname:     author.Surname,
+		Message:     post.Message,
+		Visibility:  post.Visibility,
+		CreatedAt:   post.CreatedAt,
+		UpdatedAt:   post.UpdatedAt,
+		Reactions:   map[string]int64{},
+		MyReactions: []string{},
+		Entities:    post.Entities,
+		Attachments: []models.AttachmentResponse{},
+	}
+	pubsub.Publish(pubsub.TopicPosts, pubsub.Event{
+		Type:       pubsub.EventPostCreated,
+		AuthorID:   post.UserID,
+		Visibility: post.Visibility,
+		Data:       data,
+	})
+
+	// Webhook receivers are outside the platform, they only hear about public posts
+	if post.Visibility == VisibilityPublic {
+		emitWebhookEvent(db, WebhookPostCreated, data)
+	}
+}
+
+// publishCommentCreated pushes a new comment to the streams subscribed to its post, in the same shape as GET /api/v1/comments/:pid
+func publishCommentCreated(db *gorm.DB, comment models.Comment) {
+	var author models.User
+	if err := db.Select("user_id, username").First(&author, comment.AuthorID).Error; err != nil {
+		log.Println("Failed to publish comment:", err)
+		return
+	}
+
+	data := models.GetCommentRequest{
+		CommentID:   comment.CommentID,
+		Username:    author.Username,
+		CommentMSG:  comment.CommentMSG,
+		Reactions:   map[string]int64{},
+		MyReactions: []string{},
+		Entities:    comment.Entities,
+		Attachments: []models.AttachmentResponse{},
+	}
+	pubsub.Publish(pubsub.PostTopic(comment.PostID), pubsub.Event{
+		Type:     pubsub.EventCommentCreated,
+		AuthorID: comment.AuthorID,
+		Data:     data,
+	})
+
+	var post models.Post
+	if err := db.Select("post_id, visibility, hidden_at").First(&post, comment.PostID).Error; err != nil {
+		log.Println("Failed to read post of comment:", err)
+		return
+	}
+	if post.Visibility == VisibilityPublic && post.HiddenAt == nil {
+		emitWebhookEvent(db, WebhookCommentCreated, webhookComment{PostID: comment.PostID, GetCommentRequest: data})
+	}
+}
+
+// publishNotification pushes a notification to the streams of its recipient, in the same shape as GET /api/v1/restricted/notifications
+func publishNotification(db *gorm.DB, notification models.Notification) {
+	var actor models.User
+	if err := db.Select("user_id, username").First(&actor, notification.ActorID).Error; err != nil {
+		log.Println("Failed to publish notification:", err)
+		return
+	}
+
+	pubsub.Publish(pubsub.UserTopic(notification.UserID), pubsub.Event{
+		Type:     pubsub.EventNotification,
+		AuthorID: notification.ActorID,
+		Data: models.NotificationResponse{
+			NotificationID: notification.NotificationID,
+			Type:           notification.Type,
+			ActorID:        notification.ActorID,
+			ActorUsername:  actor.Username,
+			PostID:         notification.PostID,
+			CommentID:      notification.CommentID,
+			ReadAt:         notification.ReadAt,
+			CreatedAt:      notification.CreatedAt,
+		},
+	})
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+)
+
+// GetPostsByTag godoc
+// @Summary List posts by hashtag
+// @Description Get the posts whose message contains the hashtag, newest first. Only the posts the viewer can read are listed.
+// @Description With a valid token, posts by users the viewer blocked or muted are left out
+// @Tags Tags
+// @Accept json
+// @Produce json
+// @Param tag path string true "Hashtag, with or without the leading #"
+// @Param page query int false "Page number, starting at 1"
+// @Param page_size query int false "Posts per page (max 50, default 20)"
+// @Success 200 {array} models.GetPublicPostsRequest "Posts using the hashtag"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to get posts"
+// @Router /api/v1/tags/{tag} [get]
+func GetPostsByTag(c echo.Context) error {
+	request := new(models.PaginationRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+	page, pageSize := pageAndSize(*request)
+
+	tag := strings.ToLower(strings.TrimPrefix(c.Param("tag"), "#"))
+	if tag == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+
+	viewerID, _ := helpers.CurrentUserID(c)
+	var posts []models.GetPublicPostsRequest
+	query := postListQuery(config.DB).
+		Joins("inner join post_hashtags on post_hashtags.post_id = posts.post_id").
+		Joins("inner join hashtags on hashtags.hashtag_id = post_hashtags.hashtag_id").
+		Where("hashtags.tag = ?", tag)
+	if result := excludeHiddenAuthors(feedPosts(query, viewerID), "posts.user_id", viewerID).
+		Order("posts.created_at DESC, posts.post_id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).
+		Scan(&posts); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get posts"})
+	}
+
+	if err := ServiceFor(c).decoratePosts(posts); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get posts"})
+	}
+
+	return c.JSON(http.StatusOK, posts)
+}
+
+// GetTrendingTags godoc
+// @Summary List trending hashtags
+// @Description Get the hashtags used most often in public posts and their comments during the sliding window ending now
+// @Tags Tags
+// @Accept json
+// @Produce json
+// @Param window query string false "Window length as a duration, e.g. 1h or 24h (default 24h, max 168h)"
+// @Param limit query int false "Number of tags (max 50, default 10)"
+// @Success 200 {array} models.TrendingTagResponse "Trending hashtags"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to get trending tags"
+// @Router /api/v1/trending/tags [get]
+func GetTrendingTags(c echo.Context) error {
+	request := new(models.TrendingTagsRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	window := defaultTrendingWindow
+	if request.Window != "" {
+		parsed, err := time.ParseDuration(request.Window)
+		if err != nil || parsed <= 0 || parsed > maxTrendingWindow {
+			return c.JSON(http.StatusBadRequest, map[string]string{"message": "Invalid window"})
+		}
+		window = parsed
+	}
+	limit := request.Limit
+	if limit == 0 {
+		limit = 10
+	}
+
+	since := time.Now().Add(-window)
+	tags := []models.TrendingTagResponse{}
+	if result := config.DB.Raw(`SELECT hashtags.tag AS tag, COUNT(*) AS uses
+		FROM (SELECT post_hashtags.hashtag_id, post_hashtags.created_at FROM post_hashtags
+				INNER JOIN posts ON posts.post_id = post_hashtags.post_id WHERE posts.hidden_at IS NULL AND posts.publish_at IS NULL AND posts.visibility = ?
+			UNION ALL SELECT comment_hashtags.hashtag_id, comment_hashtags.created_at FROM comment_hashtags
+				INNER JOIN comments ON comments.comment_id = comment_hashtags.comment_id
+				INNER JOIN posts ON posts.post_id = comments.post_id
+				WHERE comments.hidden_at IS NULL AND posts.hidden_at IS NULL AND posts.visibility = ?) AS tag_uses
+		INNER JOIN hashtags ON hashtags.hashtag_id = tag_uses.hashtag_id
+		WHERE tag_uses.created_at >= ?
+		GROUP BY hashtags.tag
+		ORDER BY uses DESC, hashtags.tag ASC
+		LIMIT ?`, VisibilityPublic, VisibilityPublic, since, limit).Scan(&tags); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get trending tags"})
+	}
+
+	return c.JSON(http.StatusOK, tags)
+}
+
+// pageAndSize applies the defaults of PaginationRequest
+func pageAndSize(request models.PaginationRequest) (int, int) {
+	page, pageSize := request.Page, request.PageSize
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
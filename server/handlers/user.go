@@ -1,15 +1,17 @@
 package handlers
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"server/config"
 	"server/helpers"
 	"server/models"
+	"server/passwords"
+	"server/search"
 	"strconv"
 
 	"github.com/labstack/echo/v4"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // GetUsers godoc
@@ -62,7 +64,7 @@ func GetUsers(c echo.Context) error {
 
 // LoggedInUser godoc
 // @Summary Log in a user
-// @Description Authenticate a user and return a JWT token
+// @Description Authenticate a user and return a JWT token. Logging in reactivates a deactivated account
 // @Tags Users
 // @Accept json
 // @Produce json
@@ -70,6 +72,8 @@ func GetUsers(c echo.Context) error {
 // @Success 200 {object} map[string]string "Login successful, token returned"
 // @Failure 400 {object} map[string]string "Invalid input"
 // @Failure 401 {object} map[string]string "Invalid username, email, or password"
+// @Failure 401 {object} map[string]string "Account deleted"
+// @Failure 403 {object} map[string]string "Account suspended or banned"
 // @Failure 500 {object} map[string]string "Failed to generate token"
 // @Router /api/v1/login [post]
 func LoggedInUser(c echo.Context) error {
@@ -80,12 +84,36 @@ func LoggedInUser(c echo.Context) error {
 
 	var user models.User
 	if result := config.DB.Where("username = ? OR email = ?", request.Identifier, request.Identifier).First(&user); result.Error != nil {
+		audit(loginAudit(c, AuditLoginFailed, user, request.Identifier, "Unknown username or email"))
 		return c.JSON((http.StatusUnauthorized), map[string]string{"message": "Invalid username or email"})
 	}
-	// Reference: CompareHashAndPassword compares a bcrypt hashed password with its possible plaintext equivalent. Returns nil on success, or an error on failure.
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)); err != nil {
+	// Accepts argon2id and the bcrypt hashes of older accounts, those and hashes with outdated parameters are replaced.
+	// A password over the maximum length cannot match and is not hashed, hashing megabytes of input is a cheap way to load the server.
+	if passwords.Default.TooLong(request.Password) {
+		audit(loginAudit(c, AuditLoginFailed, user, request.Identifier, "Password too long"))
 		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid password"})
 	}
+	matches, outdated := passwords.Default.Verify(user.Password, request.Password)
+	if !matches {
+		audit(loginAudit(c, AuditLoginFailed, user, request.Identifier, "Invalid password"))
+		return c.JSON(http.StatusUnauthorized, map[string]string{"message": "Invalid password"})
+	}
+	if outdated {
+		rehashPassword(config.DB, &user, request.Password)
+	}
+
+	// Suspended or banned by a moderator, a deactivated account comes back when its owner logs in
+	if user.Status == AccountDeactivated {
+		if result := config.DB.Model(&user).Update("status", AccountActive); result.Error != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to reactivate account"})
+		}
+		user.Status = AccountActive
+		audit(loginAudit(c, AuditAccountReactivate, user, request.Identifier, ""))
+	}
+	if status, message := accountRestriction(user); status != 0 {
+		audit(loginAudit(c, AuditLoginFailed, user, request.Identifier, message))
+		return c.JSON(status, map[string]string{"message": message})
+	}
 
 	token, err := helpers.GenerateJWTToken(user)
 	if err != nil {
@@ -98,6 +126,7 @@ func LoggedInUser(c echo.Context) error {
 	if result := config.DB.Model(&user).Where("(username = ? OR email = ?) AND password = ?", request.Identifier, request.Identifier, user.Password).Update("cookie_token", token); result.Error != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update user session token"})
 	}
+	audit(loginAudit(c, AuditLogin, user, request.Identifier, ""))
 
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": "Login successful",
@@ -140,6 +169,10 @@ func CreateUser(c echo.Context) error {
 	// isAdmin should be gain and lost by an admin user only, not through registration.
 	isAdmin := "0"
 
+	if err := checkNewPassword(config.DB, models.User{Username: request.Username}, request.Password); err != nil {
+		return err
+	}
+
 	// Hash Password
 	hashedPassword, err := helpers.HashPassword(request.Password)
 	if err != nil {
@@ -160,9 +193,35 @@ func CreateUser(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Error: Failed to create user. Please try again"})
 	}
 
+	if err := recordPassword(config.DB, user.UserID, user.Password); err != nil {
+		log.Println("Failed to record password history:", err)
+	}
+	search.IndexUser(user)
+	created := models.UserRelationResponse{UserID: user.UserID, Username: user.Username}
+	if user.CreatedAt != nil {
+		created.CreatedAt = *user.CreatedAt
+	}
+	emitWebhookEvent(config.DB, WebhookUserCreated, created)
+
+	entry := auditEntry(c, AuditUserRegister, ReportTargetUser, user.UserID)
+	entry.ActorType, entry.ActorID, entry.ActorName = AuditActorUser, &user.UserID, user.Username
+	audit(entry)
+
 	return c.JSON(http.StatusCreated, user)
 }
 
+// loginAudit describes a login attempt. The actor is the account that was tried, or the identifier when it does not exist.
+func loginAudit(c echo.Context, action string, user models.User, identifier string, details string) models.AuditLog {
+	entry := auditEntry(c, action, ReportTargetUser, "")
+	entry.ActorName = identifier
+	if user.UserID != 0 {
+		entry.ActorType, entry.ActorID, entry.ActorName = AuditActorUser, &user.UserID, user.Username
+		entry.TargetID = fmt.Sprint(user.UserID)
+	}
+	entry.Details = details
+	return entry
+}
+
 // UpdateUser godoc
 // @Summary Update an existing user
 // @Description Update the details of an existing user by ID
@@ -195,6 +254,7 @@ func UpdateUser(c echo.Context) error {
 	}
 
 	// Update user specific fields
+	before := user
 	user.Username = request.Username
 	user.Firstname = request.Firstname
 	user.Surname = request.Surname
@@ -210,6 +270,12 @@ func UpdateUser(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update user"})
 	}
 
+	search.IndexUser(user)
+
+	entry := auditEntry(c, AuditUserUpdate, ReportTargetUser, user.UserID)
+	entry.Changes = auditChanges(before, user)
+	audit(entry)
+
 	return c.JSON(http.StatusOK, user)
 }
 
@@ -245,6 +311,9 @@ func ChangePassword(c echo.Context) error {
 
 	// Handle Password change separately by checking if password is provided in the request
 	if request.Password != "" {
+		if err := checkNewPassword(config.DB, user, request.Password); err != nil {
+			return err
+		}
 		hashedPassword, err := helpers.HashPassword(request.Password)
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to hash password"})
@@ -261,10 +330,17 @@ func ChangePassword(c echo.Context) error {
 	if result := config.DB.Model(&user).Updates(updatedStruct); result.Error != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update password"})
 	}
+	if err := recordPassword(config.DB, user.UserID, user.Password); err != nil {
+		log.Println("Failed to record password history:", err)
+	}
+
+	audit(auditEntry(c, AuditPasswordChange, ReportTargetUser, user.UserID))
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Password updated successfully"})
 }
 
+// References: https://pkg.go.dev/golang.org/x/crypto/argon2
+// Overview: New passwords are hashed with argon2id (RFC 9106), see the passwords package.
 // References: https://pkg.go.dev/golang.org/x/crypto/bcrypt
-// Overview: Package bcrypt implements Provos and Mazières's bcrypt adaptive hashing algorithm.
+// Overview: Older accounts keep bcrypt hashes until their next login. Package bcrypt implements Provos and Mazières's bcrypt adaptive hashing algorithm.
 // See http://www.usenix.org/event/usenix99/provos/provos.pdf
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/models"
+	"server/search"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Post visibility levels
+const (
+	// VisibilityPublic posts can be read by anyone, including anonymous viewers
+	VisibilityPublic = "public"
+	// VisibilityFollowers posts can be read by the author and the users following them
+	VisibilityFollowers = "followers"
+	// VisibilityPrivate posts can only be read by the author
+	VisibilityPrivate = "private"
+	// VisibilityDraft posts are unpublished: only the author can read them, and they stay out of feeds until published
+	VisibilityDraft = "draft"
+)
+
+// visiblePosts restricts a query on posts to the ones the viewer may read. viewerID is 0 for anonymous viewers.
+// Scheduled posts are only visible to their author. Posts hidden by moderators are not filtered here.
+func visiblePosts(query *gorm.DB, viewerID uint) *gorm.DB {
+	if viewerID == 0 {
+		return query.Where("posts.visibility = ? AND posts.publish_at IS NULL", VisibilityPublic)
+	}
+	// The subquery runs on the connection of query, which may be a transaction
+	following := query.Session(&gorm.Session{NewDB: true}).Table("follows").Select("followee_id").Where("follower_id = ?", viewerID)
+	return query.Where("posts.user_id = ? OR (posts.publish_at IS NULL AND (posts.visibility = ? OR (posts.visibility = ? AND posts.user_id IN (?))))",
+		viewerID, VisibilityPublic, VisibilityFollowers, following)
+}
+
+// feedPosts is visiblePosts without drafts and scheduled posts, for every listing of posts
+func feedPosts(query *gorm.DB, viewerID uint) *gorm.DB {
+	return visiblePosts(query, viewerID).Where("posts.visibility <> ? AND posts.publish_at IS NULL", VisibilityDraft)
+}
+
+// canViewPost reports whether the viewer may read the post, including their own drafts and scheduled posts
+func canViewPost(db *gorm.DB, post models.Post, viewerID uint) (bool, error) {
+	switch {
+	case post.PublishAt != nil:
+		return viewerID != 0 && post.UserID == viewerID, nil
+	case post.Visibility == VisibilityPublic:
+		return true, nil
+	case viewerID != 0 && post.UserID == viewerID:
+		return true, nil
+	case viewerID != 0 && post.Visibility == VisibilityFollowers:
+		var follows int64
+		err := db.Model(&models.Follow{}).Where("follower_id = ? AND followee_id = ?", viewerID, post.UserID).Count(&follows).Error
+		return follows > 0, err
+	}
+	return false, nil
+}
+
+// findVisiblePost loads a post that is not hidden by moderators and that the viewer may read.
+// A post the viewer may not read is reported as not found, so its existence does not leak.
+func findVisiblePost(db *gorm.DB, postID interface{}, viewerID uint) (models.Post, error) {
+	var post models.Post
+	if err := db.Where("hidden_at IS NULL").First(&post, postID).Error; err != nil {
+		return post, err
+	}
+	visible, err := canViewPost(db, post, viewerID)
+	if err != nil {
+		return post, err
+	}
+	if !visible {
+		return post, gorm.ErrRecordNotFound
+	}
+	return post, nil
+}
+
+// followingIDs returns the users the viewer follows, as a set
+func followingIDs(db *gorm.DB, viewerID uint) (map[uint]bool, error) {
+	var ids []uint
+	if err := db.Model(&models.Follow{}).Where("follower_id = ?", viewerID).Pluck("followee_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	following := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		following[id] = true
+	}
+	return following, nil
+}
+
+// indexUserForSearch indexes a user unless the account is unlisted
+func indexUserForSearch(user models.User) {
+	if slices.Contains(unlistedAccountStatuses, user.Status) {
+		search.Remove(search.TypeUser, user.UserID)
+		return
+	}
+	search.IndexUser(user)
+}
+
+// indexCommentForSearch indexes a comment that is not hidden when its post is public and not hidden
+func indexCommentForSearch(db *gorm.DB, comment models.Comment) {
+	var post models.Post
+	if comment.HiddenAt != nil || db.Where("hidden_at IS NULL AND publish_at IS NULL AND visibility = ?", VisibilityPublic).First(&post, comment.PostID).Error != nil {
+		search.Remove(search.TypeComment, comment.CommentID)
+		return
+	}
+	search.IndexComment(comment)
+}
+
+// indexPostForSearch keeps the search index in line with the post: search only returns published, public posts
+// and the comments on them, so a post that is not public is removed along with its comments
+func indexPostForSearch(db *gorm.DB, post models.Post) {
+	var comments []models.Comment
+	db.Where("post_id = ?", post.PostID).Find(&comments)
+
+	if post.HiddenAt != nil || post.PublishAt != nil || post.Visibility != VisibilityPublic {
+		search.Remove(search.TypePost, post.PostID)
+		for _, comment := range comments {
+			search.Remove(search.TypeComment, comment.CommentID)
+		}
+		return
+	}
+
+	search.IndexPost(post)
+	for _, comment := range comments {
+		if comment.HiddenAt == nil {
+			search.IndexComment(comment)
+		}
+	}
+}
+
+// GetDrafts godoc
+// @Summary List my drafts
+// @Description List the unpublished posts of the authenticated user. Publish a draft by editing it with another visibility
+// @Tags Posts
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.GetPublicPostsRequest "Drafts"
+// @Failure 500 {object} map[string]string "Failed to get drafts"
+// @Router /api/v1/restricted/drafts [get]
+func GetDrafts(c echo.Context) error {
+	userID, _ := helpers.CurrentUserID(c)
+
+	posts := []models.GetPublicPostsRequest{}
+	if result := postListQuery(config.DB).Where("posts.user_id = ? AND posts.visibility = ?", userID, VisibilityDraft).
+		Order("posts.updated_at DESC").Scan(&posts); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get drafts"})
+	}
+
+	if err := ServiceFor(c).decoratePosts(posts); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get drafts"})
+	}
+
+	return c.JSON(http.StatusOK, posts)
+}
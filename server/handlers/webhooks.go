@@ -0,0 +1,480 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"server/config"
+	"server/helpers"
+	"server/jobs"
+	"server/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Webhook event types
+const (
+	WebhookUserCreated    = "user.created"
+	WebhookPostCreated    = "post.created"
+	WebhookCommentCreated = "comment.created"
+	// WebhookPing is only sent by the test ping endpoint
+	WebhookPing = "ping"
+)
+
+// Headers of a webhook request. The signature is "sha256=" and the hex HMAC-SHA256, keyed with the secret of the webhook,
+// of the timestamp, a dot and the body. Receivers should reject old timestamps to prevent replays.
+const (
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderID        = "X-Webhook-Id"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+// Bytes of the receiver's response kept in the delivery log
+const maxWebhookResponseBody = 1024
+
+// webhookClient sends the deliveries. It does not follow redirects, the registered URL is the one that gets the payload.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// webhookEvent is the JSON body of every delivery
+type webhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// webhookComment is the data of a comment.created event
+type webhookComment struct {
+	PostID uint `json:"post_id"`
+	models.GetCommentRequest
+}
+
+type webhookJobPayload struct {
+	WebhookID uint   `json:"webhook_id"`
+	EventID   string `json:"event_id"`
+	EventType string `json:"event_type"`
+	Body      string `json:"body"`
+}
+
+// SignWebhookPayload returns the value of the signature header for a body sent at timestamp (unix seconds)
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomHex(size int) (string, error) {
+	random := make([]byte, size)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(random), nil
+}
+
+func newWebhookEvent(eventType string, data interface{}) (webhookEvent, []byte, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return webhookEvent{}, nil, err
+	}
+	event := webhookEvent{ID: id, Type: eventType, CreatedAt: time.Now().UTC(), Data: data}
+	body, err := json.Marshal(event)
+	return event, body, err
+}
+
+func webhookSubscribes(webhook models.Webhook, eventType string) bool {
+	for _, subscribed := range strings.Split(webhook.Events, ",") {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// emitWebhookEvent queues a delivery of the event to every active webhook subscribed to its type.
+// The body is built once, so every attempt sends the same bytes and event ID.
+func emitWebhookEvent(db *gorm.DB, eventType string, data interface{}) {
+	var webhooks []models.Webhook
+	if err := db.Where("active = ?", true).Find(&webhooks).Error; err != nil {
+		log.Println("Failed to read webhooks:", err)
+		return
+	}
+
+	var event webhookEvent
+	var body []byte
+	for _, webhook := range webhooks {
+		if !webhookSubscribes(webhook, eventType) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if event, body, err = newWebhookEvent(eventType, data); err != nil {
+				log.Println("Failed to build webhook event:", err)
+				return
+			}
+		}
+		payload := webhookJobPayload{WebhookID: webhook.WebhookID, EventID: event.ID, EventType: eventType, Body: string(body)}
+		if _, err := jobs.Enqueue(db, JobWebhookDelivery, payload); err != nil {
+			log.Println("Failed to queue webhook delivery:", err)
+		}
+	}
+}
+
+// runWebhookDelivery is the queue handler of JobWebhookDelivery, a failed attempt is retried by the queue with backoff
+func runWebhookDelivery(db *gorm.DB) jobs.Handler {
+	return func(ctx context.Context, queued models.Job) error {
+		var payload webhookJobPayload
+		if err := jobs.Decode(queued, &payload); err != nil {
+			return jobs.Permanent(err)
+		}
+
+		var webhook models.Webhook
+		if err := db.First(&webhook, payload.WebhookID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !webhook.Active {
+			return nil
+		}
+
+		_, err := deliverWebhook(ctx, db, webhook, payload.EventID, payload.EventType, []byte(payload.Body), queued.Attempts)
+		return err
+	}
+}
+
+// deliverWebhook sends one attempt and records it in the delivery log. Anything but a 2xx response is an error.
+func deliverWebhook(ctx context.Context, db *gorm.DB, webhook models.Webhook, eventID string, eventType string, body []byte, attempt int) (models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{WebhookID: webhook.WebhookID, EventID: eventID, EventType: eventType, Attempt: attempt}
+
+	err := func() error {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		timestamp := time.Now().Unix()
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		request.Header.Set("User-Agent", "server-webhooks/1")
+		request.Header.Set(WebhookHeaderEvent, eventType)
+		request.Header.Set(WebhookHeaderID, eventID)
+		request.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+		request.Header.Set(WebhookHeaderSignature, SignWebhookPayload(webhook.Secret, timestamp, body))
+
+		started := time.Now()
+		response, err := webhookClient.Do(request)
+		delivery.DurationMS = time.Since(started).Milliseconds()
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		delivery.StatusCode = response.StatusCode
+		received, _ := io.ReadAll(io.LimitReader(response.Body, maxWebhookResponseBody))
+		// Receivers may answer with anything, the column only stores text
+		delivery.ResponseBody = strings.ToValidUTF8(string(received), "\uFFFD")
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return fmt.Errorf("receiver answered %d", response.StatusCode)
+		}
+		return nil
+	}()
+
+	delivery.Success = err == nil
+	if err != nil {
+		delivery.Error = truncateRunes(err.Error(), 255)
+	}
+	if result := db.Create(&delivery); result.Error != nil {
+		log.Println("Failed to record webhook delivery:", result.Error)
+	}
+	return delivery, err
+}
+
+// GetWebhooks godoc
+// @Summary List webhooks
+// @Description List the registered webhook endpoints
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.WebhookResponse "Webhooks"
+// @Failure 500 {object} map[string]string "Failed to get webhooks"
+// @Router /api/v1/admin/webhooks [get]
+func GetWebhooks(c echo.Context) error {
+	var webhooks []models.Webhook
+	if result := config.DB.Order("webhook_id ASC").Find(&webhooks); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get webhooks"})
+	}
+
+	responses := make([]models.WebhookResponse, len(webhooks))
+	for i, webhook := range webhooks {
+		responses[i] = webhookResponse(webhook)
+	}
+	return c.JSON(http.StatusOK, responses)
+}
+
+// CreateWebhook godoc
+// @Summary Register a webhook
+// @Description Register an endpoint that receives the subscribed events as signed JSON POST requests.
+// @Description The secret used for the signatures is only returned in this response
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body models.CreateWebhookRequest true "Endpoint and events"
+// @Success 201 {object} models.WebhookResponse "Webhook with its secret"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Failed to create webhook"
+// @Router /api/v1/admin/webhooks [post]
+func CreateWebhook(c echo.Context) error {
+	request := new(models.CreateWebhookRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create webhook"})
+	}
+	webhook := models.Webhook{
+		URL:         request.URL,
+		Secret:      secret,
+		Events:      strings.Join(request.Events, ","),
+		Description: request.Description,
+		Active:      true,
+	}
+	if result := config.DB.Create(&webhook); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to create webhook"})
+	}
+	entry := auditEntry(c, AuditWebhookCreate, "webhook", webhook.WebhookID)
+	entry.Details = webhook.URL
+	audit(entry)
+
+	response := webhookResponse(webhook)
+	response.Secret = webhook.Secret
+	return c.JSON(http.StatusCreated, response)
+}
+
+// GetWebhook godoc
+// @Summary Get a webhook
+// @Description Get a registered webhook endpoint
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param wid path int true "Webhook ID"
+// @Success 200 {object} models.WebhookResponse "Webhook"
+// @Failure 404 {object} map[string]string "Webhook not found"
+// @Router /api/v1/admin/webhooks/{wid} [get]
+func GetWebhook(c echo.Context) error {
+	webhook, err := findWebhook(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, webhookResponse(webhook))
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook
+// @Description Change the URL, events, description of a webhook or pause it. Deliveries already queued for an inactive webhook are dropped
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param wid path int true "Webhook ID"
+// @Param webhook body models.UpdateWebhookRequest true "Changes"
+// @Success 200 {object} models.WebhookResponse "Updated webhook"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Webhook not found"
+// @Failure 500 {object} map[string]string "Failed to update webhook"
+// @Router /api/v1/admin/webhooks/{wid} [put]
+func UpdateWebhook(c echo.Context) error {
+	request := new(models.UpdateWebhookRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+	webhook, err := findWebhook(c)
+	if err != nil {
+		return err
+	}
+
+	before := webhookResponse(webhook)
+	updates := map[string]interface{}{}
+	if request.URL != "" {
+		updates["url"] = request.URL
+	}
+	if len(request.Events) > 0 {
+		updates["events"] = strings.Join(request.Events, ",")
+	}
+	if request.Description != nil {
+		updates["description"] = *request.Description
+	}
+	if request.Active != nil {
+		updates["active"] = *request.Active
+	}
+	if len(updates) > 0 {
+		if result := config.DB.Model(&webhook).Updates(updates); result.Error != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update webhook"})
+		}
+	}
+	if result := config.DB.First(&webhook, webhook.WebhookID); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to update webhook"})
+	}
+
+	entry := auditEntry(c, AuditWebhookUpdate, "webhook", webhook.WebhookID)
+	entry.Changes = auditChanges(before, webhookResponse(webhook))
+	audit(entry)
+
+	return c.JSON(http.StatusOK, webhookResponse(webhook))
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook
+// @Description Delete a webhook with its delivery log. Deliveries already queued are dropped
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param wid path int true "Webhook ID"
+// @Success 200 {object} map[string]string "Webhook deleted"
+// @Failure 404 {object} map[string]string "Webhook not found"
+// @Failure 500 {object} map[string]string "Failed to delete webhook"
+// @Router /api/v1/admin/webhooks/{wid} [delete]
+func DeleteWebhook(c echo.Context) error {
+	webhook, err := findWebhook(c)
+	if err != nil {
+		return err
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", webhook.WebhookID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&webhook).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to delete webhook"})
+	}
+	entry := auditEntry(c, AuditWebhookDelete, "webhook", webhook.WebhookID)
+	entry.Details = webhook.URL
+	audit(entry)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Webhook deleted"})
+}
+
+// PingWebhook godoc
+// @Summary Send a test ping to a webhook
+// @Description Deliver a signed ping event right away, without retries, and return the delivery. Works on inactive webhooks too
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param wid path int true "Webhook ID"
+// @Success 200 {object} models.WebhookDeliveryResponse "Delivery, success tells whether the receiver accepted it"
+// @Failure 404 {object} map[string]string "Webhook not found"
+// @Failure 500 {object} map[string]string "Failed to ping webhook"
+// @Router /api/v1/admin/webhooks/{wid}/ping [post]
+func PingWebhook(c echo.Context) error {
+	webhook, err := findWebhook(c)
+	if err != nil {
+		return err
+	}
+
+	event, body, err := newWebhookEvent(WebhookPing, map[string]uint{"webhook_id": webhook.WebhookID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to ping webhook"})
+	}
+	delivery, _ := deliverWebhook(c.Request().Context(), config.DB, webhook, event.ID, WebhookPing, body, 1)
+
+	return c.JSON(http.StatusOK, webhookDeliveryResponse(delivery))
+}
+
+// GetWebhookDeliveries godoc
+// @Summary List the deliveries of a webhook
+// @Description List the delivery attempts of a webhook, newest first
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param wid path int true "Webhook ID"
+// @Param page query int false "Page number, starting at 1"
+// @Param page_size query int false "Deliveries per page (max 50, default 20)"
+// @Success 200 {array} models.WebhookDeliveryResponse "Deliveries"
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Webhook not found"
+// @Failure 500 {object} map[string]string "Failed to get deliveries"
+// @Router /api/v1/admin/webhooks/{wid}/deliveries [get]
+func GetWebhookDeliveries(c echo.Context) error {
+	request := new(models.PaginationRequest)
+	if err := helpers.BindAndValidateRequest(c, request); err != nil {
+		return err
+	}
+	webhook, err := findWebhook(c)
+	if err != nil {
+		return err
+	}
+
+	page, pageSize := pageAndSize(*request)
+	var deliveries []models.WebhookDelivery
+	if result := config.DB.Where("webhook_id = ?", webhook.WebhookID).Order("created_at DESC, webhook_delivery_id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).Find(&deliveries); result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "Failed to get deliveries"})
+	}
+
+	responses := make([]models.WebhookDeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		responses[i] = webhookDeliveryResponse(delivery)
+	}
+	return c.JSON(http.StatusOK, responses)
+}
+
+// findWebhook loads the webhook of the wid parameter, the error is the response to return
+func findWebhook(c echo.Context) (models.Webhook, error) {
+	var webhook models.Webhook
+	webhookID, err := strconv.Atoi(c.Param("wid"))
+	if err != nil {
+		return webhook, echo.NewHTTPError(http.StatusBadRequest, map[string]string{"message": "Invalid input"})
+	}
+	if result := config.DB.First(&webhook, webhookID); result.Error != nil {
+		return webhook, echo.NewHTTPError(http.StatusNotFound, map[string]string{"message": "Webhook not found"})
+	}
+	return webhook, nil
+}
+
+func webhookResponse(webhook models.Webhook) models.WebhookResponse {
+	return models.WebhookResponse{
+		WebhookID:   webhook.WebhookID,
+		URL:         webhook.URL,
+		Events:      strings.Split(webhook.Events, ","),
+		Description: webhook.Description,
+		Active:      webhook.Active,
+		CreatedAt:   webhook.CreatedAt,
+		UpdatedAt:   webhook.UpdatedAt,
+	}
+}
+
+func webhookDeliveryResponse(delivery models.WebhookDelivery) models.WebhookDeliveryResponse {
+	return models.WebhookDeliveryResponse{
+		WebhookDeliveryID: delivery.WebhookDeliveryID,
+		EventID:           delivery.EventID,
+		EventType:         delivery.EventType,
+		Attempt:           delivery.Attempt,
+		StatusCode:        delivery.StatusCode,
+		Success:           delivery.Success,
+		Error:             delivery.Error,
+		ResponseBody:      delivery.ResponseBody,
+		DurationMS:        delivery.DurationMS,
+		CreatedAt:         delivery.CreatedAt,
+	}
+}
@@ -22,6 +22,13 @@ func NewValidator() *CustomValidator {
 	return &CustomValidator{validator: validator.New()}
 }
 
+var structValidator = validator.New()
+
+// ValidateStruct checks the validate tags of req, for the requests that do not come in through Echo
+func ValidateStruct(req interface{}) error {
+	return structValidator.Struct(req)
+}
+
 func BindAndValidateRequest(c echo.Context, req interface{}) error {
 	// Bind request data
 	// Note: Should return an error instead of JSON failed response
@@ -0,0 +1,24 @@
+package helpers
+
+import (
+	"server/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// CurrentUserID returns the user ID of the JWT attached to the request, if any.
+// Public routes use an optional JWT middleware, so a missing token is not an error here.
+func CurrentUserID(c echo.Context) (uint, bool) {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok || token == nil || !token.Valid {
+		return 0, false
+	}
+
+	claims, ok := token.Claims.(*models.JWTClaims)
+	if !ok || claims.UserID == 0 {
+		return 0, false
+	}
+
+	return claims.UserID, true
+}
@@ -0,0 +1,104 @@
+package helpers
+
+import (
+	"server/models"
+	"strings"
+	"unicode"
+)
+
+const (
+	EntityHashtag = "hashtag"
+	EntityMention = "mention"
+
+	maxHashtagLength = 64
+	maxMentionLength = 32
+)
+
+// ExtractEntities finds #hashtags and @mentions in text.
+// A sign only starts an entity at the beginning of the text or after a character that cannot be part of a word,
+// so "mail@example.com" or "issue#12" are ignored. Hashtag values are lowercased, mention values are kept as written.
+// Offsets are code point offsets and End is exclusive.
+func ExtractEntities(text string) []models.TextEntity {
+	runes := []rune(text)
+	entities := []models.TextEntity{}
+
+	for i := 0; i < len(runes); i++ {
+		sign := runes[i]
+		if sign != '#' && sign != '@' {
+			continue
+		}
+		if i > 0 && (isTagRune(runes[i-1]) || runes[i-1] == '#' || runes[i-1] == '@') {
+			continue
+		}
+
+		j := i + 1
+		if sign == '#' {
+			for j < len(runes) && isTagRune(runes[j]) {
+				j++
+			}
+			value := string(runes[i+1 : j])
+			if j-i-1 == 0 || j-i-1 > maxHashtagLength || isAllDigits(value) {
+				i = j - 1
+				continue
+			}
+			entities = append(entities, models.TextEntity{Type: EntityHashtag, Value: strings.ToLower(value), Start: i, End: j})
+		} else {
+			for j < len(runes) && isMentionRune(runes[j]) {
+				j++
+			}
+			// A trailing dot or hyphen is punctuation, not part of the username
+			for j > i+1 && (runes[j-1] == '.' || runes[j-1] == '-') {
+				j--
+			}
+			length := j - i - 1
+			if length < 3 || length > maxMentionLength {
+				i = j - 1
+				continue
+			}
+			entities = append(entities, models.TextEntity{Type: EntityMention, Value: string(runes[i+1 : j]), Start: i, End: j})
+		}
+		i = j - 1
+	}
+
+	return entities
+}
+
+// HashtagsOf returns the distinct hashtags of the entities
+func HashtagsOf(entities []models.TextEntity) []string {
+	return distinctValues(entities, EntityHashtag)
+}
+
+// MentionsOf returns the distinct mentioned usernames of the entities
+func MentionsOf(entities []models.TextEntity) []string {
+	return distinctValues(entities, EntityMention)
+}
+
+func distinctValues(entities []models.TextEntity, entityType string) []string {
+	seen := make(map[string]bool)
+	values := []string{}
+	for _, entity := range entities {
+		if entity.Type != entityType || seen[entity.Value] {
+			continue
+		}
+		seen[entity.Value] = true
+		values = append(values, entity.Value)
+	}
+	return values
+}
+
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func isMentionRune(r rune) bool {
+	return isTagRune(r) || r == '.' || r == '-'
+}
+
+func isAllDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -2,19 +2,14 @@ package helpers
 
 import (
 	"errors"
-
-	"golang.org/x/crypto/bcrypt"
+	"server/passwords"
 )
 
+// HashPassword hashes with argon2id and the parameters of the password policy
 func HashPassword(password string) (string, error) {
 	if password == "" {
 		return "", errors.New("password is required")
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-
-	return string(hash), nil
+	return passwords.Default.Hash(password)
 }
@@ -0,0 +1,121 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Image content types accepted for attachments, the standard library can decode all of them
+var ImageContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Images with more pixels than this are rejected before decoding, a small file can still expand to gigabytes
+const maxImagePixels = 40_000_000
+
+var ErrUnsupportedImage = errors.New("unsupported image type")
+
+// ProbeImage sniffs the content type from the bytes (the client supplied type is not trusted)
+// and reads the dimensions without decoding the whole image
+func ProbeImage(data []byte) (string, image.Config, error) {
+	contentType := http.DetectContentType(data)
+	if !ImageContentTypes[contentType] {
+		return "", image.Config{}, ErrUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", image.Config{}, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return "", image.Config{}, errors.New("image dimensions out of range")
+	}
+
+	return contentType, config, nil
+}
+
+// Thumbnail returns a JPEG that fits in a size x size box
+func Thumbnail(data []byte, size int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	thumbWidth, thumbHeight := width, height
+	if width > size || height > size {
+		if width >= height {
+			thumbWidth, thumbHeight = size, max(1, height*size/width)
+		} else {
+			thumbWidth, thumbHeight = max(1, width*size/height), size
+		}
+	}
+
+	return encodeJPEG(resample(src, bounds, thumbWidth, thumbHeight))
+}
+
+// Avatar returns a size x size JPEG cropped from the center of the image
+func Avatar(data []byte, size int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+	crop := image.Rect(x0, y0, x0+side, y0+side)
+
+	return encodeJPEG(resample(src, crop, min(size, side), min(size, side)))
+}
+
+// resample scales the area of src to width x height. Each output pixel averages the source pixels it covers, and
+// transparent areas are flattened onto white. The source is copied one band of rows at a time with image/draw, which
+// converts the common decoded formats without going through At for every pixel.
+func resample(src image.Image, area image.Rectangle, width int, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	band := image.NewRGBA(image.Rect(0, 0, area.Dx(), (area.Dy()+height-1)/height))
+	for y := 0; y < height; y++ {
+		y0 := area.Min.Y + y*area.Dy()/height
+		y1 := max(y0+1, area.Min.Y+(y+1)*area.Dy()/height)
+		rows := image.Rect(0, 0, area.Dx(), y1-y0)
+		draw.Draw(band, rows, image.White, image.Point{}, draw.Src)
+		draw.Draw(band, rows, src, image.Pt(area.Min.X, y0), draw.Over)
+
+		for x := 0; x < width; x++ {
+			x0 := x * area.Dx() / width
+			x1 := max(x0+1, (x+1)*area.Dx()/width)
+
+			var r, g, b, n int
+			for sy := 0; sy < y1-y0; sy++ {
+				pix := band.Pix[sy*band.Stride+x0*4 : sy*band.Stride+x1*4]
+				for i := 0; i < len(pix); i += 4 {
+					r, g, b = r+int(pix[i]), g+int(pix[i+1]), b+int(pix[i+2])
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: 0xff})
+		}
+	}
+	return dst
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -1,13 +1,11 @@
 package helpers
 
 import (
-	"os"
 	"server/models"
-	"time"
-
-	"github.com/golang-jwt/jwt/v5"
+	"server/tokens"
 )
 
+// GenerateJWTToken issues a token for the user, signed with the current key of tokens.Default
 func GenerateJWTToken(user models.User) (string, error) {
 	// Best standard is to have a standard claim as another object
 	// Reference: https://pkg.go.dev/github.com/golang-jwt/jwt/v5#NewWithClaims
@@ -17,17 +15,7 @@ func GenerateJWTToken(user models.User) (string, error) {
 		Firstname: user.Firstname,
 		Surname:   user.Surname,
 		Admin:     user.IsAdmin,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	jwtSecret := os.Getenv("JWT_SECRET")
-	tokenString, err := token.SignedString([]byte(jwtSecret))
-	if err != nil {
-		return "", err
 	}
 
-	return tokenString, nil
+	return tokens.Default.Sign(&claims)
 }
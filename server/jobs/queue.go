@@ -0,0 +1,494 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"server/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Job statuses
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	// StatusSucceeded jobs are purged once they are older than Config.Retention
+	StatusSucceeded = "succeeded"
+	// StatusDead jobs ran out of attempts or failed permanently, they stay in the table until an admin retries them
+	StatusDead = "dead"
+)
+
+// Defaults of the queue, see Config and Options
+const (
+	DefaultMaxAttempts = 5
+	DefaultTimeout     = 5 * time.Minute
+	DefaultRetention   = 7 * 24 * time.Hour
+)
+
+// purgeInterval is how often a started queue purges the succeeded jobs
+const purgeInterval = time.Hour
+
+// ErrNotRetryable is returned by Retry for a job that is not dead
+var ErrNotRetryable = errors.New("only dead jobs can be retried")
+
+// Handler runs one job. Returning an error schedules another attempt with backoff, unless it is Permanent.
+// A job can run more than once (a worker may die after the work is done but before it is recorded), handlers must be idempotent.
+type Handler func(ctx context.Context, job models.Job) error
+
+// Options of a job type
+type Options struct {
+	// Concurrency is how many jobs of the type run at the same time in this process, 0 means as many as there are workers
+	Concurrency int
+	// MaxAttempts before the job is dead, 0 means DefaultMaxAttempts
+	MaxAttempts int
+	// Timeout of one attempt, 0 means DefaultTimeout. The job is locked a little longer than that, and the lock is extended while the handler runs.
+	Timeout time.Duration
+}
+
+// Config tunes a queue
+type Config struct {
+	// Workers is how many jobs run at the same time in this process, across all types
+	Workers int
+	// PollInterval is how often the table is checked for due jobs, enqueuing from this process also wakes the queue up
+	PollInterval time.Duration
+	// BackoffBase is the delay before the second attempt, it doubles with every attempt up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Retention is how long succeeded jobs are kept before they are purged, 0 means DefaultRetention
+	Retention time.Duration
+}
+
+type registration struct {
+	handler Handler
+	options Options
+}
+
+// Queue claims due jobs from the jobs table and runs them with the handlers registered for their type.
+// Any number of processes can share the table: on MySQL jobs are claimed with SELECT ... FOR UPDATE SKIP LOCKED,
+// elsewhere a conditional update makes sure only one worker gets a job.
+type Queue struct {
+	db       *gorm.DB
+	config   Config
+	workerID string
+
+	mu       sync.Mutex
+	handlers map[string]registration
+	running  map[string]int
+	total    int
+	wake     chan struct{}
+}
+
+// Default is the queue used by the handlers, set by Init
+var Default *Queue
+
+// Init sets up the default queue. JOBS_WORKERS, JOBS_POLL_INTERVAL and JOBS_RETENTION override the number of workers (4),
+// the poll interval (5s) and how long succeeded jobs are kept (7 days).
+func Init(db *gorm.DB) {
+	config := Config{Workers: 4, PollInterval: 5 * time.Second, Retention: DefaultRetention}
+	if workers, err := strconv.Atoi(os.Getenv("JOBS_WORKERS")); err == nil && workers > 0 {
+		config.Workers = workers
+	}
+	if interval, err := time.ParseDuration(os.Getenv("JOBS_POLL_INTERVAL")); err == nil && interval > 0 {
+		config.PollInterval = interval
+	}
+	if retention, err := time.ParseDuration(os.Getenv("JOBS_RETENTION")); err == nil && retention > 0 {
+		config.Retention = retention
+	}
+	Default = New(db, config)
+}
+
+// New returns a queue on the jobs table of db
+func New(db *gorm.DB, config Config) *Queue {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 5 * time.Second
+	}
+	if config.BackoffBase <= 0 {
+		config.BackoffBase = 10 * time.Second
+	}
+	if config.BackoffMax <= 0 {
+		config.BackoffMax = time.Hour
+	}
+	if config.Retention <= 0 {
+		config.Retention = DefaultRetention
+	}
+	hostname, _ := os.Hostname()
+	random := make([]byte, 4)
+	rand.Read(random)
+	return &Queue{
+		db:       db,
+		config:   config,
+		workerID: fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(random)),
+		handlers: make(map[string]registration),
+		running:  make(map[string]int),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Register sets the handler of a job type. Register every type at startup, before Start.
+func (q *Queue) Register(jobType string, handler Handler, options Options) {
+	if options.Concurrency <= 0 || options.Concurrency > q.config.Workers {
+		options.Concurrency = q.config.Workers
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = DefaultMaxAttempts
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = registration{handler: handler, options: options}
+}
+
+// Enqueue stores a job for the default queue, to run as soon as a worker is free. Pass the transaction when the job must
+// only exist if the change that asks for it is committed.
+func Enqueue(db *gorm.DB, jobType string, payload interface{}) (models.Job, error) {
+	return Default.EnqueueAt(db, jobType, payload, time.Now())
+}
+
+// EnqueueAt stores a job for the default queue that does not run before runAt
+func EnqueueAt(db *gorm.DB, jobType string, payload interface{}, runAt time.Time) (models.Job, error) {
+	return Default.EnqueueAt(db, jobType, payload, runAt)
+}
+
+// EnqueueAt stores a job that does not run before runAt, with the attempts registered for its type.
+// A nil queue stores the job with the default attempts for whichever process handles the type.
+func (q *Queue) EnqueueAt(db *gorm.DB, jobType string, payload interface{}, runAt time.Time) (models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return models.Job{}, err
+	}
+	job := models.Job{
+		Type:        jobType,
+		Payload:     string(data),
+		Status:      StatusPending,
+		MaxAttempts: DefaultMaxAttempts,
+		RunAt:       runAt,
+	}
+	if q != nil {
+		q.mu.Lock()
+		if registered, ok := q.handlers[jobType]; ok {
+			job.MaxAttempts = registered.options.MaxAttempts
+		}
+		q.mu.Unlock()
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return job, err
+	}
+	if q != nil {
+		q.Wake()
+	}
+	return job, nil
+}
+
+// Decode reads the payload of a job into target
+func Decode(job models.Job, target interface{}) error {
+	return json.Unmarshal([]byte(job.Payload), target)
+}
+
+// LastAttempt reports whether a failure of the running attempt makes the job dead
+func LastAttempt(job models.Job) bool {
+	return job.Attempts >= job.MaxAttempts
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error as one that retrying will not fix, the job is dead right away
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// Retry puts a dead job back in the queue with a fresh set of attempts
+func Retry(db *gorm.DB, jobID uint) error {
+	result := db.Model(&models.Job{}).Where("job_id = ? AND status = ?", jobID, StatusDead).Updates(map[string]interface{}{
+		"status":       StatusPending,
+		"attempts":     0,
+		"run_at":       time.Now(),
+		"completed_at": nil,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var job models.Job
+		if err := db.First(&job, jobID).Error; err != nil {
+			return err
+		}
+		return ErrNotRetryable
+	}
+	if Default != nil {
+		Default.Wake()
+	}
+	return nil
+}
+
+// Wake makes a started queue look for due jobs now instead of at the next poll
+func (q *Queue) Wake() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs due jobs in the background until ctx is done. Jobs that are running when it stops are finished.
+// Succeeded jobs older than the retention are purged every hour.
+func (q *Queue) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(q.config.PollInterval)
+		defer ticker.Stop()
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		var purged time.Time
+		for {
+			if time.Since(purged) >= purgeInterval {
+				if _, err := q.Purge(); err != nil {
+					log.Println("Failed to purge succeeded jobs:", err)
+				}
+				purged = time.Now()
+			}
+			if err := q.recoverAbandoned(); err != nil {
+				log.Println("Failed to recover abandoned jobs:", err)
+			}
+			if _, err := q.dispatch(ctx, &wg); err != nil {
+				log.Println("Failed to claim jobs:", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-q.wake:
+			}
+		}
+	}()
+}
+
+// Purge deletes the jobs that succeeded longer than the retention ago and returns how many there were.
+// Dead jobs are kept for the admins to look at and retry.
+func (q *Queue) Purge() (int64, error) {
+	result := q.db.Where("status = ? AND completed_at < ?", StatusSucceeded, time.Now().Add(-q.config.Retention)).Delete(&models.Job{})
+	return result.RowsAffected, result.Error
+}
+
+// RunDue runs the jobs that are due until none is left, waiting for each batch to finish, and returns how many ran.
+// Jobs retried with backoff are not due again until later, so it does return.
+func (q *Queue) RunDue(ctx context.Context) (int, error) {
+	ran := 0
+	for {
+		if err := q.recoverAbandoned(); err != nil {
+			return ran, err
+		}
+		var wg sync.WaitGroup
+		started, err := q.dispatch(ctx, &wg)
+		wg.Wait()
+		ran += started
+		if err != nil || started == 0 {
+			return ran, err
+		}
+	}
+}
+
+// dispatch claims as many due jobs as there are free slots and runs each in its own goroutine
+func (q *Queue) dispatch(ctx context.Context, wg *sync.WaitGroup) (int, error) {
+	started := 0
+	q.mu.Lock()
+	types := make(map[string]registration, len(q.handlers))
+	for jobType, registered := range q.handlers {
+		types[jobType] = registered
+	}
+	q.mu.Unlock()
+
+	for jobType, registered := range types {
+		q.mu.Lock()
+		free := q.config.Workers - q.total
+		if typeFree := registered.options.Concurrency - q.running[jobType]; typeFree < free {
+			free = typeFree
+		}
+		q.mu.Unlock()
+		if free <= 0 {
+			continue
+		}
+
+		claimed, err := q.claim(jobType, free, registered.options)
+		if err != nil {
+			return started, err
+		}
+		for _, job := range claimed {
+			q.mu.Lock()
+			q.running[jobType]++
+			q.total++
+			q.mu.Unlock()
+
+			wg.Add(1)
+			go func(job models.Job, registered registration) {
+				defer wg.Done()
+				q.execute(ctx, job, registered)
+				q.mu.Lock()
+				q.running[job.Type]--
+				q.total--
+				q.mu.Unlock()
+				q.Wake()
+			}(job, registered)
+			started++
+		}
+	}
+	return started, nil
+}
+
+// claim locks up to limit due jobs of a type for this worker
+func (q *Queue) claim(jobType string, limit int, options Options) ([]models.Job, error) {
+	now := time.Now()
+	lockedUntil := now.Add(lockDuration(options))
+	var claimed []models.Job
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("status = ? AND type = ? AND run_at <= ?", StatusPending, jobType, now).Order("run_at ASC, job_id ASC").Limit(limit)
+		if tx.Dialector.Name() == "mysql" {
+			// Rows locked by another worker are skipped instead of waited for
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		var due []models.Job
+		if err := query.Find(&due).Error; err != nil {
+			return err
+		}
+
+		for _, job := range due {
+			result := tx.Model(&models.Job{}).Where("job_id = ? AND status = ?", job.JobID, StatusPending).Updates(map[string]interface{}{
+				"status":       StatusRunning,
+				"attempts":     gorm.Expr("attempts + 1"),
+				"locked_by":    q.workerID,
+				"locked_until": lockedUntil,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			job.Status = StatusRunning
+			job.Attempts++
+			job.LockedBy = q.workerID
+			job.LockedUntil = &lockedUntil
+			claimed = append(claimed, job)
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+// lockDuration is how long a claimed job stays locked without being extended, a little longer than one attempt
+func lockDuration(options Options) time.Duration {
+	return options.Timeout + options.Timeout/10 + time.Second
+}
+
+// execute runs a claimed job and records the outcome. The lock is extended while the handler runs,
+// so a handler that outlives its timeout is not taken for abandoned and run a second time next to itself.
+func (q *Queue) execute(ctx context.Context, job models.Job, registered registration) {
+	attemptCtx, cancel := context.WithTimeout(ctx, registered.options.Timeout)
+	defer cancel()
+
+	stopHeartbeat := q.heartbeat(job, lockDuration(registered.options))
+	err := run(attemptCtx, registered.handler, job)
+	stopHeartbeat()
+	now := time.Now()
+	updates := map[string]interface{}{"locked_by": "", "locked_until": nil}
+	var permanent permanentError
+	switch {
+	case err == nil:
+		updates["status"] = StatusSucceeded
+		updates["completed_at"] = now
+	case errors.As(err, &permanent) || LastAttempt(job):
+		log.Printf("Job %d (%s) is dead after %d attempts: %v", job.JobID, job.Type, job.Attempts, err)
+		updates["status"] = StatusDead
+		updates["last_error"] = err.Error()
+		updates["completed_at"] = now
+	default:
+		updates["status"] = StatusPending
+		updates["last_error"] = err.Error()
+		updates["run_at"] = now.Add(q.backoff(job.Attempts))
+	}
+
+	// Only the worker holding the job records the outcome, a job that ran past its lock may belong to someone else by now
+	if err := q.db.Model(&models.Job{}).Where("job_id = ? AND locked_by = ?", job.JobID, q.workerID).Updates(updates).Error; err != nil {
+		log.Printf("Failed to record the outcome of job %d: %v", job.JobID, err)
+	}
+}
+
+// heartbeat pushes back the lock of a running job every third of the lock duration, until the returned function is called.
+// It gives up when the job is no longer locked by this worker.
+func (q *Queue) heartbeat(job models.Job, lock time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lock / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			result := q.db.Model(&models.Job{}).Where("job_id = ? AND status = ? AND locked_by = ?", job.JobID, StatusRunning, q.workerID).
+				Update("locked_until", time.Now().Add(lock))
+			if result.Error != nil {
+				log.Printf("Failed to extend the lock of job %d: %v", job.JobID, result.Error)
+			} else if result.RowsAffected == 0 {
+				log.Printf("Job %d (%s) is no longer locked by this worker", job.JobID, job.Type)
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// run calls the handler, turning a panic into an error so one bad job does not take the process down
+func run(ctx context.Context, handler Handler, job models.Job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff is the delay before the next attempt after the given number of attempts
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.config.BackoffBase
+	for i := 1; i < attempts && delay < q.config.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > q.config.BackoffMax {
+		delay = q.config.BackoffMax
+	}
+	return delay
+}
+
+// recoverAbandoned puts back running jobs whose lock expired, their worker died or lost the database.
+// The attempt counts, a job that keeps killing its worker ends up dead.
+func (q *Queue) recoverAbandoned() error {
+	now := time.Now()
+	if err := q.db.Model(&models.Job{}).Where("status = ? AND locked_until < ? AND attempts >= max_attempts", StatusRunning, now).
+		Updates(map[string]interface{}{"status": StatusDead, "locked_by": "", "locked_until": nil, "last_error": "Abandoned by its worker", "completed_at": now}).Error; err != nil {
+		return err
+	}
+	return q.db.Model(&models.Job{}).Where("status = ? AND locked_until < ?", StatusRunning, now).
+		Updates(map[string]interface{}{"status": StatusPending, "locked_by": "", "locked_until": nil, "last_error": "Abandoned by its worker", "run_at": now}).Error
+}
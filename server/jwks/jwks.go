@@ -0,0 +1,111 @@
+// Package jwks reads and writes JSON Web Key Sets (RFC 7517), the format public signing keys are published in
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// Key is a public key of a key set
+type Key struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// Set is a JSON Web Key Set
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+// PublicKeys returns the signing keys of the set by kid. Encryption keys and keys that cannot be decoded are skipped.
+func (set Set) PublicKeys() map[string]interface{} {
+	keys := map[string]interface{}{}
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if public := key.PublicKey(); public != nil {
+			keys[key.Kid] = public
+		}
+	}
+	return keys
+}
+
+// PublicKey decodes an RSA, EC (P-256, P-384, P-521) or Ed25519 key, nil when it is not one of them or invalid
+func (key Key) PublicKey() interface{} {
+	switch key.Kty {
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(key.N)
+		e, errE := base64.RawURLEncoding.DecodeString(key.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(key.X)
+		y, errY := base64.RawURLEncoding.DecodeString(key.Y)
+		if errX != nil || errY != nil {
+			return nil
+		}
+		public := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(public.X, public.Y) {
+			return nil
+		}
+		return public
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if key.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil
+		}
+		return ed25519.PublicKey(x)
+	}
+	return nil
+}
+
+// New describes an RSA, EC or Ed25519 public key as a signing key of a set, ok is false for other key types
+func New(kid string, alg string, public interface{}) (Key, bool) {
+	key := Key{Kid: kid, Alg: alg, Use: "sig"}
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		key.Kty = "RSA"
+		key.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		key.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		key.Kty = "EC"
+		key.Crv = public.Curve.Params().Name
+		key.X = base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, size)))
+		key.Y = base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		key.Kty = "OKP"
+		key.Crv = "Ed25519"
+		key.X = base64.RawURLEncoding.EncodeToString(public)
+	default:
+		return key, false
+	}
+	return key, true
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"path/filepath"
 	"server/config"
+	"server/filter"
 	"server/handlers"
 	"server/helpers"
+	"server/jobs"
+	"server/oidc"
+	"server/passwords"
 	"server/routes"
+	"server/rpc"
+	"server/search"
+	"server/storage"
+	"server/tokens"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
@@ -36,6 +46,36 @@ func main() {
 	// Connect to database
 	config.ConnectDatabase()
 
+	// Pick the search engine (MySQL FULLTEXT or in-memory index) for the connected database
+	search.Init(config.DB)
+
+	// Blob storage for attachments (local filesystem in STORAGE_DIR), download URLs are signed with STORAGE_SIGNING_KEY
+	storage.Init()
+
+	// Content filter pipeline run before posts and comments are stored (FILTER_* variables)
+	filter.Init(config.DB)
+
+	// JWT signing keys (JWT_ALGORITHM, JWT_TTL, JWT_KEY_ROTATION, ...), the next key is created ahead of its rotation
+	tokens.Init(config.DB)
+	tokens.Default.StartRotation(config.DB, time.Hour)
+
+	// Password policy and argon2id parameters (PASSWORD_* variables)
+	passwords.Init()
+
+	// External login providers (OIDC_PROVIDERS and OIDC_{NAME}_* variables)
+	oidc.Init()
+
+	// Durable background job queue (JOBS_WORKERS, JOBS_POLL_INTERVAL, JOBS_RETENTION), every job type is registered before it starts
+	jobs.Init(config.DB)
+	handlers.RegisterJobs(jobs.Default, config.DB)
+	jobs.Default.Start(context.Background())
+
+	// Delete personal data exports once they expire (EXPORT_TTL, 24h by default)
+	handlers.StartExportCleanup(config.DB, time.Hour)
+
+	// Publish scheduled posts once they are due
+	handlers.StartScheduledPublisher(config.DB, 30*time.Second)
+
 	// Start server
 	e := echo.New()
 	e.Use(handlers.ServerHeader)
@@ -45,11 +85,19 @@ func main() {
 
 	// Middleware
 	e.Use(middleware.Recover())
+	// Every response carries an X-Request-Id, the audit log records it to correlate entries with requests
+	e.Use(middleware.RequestID())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"http://localhost:3000"}, // Since this application is for demo purposes only, we will allow only localhost:3000
 		AllowMethods: []string{echo.GET, echo.POST, echo.PUT, echo.DELETE},
 	}))
 
 	routes.SetupRoutes(e)
+
+	// gRPC API for other services on its own port (GRPC_ADDR, :50051 by default), it runs the same operations as REST
+	go func() {
+		e.Logger.Fatal(rpc.Serve())
+	}()
+
 	e.Logger.Fatal(e.Start(":1323"))
 }
@@ -0,0 +1,3 @@
+DROP INDEX ft_users_names ON users;
+DROP INDEX ft_comments_comment_msg ON comments;
+DROP INDEX ft_posts_message ON posts;
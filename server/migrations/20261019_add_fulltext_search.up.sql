@@ -0,0 +1,3 @@
+CREATE FULLTEXT INDEX ft_posts_message ON posts (message);
+CREATE FULLTEXT INDEX ft_comments_comment_msg ON comments (comment_msg);
+CREATE FULLTEXT INDEX ft_users_names ON users (username, firstname, surname);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS comment_reaction_counts;
+DROP TABLE IF EXISTS post_reaction_counts;
+DROP TABLE IF EXISTS comment_reactions;
+DROP TABLE IF EXISTS post_reactions;
@@ -0,0 +1,21 @@
+-- Images attached to a post or a comment (exactly one of post_id and comment_id is set).
+-- The bytes live in the blob store, storage_key and thumbnail_key point at them.
+CREATE TABLE IF NOT EXISTS attachments(
+    attachment_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    post_id INT NULL,
+    comment_id INT NULL,
+    content_type VARCHAR(64) NOT NULL,
+    size BIGINT NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    thumbnail_key VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_attachments_post_id (post_id),
+    INDEX idx_attachments_comment_id (comment_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(post_id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE
+);
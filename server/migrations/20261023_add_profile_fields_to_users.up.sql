@@ -0,0 +1,6 @@
+ALTER TABLE users
+    ADD COLUMN display_name VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN bio VARCHAR(280) NOT NULL DEFAULT '',
+    ADD COLUMN location VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN website VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN avatar_key VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,40 @@
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP NULL;
+ALTER TABLE posts ADD COLUMN hidden_at TIMESTAMP NULL;
+ALTER TABLE comments ADD COLUMN hidden_at TIMESTAMP NULL;
+
+-- target_id points at posts, comments or users depending on target_type, so it has no FK.
+-- Reports outlive deleted content as part of the moderation history.
+CREATE TABLE IF NOT EXISTS reports(
+    report_id INT AUTO_INCREMENT PRIMARY KEY,
+    reporter_id INT NOT NULL,
+    target_type VARCHAR(16) NOT NULL,
+    target_id INT NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    details VARCHAR(1000) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'open',
+    assigned_to VARCHAR(64) NOT NULL DEFAULT '',
+    resolved_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_reports_target (target_type, target_id),
+    INDEX idx_reports_status_created (status, created_at),
+    FOREIGN KEY (reporter_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Audit trail of moderator decisions. user_id is the user the action applies to.
+CREATE TABLE IF NOT EXISTS moderation_actions(
+    moderation_action_id INT AUTO_INCREMENT PRIMARY KEY,
+    report_id INT NULL,
+    moderator VARCHAR(64) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    target_type VARCHAR(16) NOT NULL,
+    target_id INT NOT NULL,
+    user_id INT NULL,
+    reason VARCHAR(1000) NOT NULL,
+    suspended_until TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_moderation_actions_report_id (report_id),
+    INDEX idx_moderation_actions_user_id (user_id),
+    FOREIGN KEY (report_id) REFERENCES reports(report_id) ON DELETE SET NULL,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
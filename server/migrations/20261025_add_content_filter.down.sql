@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS filter_events;
+DELETE FROM reports WHERE reporter_id IS NULL;
+ALTER TABLE reports MODIFY COLUMN reporter_id INT NOT NULL;
+ALTER TABLE users DROP COLUMN created_at;
@@ -0,0 +1,21 @@
+-- Existing accounts keep a NULL creation time, so the new-account heuristics leave them alone
+ALTER TABLE users ADD COLUMN created_at TIMESTAMP NULL;
+ALTER TABLE users MODIFY COLUMN created_at TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Reports opened by the content filter have no reporter
+ALTER TABLE reports MODIFY COLUMN reporter_id INT NULL;
+
+CREATE TABLE IF NOT EXISTS filter_events(
+    filter_event_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    target_type VARCHAR(16) NOT NULL,
+    target_id INT NULL,
+    verdict VARCHAR(16) NOT NULL,
+    filter VARCHAR(32) NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    excerpt VARCHAR(1000) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_filter_events_user_id (user_id),
+    INDEX idx_filter_events_verdict_created (verdict, created_at),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
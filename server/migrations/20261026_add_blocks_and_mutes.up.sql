@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS blocks(
+    blocker_id INT NOT NULL,
+    blocked_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    INDEX idx_blocks_blocked_id (blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS mutes(
+    muter_id INT NOT NULL,
+    muted_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (muter_id, muted_id),
+    INDEX idx_mutes_muted_id (muted_id),
+    FOREIGN KEY (muter_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (muted_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
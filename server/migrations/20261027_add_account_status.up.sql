@@ -0,0 +1,3 @@
+-- active, suspended, banned, deactivated or deleted (anonymized)
+ALTER TABLE users ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active';
+UPDATE users SET status = 'suspended' WHERE suspended_until > CURRENT_TIMESTAMP;
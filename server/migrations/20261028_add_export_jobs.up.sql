@@ -0,0 +1,17 @@
+-- Personal data exports. storage_key points at the ZIP archive in the blob store while status is ready,
+-- it is cleared once expires_at has passed and the archive is deleted.
+CREATE TABLE IF NOT EXISTS export_jobs(
+    export_job_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    storage_key VARCHAR(255) NOT NULL DEFAULT '',
+    size BIGINT NOT NULL DEFAULT 0,
+    error VARCHAR(255) NOT NULL DEFAULT '',
+    completed_at TIMESTAMP NULL,
+    expires_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_export_jobs_user_id (user_id),
+    INDEX idx_export_jobs_expires_at (expires_at),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
@@ -0,0 +1,27 @@
+-- Append-only audit log of admin actions, authentication events and account changes.
+-- There are no foreign keys so entries outlive deleted users, and the triggers refuse any update or delete.
+CREATE TABLE IF NOT EXISTS audit_logs(
+    audit_log_id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    actor_type VARCHAR(16) NOT NULL,
+    actor_id INT NULL,
+    actor_name VARCHAR(64) NOT NULL DEFAULT '',
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(16) NOT NULL DEFAULT '',
+    target_id VARCHAR(64) NOT NULL DEFAULT '',
+    details VARCHAR(255) NOT NULL DEFAULT '',
+    changes TEXT NULL,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_audit_logs_actor (actor_type, actor_id),
+    INDEX idx_audit_logs_action (action),
+    INDEX idx_audit_logs_target (target_type, target_id),
+    INDEX idx_audit_logs_request_id (request_id),
+    INDEX idx_audit_logs_created_at (created_at)
+);
+
+CREATE TRIGGER audit_logs_no_update BEFORE UPDATE ON audit_logs
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_logs is append-only';
+
+CREATE TRIGGER audit_logs_no_delete BEFORE DELETE ON audit_logs
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_logs is append-only';
@@ -0,0 +1,2 @@
+DROP INDEX idx_posts_visibility ON posts;
+ALTER TABLE posts DROP COLUMN visibility;
@@ -0,0 +1,3 @@
+-- public, followers, private (only the author) or draft (unpublished)
+ALTER TABLE posts ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public';
+CREATE INDEX idx_posts_visibility ON posts (visibility);
@@ -0,0 +1,2 @@
+DROP INDEX idx_posts_publish_at ON posts;
+ALTER TABLE posts DROP COLUMN publish_at;
@@ -0,0 +1,3 @@
+-- Set while the post is scheduled, cleared when the publisher publishes it
+ALTER TABLE posts ADD COLUMN publish_at TIMESTAMP NULL;
+CREATE INDEX idx_posts_publish_at ON posts (publish_at);
@@ -0,0 +1,11 @@
+-- Keys the server signs its JWTs with, created and rotated by the server itself
+CREATE TABLE IF NOT EXISTS signing_keys(
+    signing_key_id INT AUTO_INCREMENT PRIMARY KEY,
+    kid VARCHAR(64) NOT NULL,
+    algorithm VARCHAR(16) NOT NULL,
+    private_key TEXT NOT NULL,
+    not_before TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_signing_keys_kid (kid),
+    UNIQUE INDEX idx_signing_keys_not_before (not_before)
+);
@@ -0,0 +1,10 @@
+-- Hashes of the last passwords of each user. Existing bcrypt hashes in users.password stay valid,
+-- they are replaced by argon2id hashes when their owners log in.
+CREATE TABLE IF NOT EXISTS password_histories(
+    password_history_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_password_histories_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS comment_users(
+    comment_id INT NOT NULL,
+    user_id INT NOT NULL,
+    PRIMARY KEY (comment_id, user_id),
+    FOREIGN KEY (comment_id) REFERENCES comments(comment_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+INSERT INTO comment_users (comment_id, user_id) SELECT comment_id, author_id FROM comments;
+ALTER TABLE comments DROP FOREIGN KEY fk_comments_author;
+DROP INDEX idx_comments_author_id ON comments;
+ALTER TABLE comments DROP COLUMN author_id;
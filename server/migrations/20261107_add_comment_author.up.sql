@@ -0,0 +1,9 @@
+-- Comments record their author directly instead of through comment_users, which only ever held one author per comment
+ALTER TABLE comments ADD COLUMN author_id INT NULL AFTER post_id;
+UPDATE comments SET author_id = (SELECT MIN(comment_users.user_id) FROM comment_users WHERE comment_users.comment_id = comments.comment_id);
+-- Comments without an author were left behind by a failed insert, GetComments never returned them
+DELETE FROM comments WHERE author_id IS NULL;
+ALTER TABLE comments MODIFY author_id INT NOT NULL;
+CREATE INDEX idx_comments_author_id ON comments (author_id);
+ALTER TABLE comments ADD CONSTRAINT fk_comments_author FOREIGN KEY (author_id) REFERENCES users(user_id) ON DELETE CASCADE;
+DROP TABLE comment_users;
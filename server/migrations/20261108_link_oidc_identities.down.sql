@@ -0,0 +1,2 @@
+ALTER TABLE oidc_login_states DROP COLUMN user_id;
+ALTER TABLE users DROP COLUMN email_verified_at;
@@ -0,0 +1,6 @@
+-- External logins are only linked by email to accounts whose email was verified, which are the accounts a provider created.
+-- Accounts registered with a password link a provider themselves, oidc_login_states.user_id is who started the link.
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP NULL;
+UPDATE users SET email_verified_at = CURRENT_TIMESTAMP
+    WHERE password = '' AND EXISTS (SELECT 1 FROM user_identities WHERE user_identities.user_id = users.user_id AND user_identities.email = users.email);
+ALTER TABLE oidc_login_states ADD COLUMN user_id INT NULL AFTER code_verifier;
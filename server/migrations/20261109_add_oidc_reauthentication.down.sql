@@ -0,0 +1,2 @@
+ALTER TABLE oidc_login_states DROP COLUMN reauthenticate;
+ALTER TABLE user_identities DROP COLUMN authenticated_at;
@@ -0,0 +1,3 @@
+-- Accounts created by an external provider have no password, a recent sign-in at the provider confirms them instead
+ALTER TABLE user_identities ADD COLUMN authenticated_at TIMESTAMP NULL AFTER last_login_at;
+ALTER TABLE oidc_login_states ADD COLUMN reauthenticate BOOLEAN NOT NULL DEFAULT FALSE AFTER user_id;
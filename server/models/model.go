@@ -15,18 +15,38 @@ type User struct {
 	Password    string `gorm:"not null" json:"-" validate:"required,min=8"`
 	IsAdmin     string `gorm:"default:'0'" json:"is_admin"`
 	CookieToken string `json:"-"`
-	Posts       []Post
-	Comments    []CommentUser
+	DisplayName string `gorm:"type:varchar(64);not null;default:''" json:"display_name"`
+	Bio         string `gorm:"type:varchar(280);not null;default:''" json:"bio"`
+	Location    string `gorm:"type:varchar(64);not null;default:''" json:"location"`
+	Website     string `gorm:"type:varchar(255);not null;default:''" json:"website"`
+	AvatarKey   string `gorm:"type:varchar(255);not null;default:''" json:"-"`
+	// Status is active, suspended, banned, deactivated or deleted (an anonymized account)
+	Status string `gorm:"type:varchar(16);not null;default:'active'" json:"status"`
+	// SuspendedUntil is set by moderators, the user cannot log in before that time
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	// CreatedAt is unknown for accounts registered before it was recorded
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// EmailVerifiedAt is set when an identity provider vouched for the email of an account it created.
+	// Accounts registered with a password have none, so an external login is never linked to them by email.
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	Posts           []Post     `gorm:"constraint:OnDelete:CASCADE"`
+	Comments        []Comment  `gorm:"foreignKey:AuthorID;constraint:OnDelete:CASCADE"`
 }
 
 // Post represents a post in the system
 // @Description Represents a post created by a user
 type Post struct {
-	PostID    uint   `gorm:"primaryKey"`
-	UserID    uint   `gorm:"not null"`
-	Message   string `gorm:"not null"`
+	PostID  uint   `gorm:"primaryKey"`
+	UserID  uint   `gorm:"not null"`
+	Message string `gorm:"not null"`
+	// Visibility is public, followers, private (only the author) or draft (unpublished)
+	Visibility string `gorm:"type:varchar(16);not null;default:'public';index"`
+	// PublishAt is set while the post is scheduled, only the author can see it until it is published
+	PublishAt *time.Time `gorm:"index" json:",omitempty"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	HiddenAt  *time.Time   `json:",omitempty"`
+	Entities  []TextEntity `gorm:"-" json:",omitempty"`
 }
 
 // Comment represents a comment in the system
@@ -34,17 +54,374 @@ type Post struct {
 type Comment struct {
 	CommentID  uint   `gorm:"primaryKey"`
 	PostID     uint   `gorm:"not null"`
+	AuthorID   uint   `gorm:"not null;index"`
 	CommentMSG string `gorm:"not null"`
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
+	HiddenAt   *time.Time   `json:",omitempty"`
+	Entities   []TextEntity `gorm:"-" json:",omitempty"`
 }
 
-// CommentUser represents the relationship between comments and users
-// @Description Represents the many-to-many relationship between comments and users
-type CommentUser struct {
-	ID        uint `gorm:"primaryKey"`
-	CommentID uint
-	UserID    uint
+// PostReaction represents a reaction left by a user on a post
+// @Description Represents a single reaction (like, love, ...) by a user on a post
+type PostReaction struct {
+	PostID       uint   `gorm:"primaryKey;autoIncrement:false"`
+	UserID       uint   `gorm:"primaryKey;autoIncrement:false"`
+	ReactionType string `gorm:"primaryKey;type:varchar(16)"`
+	CreatedAt    time.Time
+	Post         Post `gorm:"constraint:OnDelete:CASCADE"`
+	User         User `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// CommentReaction represents a reaction left by a user on a comment
+// @Description Represents a single reaction (like, love, ...) by a user on a comment
+type CommentReaction struct {
+	CommentID    uint   `gorm:"primaryKey;autoIncrement:false"`
+	UserID       uint   `gorm:"primaryKey;autoIncrement:false"`
+	ReactionType string `gorm:"primaryKey;type:varchar(16)"`
+	CreatedAt    time.Time
+	Comment      Comment `gorm:"constraint:OnDelete:CASCADE"`
+	User         User    `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// PostReactionCount holds the denormalized number of reactions per type on a post
+// @Description Denormalized reaction counter for a post, kept in sync with post_reactions
+type PostReactionCount struct {
+	PostID       uint   `gorm:"primaryKey;autoIncrement:false"`
+	ReactionType string `gorm:"primaryKey;type:varchar(16)"`
+	Total        int64  `gorm:"not null;default:0"`
+	Post         Post   `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// CommentReactionCount holds the denormalized number of reactions per type on a comment
+// @Description Denormalized reaction counter for a comment, kept in sync with comment_reactions
+type CommentReactionCount struct {
+	CommentID    uint    `gorm:"primaryKey;autoIncrement:false"`
+	ReactionType string  `gorm:"primaryKey;type:varchar(16)"`
+	Total        int64   `gorm:"not null;default:0"`
+	Comment      Comment `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// Hashtag represents a normalized (lowercase) hashtag
+// @Description Represents a hashtag used in posts or comments
+type Hashtag struct {
+	HashtagID uint   `gorm:"primaryKey"`
+	Tag       string `gorm:"type:varchar(64);unique;not null"`
+}
+
+// PostHashtag represents the use of a hashtag in a post
+// @Description Links a post to the hashtags found in its message
+type PostHashtag struct {
+	PostID    uint `gorm:"primaryKey;autoIncrement:false"`
+	HashtagID uint `gorm:"primaryKey;autoIncrement:false"`
+	CreatedAt time.Time
+	Post      Post    `gorm:"constraint:OnDelete:CASCADE"`
+	Hashtag   Hashtag `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// CommentHashtag represents the use of a hashtag in a comment
+// @Description Links a comment to the hashtags found in its message
+type CommentHashtag struct {
+	CommentID uint `gorm:"primaryKey;autoIncrement:false"`
+	HashtagID uint `gorm:"primaryKey;autoIncrement:false"`
+	CreatedAt time.Time
+	Comment   Comment `gorm:"constraint:OnDelete:CASCADE"`
+	Hashtag   Hashtag `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// PostMention represents a user mentioned in a post
+// @Description Links a post to the users @mentioned in its message
+type PostMention struct {
+	PostID    uint `gorm:"primaryKey;autoIncrement:false"`
+	UserID    uint `gorm:"primaryKey;autoIncrement:false"`
+	CreatedAt time.Time
+	Post      Post `gorm:"constraint:OnDelete:CASCADE"`
+	User      User `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// CommentMention represents a user mentioned in a comment
+// @Description Links a comment to the users @mentioned in its message
+type CommentMention struct {
+	CommentID uint `gorm:"primaryKey;autoIncrement:false"`
+	UserID    uint `gorm:"primaryKey;autoIncrement:false"`
+	CreatedAt time.Time
 	Comment   Comment `gorm:"constraint:OnDelete:CASCADE"`
 	User      User    `gorm:"constraint:OnDelete:CASCADE"`
 }
+
+// Follow represents a user following another user
+// @Description Represents the follower/followee relationship between two users
+type Follow struct {
+	FollowerID uint `gorm:"primaryKey;autoIncrement:false"`
+	FolloweeID uint `gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt  time.Time
+	Follower   User `gorm:"foreignKey:FollowerID;constraint:OnDelete:CASCADE"`
+	Followee   User `gorm:"foreignKey:FolloweeID;constraint:OnDelete:CASCADE"`
+}
+
+// Block represents a user blocking another user
+// @Description The blocked user cannot follow the blocker or comment on their posts, and disappears from the blocker's feeds
+type Block struct {
+	BlockerID uint `gorm:"primaryKey;autoIncrement:false"`
+	BlockedID uint `gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt time.Time
+	Blocker   User `gorm:"foreignKey:BlockerID;constraint:OnDelete:CASCADE"`
+	Blocked   User `gorm:"foreignKey:BlockedID;constraint:OnDelete:CASCADE"`
+}
+
+// Mute represents a user muting another user
+// @Description The muted user disappears from the muter's feeds and notifications without being told
+type Mute struct {
+	MuterID   uint `gorm:"primaryKey;autoIncrement:false"`
+	MutedID   uint `gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt time.Time
+	Muter     User `gorm:"foreignKey:MuterID;constraint:OnDelete:CASCADE"`
+	Muted     User `gorm:"foreignKey:MutedID;constraint:OnDelete:CASCADE"`
+}
+
+// Notification represents an in-app notification for a user
+// @Description Represents an event (mention, reply, follow, reaction) another user caused for the recipient
+type Notification struct {
+	NotificationID uint   `gorm:"primaryKey"`
+	UserID         uint   `gorm:"not null;index:idx_notifications_user_read"`
+	ActorID        uint   `gorm:"not null"`
+	Type           string `gorm:"type:varchar(16);not null"`
+	PostID         *uint
+	CommentID      *uint
+	ReadAt         *time.Time `gorm:"index:idx_notifications_user_read"`
+	CreatedAt      time.Time
+	User           User     `gorm:"constraint:OnDelete:CASCADE"`
+	Actor          User     `gorm:"foreignKey:ActorID;constraint:OnDelete:CASCADE"`
+	Post           *Post    `gorm:"constraint:OnDelete:CASCADE"`
+	Comment        *Comment `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// NotificationPreference represents whether a user wants notifications of a given type
+// @Description A missing row means the notification type is enabled
+type NotificationPreference struct {
+	UserID  uint   `gorm:"primaryKey;autoIncrement:false"`
+	Type    string `gorm:"primaryKey;type:varchar(16)"`
+	Enabled bool   `gorm:"not null"`
+	User    User   `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// Attachment represents an image attached to a post or a comment
+// @Description Metadata of an uploaded image, the bytes live in the blob store under StorageKey and ThumbnailKey
+type Attachment struct {
+	AttachmentID uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"not null"`
+	PostID       *uint  `gorm:"index"`
+	CommentID    *uint  `gorm:"index"`
+	ContentType  string `gorm:"type:varchar(64);not null"`
+	Size         int64  `gorm:"not null"`
+	Width        int    `gorm:"not null"`
+	Height       int    `gorm:"not null"`
+	SHA256       string `gorm:"column:sha256;type:char(64);not null"`
+	StorageKey   string `gorm:"type:varchar(255);not null"`
+	ThumbnailKey string `gorm:"type:varchar(255);not null"`
+	CreatedAt    time.Time
+	User         User     `gorm:"constraint:OnDelete:CASCADE"`
+	Post         *Post    `gorm:"constraint:OnDelete:CASCADE"`
+	Comment      *Comment `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// Report represents a user flagging a post, comment or user for moderation
+// @Description A report stays open until a moderator acts on it or dismisses it.
+// @Description ReporterID is empty for content held by the automated content filter
+type Report struct {
+	ReportID   uint   `gorm:"primaryKey"`
+	ReporterID *uint  `gorm:"index"`
+	TargetType string `gorm:"type:varchar(16);not null;index:idx_reports_target"`
+	TargetID   uint   `gorm:"not null;index:idx_reports_target"`
+	Reason     string `gorm:"type:varchar(32);not null"`
+	Details    string `gorm:"type:varchar(1000);not null;default:''"`
+	Status     string `gorm:"type:varchar(16);not null;default:'open';index:idx_reports_status_created"`
+	AssignedTo string `gorm:"type:varchar(64);not null;default:''"`
+	ResolvedAt *time.Time
+	CreatedAt  time.Time `gorm:"index:idx_reports_status_created"`
+	UpdatedAt  time.Time
+	Reporter   *User `gorm:"foreignKey:ReporterID;constraint:OnDelete:CASCADE"`
+}
+
+// ModerationAction represents a decision taken by a moderator, kept as an audit trail
+// @Description TargetType/TargetID name the reported content, UserID the user the action applies to (the author or the reported user)
+type ModerationAction struct {
+	ModerationActionID uint   `gorm:"primaryKey"`
+	ReportID           *uint  `gorm:"index"`
+	Moderator          string `gorm:"type:varchar(64);not null"`
+	Action             string `gorm:"type:varchar(16);not null"`
+	TargetType         string `gorm:"type:varchar(16);not null"`
+	TargetID           uint   `gorm:"not null"`
+	UserID             *uint  `gorm:"index"`
+	Reason             string `gorm:"type:varchar(1000);not null"`
+	SuspendedUntil     *time.Time
+	CreatedAt          time.Time
+	Report             *Report `gorm:"constraint:OnDelete:SET NULL"`
+	User               *User   `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// FilterEvent records content the automated filter rejected or held for review
+// @Description TargetID is empty for rejected content, which is never stored. Excerpt keeps the start of the text for the moderators
+type FilterEvent struct {
+	FilterEventID uint   `gorm:"primaryKey"`
+	UserID        uint   `gorm:"not null;index"`
+	TargetType    string `gorm:"type:varchar(16);not null"`
+	TargetID      *uint
+	Verdict       string    `gorm:"type:varchar(16);not null;index:idx_filter_events_verdict_created"`
+	Filter        string    `gorm:"type:varchar(32);not null"`
+	Reason        string    `gorm:"type:varchar(255);not null"`
+	Excerpt       string    `gorm:"type:varchar(1000);not null;default:''"`
+	CreatedAt     time.Time `gorm:"index:idx_filter_events_verdict_created"`
+	User          User      `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// ExportJob represents a user asking for a copy of their personal data
+// @Description The ZIP archive is built in the background. StorageKey points at it once the job is ready and is cleared when it expires
+type ExportJob struct {
+	ExportJobID uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"not null;index"`
+	Status      string `gorm:"type:varchar(16);not null;default:'pending'"`
+	StorageKey  string `gorm:"type:varchar(255);not null;default:''"`
+	Size        int64  `gorm:"not null;default:0"`
+	Error       string `gorm:"type:varchar(255);not null;default:''"`
+	CompletedAt *time.Time
+	ExpiresAt   *time.Time `gorm:"index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	User        User `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// AuditLog records an admin action, an authentication event or a change to an account. Rows are never updated or deleted
+// @Description ActorType is user, admin or anonymous. TargetID is text because migrations are named, not numbered.
+// @Description Changes holds the fields that changed as {"field": {"from": ..., "to": ...}}
+type AuditLog struct {
+	AuditLogID uint      `gorm:"primaryKey"`
+	ActorType  string    `gorm:"type:varchar(16);not null;index:idx_audit_logs_actor"`
+	ActorID    *uint     `gorm:"index:idx_audit_logs_actor"`
+	ActorName  string    `gorm:"type:varchar(64);not null;default:''"`
+	Action     string    `gorm:"type:varchar(64);not null;index"`
+	TargetType string    `gorm:"type:varchar(16);not null;default:'';index:idx_audit_logs_target"`
+	TargetID   string    `gorm:"type:varchar(64);not null;default:'';index:idx_audit_logs_target"`
+	Details    string    `gorm:"type:varchar(255);not null;default:''"`
+	Changes    string    `gorm:"type:text"`
+	IP         string    `gorm:"column:ip;type:varchar(45);not null;default:''"`
+	RequestID  string    `gorm:"type:varchar(64);not null;default:'';index"`
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+// Job is a unit of background work in the durable job queue
+// @Description Status is pending, running, succeeded or dead (out of attempts). Payload is the JSON given when the job was enqueued.
+// @Description A running job whose LockedUntil has passed was abandoned by its worker and is picked up again
+type Job struct {
+	JobID       uint      `gorm:"primaryKey"`
+	Type        string    `gorm:"type:varchar(64);not null;index:idx_jobs_claim,priority:2"`
+	Payload     string    `gorm:"type:text"`
+	Status      string    `gorm:"type:varchar(16);not null;default:'pending';index:idx_jobs_claim,priority:1"`
+	Attempts    int       `gorm:"not null;default:0"`
+	MaxAttempts int       `gorm:"not null;default:5"`
+	RunAt       time.Time `gorm:"not null;index:idx_jobs_claim,priority:3"`
+	LockedBy    string    `gorm:"type:varchar(128);not null;default:''"`
+	LockedUntil *time.Time
+	LastError   string `gorm:"type:text"`
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Webhook is an endpoint registered by an admin to receive platform events
+// @Description Events is the comma separated list of subscribed event types. Secret signs the payloads, it is only shown when the webhook is created
+type Webhook struct {
+	WebhookID   uint   `gorm:"primaryKey"`
+	URL         string `gorm:"type:varchar(2048);not null"`
+	Secret      string `gorm:"type:varchar(64);not null" json:"-"`
+	Events      string `gorm:"type:varchar(255);not null"`
+	Description string `gorm:"type:varchar(255);not null;default:''"`
+	Active      bool   `gorm:"not null;default:true"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// WebhookDelivery records one attempt to deliver an event to a webhook
+// @Description EventID is the same for every attempt of an event, receivers use it to ignore duplicates
+type WebhookDelivery struct {
+	WebhookDeliveryID uint   `gorm:"primaryKey"`
+	WebhookID         uint   `gorm:"not null;index"`
+	EventID           string `gorm:"type:varchar(64);not null;index"`
+	EventType         string `gorm:"type:varchar(64);not null"`
+	Attempt           int    `gorm:"not null"`
+	StatusCode        int    `gorm:"not null;default:0"`
+	Success           bool   `gorm:"not null"`
+	Error             string `gorm:"type:varchar(255);not null;default:''"`
+	ResponseBody      string `gorm:"type:text"`
+	DurationMS        int64  `gorm:"not null;default:0"`
+	CreatedAt         time.Time
+	Webhook           Webhook `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// APIKey is a personal key a user creates for scripts and bots. Only the SHA-256 of the key is stored
+// @Description Prefix is the start of the key, shown so the owner can tell keys apart. Scopes is a comma separated list of read, post and comment
+type APIKey struct {
+	APIKeyID   uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"not null;index"`
+	Name       string `gorm:"type:varchar(64);not null"`
+	Prefix     string `gorm:"type:varchar(16);not null"`
+	KeyHash    string `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	Scopes     string `gorm:"type:varchar(64);not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+	User       User `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// UserIdentity links an account to a user of an external OpenID Connect provider
+// @Description Subject is the stable ID of the user at the provider, Email is what the provider reported at the last login
+type UserIdentity struct {
+	UserIdentityID uint   `gorm:"primaryKey"`
+	UserID         uint   `gorm:"not null;index"`
+	Provider       string `gorm:"type:varchar(32);not null;uniqueIndex:idx_user_identities_subject"`
+	Subject        string `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_subject"`
+	Email          string `gorm:"type:varchar(255);not null;default:''"`
+	LastLoginAt    *time.Time
+	// AuthenticatedAt is when the user last entered their credentials at the provider, it stands in for the password of accounts without one
+	AuthenticatedAt *time.Time
+	CreatedAt       time.Time
+	User            User `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// OIDCLoginState is a login with an external provider that was started but not finished yet
+// @Description StateHash is the SHA-256 of the state sent to the provider. Rows are deleted when the login finishes or expires
+type OIDCLoginState struct {
+	StateHash    string `gorm:"type:char(64);primaryKey"`
+	Provider     string `gorm:"type:varchar(32);not null"`
+	Nonce        string `gorm:"type:varchar(64);not null"`
+	CodeVerifier string `gorm:"type:varchar(128);not null"`
+	// UserID is the logged in user who started the login to link the provider to their account, nil for a login
+	UserID *uint
+	// Reauthenticate logins asked the provider to prompt for the credentials
+	Reauthenticate bool      `gorm:"not null;default:false"`
+	ExpiresAt      time.Time `gorm:"not null;index"`
+	CreatedAt      time.Time
+}
+
+// SigningKey is a key pair the server signs its JWTs with, identified in the tokens by Kid
+// @Description The newest key whose NotBefore has passed signs new tokens. Older keys are kept, and published, until the tokens they signed expired
+type SigningKey struct {
+	SigningKeyID uint   `gorm:"primaryKey"`
+	Kid          string `gorm:"type:varchar(64);not null;uniqueIndex"`
+	Algorithm    string `gorm:"type:varchar(16);not null"`
+	// PrivateKey is PEM encoded PKCS #8
+	PrivateKey string    `gorm:"type:text;not null" json:"-"`
+	NotBefore  time.Time `gorm:"not null;uniqueIndex"`
+	CreatedAt  time.Time
+}
+
+// PasswordHistory keeps the hashes of the last passwords of a user, so they cannot be used again
+// @Description Rows beyond the history length of the password policy are deleted when a password is set
+type PasswordHistory struct {
+	PasswordHistoryID uint   `gorm:"primaryKey"`
+	UserID            uint   `gorm:"not null;index"`
+	Password          string `gorm:"type:varchar(255);not null" json:"-"`
+	CreatedAt         time.Time
+	User              User `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
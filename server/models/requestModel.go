@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -16,7 +17,8 @@ type CreateUserRequest struct {
 	Firstname string `json:"firstname" validate:"required"`
 	Surname   string `json:"surname" validate:"required"`
 	Email     string `json:"email" validate:"required,email"`
-	Password  string `json:"password" validate:"required,min=8"`
+	// Length and the other rules are the password policy, checked by the handler
+	Password string `json:"password" validate:"required"`
 }
 
 // UpdateUserRequest represents the data needed to update user information
@@ -30,7 +32,8 @@ type UpdateUserRequest struct {
 // UpdateUserPasswordRequest represents the data needed to update a user's password
 // @Description Request model for updating a user's password
 type UpdateUserPasswordRequest struct {
-	Password string `json:"password" validate:"required,min=8"`
+	// Length and the other rules are the password policy, checked by the handler
+	Password string `json:"password" validate:"required"`
 }
 
 // LoginUserRequest represents the data needed for user login
@@ -54,13 +57,21 @@ type JWTClaims struct {
 // GetPublicPostsRequest represents the data for retrieving public posts
 // @Description Response model for retrieving public posts
 type GetPublicPostsRequest struct {
-	PostID    uint      `json:"post_id"`
-	Username  string    `json:"username"`
-	Firstname string    `json:"firstname"`
-	Surname   string    `json:"surname"`
-	Message   string    `json:"post_message"`
-	CreatedAt time.Time `json:"post_created_at"`
-	UpdatedAt time.Time `json:"post_updated_at"`
+	PostID     uint   `json:"post_id"`
+	Username   string `json:"username"`
+	Firstname  string `json:"firstname"`
+	Surname    string `json:"surname"`
+	Message    string `json:"post_message"`
+	Visibility string `json:"visibility"`
+	// PublishAt is only set on scheduled posts
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	CreatedAt time.Time  `json:"post_created_at"`
+	UpdatedAt time.Time  `json:"post_updated_at"`
+	// Reactions, MyReactions, Entities and Attachments are filled in after the query, MyReactions is empty for anonymous viewers
+	Reactions   map[string]int64     `json:"reactions" gorm:"-"`
+	MyReactions []string             `json:"my_reactions" gorm:"-"`
+	Entities    []TextEntity         `json:"entities" gorm:"-"`
+	Attachments []AttachmentResponse `json:"attachments" gorm:"-"`
 }
 
 // GetMigrationListRequest represents the data for retrieving migration information
@@ -79,14 +90,29 @@ type RunMigrationRequest struct {
 // GetCommentRequest represents the data needed to get a comment
 // @Description Request model for get a comment
 type GetCommentRequest struct {
-	Username   string `json:"username"`
-	CommentMSG string `json:"comment_msg"`
+	CommentID   uint                 `json:"comment_id"`
+	Username    string               `json:"username"`
+	CommentMSG  string               `json:"comment_msg"`
+	Reactions   map[string]int64     `json:"reactions" gorm:"-"`
+	MyReactions []string             `json:"my_reactions" gorm:"-"`
+	Entities    []TextEntity         `json:"entities" gorm:"-"`
+	Attachments []AttachmentResponse `json:"attachments" gorm:"-"`
 }
 
 // CreatePostRequest represents the data needed to create a post
 // @Description Request model for creating a post
 type CreatePostRequest struct {
 	Message string `json:"message" validate:"required"`
+	// Visibility defaults to public
+	Visibility string `json:"visibility" validate:"omitempty,oneof=public followers private draft"`
+	// PublishAt schedules the post, it stays hidden until then
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// ReschedulePostRequest represents the new publication time of a scheduled post
+// @Description Request model for rescheduling a post
+type ReschedulePostRequest struct {
+	PublishAt time.Time `json:"publish_at" validate:"required"`
 }
 
 // CreateCommentRequest represents the data needed to create a comment
@@ -95,3 +121,476 @@ type CreateCommentRequest struct {
 	PostID     uint   `json:"post_id" validate:"required"`
 	CommentMSG string `json:"comment_msg" validate:"required"`
 }
+
+// ReactionSummaryResponse represents the aggregated reactions of a post or comment
+// @Description Response model for reaction counts and the reactions of the current user
+type ReactionSummaryResponse struct {
+	Reactions   map[string]int64 `json:"reactions"`
+	MyReactions []string         `json:"my_reactions"`
+}
+
+// SearchRequest represents the query parameters of a search
+// @Description Request model for searching posts, comments and users
+type SearchRequest struct {
+	Query    string `query:"q" validate:"required,min=2,max=200"`
+	Types    string `query:"type"`
+	Page     int    `query:"page" validate:"omitempty,min=1"`
+	PageSize int    `query:"page_size" validate:"omitempty,min=1,max=50"`
+}
+
+// SearchResult represents a single search hit
+// @Description A post, comment or user matching the search query. Snippet is HTML escaped with matches wrapped in <mark>
+type SearchResult struct {
+	Type      string     `json:"type"`
+	ID        uint       `json:"id"`
+	PostID    uint       `json:"post_id,omitempty"`
+	Username  string     `json:"username"`
+	Snippet   string     `json:"snippet"`
+	Score     float64    `json:"score"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// SearchResponse represents a page of search results
+// @Description Response model for search results ordered by relevance
+type SearchResponse struct {
+	Query    string         `json:"query"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+	Total    int64          `json:"total"`
+	Results  []SearchResult `json:"results"`
+}
+
+// TextEntity represents a hashtag or mention found in a post or comment
+// @Description Hashtag or @mention with its position in the text. Start and End are character (code point) offsets, End is exclusive and both include the # or @ sign
+type TextEntity struct {
+	Type   string `json:"type"`
+	Value  string `json:"value"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	UserID uint   `json:"user_id,omitempty"`
+}
+
+// UpdatePostRequest represents the data needed to edit a post
+// @Description Request model for editing a post
+type UpdatePostRequest struct {
+	Message string `json:"message" validate:"required"`
+	// Visibility is kept when empty. A published post cannot go back to draft
+	Visibility string `json:"visibility" validate:"omitempty,oneof=public followers private draft"`
+}
+
+// UpdateCommentRequest represents the data needed to edit a comment
+// @Description Request model for editing a comment
+type UpdateCommentRequest struct {
+	CommentMSG string `json:"comment_msg" validate:"required"`
+}
+
+// TrendingTagResponse represents a hashtag and how often it was used in the trending window
+// @Description Response model for trending hashtags
+type TrendingTagResponse struct {
+	Tag  string `json:"tag"`
+	Uses int64  `json:"uses"`
+}
+
+// PaginationRequest represents the paging query parameters of list endpoints
+// @Description Request model for paging, page starts at 1
+type PaginationRequest struct {
+	Page     int `query:"page" validate:"omitempty,min=1"`
+	PageSize int `query:"page_size" validate:"omitempty,min=1,max=50"`
+}
+
+// TrendingTagsRequest represents the query parameters for trending hashtags
+// @Description Request model for trending hashtags, window is a Go duration such as 1h or 24h
+type TrendingTagsRequest struct {
+	Window string `query:"window"`
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=50"`
+}
+
+// NotificationResponse represents a notification returned to its recipient
+// @Description Response model for a notification
+type NotificationResponse struct {
+	NotificationID uint       `json:"notification_id"`
+	Type           string     `json:"type"`
+	ActorID        uint       `json:"actor_id"`
+	ActorUsername  string     `json:"actor_username"`
+	PostID         *uint      `json:"post_id,omitempty"`
+	CommentID      *uint      `json:"comment_id,omitempty"`
+	ReadAt         *time.Time `json:"read_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// GetNotificationsRequest represents the query parameters for listing notifications
+// @Description Request model for listing notifications
+type GetNotificationsRequest struct {
+	PaginationRequest
+	UnreadOnly bool `query:"unread"`
+}
+
+// UnreadCountResponse represents the number of unread notifications
+// @Description Response model for the unread notification count
+type UnreadCountResponse struct {
+	Unread int64 `json:"unread"`
+}
+
+// AttachmentResponse represents an attachment with its signed URLs
+// @Description Attachment metadata, URL and ThumbnailURL are signed and stop working after ExpiresAt
+type AttachmentResponse struct {
+	AttachmentID uint      `json:"attachment_id"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	SHA256       string    `json:"sha256"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// GetAttachmentRequest represents the signed query of an attachment download
+// @Description Request model for downloading an attachment
+type GetAttachmentRequest struct {
+	Variant   string `query:"variant" validate:"omitempty,oneof=original thumbnail"`
+	Expires   int64  `query:"expires" validate:"required"`
+	Signature string `query:"signature" validate:"required"`
+}
+
+// UpdateProfileRequest represents the profile fields a user can edit
+// @Description Request model for updating the authenticated user's profile, every field is replaced
+type UpdateProfileRequest struct {
+	DisplayName string `json:"display_name" validate:"max=64"`
+	Bio         string `json:"bio" validate:"max=280"`
+	Location    string `json:"location" validate:"max=64"`
+	Website     string `json:"website" validate:"omitempty,http_url,max=255"`
+}
+
+// UserProfileResponse represents the public profile of a user
+// @Description Public profile of a user, it never contains the email or the admin flag
+type UserProfileResponse struct {
+	UserID         uint   `json:"uid"`
+	Username       string `json:"username"`
+	DisplayName    string `json:"display_name"`
+	Firstname      string `json:"firstname"`
+	Surname        string `json:"surname"`
+	Bio            string `json:"bio"`
+	Location       string `json:"location"`
+	Website        string `json:"website"`
+	AvatarURL      string `json:"avatar_url"`
+	PostCount      int64  `json:"post_count"`
+	FollowerCount  int64  `json:"follower_count"`
+	FollowingCount int64  `json:"following_count"`
+	// Following is true when the authenticated viewer follows this user
+	Following bool `json:"following"`
+}
+
+// CreateReportRequest represents the data needed to report content
+// @Description Request model for reporting a post, comment or user
+type CreateReportRequest struct {
+	TargetType string `json:"target_type" validate:"required,oneof=post comment user"`
+	TargetID   uint   `json:"target_id" validate:"required"`
+	Reason     string `json:"reason" validate:"required,oneof=spam harassment hate violence nudity misinformation other"`
+	Details    string `json:"details" validate:"max=1000"`
+}
+
+// GetReportsRequest represents the filters of the moderation queue
+// @Description Request model for listing reports, status defaults to open
+type GetReportsRequest struct {
+	PaginationRequest
+	Status     string `query:"status" validate:"omitempty,oneof=open resolved dismissed all"`
+	TargetType string `query:"target_type" validate:"omitempty,oneof=post comment user"`
+	Reason     string `query:"reason"`
+	AssignedTo string `query:"assigned_to"`
+}
+
+// ReportResponse represents a report in the moderation queue
+// @Description Report with the reporter's username and a preview of the reported content
+type ReportResponse struct {
+	ReportID         uint       `json:"report_id"`
+	ReporterID       *uint      `json:"reporter_id"`
+	ReporterUsername string     `json:"reporter_username"`
+	TargetType       string     `json:"target_type"`
+	TargetID         uint       `json:"target_id"`
+	TargetPreview    string     `json:"target_preview" gorm:"-"`
+	Reason           string     `json:"reason"`
+	Details          string     `json:"details"`
+	Status           string     `json:"status"`
+	AssignedTo       string     `json:"assigned_to"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// AssignReportRequest represents the moderator a report is assigned to
+// @Description Request model for assigning a report, an empty value unassigns it
+type AssignReportRequest struct {
+	AssignedTo string `json:"assigned_to" validate:"max=64"`
+}
+
+// ModerationActionRequest represents a moderator decision on a report
+// @Description hide, unhide and delete apply to reported posts and comments, warn and suspend to the author or the reported user.
+// @Description dismiss closes the report without any action. SuspendHours is required for suspend
+type ModerationActionRequest struct {
+	Action       string `json:"action" validate:"required,oneof=hide unhide delete warn suspend dismiss"`
+	Reason       string `json:"reason" validate:"required,max=1000"`
+	SuspendHours int    `json:"suspend_hours" validate:"omitempty,min=1,max=8760"`
+}
+
+// WarningResponse represents a moderator warning shown to the warned user
+// @Description Warning issued by a moderator about a post, comment or the account itself
+type WarningResponse struct {
+	TargetType string    `json:"target_type"`
+	TargetID   uint      `json:"target_id"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// GetFilterEventsRequest represents the filters of the content filter log
+// @Description Request model for listing content filter events, newest first
+type GetFilterEventsRequest struct {
+	PaginationRequest
+	Verdict string `query:"verdict" validate:"omitempty,oneof=hold reject"`
+	Filter  string `query:"filter"`
+}
+
+// FilterEventResponse represents content the automated filter rejected or held
+// @Description Filter event with the author's username
+type FilterEventResponse struct {
+	FilterEventID uint      `json:"filter_event_id"`
+	UserID        uint      `json:"user_id"`
+	Username      string    `json:"username"`
+	TargetType    string    `json:"target_type"`
+	TargetID      *uint     `json:"target_id"`
+	Verdict       string    `json:"verdict"`
+	Filter        string    `json:"filter"`
+	Reason        string    `json:"reason"`
+	Excerpt       string    `json:"excerpt"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// UserRelationResponse represents a user in the blocked or muted list of the authenticated user
+// @Description User with the time the relation was created
+type UserRelationResponse struct {
+	UserID    uint      `json:"uid"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UpdateAccountStatusRequest represents an admin changing the status of an account
+// @Description Request model for changing an account status. SuspendHours is required for suspended
+type UpdateAccountStatusRequest struct {
+	Status       string `json:"status" validate:"required,oneof=active suspended banned deactivated"`
+	Reason       string `json:"reason" validate:"required,max=1000"`
+	SuspendHours int    `json:"suspend_hours" validate:"omitempty,min=1,max=8760"`
+}
+
+// DeleteAccountRequest represents the confirmation needed to delete your own account
+// @Description Request model for deleting the authenticated user's account
+type DeleteAccountRequest struct {
+	// Password is left out by accounts created by an external provider, they log in with it again instead
+	Password string `json:"password"`
+}
+
+// DeactivateAccountRequest represents the confirmation needed to deactivate your own account
+// @Description Request model for deactivating the authenticated user's account, logging in again reactivates it
+type DeactivateAccountRequest struct {
+	// Password is left out by accounts created by an external provider, they log in with it again instead
+	Password string `json:"password"`
+}
+
+// LinkOIDCProviderRequest represents the confirmation needed to link an external provider to your account
+// @Description Request model for linking an OpenID Connect provider to the authenticated user's account
+type LinkOIDCProviderRequest struct {
+	// Password is left out by accounts created by an external provider, they log in with it again instead
+	Password string `json:"password"`
+}
+
+// OIDCRedirectResponse represents where to send the browser to continue at the provider
+// @Description The browser comes back to the callback of the provider, which finishes the link and returns a JWT
+type OIDCRedirectResponse struct {
+	RedirectURL string `json:"redirect_url"`
+}
+
+// ExportJobResponse represents the state of a personal data export
+// @Description DownloadURL is only set while the archive is ready, it is signed and stops working after ExpiresAt
+type ExportJobResponse struct {
+	ExportJobID uint       `json:"export_id"`
+	Status      string     `json:"status"`
+	Size        int64      `json:"size,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+// GetExportRequest represents the signed query of an export download
+// @Description Request model for downloading a personal data export
+type GetExportRequest struct {
+	Expires   int64  `query:"expires" validate:"required"`
+	Signature string `query:"signature" validate:"required"`
+}
+
+// ExportProfile represents the account data written to profile.json of a personal data export
+// @Description Everything stored about the account itself, including the private fields
+type ExportProfile struct {
+	UserID         uint       `json:"uid"`
+	Username       string     `json:"username"`
+	Firstname      string     `json:"firstname"`
+	Surname        string     `json:"surname"`
+	Email          string     `json:"email"`
+	DisplayName    string     `json:"display_name"`
+	Bio            string     `json:"bio"`
+	Location       string     `json:"location"`
+	Website        string     `json:"website"`
+	Status         string     `json:"status"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+}
+
+// ExportReaction represents a reaction written to reactions.json of a personal data export
+// @Description TargetType is post or comment
+type ExportReaction struct {
+	TargetType   string    `json:"target_type"`
+	TargetID     uint      `json:"target_id"`
+	ReactionType string    `json:"reaction_type"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ExportFollows represents follows.json of a personal data export
+// @Description The users the account follows and the users following it
+type ExportFollows struct {
+	Following []UserRelationResponse `json:"following"`
+	Followers []UserRelationResponse `json:"followers"`
+}
+
+// GetAuditLogsRequest represents the filters of the audit log
+// @Description Request model for listing or exporting the audit log, newest first. Since and until are RFC 3339 times.
+// @Description Format json is paginated, csv and jsonl download every matching entry
+type GetAuditLogsRequest struct {
+	PaginationRequest
+	ActorType  string `query:"actor_type" validate:"omitempty,oneof=user admin anonymous"`
+	ActorID    uint   `query:"actor_id"`
+	Action     string `query:"action"`
+	TargetType string `query:"target_type"`
+	TargetID   string `query:"target_id"`
+	RequestID  string `query:"request_id"`
+	IP         string `query:"ip"`
+	Since      string `query:"since" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Until      string `query:"until" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Format     string `query:"format" validate:"omitempty,oneof=json csv jsonl"`
+}
+
+// AuditLogResponse represents an entry of the audit log
+// @Description Audit log entry, changes is omitted when the action did not change any field
+type AuditLogResponse struct {
+	AuditLogID uint            `json:"audit_log_id"`
+	ActorType  string          `json:"actor_type"`
+	ActorID    *uint           `json:"actor_id"`
+	ActorName  string          `json:"actor_name"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Details    string          `json:"details,omitempty"`
+	Changes    json.RawMessage `json:"changes,omitempty" swaggertype:"object"`
+	IP         string          `json:"ip"`
+	RequestID  string          `json:"request_id"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// GetJobsRequest represents the filters of the job queue listing
+// @Description Query parameters for listing background jobs
+type GetJobsRequest struct {
+	PaginationRequest
+	Status string `query:"status" validate:"omitempty,oneof=pending running succeeded dead"`
+	Type   string `query:"type"`
+}
+
+// JobResponse represents a job of the background queue
+// @Description Background job with its payload, attempts and last error
+type JobResponse struct {
+	JobID       uint            `json:"job_id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload" swaggertype:"object"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedBy    string          `json:"locked_by,omitempty"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// CreateWebhookRequest represents the data needed to register a webhook
+// @Description Request model for registering a webhook. Events are user.created, post.created and comment.created
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required,http_url,max=2048"`
+	Events      []string `json:"events" validate:"required,min=1,dive,oneof=user.created post.created comment.created"`
+	Description string   `json:"description" validate:"max=255"`
+}
+
+// UpdateWebhookRequest represents the changes to a webhook, omitted fields are kept
+// @Description Request model for updating a webhook
+type UpdateWebhookRequest struct {
+	URL         string   `json:"url" validate:"omitempty,http_url,max=2048"`
+	Events      []string `json:"events" validate:"omitempty,min=1,dive,oneof=user.created post.created comment.created"`
+	Description *string  `json:"description" validate:"omitempty,max=255"`
+	Active      *bool    `json:"active"`
+}
+
+// WebhookResponse represents a registered webhook
+// @Description Webhook endpoint, secret is only returned when the webhook is created
+type WebhookResponse struct {
+	WebhookID   uint      `json:"webhook_id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDeliveryResponse represents one attempt to deliver an event
+// @Description Delivery attempt with the response of the receiver, the response body is truncated
+type WebhookDeliveryResponse struct {
+	WebhookDeliveryID uint      `json:"delivery_id"`
+	EventID           string    `json:"event_id"`
+	EventType         string    `json:"event_type"`
+	Attempt           int       `json:"attempt"`
+	StatusCode        int       `json:"status_code"`
+	Success           bool      `json:"success"`
+	Error             string    `json:"error,omitempty"`
+	ResponseBody      string    `json:"response_body"`
+	DurationMS        int64     `json:"duration_ms"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// CreateAPIKeyRequest represents a user creating a personal API key
+// @Description Request model for creating an API key. Without expires_at the key never expires
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=64"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=read post comment"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyResponse represents a personal API key
+// @Description API key, the key itself is only returned when it is created
+type APIKeyResponse struct {
+	APIKeyID   uint       `json:"key_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Key        string     `json:"key,omitempty"`
+}
+
+// GraphQLRequest represents a GraphQL query or mutation
+// @Description Request model of the GraphQL endpoint, variables are optional
+type GraphQLRequest struct {
+	Query         string                 `json:"query" validate:"required,max=20000"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
@@ -22,14 +22,10 @@ type MockUser struct {
 	CookieToken string `gorm:"column:cookie_token" json:"cookie_token"`
 }
 
-type MockCommentUser struct {
-	CommentID uint `gorm:"column:comment_id;primaryKey" json:"comment_id"`
-	UserID    uint `gorm:"column:user_id;primaryKey" json:"user_id"`
-}
-
 type MockComment struct {
 	CommentID uint      `gorm:"column:comment_id;primaryKey;autoIncrement:true" json:"comment_id"`
 	PostID    uint      `gorm:"column:post_id;not null" json:"post_id"`
+	AuthorID  uint      `gorm:"column:author_id;not null" json:"author_id"`
 	Message   string    `gorm:"column:comment_msg;not null" json:"comment_msg"`
 	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP" json:"updated_at"`
@@ -0,0 +1,354 @@
+// Package oidc logs users in with external OpenID Connect providers, using the authorization code flow with PKCE.
+// Providers are discovered from their issuer, ID tokens are verified against the keys the provider publishes.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"server/jwks"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config describes a provider registered with this server as an OAuth2 client
+type Config struct {
+	// Name identifies the provider in the login URLs, such as /api/v1/auth/oidc/{name}
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback registered with the provider, /api/v1/auth/oidc/{name}/callback on this server
+	RedirectURL string
+	// Scopes are requested on top of openid, email and profile by default
+	Scopes []string
+}
+
+// Claims are the ID token claims used to find or create the account
+type Claims struct {
+	Email             string `json:"email"`
+	EmailVerified     Bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+	PreferredUsername string `json:"preferred_username"`
+	Nonce             string `json:"nonce"`
+	// AuthTime is when the user last entered their credentials at the provider, not every provider sends it
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Bool accepts true and "true", some providers send email_verified as a string
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	*b = Bool(strings.Trim(string(data), `"`) == "true")
+	return nil
+}
+
+// Errors of the login flow
+var (
+	ErrUnknownProvider = errors.New("oidc: unknown provider")
+	ErrInvalidToken    = errors.New("oidc: invalid ID token")
+)
+
+// Provider is a configured identity provider. Its endpoints and keys are fetched on first use and cached.
+type Provider struct {
+	Config
+	client *http.Client
+
+	mu                    sync.Mutex
+	authorizationEndpoint string
+	tokenEndpoint         string
+	jwksURI               string
+	keys                  map[string]interface{}
+	keysFetchedAt         time.Time
+}
+
+// Keys are fetched again for an unknown kid (key rotation), but not more often than this
+const keyRefreshInterval = time.Minute
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]*Provider{}
+)
+
+// Init registers the providers listed in OIDC_PROVIDERS (comma separated names). Each name reads
+// OIDC_{NAME}_ISSUER, OIDC_{NAME}_CLIENT_ID, OIDC_{NAME}_CLIENT_SECRET, OIDC_{NAME}_REDIRECT_URL and optionally OIDC_{NAME}_SCOPES.
+func Init() {
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		config := Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			config.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+		if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+			log.Println("Skipping OIDC provider " + name + ": issuer, client ID and redirect URL are required")
+			continue
+		}
+		Register(config)
+	}
+}
+
+// Register adds a provider, replacing one with the same name
+func Register(config Config) *Provider {
+	provider := &Provider{Config: config, client: &http.Client{Timeout: 10 * time.Second}}
+	mu.Lock()
+	providers[config.Name] = provider
+	mu.Unlock()
+	return provider
+}
+
+// Get returns the provider registered under name
+func Get(name string) (*Provider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	provider, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// Names returns the registered providers, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RandomString returns a URL safe random string, for states, nonces and PKCE verifiers
+func RandomString() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// CodeChallenge is the S256 PKCE challenge of a verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns where to send the browser to log in with the provider.
+// With reauthenticate the provider is asked to prompt for the credentials even when the user has a session there.
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string, verifier string, reauthenticate bool) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	scopes := append([]string{"openid", "email", "profile"}, p.Scopes...)
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if reauthenticate {
+		query.Set("prompt", "login")
+		query.Set("max_age", "0")
+	}
+	separator := "?"
+	if strings.Contains(p.authorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.authorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens and returns the verified claims of the ID token
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string, nonce string) (*Claims, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"code_verifier": {verifier},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("oidc: token endpoint answered %d", response.StatusCode)
+	}
+	if response.StatusCode != http.StatusOK || tokens.Error != "" {
+		return nil, fmt.Errorf("oidc: token endpoint answered %d: %s %s", response.StatusCode, tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: missing from the token response", ErrInvalidToken)
+	}
+
+	return p.Verify(ctx, tokens.IDToken, nonce)
+}
+
+// Verify checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *Provider) Verify(ctx context.Context, idToken string, nonce string) (*Claims, error) {
+	claims := new(Claims)
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// discover reads the provider endpoints from its issuer once
+func (p *Provider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tokenEndpoint != "" {
+		return nil
+	}
+
+	var document struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &document); err != nil {
+		return err
+	}
+	if document.Issuer != p.Issuer {
+		return fmt.Errorf("oidc: discovery returned issuer %q, expected %q", document.Issuer, p.Issuer)
+	}
+	if document.AuthorizationEndpoint == "" || document.TokenEndpoint == "" || document.JWKSURI == "" {
+		return errors.New("oidc: discovery document is missing endpoints")
+	}
+	p.authorizationEndpoint = document.AuthorizationEndpoint
+	p.tokenEndpoint = document.TokenEndpoint
+	p.jwksURI = document.JWKSURI
+	return nil
+}
+
+// key returns the signing key with the kid, fetching the key set again when it is unknown
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	var set jwks.Set
+	if err := p.getJSON(ctx, p.jwksURI, &set); err != nil {
+		return nil, err
+	}
+	p.keys = set.PublicKeys()
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// lookupKey accepts a token without kid when the provider publishes a single key
+func (p *Provider) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, rawURL string, target interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := p.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %s answered %d", rawURL, response.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(target)
+}
+
+// HashState is how login states are stored, so a leaked table cannot be used to finish someone else's login
+func HashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,103 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Params of argon2id. They are stored in every hash, so they can be raised without breaking existing hashes.
+type Params struct {
+	// Memory in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams follow the recommendation of RFC 9106 for memory constrained environments
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var errMalformedHash = errors.New("passwords: malformed hash")
+
+// hashing bounds how many hashes are computed at once. Each argon2id hash holds Params.Memory while it runs,
+// a burst of logins would otherwise take as much memory as there are requests. Init sizes it from PASSWORD_HASH_CONCURRENCY.
+var hashing = make(chan struct{}, max(2, runtime.NumCPU()))
+
+// acquire waits for a free hashing slot and returns the function that releases it
+func acquire() func() {
+	hashing <- struct{}{}
+	return func() { <-hashing }
+}
+
+// Hash returns the argon2id hash of the password in the PHC string format: $argon2id$v=19$m=65536,t=3,p=2$salt$key
+func (p Policy) Hash(password string) (string, error) {
+	salt := make([]byte, p.Argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	defer acquire()()
+	key := argon2.IDKey([]byte(password), salt, p.Argon2.Iterations, p.Argon2.Memory, p.Argon2.Parallelism, p.Argon2.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Argon2.Memory, p.Argon2.Iterations, p.Argon2.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether the password matches the hash, and whether the hash should be replaced by Hash because it
+// uses bcrypt or other argon2id parameters than the policy. An empty or malformed hash matches nothing.
+func (p Policy) Verify(hash string, password string) (matches bool, outdated bool) {
+	defer acquire()()
+	if strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$") {
+		// Accounts created before argon2id was the default
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, true
+	}
+
+	params, salt, key, err := decodeHash(hash)
+	if err != nil {
+		return false, false
+	}
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, computed) != 1 {
+		return false, false
+	}
+	return true, params != p.Argon2
+}
+
+func decodeHash(hash string) (Params, []byte, []byte, error) {
+	var params Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errMalformedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, errMalformedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, errMalformedHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,82 @@
+package passwords
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+)
+
+// Breached looks the password up in a local copy of a k-anonymity password list, such as the Pwned Passwords
+// download: one uppercase SHA-1 hash per line, optionally followed by ":count", sorted by hash. The file is
+// binary searched, so lists of billions of hashes work without loading them.
+func Breached(path string, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	// low is always the start of a line, the lines starting before it sort before the target
+	low, high := int64(0), info.Size()
+	for low < high {
+		middle := low + (high-low)/2
+		start, line, err := lineFrom(file, middle)
+		if err != nil {
+			return false, err
+		}
+		if start >= high || line == "" {
+			high = middle
+			continue
+		}
+		hash := strings.ToUpper(strings.TrimSpace(strings.SplitN(line, ":", 2)[0]))
+		switch {
+		case hash == target:
+			return true, nil
+		case hash < target:
+			low = start + int64(len(line)) + 1
+		default:
+			high = middle
+		}
+	}
+	return false, nil
+}
+
+// lineFrom returns the first line starting at offset or later, without the newline, and where it starts.
+// Lines are short (a hash and a count).
+func lineFrom(file *os.File, offset int64) (int64, string, error) {
+	// Reading from the byte before tells whether offset is the start of a line
+	readAt := offset - 1
+	if readAt < 0 {
+		readAt = 0
+	}
+	buffer := make([]byte, 256)
+	n, err := file.ReadAt(buffer, readAt)
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+	buffer = buffer[:n]
+
+	start := readAt
+	if offset > 0 {
+		newline := bytes.IndexByte(buffer, '\n')
+		if newline < 0 {
+			return offset + int64(n), "", nil
+		}
+		buffer = buffer[newline+1:]
+		start = readAt + int64(newline) + 1
+	}
+	if end := bytes.IndexByte(buffer, '\n'); end >= 0 {
+		buffer = buffer[:end]
+	}
+	return start, string(buffer), nil
+}
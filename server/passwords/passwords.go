@@ -0,0 +1,124 @@
+// Package passwords hashes passwords with argon2id and enforces the password policy: length, no username,
+// not in a list of breached passwords and not one of the last passwords of the account.
+package passwords
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Policy is what a new password must satisfy, and how it is hashed
+type Policy struct {
+	MinLength int
+	// MaxLength bounds the work of hashing a password, 0 means no limit
+	MaxLength int
+	// History is how many of the last passwords of an account cannot be used again, including the current one. 0 allows reuse.
+	History int
+	// BreachedList is a file of breached passwords, see Breached. Empty skips the check.
+	BreachedList string
+	// Argon2 are the parameters of new hashes, hashes with other parameters are replaced at the next login
+	Argon2 Params
+}
+
+// Default is the policy used by the handlers
+var Default = Policy{
+	MinLength: 8,
+	MaxLength: 128,
+	History:   5,
+	Argon2:    DefaultParams,
+}
+
+// Violation is a rule a new password breaks, the message can be shown to the user
+type Violation string
+
+func (v Violation) Error() string {
+	return string(v)
+}
+
+// Init sets Default from PASSWORD_MIN_LENGTH, PASSWORD_MAX_LENGTH, PASSWORD_HISTORY, PASSWORD_BREACHED_LIST and
+// PASSWORD_ARGON2_MEMORY (KiB), PASSWORD_ARGON2_ITERATIONS, PASSWORD_ARGON2_PARALLELISM.
+// PASSWORD_HASH_CONCURRENCY is how many hashes are computed at once, the number of CPUs (at least 2) by default.
+func Init() {
+	policy := Default
+	readInt("PASSWORD_MIN_LENGTH", func(value int) { policy.MinLength = value })
+	readInt("PASSWORD_MAX_LENGTH", func(value int) { policy.MaxLength = value })
+	readInt("PASSWORD_HISTORY", func(value int) { policy.History = value })
+	readInt("PASSWORD_ARGON2_MEMORY", func(value int) { policy.Argon2.Memory = uint32(value) })
+	readInt("PASSWORD_ARGON2_ITERATIONS", func(value int) { policy.Argon2.Iterations = uint32(value) })
+	readInt("PASSWORD_ARGON2_PARALLELISM", func(value int) { policy.Argon2.Parallelism = uint8(value) })
+	readInt("PASSWORD_HASH_CONCURRENCY", func(value int) {
+		if value == 0 {
+			log.Fatal("PASSWORD_HASH_CONCURRENCY must be positive")
+		}
+		hashing = make(chan struct{}, value)
+	})
+
+	if path := os.Getenv("PASSWORD_BREACHED_LIST"); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			log.Fatal("Cannot read the breached password list: ", err)
+		}
+		policy.BreachedList = path
+	}
+	if policy.Argon2.Memory == 0 || policy.Argon2.Iterations == 0 || policy.Argon2.Parallelism == 0 {
+		log.Fatal("The argon2 memory, iterations and parallelism must be positive")
+	}
+	Default = policy
+}
+
+func readInt(name string, set func(int)) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		log.Fatalf("%s must be a number, got %q", name, value)
+	}
+	set(number)
+}
+
+// TooLong reports whether the password is over MaxLength. No password of the policy is, so a login with one is refused
+// before paying for the hash.
+func (p Policy) TooLong(password string) bool {
+	return p.MaxLength > 0 && utf8.RuneCountInString(password) > p.MaxLength
+}
+
+// Check returns a Violation when the password breaks a rule. previous are the hashes of the last passwords of the
+// account, newest first, only the first History of them are compared. Other errors come from reading the breached list.
+func (p Policy) Check(password string, username string, previous []string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return Violation(fmt.Sprintf("The password must be at least %d characters long", p.MinLength))
+	}
+	if p.TooLong(password) {
+		return Violation(fmt.Sprintf("The password must be at most %d characters long", p.MaxLength))
+	}
+	// Very short usernames would rule out too many passwords by accident
+	if len(username) >= 3 && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return Violation("The password must not contain the username")
+	}
+
+	if p.BreachedList != "" {
+		breached, err := Breached(p.BreachedList, password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			return Violation("The password appears in a list of breached passwords, choose another one")
+		}
+	}
+
+	// Hashing is the slow part, so the history comes last
+	if len(previous) > p.History {
+		previous = previous[:p.History]
+	}
+	for _, hash := range previous {
+		if matches, _ := p.Verify(hash, password); matches {
+			return Violation(fmt.Sprintf("The password must differ from the last %d passwords", p.History))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package pubsub
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Topics events are published on
+const TopicPosts = "posts"
+
+// PostTopic is the topic of the comments of one post
+func PostTopic(postID uint) string {
+	return fmt.Sprintf("post:%d", postID)
+}
+
+// UserTopic is the private topic of one user, used for notifications
+func UserTopic(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// Event types
+const (
+	EventPostCreated    = "post.created"
+	EventCommentCreated = "comment.created"
+	EventNotification   = "notification"
+)
+
+// Event is a message delivered to the subscribers of a topic
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+	// AuthorID is the user who caused the event, so streams can leave out users the viewer blocked or muted
+	AuthorID uint `json:"-"`
+	// Visibility of the post the event is about. Streams only deliver non-public posts to the users allowed to read them
+	Visibility string `json:"-"`
+}
+
+// Subscription receives the events of the topics it was created for until it is closed
+type Subscription interface {
+	Events() <-chan Event
+	Close()
+}
+
+// Broker fans events out to subscribers.
+// Publishing never blocks the caller: a subscriber that does not keep up misses events instead of slowing down writes.
+// The in-process implementation only reaches clients connected to this instance, an external broker
+// (Redis, NATS, ...) can be plugged in by implementing this interface and assigning it to Default.
+type Broker interface {
+	Publish(topic string, event Event)
+	Subscribe(topics ...string) Subscription
+}
+
+// Default is the broker used by the handlers
+var Default Broker = NewMemoryBroker(64)
+
+// Publish sends an event to the default broker
+func Publish(topic string, event Event) {
+	if Default != nil {
+		Default.Publish(topic, event)
+	}
+}
+
+// MemoryBroker is an in-process Broker
+type MemoryBroker struct {
+	mu          sync.RWMutex
+	buffer      int
+	subscribers map[string]map[*memorySubscription]struct{}
+}
+
+// NewMemoryBroker returns a broker whose subscriptions buffer up to buffer events each
+func NewMemoryBroker(buffer int) *MemoryBroker {
+	return &MemoryBroker{
+		buffer:      buffer,
+		subscribers: make(map[string]map[*memorySubscription]struct{}),
+	}
+}
+
+type memorySubscription struct {
+	broker *MemoryBroker
+	topics []string
+	events chan Event
+	once   sync.Once
+}
+
+func (s *memorySubscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *memorySubscription) Close() {
+	s.once.Do(func() {
+		s.broker.mu.Lock()
+		defer s.broker.mu.Unlock()
+		for _, topic := range s.topics {
+			delete(s.broker.subscribers[topic], s)
+			if len(s.broker.subscribers[topic]) == 0 {
+				delete(s.broker.subscribers, topic)
+			}
+		}
+		close(s.events)
+	})
+}
+
+func (b *MemoryBroker) Subscribe(topics ...string) Subscription {
+	subscription := &memorySubscription{
+		broker: b,
+		topics: topics,
+		events: make(chan Event, b.buffer),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, topic := range topics {
+		if b.subscribers[topic] == nil {
+			b.subscribers[topic] = make(map[*memorySubscription]struct{})
+		}
+		b.subscribers[topic][subscription] = struct{}{}
+	}
+
+	return subscription
+}
+
+func (b *MemoryBroker) Publish(topic string, event Event) {
+	// Holding the read lock keeps Close from closing a channel we are sending on
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for subscription := range b.subscribers[topic] {
+		select {
+		case subscription.events <- event:
+		default:
+		}
+	}
+}
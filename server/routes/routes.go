@@ -1,12 +1,10 @@
 package routes
 
 import (
-	"os"
 	"server/handlers"
 	"server/helpers"
-	"server/models"
+	"server/tokens"
 
-	"github.com/golang-jwt/jwt/v5"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -17,23 +15,71 @@ func SetupRoutes(e *echo.Echo) {
 	api := e.Group("/api/v1")
 
 	// Public Routes for maintaining health check and swagger
-	e.GET("/", handlers.HealthCheck)             // GET / (Health check endpoint)
-	e.GET("/swagger/*", handlers.SwaggerHandler) // GET /swagger/* (Swagger documentation)
+	e.GET("/", handlers.HealthCheck)                  // GET / (Health check endpoint)
+	e.GET("/swagger/*", handlers.SwaggerHandler)      // GET /swagger/* (Swagger documentation)
+	e.GET("/.well-known/jwks.json", handlers.GetJWKS) // GET /.well-known/jwks.json (Public keys verifying our JWTs)
+
+	// JWT config shared by the restricted group and the optional auth on public routes,
+	// tokens are verified with the rotating signing keys (see the tokens package)
+	jwtConfig := echojwt.Config{
+		ParseTokenFunc: func(c echo.Context, auth string) (interface{}, error) {
+			return tokens.Default.Parse(auth)
+		},
+	}
+
+	// Optional JWT: public routes still work without a token, but a valid token of an active account lets handlers know the viewer
+	optionalJWTConfig := jwtConfig
+	optionalJWTConfig.ContinueOnIgnoredError = true
+	optionalJWTConfig.ErrorHandler = func(c echo.Context, err error) error {
+		return nil
+	}
+	parseOptionalJWT := echojwt.WithConfig(optionalJWTConfig)
+	optionalJWT := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return parseOptionalJWT(handlers.OptionalActiveAccount(next))
+	}
+
+	// Streaming JWT: the browser EventSource cannot set headers, so the token may also come from the query string
+	streamJWTConfig := jwtConfig
+	streamJWTConfig.TokenLookup = "header:Authorization:Bearer ,query:token"
 
 	// Public API Routes
-	api.POST("/login", handlers.LoggedInUser)       // POST /api/v1/login
-	api.POST("/logout", handlers.Logout)            // POST /api/v1/logout
-	api.POST("/users", handlers.CreateUser)         // POST /api/v1/users
-	api.GET("/posts", handlers.GetPosts)            // GET /api/v1/posts
-	api.GET("/posts/:pid", handlers.GetPosts)       // GET /api/v1/posts/:pid
-	api.GET("/comments/:pid", handlers.GetComments) // GET /api/v1/comments/:pid
+	api.POST("/login", handlers.LoggedInUser)                    // POST /api/v1/login
+	api.POST("/logout", handlers.Logout)                         // POST /api/v1/logout
+	api.POST("/users", handlers.CreateUser)                      // POST /api/v1/users
+	api.GET("/posts", handlers.GetPosts, optionalJWT)            // GET /api/v1/posts
+	api.GET("/posts/:pid", handlers.GetPosts, optionalJWT)       // GET /api/v1/posts/:pid
+	api.GET("/comments/:pid", handlers.GetComments, optionalJWT) // GET /api/v1/comments/:pid
+	api.GET("/search", handlers.Search)                          // GET /api/v1/search
+	api.GET("/tags/:tag", handlers.GetPostsByTag, optionalJWT)   // GET /api/v1/tags/:tag
+	api.GET("/trending/tags", handlers.GetTrendingTags)          // GET /api/v1/trending/tags
+
+	// GraphQL (queries are public, mutations need a token and share the validation of the REST routes)
+	api.POST("/graphql", handlers.GraphQL, optionalJWT) // POST /api/v1/graphql (Query users, posts and comments, or create and edit them)
+
+	// Log in with external OpenID Connect providers (authorization code flow with PKCE)
+	api.GET("/auth/oidc", handlers.GetOIDCProviders)                // GET /api/v1/auth/oidc (List external login providers)
+	api.GET("/auth/oidc/:provider", handlers.OIDCLogin)             // GET /api/v1/auth/oidc/:provider (Redirect to the provider)
+	api.GET("/auth/oidc/:provider/callback", handlers.OIDCCallback) // GET /api/v1/auth/oidc/:provider/callback (Finish the login, returns a JWT)
+
+	// Public profiles (email and admin flag are never exposed)
+	api.GET("/users/:username", handlers.GetUserProfile, optionalJWT) // GET /api/v1/users/:username (Public profile with counts)
+	api.GET("/users/:username/avatar", handlers.GetAvatar)            // GET /api/v1/users/:username/avatar (Avatar image)
+
+	// Attachment downloads, authorized by the signature in the URL and the visibility of the post for the viewer
+	api.GET("/attachments/:aid", handlers.GetAttachment, optionalJWT) // GET /api/v1/attachments/:aid (Download an attachment or its thumbnail)
+
+	// Personal data export downloads, authorized by the signature in the URL
+	api.GET("/exports/:jid", handlers.DownloadExport) // GET /api/v1/exports/:jid (Download a personal data export)
+
+	// Real-time updates (Server-Sent Events)
+	api.GET("/stream", handlers.Stream, echojwt.WithConfig(streamJWTConfig), handlers.ActiveAccount) // GET /api/v1/stream (Stream new posts, comments and notifications)
 
 	// GET /api/v1/restricted/comments/:pid (Retrieve all comments for a post)
 
 	//------------------------ Admin routes ------------------------//
 	admin := api.Group("/admin")
 	loggerConfig := middleware.LoggerConfig{
-		Format: `${time_rfc3339} ${status} ${method} ${host}${path} ${latency_human}` + "\n",
+		Format: `${time_rfc3339} ${id} ${status} ${method} ${host}${path} ${latency_human}` + "\n",
 	}
 	admin.Use(middleware.LoggerWithConfig(loggerConfig))
 	admin.Use(helpers.CustomBasicAuth)
@@ -44,6 +90,34 @@ func SetupRoutes(e *echo.Echo) {
 	admin.GET("/get-migrations", handlers.GetMigration)  // GET /api/v1/admin/get-migrations (Retrieve all migrations)
 	admin.POST("/run-migrations", handlers.RunMigration) // POST /api/v1/admin/run-migrations (Run migrations)
 
+	// Moderation queue
+	admin.GET("/reports", handlers.GetReports)                // GET /api/v1/admin/reports (List reports)
+	admin.PUT("/reports/:rid/assign", handlers.AssignReport)  // PUT /api/v1/admin/reports/:rid/assign (Assign a report to a moderator)
+	admin.POST("/reports/:rid/actions", handlers.ActOnReport) // POST /api/v1/admin/reports/:rid/actions (Hide, delete, warn, suspend or dismiss)
+
+	// Account status (suspend, ban, deactivate or reactivate)
+	admin.PUT("/users/:uid/status", handlers.UpdateAccountStatus) // PUT /api/v1/admin/users/:uid/status (Change the status of an account)
+
+	// Audit log of admin actions, authentication events and account changes
+	admin.GET("/audit-logs", handlers.GetAuditLogs) // GET /api/v1/admin/audit-logs (List or export the audit log as json, csv or jsonl)
+
+	// Background job queue
+	admin.GET("/jobs", handlers.GetJobs)              // GET /api/v1/admin/jobs (List background jobs)
+	admin.GET("/jobs/:jid", handlers.GetJob)          // GET /api/v1/admin/jobs/:jid (Get a background job)
+	admin.POST("/jobs/:jid/retry", handlers.RetryJob) // POST /api/v1/admin/jobs/:jid/retry (Retry a dead job)
+
+	// Outgoing webhooks for platform events
+	admin.GET("/webhooks", handlers.GetWebhooks)                          // GET /api/v1/admin/webhooks (List webhooks)
+	admin.POST("/webhooks", handlers.CreateWebhook)                       // POST /api/v1/admin/webhooks (Register a webhook, returns its signing secret)
+	admin.GET("/webhooks/:wid", handlers.GetWebhook)                      // GET /api/v1/admin/webhooks/:wid (Get a webhook)
+	admin.PUT("/webhooks/:wid", handlers.UpdateWebhook)                   // PUT /api/v1/admin/webhooks/:wid (Update or pause a webhook)
+	admin.DELETE("/webhooks/:wid", handlers.DeleteWebhook)                // DELETE /api/v1/admin/webhooks/:wid (Delete a webhook)
+	admin.POST("/webhooks/:wid/ping", handlers.PingWebhook)               // POST /api/v1/admin/webhooks/:wid/ping (Send a test ping)
+	admin.GET("/webhooks/:wid/deliveries", handlers.GetWebhookDeliveries) // GET /api/v1/admin/webhooks/:wid/deliveries (Delivery log of a webhook)
+
+	// Automated content filter log
+	admin.GET("/filter-events", handlers.GetFilterEvents) // GET /api/v1/admin/filter-events (List rejected and held posts and comments)
+
 	//------------------------ Cookie (For debug) ------------------------//
 	cookie := api.Group("/cookie")
 	cookie.Use(handlers.CookieChecker)
@@ -51,22 +125,80 @@ func SetupRoutes(e *echo.Echo) {
 
 	//------------------------ JWT Protected Routes (Need authentication routes) ------------------------//
 	jwt_protected := api.Group("/restricted")
-	config := echojwt.Config{
-		NewClaimsFunc: func(c echo.Context) jwt.Claims {
-			return new(models.JWTClaims)
-		},
-		SigningKey: []byte(os.Getenv("JWT_SECRET")),
-	}
-	jwt_protected.Use(echojwt.WithConfig(config))
+	// A JWT or a personal API key (Authorization: ApiKey <key> or X-API-Key), keys are limited to their scopes
+	jwt_protected.Use(handlers.JWTAPIMiddleware(jwtConfig))
+	// Tokens of suspended, banned, deactivated and deleted accounts stop working right away
+	jwt_protected.Use(handlers.ActiveAccount)
 	jwt_protected.GET("/main", handlers.RestrictedHandler) // GET /api/v1/restricted/main
 
 	// User routes
 	jwt_protected.PUT("/users/:uid", handlers.UpdateUser)                     // PUT /api/v1/restricted/users/:uid (Update a user by ID)
 	jwt_protected.PUT("/users-update-password/:uid", handlers.ChangePassword) // PUT /api/v1/restricted/users-update-password/:uid (Update a user's password by ID)
+	jwt_protected.PUT("/profile", handlers.UpdateProfile)                     // PUT /api/v1/restricted/profile (Update the profile of the authenticated user)
+	jwt_protected.PUT("/profile/avatar", handlers.UploadAvatar)               // PUT /api/v1/restricted/profile/avatar (Upload an avatar)
+	jwt_protected.DELETE("/profile/avatar", handlers.DeleteAvatar)            // DELETE /api/v1/restricted/profile/avatar (Remove the avatar)
+
+	// Account routes (password confirmation, or a recent login with the provider for accounts without a password, required)
+	jwt_protected.POST("/account/deactivate", handlers.DeactivateAccount) // POST /api/v1/restricted/account/deactivate (Deactivate my account)
+	jwt_protected.DELETE("/account", handlers.DeleteAccount)              // DELETE /api/v1/restricted/account (Delete my account)
+
+	// Link an external login provider to my account (confirmed like the account routes, the provider's callback finishes it)
+	jwt_protected.POST("/auth/oidc/:provider/link", handlers.LinkOIDCProvider) // POST /api/v1/restricted/auth/oidc/:provider/link (Start linking a provider)
+
+	// Personal API keys for scripts and bots (the key is only shown once)
+	jwt_protected.GET("/api-keys", handlers.GetAPIKeys)           // GET /api/v1/restricted/api-keys (List my API keys)
+	jwt_protected.POST("/api-keys", handlers.CreateAPIKey)        // POST /api/v1/restricted/api-keys (Create an API key)
+	jwt_protected.DELETE("/api-keys/:kid", handlers.RevokeAPIKey) // DELETE /api/v1/restricted/api-keys/:kid (Revoke an API key)
+
+	// Personal data export (built in the background, poll the job until it is ready)
+	jwt_protected.POST("/me/export", handlers.RequestExport) // POST /api/v1/restricted/me/export (Start an export of my data)
+	jwt_protected.GET("/me/export/:jid", handlers.GetExport) // GET /api/v1/restricted/me/export/:jid (Poll an export)
 
 	// Post routes
-	jwt_protected.POST("/posts", handlers.CreatePost) // POST /api/v1/restricted/posts (Create a new post)
+	jwt_protected.POST("/posts", handlers.CreatePost)     // POST /api/v1/restricted/posts (Create a new post)
+	jwt_protected.PUT("/posts/:pid", handlers.UpdatePost) // PUT /api/v1/restricted/posts/:pid (Edit a post)
+	jwt_protected.GET("/drafts", handlers.GetDrafts)      // GET /api/v1/restricted/drafts (List my unpublished posts)
+
+	// Scheduled posts (published by a background worker once due)
+	jwt_protected.GET("/scheduled-posts", handlers.GetScheduledPosts)           // GET /api/v1/restricted/scheduled-posts (List my scheduled posts)
+	jwt_protected.PUT("/scheduled-posts/:pid", handlers.ReschedulePost)         // PUT /api/v1/restricted/scheduled-posts/:pid (Change the publication time)
+	jwt_protected.DELETE("/scheduled-posts/:pid", handlers.CancelScheduledPost) // DELETE /api/v1/restricted/scheduled-posts/:pid (Cancel, the post is kept as a draft)
 
 	// Comment routes
-	jwt_protected.POST("/comments", handlers.CreateComment) // POST /api/v1/restricted/comments (Create a new comment)
+	jwt_protected.POST("/comments", handlers.CreateComment)     // POST /api/v1/restricted/comments (Create a new comment)
+	jwt_protected.PUT("/comments/:cid", handlers.UpdateComment) // PUT /api/v1/restricted/comments/:cid (Edit a comment)
+
+	// Attachment routes (multipart upload of a single image)
+	jwt_protected.POST("/posts/:pid/attachments", handlers.UploadPostAttachment)       // POST /api/v1/restricted/posts/:pid/attachments (Attach an image to a post)
+	jwt_protected.POST("/comments/:cid/attachments", handlers.UploadCommentAttachment) // POST /api/v1/restricted/comments/:cid/attachments (Attach an image to a comment)
+
+	// Report routes
+	jwt_protected.POST("/reports", handlers.CreateReport) // POST /api/v1/restricted/reports (Report a post, comment or user)
+	jwt_protected.GET("/warnings", handlers.GetWarnings)  // GET /api/v1/restricted/warnings (List moderator warnings)
+
+	// Follow routes
+	jwt_protected.PUT("/follows/:uid", handlers.FollowUser)      // PUT /api/v1/restricted/follows/:uid (Follow a user)
+	jwt_protected.DELETE("/follows/:uid", handlers.UnfollowUser) // DELETE /api/v1/restricted/follows/:uid (Unfollow a user)
+
+	// Block and mute routes (PUT and DELETE are idempotent)
+	jwt_protected.GET("/blocks", handlers.GetBlocks)           // GET /api/v1/restricted/blocks (List blocked users)
+	jwt_protected.PUT("/blocks/:uid", handlers.BlockUser)      // PUT /api/v1/restricted/blocks/:uid (Block a user)
+	jwt_protected.DELETE("/blocks/:uid", handlers.UnblockUser) // DELETE /api/v1/restricted/blocks/:uid (Unblock a user)
+	jwt_protected.GET("/mutes", handlers.GetMutes)             // GET /api/v1/restricted/mutes (List muted users)
+	jwt_protected.PUT("/mutes/:uid", handlers.MuteUser)        // PUT /api/v1/restricted/mutes/:uid (Mute a user)
+	jwt_protected.DELETE("/mutes/:uid", handlers.UnmuteUser)   // DELETE /api/v1/restricted/mutes/:uid (Unmute a user)
+
+	// Notification routes
+	jwt_protected.GET("/notifications", handlers.GetNotifications)                         // GET /api/v1/restricted/notifications (List notifications)
+	jwt_protected.GET("/notifications/unread-count", handlers.GetUnreadNotificationCount)  // GET /api/v1/restricted/notifications/unread-count (Count unread notifications)
+	jwt_protected.PUT("/notifications/read-all", handlers.MarkAllNotificationsRead)        // PUT /api/v1/restricted/notifications/read-all (Mark all notifications as read)
+	jwt_protected.PUT("/notifications/:nid/read", handlers.MarkNotificationRead)           // PUT /api/v1/restricted/notifications/:nid/read (Mark a notification as read)
+	jwt_protected.GET("/notification-preferences", handlers.GetNotificationPreferences)    // GET /api/v1/restricted/notification-preferences (Get notification preferences)
+	jwt_protected.PUT("/notification-preferences", handlers.UpdateNotificationPreferences) // PUT /api/v1/restricted/notification-preferences (Update notification preferences)
+
+	// Reaction routes (PUT and DELETE are idempotent)
+	jwt_protected.PUT("/posts/:pid/reactions/:type", handlers.AddPostReaction)             // PUT /api/v1/restricted/posts/:pid/reactions/:type (React to a post)
+	jwt_protected.DELETE("/posts/:pid/reactions/:type", handlers.RemovePostReaction)       // DELETE /api/v1/restricted/posts/:pid/reactions/:type (Remove a reaction from a post)
+	jwt_protected.PUT("/comments/:cid/reactions/:type", handlers.AddCommentReaction)       // PUT /api/v1/restricted/comments/:cid/reactions/:type (React to a comment)
+	jwt_protected.DELETE("/comments/:cid/reactions/:type", handlers.RemoveCommentReaction) // DELETE /api/v1/restricted/comments/:cid/reactions/:type (Remove a reaction from a comment)
 }
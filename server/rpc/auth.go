@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"server/handlers"
+	"server/models"
+	"server/tokens"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/random"
+)
+
+type serviceKey struct{}
+
+// authInterceptor authenticates calls like the REST API: with a JWT ("authorization: Bearer <jwt>") or a personal
+// API key ("x-api-key" or "authorization: ApiKey <key>"), which then needs the scope of the operation. Calls without
+// credentials are anonymous, the operations that need an account fail with Unauthenticated.
+// Like the RequestID middleware of the REST API, every call gets an X-Request-Id (the caller's or a new one) that the
+// handlers record in the audit log and the response or error carries back.
+func authInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, request connect.AnyRequest) (connect.AnyResponse, error) {
+			requestID := request.Header().Get(echo.HeaderXRequestID)
+			if requestID == "" {
+				requestID = random.String(32)
+			}
+
+			response, err := authenticate(ctx, request, requestID, next)
+			var connectError *connect.Error
+			if err == nil {
+				response.Header().Set(echo.HeaderXRequestID, requestID)
+			} else if errors.As(err, &connectError) {
+				connectError.Meta().Set(echo.HeaderXRequestID, requestID)
+			}
+			return response, err
+		}
+	}
+}
+
+// authenticate finds the caller of a call from its credentials and runs it
+func authenticate(ctx context.Context, request connect.AnyRequest, requestID string, next connect.UnaryFunc) (connect.AnyResponse, error) {
+	header := request.Header()
+	service := handlers.Service{IP: clientIP(request), RequestID: requestID}
+
+	authorization := header.Get(echo.HeaderAuthorization)
+	if key := header.Get(handlers.APIKeyHeader); key != "" || strings.HasPrefix(authorization, handlers.APIKeyScheme+" ") {
+		if key == "" {
+			key = strings.TrimPrefix(authorization, handlers.APIKeyScheme+" ")
+		}
+		apiKey, found, err := handlers.LookupAPIKey(key)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, errors.New("Failed to check API key"))
+		}
+		if !found {
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("Invalid API key"))
+		}
+		service.APIKey = &apiKey
+		service.UserID = apiKey.User.UserID
+	} else if strings.HasPrefix(authorization, "Bearer ") {
+		token, err := tokens.Default.Parse(strings.TrimPrefix(authorization, "Bearer "))
+		if err != nil {
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("Invalid or expired token"))
+		}
+		if claims, ok := token.Claims.(*models.JWTClaims); ok {
+			service.UserID = claims.UserID
+		}
+	}
+
+	return next(context.WithValue(ctx, serviceKey{}, service), request)
+}
+
+// clientIP returns the address of the caller like Echo's RealIP: the first X-Forwarded-For address, X-Real-IP,
+// or the address of the peer
+func clientIP(request connect.AnyRequest) string {
+	header := request.Header()
+	if forwarded := header.Get(echo.HeaderXForwardedFor); forwarded != "" {
+		ip, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(ip)
+	}
+	if ip := header.Get(echo.HeaderXRealIP); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(request.Peer().Addr)
+	if err != nil {
+		return request.Peer().Addr
+	}
+	return host
+}
@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"context"
+	socialfeedv1 "server/gen/socialfeed/v1"
+	"server/handlers"
+	"server/models"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type commentService struct{}
+
+// ListComments runs GET /api/v1/comments/:pid
+func (commentService) ListComments(ctx context.Context, request *connect.Request[socialfeedv1.ListCommentsRequest]) (*connect.Response[socialfeedv1.ListCommentsResponse], error) {
+	service, err := serviceOf(ctx, false, handlers.APIKeyScopeRead)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := service.ListComments(uint(request.Msg.PostId))
+	if err != nil {
+		return nil, connectError(err)
+	}
+
+	response := &socialfeedv1.ListCommentsResponse{Comments: make([]*socialfeedv1.PostComment, len(comments))}
+	for i, comment := range comments {
+		response.Comments[i] = &socialfeedv1.PostComment{
+			CommentId:   uint64(comment.CommentID),
+			Username:    comment.Username,
+			Message:     comment.CommentMSG,
+			Reactions:   comment.Reactions,
+			MyReactions: comment.MyReactions,
+		}
+	}
+	return connect.NewResponse(response), nil
+}
+
+// CreateComment runs POST /api/v1/restricted/comments
+func (commentService) CreateComment(ctx context.Context, request *connect.Request[socialfeedv1.CreateCommentRequest]) (*connect.Response[socialfeedv1.Comment], error) {
+	service, err := serviceOf(ctx, true, handlers.APIKeyScopeComment)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := service.CreateComment(models.CreateCommentRequest{PostID: uint(request.Msg.PostId), CommentMSG: request.Msg.Message})
+	if err != nil {
+		return nil, connectError(err)
+	}
+	return connect.NewResponse(commentMessage(comment)), nil
+}
+
+// UpdateComment runs PUT /api/v1/restricted/comments/:cid
+func (commentService) UpdateComment(ctx context.Context, request *connect.Request[socialfeedv1.UpdateCommentRequest]) (*connect.Response[socialfeedv1.Comment], error) {
+	service, err := serviceOf(ctx, true, handlers.APIKeyScopeComment)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := service.UpdateComment(uint(request.Msg.CommentId), models.UpdateCommentRequest{CommentMSG: request.Msg.Message})
+	if err != nil {
+		return nil, connectError(err)
+	}
+	return connect.NewResponse(commentMessage(comment)), nil
+}
+
+func commentMessage(comment models.Comment) *socialfeedv1.Comment {
+	return &socialfeedv1.Comment{
+		CommentId: uint64(comment.CommentID),
+		PostId:    uint64(comment.PostID),
+		Message:   comment.CommentMSG,
+		CreatedAt: timestamppb.New(comment.CreatedAt),
+		UpdatedAt: timestamppb.New(comment.UpdatedAt),
+		Hidden:    comment.HiddenAt != nil,
+	}
+}
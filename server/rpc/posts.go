@@ -0,0 +1,108 @@
+package rpc
+
+import (
+	"context"
+	socialfeedv1 "server/gen/socialfeed/v1"
+	"server/handlers"
+	"server/models"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type postService struct{}
+
+// ListPosts runs the feed of GET /api/v1/posts
+func (postService) ListPosts(ctx context.Context, request *connect.Request[socialfeedv1.ListPostsRequest]) (*connect.Response[socialfeedv1.ListPostsResponse], error) {
+	service, err := serviceOf(ctx, false, handlers.APIKeyScopeRead)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := service.ListPosts()
+	if err != nil {
+		return nil, connectError(err)
+	}
+
+	response := &socialfeedv1.ListPostsResponse{Posts: make([]*socialfeedv1.FeedPost, len(posts))}
+	for i, post := range posts {
+		response.Posts[i] = &socialfeedv1.FeedPost{
+			PostId:      uint64(post.PostID),
+			Username:    post.Username,
+			Firstname:   post.Firstname,
+			Surname:     post.Surname,
+			Message:     post.Message,
+			Visibility:  post.Visibility,
+			CreatedAt:   timestamppb.New(post.CreatedAt),
+			UpdatedAt:   timestamppb.New(post.UpdatedAt),
+			Reactions:   post.Reactions,
+			MyReactions: post.MyReactions,
+		}
+	}
+	return connect.NewResponse(response), nil
+}
+
+// GetPost runs GET /api/v1/posts?pid=
+func (postService) GetPost(ctx context.Context, request *connect.Request[socialfeedv1.GetPostRequest]) (*connect.Response[socialfeedv1.Post], error) {
+	service, err := serviceOf(ctx, false, handlers.APIKeyScopeRead)
+	if err != nil {
+		return nil, err
+	}
+	post, err := service.GetPost(uint(request.Msg.PostId))
+	if err != nil {
+		return nil, connectError(err)
+	}
+	return connect.NewResponse(postMessage(post)), nil
+}
+
+// CreatePost runs POST /api/v1/restricted/posts
+func (postService) CreatePost(ctx context.Context, request *connect.Request[socialfeedv1.CreatePostRequest]) (*connect.Response[socialfeedv1.Post], error) {
+	service, err := serviceOf(ctx, true, handlers.APIKeyScopePost)
+	if err != nil {
+		return nil, err
+	}
+
+	create := models.CreatePostRequest{Message: request.Msg.Message, Visibility: request.Msg.Visibility}
+	if request.Msg.PublishAt != nil {
+		publishAt := request.Msg.PublishAt.AsTime()
+		create.PublishAt = &publishAt
+	}
+	post, err := service.CreatePost(create)
+	if err != nil {
+		return nil, connectError(err)
+	}
+	return connect.NewResponse(postMessage(post)), nil
+}
+
+// UpdatePost runs PUT /api/v1/restricted/posts/:pid
+func (postService) UpdatePost(ctx context.Context, request *connect.Request[socialfeedv1.UpdatePostRequest]) (*connect.Response[socialfeedv1.Post], error) {
+	service, err := serviceOf(ctx, true, handlers.APIKeyScopePost)
+	if err != nil {
+		return nil, err
+	}
+	post, err := service.UpdatePost(uint(request.Msg.PostId), models.UpdatePostRequest{Message: request.Msg.Message, Visibility: request.Msg.Visibility})
+	if err != nil {
+		return nil, connectError(err)
+	}
+	return connect.NewResponse(postMessage(post)), nil
+}
+
+func postMessage(post models.Post) *socialfeedv1.Post {
+	return &socialfeedv1.Post{
+		PostId:     uint64(post.PostID),
+		UserId:     uint64(post.UserID),
+		Message:    post.Message,
+		Visibility: post.Visibility,
+		PublishAt:  optionalTimestamp(post.PublishAt),
+		CreatedAt:  timestamppb.New(post.CreatedAt),
+		UpdatedAt:  timestamppb.New(post.UpdatedAt),
+		Hidden:     post.HiddenAt != nil,
+	}
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,87 @@
+// Package rpc serves the user, post and comment operations over gRPC for other backend services, on its own port
+// next to the REST API. The services are generated from proto/socialfeed/v1 with connect-go, so they also answer
+// the Connect and gRPC-Web protocols. Every call runs the operation on handlers.Service like the REST handlers do,
+// REST and gRPC share the validation, visibility rules, content filter, notifications and audit.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"server/gen/socialfeed/v1/socialfeedv1connect"
+	"server/handlers"
+	"time"
+
+	"connectrpc.com/connect"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// DefaultAddr is the address of the gRPC server when GRPC_ADDR is not set
+const DefaultAddr = ":50051"
+
+// NewHandler returns the HTTP handler of the services
+func NewHandler() http.Handler {
+	interceptors := connect.WithInterceptors(authInterceptor())
+
+	mux := http.NewServeMux()
+	mux.Handle(socialfeedv1connect.NewUserServiceHandler(userService{}, interceptors))
+	mux.Handle(socialfeedv1connect.NewPostServiceHandler(postService{}, interceptors))
+	mux.Handle(socialfeedv1connect.NewCommentServiceHandler(commentService{}, interceptors))
+	return mux
+}
+
+// Serve runs the gRPC server on GRPC_ADDR. gRPC needs HTTP/2, it is served in cleartext (h2c) for the internal network:
+// keep the port off the public interfaces, or put a TLS terminating proxy in front of it.
+func Serve() error {
+	addr := os.Getenv("GRPC_ADDR")
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           h2c.NewHandler(NewHandler(), &http2.Server{}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+// serviceOf returns the Service of the caller the auth interceptor put in the context, authorized for an operation
+// like its REST route: restricted operations need an account, scope is the API key scope of the route
+func serviceOf(ctx context.Context, restricted bool, scope string) (handlers.Service, error) {
+	service := ctx.Value(serviceKey{}).(handlers.Service)
+	if err := service.Authorize(restricted, scope); err != nil {
+		return service, connectError(err)
+	}
+	return service, nil
+}
+
+// connectError returns the error of a Service call with the code of its HTTP status
+func connectError(err error) error {
+	var serviceError *handlers.ServiceError
+	if errors.As(err, &serviceError) {
+		return connect.NewError(statusCode(serviceError.Status), errors.New(serviceError.Message))
+	}
+	return err
+}
+
+// statusCodes maps the HTTP status of the service errors to gRPC codes, other statuses are internal errors
+var statusCodes = map[int]connect.Code{
+	http.StatusBadRequest:            connect.CodeInvalidArgument,
+	http.StatusUnauthorized:          connect.CodeUnauthenticated,
+	http.StatusForbidden:             connect.CodePermissionDenied,
+	http.StatusNotFound:              connect.CodeNotFound,
+	http.StatusConflict:              connect.CodeAlreadyExists,
+	http.StatusRequestEntityTooLarge: connect.CodeInvalidArgument,
+	http.StatusUnprocessableEntity:   connect.CodeInvalidArgument,
+	http.StatusTooManyRequests:       connect.CodeResourceExhausted,
+	http.StatusServiceUnavailable:    connect.CodeUnavailable,
+}
+
+func statusCode(status int) connect.Code {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	return connect.CodeInternal
+}
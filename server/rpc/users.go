@@ -0,0 +1,61 @@
+package rpc
+
+import (
+	"context"
+	socialfeedv1 "server/gen/socialfeed/v1"
+	"server/handlers"
+	"server/models"
+
+	"connectrpc.com/connect"
+)
+
+type userService struct{}
+
+// GetProfile runs GET /api/v1/users/:username
+func (userService) GetProfile(ctx context.Context, request *connect.Request[socialfeedv1.GetProfileRequest]) (*connect.Response[socialfeedv1.Profile], error) {
+	service, err := serviceOf(ctx, false, handlers.APIKeyScopeRead)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := service.GetProfile(request.Msg.Username)
+	if err != nil {
+		return nil, connectError(err)
+	}
+	return connect.NewResponse(profileMessage(profile)), nil
+}
+
+// UpdateProfile runs PUT /api/v1/restricted/profile, API keys cannot change the profile
+func (userService) UpdateProfile(ctx context.Context, request *connect.Request[socialfeedv1.UpdateProfileRequest]) (*connect.Response[socialfeedv1.Profile], error) {
+	service, err := serviceOf(ctx, true, "")
+	if err != nil {
+		return nil, err
+	}
+	profile, err := service.UpdateProfile(models.UpdateProfileRequest{
+		DisplayName: request.Msg.DisplayName,
+		Bio:         request.Msg.Bio,
+		Location:    request.Msg.Location,
+		Website:     request.Msg.Website,
+	})
+	if err != nil {
+		return nil, connectError(err)
+	}
+	return connect.NewResponse(profileMessage(profile)), nil
+}
+
+func profileMessage(profile models.UserProfileResponse) *socialfeedv1.Profile {
+	return &socialfeedv1.Profile{
+		UserId:         uint64(profile.UserID),
+		Username:       profile.Username,
+		DisplayName:    profile.DisplayName,
+		Firstname:      profile.Firstname,
+		Surname:        profile.Surname,
+		Bio:            profile.Bio,
+		Location:       profile.Location,
+		Website:        profile.Website,
+		AvatarUrl:      profile.AvatarURL,
+		PostCount:      profile.PostCount,
+		FollowerCount:  profile.FollowerCount,
+		FollowingCount: profile.FollowingCount,
+		Following:      profile.Following,
+	}
+}
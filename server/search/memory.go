@@ -0,0 +1,262 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"server/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BM25 tuning parameters, the usual defaults
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type document struct {
+	docType   string
+	id        uint
+	postID    uint
+	userID    uint
+	body      string
+	createdAt *time.Time
+	length    int
+}
+
+// MemoryEngine is an inverted index kept in process memory.
+// It is the fallback for databases without FULLTEXT support and is rebuilt from the database on start.
+type MemoryEngine struct {
+	db          *gorm.DB
+	mu          sync.RWMutex
+	docs        map[string]*document
+	postings    map[string]map[string]int // term -> document key -> term frequency
+	terms       []string                  // the keys of postings, sorted for prefix lookups
+	totalLength int
+}
+
+func NewMemoryEngine(db *gorm.DB) *MemoryEngine {
+	return &MemoryEngine{
+		db:       db,
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string]int),
+	}
+}
+
+func documentKey(docType string, id uint) string {
+	return fmt.Sprintf("%s:%d", docType, id)
+}
+
+// Rebuild drops the index and loads every public post, the comments on them and every user from the database
+func (m *MemoryEngine) Rebuild() error {
+	var posts []models.Post
+	if err := m.db.Where("hidden_at IS NULL AND publish_at IS NULL AND visibility = ?", "public").Find(&posts).Error; err != nil {
+		return err
+	}
+
+	var comments []models.Comment
+	if err := m.db.Table("comments").Select("comments.*").Joins("inner join posts on posts.post_id = comments.post_id").
+		Where("comments.hidden_at IS NULL AND posts.hidden_at IS NULL AND posts.visibility = ?", "public").Scan(&comments).Error; err != nil {
+		return err
+	}
+
+	// Banned and deleted accounts are not listed
+	var users []models.User
+	if err := m.db.Where("status NOT IN ?", []string{"banned", "deleted"}).Find(&users).Error; err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.docs = make(map[string]*document)
+	m.postings = make(map[string]map[string]int)
+	m.terms = nil
+	m.totalLength = 0
+	m.mu.Unlock()
+
+	for _, post := range posts {
+		m.IndexPost(post)
+	}
+	for _, comment := range comments {
+		m.IndexComment(comment)
+	}
+	for _, user := range users {
+		m.IndexUser(user)
+	}
+
+	return nil
+}
+
+func (m *MemoryEngine) IndexPost(post models.Post) {
+	createdAt := post.CreatedAt
+	m.put(&document{docType: TypePost, id: post.PostID, postID: post.PostID, userID: post.UserID, body: post.Message, createdAt: &createdAt})
+}
+
+func (m *MemoryEngine) IndexComment(comment models.Comment) {
+	createdAt := comment.CreatedAt
+	m.put(&document{docType: TypeComment, id: comment.CommentID, postID: comment.PostID, userID: comment.AuthorID, body: comment.CommentMSG, createdAt: &createdAt})
+}
+
+func (m *MemoryEngine) IndexUser(user models.User) {
+	body := strings.Join([]string{user.Username, user.Firstname, user.Surname}, " ")
+	m.put(&document{docType: TypeUser, id: user.UserID, userID: user.UserID, body: body})
+}
+
+func (m *MemoryEngine) Remove(docType string, id uint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeLocked(documentKey(docType, id))
+}
+
+func (m *MemoryEngine) put(doc *document) {
+	key := documentKey(doc.docType, doc.id)
+	tokens := Tokenize(doc.body)
+	doc.length = len(tokens)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeLocked(key)
+	m.docs[key] = doc
+	m.totalLength += doc.length
+	for _, token := range tokens {
+		if m.postings[token] == nil {
+			m.postings[token] = make(map[string]int)
+			m.insertTermLocked(token)
+		}
+		m.postings[token][key]++
+	}
+}
+
+func (m *MemoryEngine) removeLocked(key string) {
+	doc, ok := m.docs[key]
+	if !ok {
+		return
+	}
+	for _, token := range Tokenize(doc.body) {
+		if docs := m.postings[token]; docs != nil {
+			delete(docs, key)
+			if len(docs) == 0 {
+				delete(m.postings, token)
+				m.deleteTermLocked(token)
+			}
+		}
+	}
+	m.totalLength -= doc.length
+	delete(m.docs, key)
+}
+
+func (m *MemoryEngine) insertTermLocked(term string) {
+	i := sort.SearchStrings(m.terms, term)
+	m.terms = append(m.terms, "")
+	copy(m.terms[i+1:], m.terms[i:])
+	m.terms[i] = term
+}
+
+func (m *MemoryEngine) deleteTermLocked(term string) {
+	i := sort.SearchStrings(m.terms, term)
+	if i < len(m.terms) && m.terms[i] == term {
+		m.terms = append(m.terms[:i], m.terms[i+1:]...)
+	}
+}
+
+// termsWithPrefixLocked returns the indexed terms starting with prefix, they sit next to each other in the sorted terms
+func (m *MemoryEngine) termsWithPrefixLocked(prefix string) []string {
+	start := sort.SearchStrings(m.terms, prefix)
+	end := start
+	for end < len(m.terms) && strings.HasPrefix(m.terms[end], prefix) {
+		end++
+	}
+	return m.terms[start:end]
+}
+
+type scoredDocument struct {
+	doc   *document
+	score float64
+}
+
+// Search ranks documents with BM25. Query terms match indexed terms by prefix,
+// so "gola" finds "golang" the same way the highlighter marks it.
+func (m *MemoryEngine) Search(q Query) ([]models.SearchResult, int64, error) {
+	terms := Tokenize(q.Text)
+
+	m.mu.RLock()
+	scores := make(map[string]float64)
+	if len(m.docs) > 0 {
+		docCount := float64(len(m.docs))
+		avgLength := float64(m.totalLength) / docCount
+		for _, term := range terms {
+			for _, indexed := range m.termsWithPrefixLocked(term) {
+				docs := m.postings[indexed]
+				df := float64(len(docs))
+				idf := math.Log(1 + (docCount-df+0.5)/(df+0.5))
+				for key, tf := range docs {
+					doc := m.docs[key]
+					if !wantsType(q, doc.docType) {
+						continue
+					}
+					norm := 1 - bm25B + bm25B*float64(doc.length)/avgLength
+					scores[key] += idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*norm)
+				}
+			}
+		}
+	}
+
+	ranked := make([]scoredDocument, 0, len(scores))
+	for key, score := range scores {
+		ranked = append(ranked, scoredDocument{doc: m.docs[key], score: score})
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].doc.id > ranked[j].doc.id
+	})
+
+	total := int64(len(ranked))
+	start := (q.Page - 1) * q.PageSize
+	if start > len(ranked) {
+		start = len(ranked)
+	}
+	end := start + q.PageSize
+	if end > len(ranked) {
+		end = len(ranked)
+	}
+	page := ranked[start:end]
+
+	// Usernames can change, so resolve them at query time instead of storing them in the index
+	userIDs := make([]uint, 0, len(page))
+	for _, hit := range page {
+		userIDs = append(userIDs, hit.doc.userID)
+	}
+	usernames := make(map[uint]string)
+	if len(userIDs) > 0 {
+		var users []models.User
+		if err := m.db.Select("user_id, username").Where("user_id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, 0, err
+		}
+		for _, user := range users {
+			usernames[user.UserID] = user.Username
+		}
+	}
+
+	results := make([]models.SearchResult, len(page))
+	for i, hit := range page {
+		results[i] = models.SearchResult{
+			Type:      hit.doc.docType,
+			ID:        hit.doc.id,
+			PostID:    hit.doc.postID,
+			Username:  usernames[hit.doc.userID],
+			Snippet:   Highlight(hit.doc.body, terms),
+			Score:     hit.score,
+			CreatedAt: hit.doc.createdAt,
+		}
+	}
+
+	return results, total, nil
+}
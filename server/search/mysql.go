@@ -0,0 +1,134 @@
+package search
+
+import (
+	"server/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MySQLEngine ranks results with MATCH ... AGAINST on the FULLTEXT indexes
+type MySQLEngine struct {
+	db *gorm.DB
+}
+
+func NewMySQLEngine(db *gorm.DB) *MySQLEngine {
+	return &MySQLEngine{db: db}
+}
+
+// fullTextIndexes mirrors the FULLTEXT indexes in create_database.sql
+var fullTextIndexes = []struct {
+	table   string
+	name    string
+	columns string
+}{
+	{"posts", "ft_posts_message", "message"},
+	{"comments", "ft_comments_comment_msg", "comment_msg"},
+	{"users", "ft_users_names", "username, firstname, surname"},
+}
+
+// EnsureFullTextIndexes creates the FULLTEXT indexes that are missing,
+// so a database created before the search migration still works.
+func EnsureFullTextIndexes(db *gorm.DB) error {
+	for _, index := range fullTextIndexes {
+		if db.Migrator().HasIndex(index.table, index.name) {
+			continue
+		}
+		if err := db.Exec("CREATE FULLTEXT INDEX " + index.name + " ON " + index.table + " (" + index.columns + ")").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type mysqlHit struct {
+	Type      string
+	ID        uint
+	PostID    uint
+	Username  string
+	Body      string
+	CreatedAt *time.Time
+	Score     float64
+}
+
+// BooleanQuery turns search text into the terms of a MATCH ... AGAINST (? IN BOOLEAN MODE), each matching by prefix
+// like the terms of the memory engine. Tokenize only keeps letters and digits, so no boolean operator gets through.
+func BooleanQuery(text string) string {
+	terms := Tokenize(text)
+	for i, term := range terms {
+		terms[i] = term + "*"
+	}
+	return strings.Join(terms, " ")
+}
+
+func (m *MySQLEngine) Search(q Query) ([]models.SearchResult, int64, error) {
+	text := BooleanQuery(q.Text)
+	if text == "" {
+		return []models.SearchResult{}, 0, nil
+	}
+
+	var parts []string
+	var args []interface{}
+
+	if wantsType(q, TypePost) {
+		parts = append(parts, `SELECT 'post' AS type, posts.post_id AS id, posts.post_id AS post_id, users.username AS username, posts.message AS body, posts.created_at AS created_at,
+			MATCH(posts.message) AGAINST (? IN BOOLEAN MODE) AS score
+			FROM posts INNER JOIN users ON users.user_id = posts.user_id
+			WHERE MATCH(posts.message) AGAINST (? IN BOOLEAN MODE) AND posts.hidden_at IS NULL AND posts.publish_at IS NULL AND posts.visibility = 'public'`)
+		args = append(args, text, text)
+	}
+	if wantsType(q, TypeComment) {
+		parts = append(parts, `SELECT 'comment' AS type, comments.comment_id AS id, comments.post_id AS post_id, users.username AS username, comments.comment_msg AS body, comments.created_at AS created_at,
+			MATCH(comments.comment_msg) AGAINST (? IN BOOLEAN MODE) AS score
+			FROM comments INNER JOIN users ON users.user_id = comments.author_id
+			INNER JOIN posts ON posts.post_id = comments.post_id
+			WHERE MATCH(comments.comment_msg) AGAINST (? IN BOOLEAN MODE) AND comments.hidden_at IS NULL AND posts.hidden_at IS NULL AND posts.visibility = 'public'`)
+		args = append(args, text, text)
+	}
+	if wantsType(q, TypeUser) {
+		parts = append(parts, `SELECT 'user' AS type, users.user_id AS id, 0 AS post_id, users.username AS username, CONCAT(users.username, ' ', users.firstname, ' ', users.surname) AS body, NULL AS created_at,
+			MATCH(users.username, users.firstname, users.surname) AGAINST (? IN BOOLEAN MODE) AS score
+			FROM users
+			WHERE MATCH(users.username, users.firstname, users.surname) AGAINST (? IN BOOLEAN MODE) AND users.status NOT IN ('banned', 'deleted')`)
+		args = append(args, text, text)
+	}
+	if len(parts) == 0 {
+		return []models.SearchResult{}, 0, nil
+	}
+
+	union := strings.Join(parts, " UNION ALL ")
+
+	var total int64
+	if err := m.db.Raw("SELECT COUNT(*) FROM ("+union+") AS hits", args...).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var hits []mysqlHit
+	pageArgs := append(append([]interface{}{}, args...), q.PageSize, (q.Page-1)*q.PageSize)
+	if err := m.db.Raw("SELECT * FROM ("+union+") AS hits ORDER BY score DESC, id DESC LIMIT ? OFFSET ?", pageArgs...).Scan(&hits).Error; err != nil {
+		return nil, 0, err
+	}
+
+	terms := Tokenize(q.Text)
+	results := make([]models.SearchResult, len(hits))
+	for i, hit := range hits {
+		results[i] = models.SearchResult{
+			Type:      hit.Type,
+			ID:        hit.ID,
+			PostID:    hit.PostID,
+			Username:  hit.Username,
+			Snippet:   Highlight(hit.Body, terms),
+			Score:     hit.Score,
+			CreatedAt: hit.CreatedAt,
+		}
+	}
+
+	return results, total, nil
+}
+
+// MySQL maintains FULLTEXT indexes itself, nothing to do on writes
+func (m *MySQLEngine) IndexPost(post models.Post)          {}
+func (m *MySQLEngine) IndexComment(comment models.Comment) {}
+func (m *MySQLEngine) IndexUser(user models.User)          {}
+func (m *MySQLEngine) Remove(docType string, id uint)      {}
@@ -0,0 +1,181 @@
+package search
+
+import (
+	"html"
+	"log"
+	"server/models"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Searchable document types
+const (
+	TypePost    = "post"
+	TypeComment = "comment"
+	TypeUser    = "user"
+)
+
+// Query is a search request after validation and defaults have been applied
+type Query struct {
+	Text     string
+	Types    []string
+	Page     int
+	PageSize int
+}
+
+// Engine searches posts, comments and users.
+// MySQL keeps its FULLTEXT indexes up to date on its own, so the Index* methods only matter for the in-memory engine.
+type Engine interface {
+	Search(q Query) ([]models.SearchResult, int64, error)
+	IndexPost(post models.Post)
+	IndexComment(comment models.Comment)
+	IndexUser(user models.User)
+	Remove(docType string, id uint)
+}
+
+// Default is the engine used by the handlers, set by Init
+var Default Engine
+
+// Init picks the engine for the database backend: MySQL FULLTEXT when available,
+// otherwise an inverted index built in memory from the current database content.
+func Init(db *gorm.DB) {
+	if db.Dialector.Name() == "mysql" {
+		if err := EnsureFullTextIndexes(db); err != nil {
+			log.Println("Failed to create FULLTEXT indexes:", err)
+		}
+		Default = NewMySQLEngine(db)
+		return
+	}
+
+	engine := NewMemoryEngine(db)
+	if err := engine.Rebuild(); err != nil {
+		log.Println("Failed to build search index:", err)
+	}
+	Default = engine
+}
+
+// IndexPost adds or refreshes a post in the default engine
+func IndexPost(post models.Post) {
+	if Default != nil {
+		Default.IndexPost(post)
+	}
+}
+
+// IndexComment adds or refreshes a comment in the default engine
+func IndexComment(comment models.Comment) {
+	if Default != nil {
+		Default.IndexComment(comment)
+	}
+}
+
+// IndexUser adds or refreshes a user in the default engine
+func IndexUser(user models.User) {
+	if Default != nil {
+		Default.IndexUser(user)
+	}
+}
+
+// Remove drops a document from the default engine
+func Remove(docType string, id uint) {
+	if Default != nil {
+		Default.Remove(docType, id)
+	}
+}
+
+// Tokenize lowercases text and splits it on anything that is not a letter or digit.
+// Single character tokens are dropped, they match almost everything and carry no meaning.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := fields[:0]
+	for _, field := range fields {
+		if len([]rune(field)) > 1 {
+			tokens = append(tokens, field)
+		}
+	}
+	return tokens
+}
+
+const snippetLength = 160
+
+// Highlight returns an HTML escaped snippet of text around the first matching term,
+// with every word starting with one of the terms wrapped in <mark></mark>.
+func Highlight(text string, terms []string) string {
+	runes := []rune(text)
+
+	type span struct{ start, end int }
+	var matches []span
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := strings.ToLower(string(runes[i:j]))
+		for _, term := range terms {
+			if strings.HasPrefix(word, term) {
+				matches = append(matches, span{i, j})
+				break
+			}
+		}
+		i = j
+	}
+
+	// Center the snippet on the first match
+	start := 0
+	if len(matches) > 0 && len(runes) > snippetLength {
+		start = matches[0].start - snippetLength/4
+		if start < 0 {
+			start = 0
+		}
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m.start < start || m.end > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:m.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[m.start:m.end])))
+		b.WriteString("</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func wantsType(q Query, docType string) bool {
+	if len(q.Types) == 0 {
+		return true
+	}
+	for _, t := range q.Types {
+		if t == docType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strconv"
+)
+
+// ErrNotFound is returned when a blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps opaque blobs addressed by a slash separated key.
+// The local filesystem implementation is used by default, an S3-compatible store
+// can be plugged in by implementing this interface and assigning it to Default.
+type BlobStore interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// Default is the store used by the handlers, set by Init
+var Default BlobStore
+
+// SigningKey signs the URLs that grant access to blobs, set by Init
+var SigningKey []byte
+
+// Init sets up the default store in STORAGE_DIR, or ./uploads when it is not set, and the signing key from STORAGE_SIGNING_KEY.
+// Without a signing key anyone could sign URLs, the server does not start.
+func Init() {
+	dir := os.Getenv("STORAGE_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+	Default = NewLocalStore(dir)
+
+	SigningKey = []byte(os.Getenv("STORAGE_SIGNING_KEY"))
+	if len(SigningKey) == 0 {
+		log.Fatal("STORAGE_SIGNING_KEY is required, it signs the download URLs of attachments and exports")
+	}
+}
+
+// Sign returns the signature that grants access to a blob key until the expiry (unix seconds)
+func Sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, SigningKey)
+	mac.Write([]byte(key))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidSignature reports whether signature was produced by Sign for the key and expiry.
+// It does not check the expiry itself.
+func ValidSignature(key string, expires int64, signature string) bool {
+	return hmac.Equal([]byte(Sign(key, expires)), []byte(signature))
+}
@@ -0,0 +1,254 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/models"
+	"server/search"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func login(t *testing.T, identifier string, password string) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+
+	body, _ := json.Marshal(models.LoginUserRequest{Identifier: identifier, Password: password})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, handlers.LoggedInUser(e.NewContext(req, rec)))
+	return rec
+}
+
+// status code of a restricted request behind the account status check
+func restrictedStatus(t *testing.T, tokenString string) int {
+	rec, err := serveRestricted(handlers.ActiveAccount(handlers.GetWarnings), http.MethodGet, "/api/v1/restricted/warnings", "", tokenString)
+	assert.NoError(t, err)
+	return rec.Code
+}
+
+func setAccountStatus(t *testing.T, userID uint, body string) *httptest.ResponseRecorder {
+	uid := fmt.Sprint(userID)
+	return serveAdmin(t, handlers.UpdateAccountStatus, http.MethodPut, "/api/v1/admin/users/"+uid+"/status", body, "uid", uid)
+}
+
+// assertListed checks whether the account shows up in search, its profile and the GraphQL user lookups
+func assertListed(t *testing.T, user models.User, username string, listed bool) {
+	assert.Equal(t, listed, len(searchFor(t, username, search.TypeUser)) == 1, "search")
+	rec, _ := getProfile(t, username)
+	assert.Equal(t, listed, rec.Code == http.StatusOK, "profile")
+	query := fmt.Sprintf(`{ byName: user(username: %q) { uid } byID: user(uid: %d) { uid } }`, username, user.UserID)
+	_, response := serveGraphQL(t, query, nil, "")
+	assert.Empty(t, response.Errors)
+	assert.Equal(t, listed, response.Data["byName"] != nil, "GraphQL user by username")
+	assert.Equal(t, listed, response.Data["byID"] != nil, "GraphQL user by ID")
+}
+
+func assertHTTPError(t *testing.T, err error, code int) {
+	httpError, ok := err.(*echo.HTTPError)
+	if assert.True(t, ok, "expected an HTTP error, got %v", err) {
+		assert.Equal(t, code, httpError.Code)
+	}
+}
+
+func registeredUser(t *testing.T) (models.User, string) {
+	GenerateNewUser(t)
+	var user models.User
+	assert.NoError(t, config.DB.Where("username = ?", "testuser").First(&user).Error)
+	return user, createJWTTokenTest(t, user.UserID)
+}
+
+// ----------- API Testing ----------- //
+func TestAccountStatus(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	user, tokenString := registeredUser(t)
+	assert.Equal(t, http.StatusOK, restrictedStatus(t, tokenString))
+	private := createPostViaAPI(t, tokenString, "Only me")
+	assert.NoError(t, config.DB.Model(&private).Update("visibility", "private").Error)
+	readPrivate := func() int {
+		pid := fmt.Sprint(private.PostID)
+		rec, err := serveRestricted(handlers.OptionalActiveAccount(handlers.GetPosts), http.MethodGet, "/api/v1/posts?pid="+pid, "", tokenString)
+		assert.NoError(t, err)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, readPrivate())
+
+	assert.Equal(t, http.StatusBadRequest, setAccountStatus(t, user.UserID, `{"status":"suspended","reason":"Spam"}`).Code)
+
+	// Banning stops the existing token and the login
+	rec := setAccountStatus(t, user.UserID, `{"status":"banned","reason":"Repeated spam"}`)
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		var updated models.User
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+		assert.Equal(t, handlers.AccountBanned, updated.Status)
+	}
+	assert.Equal(t, http.StatusForbidden, restrictedStatus(t, tokenString))
+	rec = login(t, "testuser", "password123")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "banned")
+
+	// On public routes the token of a banned account counts as no token at all
+	assert.Equal(t, http.StatusNotFound, readPrivate())
+
+	// Suspensions end by themselves, activation ends them early
+	assert.Equal(t, http.StatusOK, setAccountStatus(t, user.UserID, `{"status":"suspended","reason":"Cool down","suspend_hours":2}`).Code)
+	assert.Equal(t, http.StatusForbidden, restrictedStatus(t, tokenString))
+	assert.Equal(t, http.StatusOK, setAccountStatus(t, user.UserID, `{"status":"active","reason":"Appeal accepted"}`).Code)
+	assert.Equal(t, http.StatusOK, restrictedStatus(t, tokenString))
+	assert.Equal(t, http.StatusOK, login(t, "testuser", "password123").Code)
+	assert.Equal(t, http.StatusOK, readPrivate())
+
+	var actions []models.ModerationAction
+	config.DB.Where("user_id = ?", user.UserID).Order("moderation_action_id").Find(&actions)
+	if assert.Len(t, actions, 3) {
+		assert.Equal(t, "ban", actions[0].Action)
+		assert.Equal(t, "Repeated spam", actions[0].Reason)
+		assert.Equal(t, "mod1", actions[0].Moderator)
+		assert.NotNil(t, actions[1].SuspendedUntil)
+		assert.Equal(t, "activate", actions[2].Action)
+	}
+
+	// A deleted user's token stops working as well
+	assert.Equal(t, http.StatusNotFound, setAccountStatus(t, 9999, `{"status":"banned","reason":"Spam"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, restrictedStatus(t, createJWTTokenTest(t, 9999)))
+}
+
+func TestDeactivateAccount(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	_, tokenString := registeredUser(t)
+
+	_, err := serveRestricted(handlers.DeactivateAccount, http.MethodPost, "/api/v1/restricted/account/deactivate", `{"password":"wrong"}`, tokenString)
+	assertHTTPError(t, err, http.StatusUnauthorized)
+	assert.Equal(t, http.StatusOK, restrictedStatus(t, tokenString))
+
+	rec, err := serveRestricted(handlers.DeactivateAccount, http.MethodPost, "/api/v1/restricted/account/deactivate", `{"password":"password123"}`, tokenString)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	assert.Equal(t, http.StatusUnauthorized, restrictedStatus(t, tokenString))
+
+	// Logging in again reactivates the account
+	assert.Equal(t, http.StatusOK, login(t, "testuser", "password123").Code)
+	assert.Equal(t, http.StatusOK, restrictedStatus(t, tokenString))
+}
+
+func TestDeleteAccount(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	user, tokenString := registeredUser(t)
+	other := createTestUserNamed(t, config.DB, "other")
+	otherToken := createJWTTokenTest(t, other.UserID)
+
+	ownPost := createPostViaAPI(t, tokenString, "My post")
+	otherPost := createPostViaAPI(t, otherToken, "Their post")
+	assert.Equal(t, http.StatusCreated, commentOn(t, ownPost.PostID, "Reply from other", otherToken))
+	assert.Equal(t, http.StatusCreated, commentOn(t, otherPost.PostID, "Reply from me", tokenString))
+	assert.Equal(t, http.StatusCreated, commentOn(t, otherPost.PostID, "Another reply from other", otherToken))
+
+	_, err := serveRestricted(handlers.DeleteAccount, http.MethodDelete, "/api/v1/restricted/account", `{"password":"nope"}`, tokenString)
+	assertHTTPError(t, err, http.StatusUnauthorized)
+	assert.Equal(t, http.StatusOK, restrictedStatus(t, tokenString))
+
+	rec, err := serveRestricted(handlers.DeleteAccount, http.MethodDelete, "/api/v1/restricted/account", `{"password":"password123"}`, tokenString)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// The account goes with its posts, its comments and the comments on its posts
+	var users, posts, comments int64
+	config.DB.Model(&models.User{}).Where("user_id = ?", user.UserID).Count(&users)
+	config.DB.Model(&models.Post{}).Count(&posts)
+	config.DB.Model(&models.Comment{}).Count(&comments)
+	assert.Equal(t, int64(0), users)
+	assert.Equal(t, int64(1), posts)
+	assert.Equal(t, int64(1), comments)
+	assert.Len(t, searchFor(t, "reply", "comment"), 1)
+	assert.Equal(t, http.StatusUnauthorized, restrictedStatus(t, tokenString))
+}
+
+func TestDeleteAccount_Anonymize(t *testing.T) {
+	createTables()
+	defer teardown()
+	t.Setenv("ACCOUNT_DELETION_POLICY", handlers.DeletionAnonymize)
+
+	user, tokenString := registeredUser(t)
+	other := createTestUserNamed(t, config.DB, "other")
+	otherToken := createJWTTokenTest(t, other.UserID)
+	uid := fmt.Sprint(user.UserID)
+
+	post := createPostViaAPI(t, tokenString, "Staying around")
+	_, err := serveRestricted(handlers.FollowUser, http.MethodPut, "/api/v1/restricted/follows/"+uid, "", otherToken, "uid", uid)
+	assert.NoError(t, err)
+	otherPost := createPostViaAPI(t, otherToken, "Their post")
+	_, err = reactToPost(t, http.MethodPut, otherPost.PostID, "like", tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, uploadPostAttachment(t, post.PostID, "photo.png", testPNG(t, 40, 30), tokenString).Code)
+
+	rec, err := serveRestricted(handlers.DeleteAccount, http.MethodDelete, "/api/v1/restricted/account", `{"password":"password123"}`, tokenString)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// The post stays under a placeholder, nothing personal is left and nobody can log in
+	posts := listVisiblePosts(t)
+	assert.Len(t, posts, 2)
+	for _, listed := range posts {
+		switch listed.PostID {
+		case post.PostID:
+			assert.Equal(t, "deleted-"+uid, listed.Username)
+			assert.Empty(t, listed.Attachments)
+		case otherPost.PostID:
+			assert.Empty(t, listed.Reactions)
+		}
+	}
+	var anonymized models.User
+	config.DB.First(&anonymized, user.UserID)
+	assert.Equal(t, handlers.AccountDeleted, anonymized.Status)
+	assert.NotContains(t, anonymized.Email, "example.com")
+	var follows int64
+	config.DB.Model(&models.Follow{}).Count(&follows)
+	assert.Equal(t, int64(0), follows)
+	assert.Zero(t, countRows(t, &models.PostReaction{}))
+	assert.Zero(t, countRows(t, &models.Attachment{}))
+
+	assert.Equal(t, http.StatusUnauthorized, login(t, "testuser", "password123").Code)
+	assert.Equal(t, http.StatusUnauthorized, restrictedStatus(t, tokenString))
+	assertListed(t, anonymized, "deleted-"+uid, false)
+}
+
+func TestBannedAccountsUnlisted(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	user, tokenString := registeredUser(t)
+	post := createPostViaAPI(t, tokenString, "Still here")
+	author := func() interface{} {
+		_, response := serveGraphQL(t, fmt.Sprintf(`{ post(id: %d) { author { username } } }`, post.PostID), nil, "")
+		listed, _ := response.Data["post"].(map[string]interface{})
+		return listed["author"]
+	}
+	assertListed(t, user, "testuser", true)
+	assert.NotNil(t, author())
+
+	// A ban takes the account out of search, its profile and GraphQL, the post keeps showing without an author
+	assert.Equal(t, http.StatusOK, setAccountStatus(t, user.UserID, `{"status":"banned","reason":"Spam"}`).Code)
+	assertListed(t, user, "testuser", false)
+	assert.Nil(t, author())
+
+	assert.Equal(t, http.StatusOK, setAccountStatus(t, user.UserID, `{"status":"active","reason":"Appeal"}`).Code)
+	assertListed(t, user, "testuser", true)
+}
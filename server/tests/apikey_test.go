@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveAuthenticated runs a request through the restricted group middleware, with the Authorization or X-API-Key header given
+func serveAuthenticated(t *testing.T, method string, path string, body string, header string, value string) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	restricted := e.Group("/api/v1/restricted", handlers.JWTAPIMiddleware(testJWTConfig()), handlers.ActiveAccount)
+	restricted.GET("/drafts", handlers.GetDrafts)
+	restricted.POST("/posts", handlers.CreatePost)
+	restricted.POST("/comments", handlers.CreateComment)
+	restricted.PUT("/follows/:uid", handlers.FollowUser)
+	restricted.GET("/api-keys", handlers.GetAPIKeys)
+	restricted.POST("/api-keys", handlers.CreateAPIKey)
+	restricted.DELETE("/api-keys/:kid", handlers.RevokeAPIKey)
+	restricted.GET("/me/export/:jid", handlers.GetExport)
+	restricted.GET("/notifications", handlers.GetNotifications)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func createAPIKey(t *testing.T, tokenString string, body string) (int, models.APIKeyResponse) {
+	rec := serveAuthenticated(t, http.MethodPost, "/api/v1/restricted/api-keys", body, echo.HeaderAuthorization, "Bearer "+tokenString)
+	var apiKey models.APIKeyResponse
+	if rec.Code == http.StatusCreated {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiKey))
+	}
+	return rec.Code, apiKey
+}
+
+// ----------- API Testing ----------- //
+func TestAPIKeys(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	user := createTestUserNamed(t, config.DB, "scripter")
+	other := createTestUserNamed(t, config.DB, "other")
+	tokenString := createJWTTokenTest(t, user.UserID)
+
+	code, _ := createAPIKey(t, tokenString, `{"name":"bot","scopes":["admin"]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = createAPIKey(t, tokenString, fmt.Sprintf(`{"name":"bot","scopes":["read"],"expires_at":%q}`, time.Now().Add(-time.Hour).Format(time.RFC3339)))
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, readKey := createAPIKey(t, tokenString, `{"name":"reader","scopes":["read"]}`)
+	if assert.Equal(t, http.StatusCreated, code) {
+		assert.True(t, strings.HasPrefix(readKey.Key, "ak_"))
+		assert.True(t, strings.HasPrefix(readKey.Key, readKey.Prefix))
+		assert.Equal(t, []string{handlers.APIKeyScopeRead}, readKey.Scopes)
+	}
+	_, postKey := createAPIKey(t, tokenString, `{"name":"poster","scopes":["post","read"]}`)
+	assert.Equal(t, []string{handlers.APIKeyScopeRead, handlers.APIKeyScopePost}, postKey.Scopes)
+	assert.Len(t, getAuditLogs(t, "action="+handlers.AuditAPIKeyCreate), 2)
+
+	// Only the hash is stored
+	var stored models.APIKey
+	assert.NoError(t, config.DB.First(&stored, readKey.APIKeyID).Error)
+	assert.NotContains(t, stored.KeyHash, readKey.Key)
+	assert.Len(t, stored.KeyHash, 64)
+
+	// Both header schemes are accepted and the request acts as the owner
+	rec := serveAuthenticated(t, http.MethodGet, "/api/v1/restricted/drafts", "", handlers.APIKeyHeader, readKey.Key)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = serveAuthenticated(t, http.MethodGet, "/api/v1/restricted/drafts", "", echo.HeaderAuthorization, "ApiKey "+readKey.Key)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = serveAuthenticated(t, http.MethodGet, "/api/v1/restricted/drafts", "", handlers.APIKeyHeader, "ak_unknown")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = serveAuthenticated(t, http.MethodGet, "/api/v1/restricted/drafts", "", "", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NoError(t, config.DB.First(&stored, readKey.APIKeyID).Error)
+	assert.NotNil(t, stored.LastUsedAt)
+
+	// Writes need the matching scope
+	rec = serveAuthenticated(t, http.MethodPost, "/api/v1/restricted/posts", `{"message":"From a script"}`, handlers.APIKeyHeader, readKey.Key)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serveAuthenticated(t, http.MethodPost, "/api/v1/restricted/posts", `{"message":"From a script"}`, handlers.APIKeyHeader, postKey.Key)
+	var post models.Post
+	if assert.Equal(t, http.StatusCreated, rec.Code) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &post))
+		assert.Equal(t, user.UserID, post.UserID)
+	}
+	rec = serveAuthenticated(t, http.MethodPost, "/api/v1/restricted/comments", fmt.Sprintf(`{"post_id":%d,"comment_msg":"Nope"}`, post.PostID), handlers.APIKeyHeader, postKey.Key)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Routes outside the scopes, such as following or managing keys, need a JWT
+	rec = serveAuthenticated(t, http.MethodPut, fmt.Sprintf("/api/v1/restricted/follows/%d", other.UserID), "", handlers.APIKeyHeader, postKey.Key)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serveAuthenticated(t, http.MethodPost, "/api/v1/restricted/api-keys", `{"name":"escalate","scopes":["post"]}`, handlers.APIKeyHeader, postKey.Key)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// The read scope does not cover exports or listing the keys
+	rec = serveAuthenticated(t, http.MethodGet, "/api/v1/restricted/api-keys", "", handlers.APIKeyHeader, readKey.Key)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serveAuthenticated(t, http.MethodGet, "/api/v1/restricted/me/export/1", "", handlers.APIKeyHeader, readKey.Key)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serveAuthenticated(t, http.MethodGet, "/api/v1/restricted/notifications", "", handlers.APIKeyHeader, readKey.Key)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Listing never returns the keys
+	rec = serveAuthenticated(t, http.MethodGet, "/api/v1/restricted/api-keys", "", echo.HeaderAuthorization, "Bearer "+tokenString)
+	var listed []models.APIKeyResponse
+	if assert.Equal(t, http.StatusOK, rec.Code) && assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed)) && assert.Len(t, listed, 2) {
+		assert.Empty(t, listed[0].Key)
+		assert.NotContains(t, rec.Body.String(), readKey.Key)
+	}
+
+	// Revoked keys stop working, other users cannot revoke them
+	kid := fmt.Sprint(readKey.APIKeyID)
+	otherToken := createJWTTokenTest(t, other.UserID)
+	rec = serveAuthenticated(t, http.MethodDelete, "/api/v1/restricted/api-keys/"+kid, "", echo.HeaderAuthorization, "Bearer "+otherToken)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serveAuthenticated(t, http.MethodDelete, "/api/v1/restricted/api-keys/"+kid, "", echo.HeaderAuthorization, "Bearer "+tokenString)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = serveAuthenticated(t, http.MethodGet, "/api/v1/restricted/drafts", "", handlers.APIKeyHeader, readKey.Key)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Len(t, getAuditLogs(t, "action="+handlers.AuditAPIKeyRevoke+"&target_id="+kid), 1)
+
+	// Expired keys stop working
+	assert.NoError(t, config.DB.Model(&models.APIKey{}).Where("api_key_id = ?", postKey.APIKeyID).Update("expires_at", time.Now().Add(-time.Second)).Error)
+	rec = serveAuthenticated(t, http.MethodGet, "/api/v1/restricted/drafts", "", handlers.APIKeyHeader, postKey.Key)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Keys of banned accounts stop working like their tokens
+	_, commentKey := createAPIKey(t, tokenString, `{"name":"commenter","scopes":["comment"]}`)
+	rec = serveAuthenticated(t, http.MethodPost, "/api/v1/restricted/comments", fmt.Sprintf(`{"post_id":%d,"comment_msg":"Scripted"}`, post.PostID), handlers.APIKeyHeader, commentKey.Key)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.NoError(t, config.DB.Model(&models.User{}).Where("user_id = ?", user.UserID).Update("status", handlers.AccountBanned).Error)
+	rec = serveAuthenticated(t, http.MethodPost, "/api/v1/restricted/comments", fmt.Sprintf(`{"post_id":%d,"comment_msg":"Again"}`, post.PostID), handlers.APIKeyHeader, commentKey.Key)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,331 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/models"
+	"server/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func testPNG(t *testing.T, width int, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// upload a file as multipart to a restricted handler, params are name/value pairs
+func uploadFile(t *testing.T, handler echo.HandlerFunc, method string, path string, filename string, data []byte, tokenString string, params ...string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", filename)
+	part.Write(data)
+	writer.Close()
+
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokenString)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	names, values := []string{}, []string{}
+	for i := 0; i+1 < len(params); i += 2 {
+		names = append(names, params[i])
+		values = append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+
+	jwtMiddleware := echojwt.WithConfig(echojwt.Config{
+		NewClaimsFunc: func(c echo.Context) jwt.Claims {
+			return new(models.JWTClaims)
+		},
+		SigningKey: []byte("testing_mock"),
+	})
+	if err := jwtMiddleware(handler)(c); err != nil {
+		if httpError, ok := err.(*echo.HTTPError); ok {
+			rec.Code = httpError.Code
+			return rec
+		}
+		t.Fatalf("Failed to upload file: %v", err)
+	}
+	return rec
+}
+
+func uploadPostAttachment(t *testing.T, postID uint, filename string, data []byte, tokenString string) *httptest.ResponseRecorder {
+	pid := fmt.Sprint(postID)
+	return uploadFile(t, handlers.UploadPostAttachment, http.MethodPost, "/api/v1/restricted/posts/"+pid+"/attachments", filename, data, tokenString, "pid", pid)
+}
+
+// download an attachment through its signed URL
+func downloadAttachment(t *testing.T, rawURL string, headers map[string]string) *httptest.ResponseRecorder {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Invalid attachment URL: %v", err)
+	}
+
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	req := httptest.NewRequest(http.MethodGet, parsed.RequestURI(), nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("aid")
+	c.SetParamValues(strings.TrimPrefix(parsed.Path, "/api/v1/attachments/"))
+
+	if err := handlers.GetAttachment(c); err != nil {
+		if httpError, ok := err.(*echo.HTTPError); ok {
+			rec.Code = httpError.Code
+			return rec
+		}
+		t.Fatalf("Failed to download attachment: %v", err)
+	}
+	return rec
+}
+
+// ----------- Unit Testing ----------- //
+func TestThumbnail(t *testing.T) {
+	// Left half opaque red, right half fully transparent
+	img := image.NewNRGBA(image.Rect(0, 0, 800, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 400; x++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+
+	data, err := helpers.Thumbnail(buf.Bytes(), 200)
+	if !assert.NoError(t, err) {
+		return
+	}
+	thumbnail, format, err := image.Decode(bytes.NewReader(data))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Rect(0, 0, 200, 100), thumbnail.Bounds())
+
+	// JPEG is lossy, compare the colors away from the edge with some slack
+	assertColor := func(x int, y int, want color.RGBA) {
+		r, g, b, _ := thumbnail.At(x, y).RGBA()
+		assert.InDelta(t, want.R, r>>8, 8)
+		assert.InDelta(t, want.G, g>>8, 8)
+		assert.InDelta(t, want.B, b>>8, 8)
+	}
+	assertColor(50, 50, color.RGBA{R: 255})
+	assertColor(150, 50, color.RGBA{R: 255, G: 255, B: 255})
+
+	// Small images keep their size, avatars are square
+	data, err = helpers.Thumbnail(testPNG(t, 30, 20), 200)
+	if assert.NoError(t, err) {
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		assert.NoError(t, err)
+		assert.Equal(t, 30, config.Width)
+		assert.Equal(t, 20, config.Height)
+	}
+	data, err = helpers.Avatar(testPNG(t, 300, 120), 64)
+	if assert.NoError(t, err) {
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		assert.NoError(t, err)
+		assert.Equal(t, 64, config.Width)
+		assert.Equal(t, 64, config.Height)
+	}
+}
+
+func BenchmarkThumbnail(b *testing.B) {
+	img := image.NewYCbCr(image.Rect(0, 0, 6000, 4000), image.YCbCrSubsampleRatio420)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		b.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := helpers.Thumbnail(buf.Bytes(), 400); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// ----------- API Testing ----------- //
+func TestUploadAndDownloadAttachment(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	userMock := createTestUser(t, config.DB)
+	postMock := createTestPost(t, config.DB, userMock)
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+
+	data := testPNG(t, 800, 400)
+	// The name and the multipart content type are ignored, the bytes decide
+	rec := uploadPostAttachment(t, postMock.PostID, "photo.jpg", data, tokenString)
+	if !assert.Equal(t, http.StatusCreated, rec.Code) {
+		return
+	}
+
+	var attachment models.AttachmentResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &attachment))
+	sum := sha256.Sum256(data)
+	assert.Equal(t, "image/png", attachment.ContentType)
+	assert.Equal(t, 800, attachment.Width)
+	assert.Equal(t, 400, attachment.Height)
+	assert.Equal(t, int64(len(data)), attachment.Size)
+	assert.Equal(t, hex.EncodeToString(sum[:]), attachment.SHA256)
+
+	// Original
+	rec = downloadAttachment(t, attachment.URL, nil)
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.Equal(t, data, rec.Body.Bytes())
+		assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+		assert.Contains(t, rec.Header().Get(echo.HeaderCacheControl), "max-age=")
+	}
+	etag := rec.Header().Get("ETag")
+	rec = downloadAttachment(t, attachment.URL, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	// Thumbnail
+	rec = downloadAttachment(t, attachment.ThumbnailURL, nil)
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		thumbnail, format, err := image.DecodeConfig(rec.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "jpeg", format)
+		assert.Equal(t, 320, thumbnail.Width)
+		assert.Equal(t, 160, thumbnail.Height)
+	}
+
+	// The signature covers the variant and the expiry
+	rec = downloadAttachment(t, strings.Replace(attachment.URL, "variant=original", "variant=thumbnail", 1), nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	var stored models.Attachment
+	config.DB.First(&stored, attachment.AttachmentID)
+	expired := time.Now().Add(-time.Minute).Unix()
+	rec = downloadAttachment(t, fmt.Sprintf("/api/v1/attachments/%d?expires=%d&signature=%s", attachment.AttachmentID, expired, storage.Sign(stored.StorageKey, expired)), nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Attachments are listed with the post
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/posts", nil)
+	rec = httptest.NewRecorder()
+	if assert.NoError(t, handlers.GetPosts(e.NewContext(req, rec))) {
+		var posts []models.GetPublicPostsRequest
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &posts))
+		if assert.Len(t, posts, 1) && assert.Len(t, posts[0].Attachments, 1) {
+			assert.Equal(t, attachment.AttachmentID, posts[0].Attachments[0].AttachmentID)
+		}
+	}
+}
+
+func TestAttachmentVisibility(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	author := createTestUserNamed(t, config.DB, "author")
+	follower := createTestUserNamed(t, config.DB, "follower")
+	authorToken := createJWTTokenTest(t, author.UserID)
+	followerToken := createJWTTokenTest(t, follower.UserID)
+	assert.NoError(t, config.DB.Create(&models.Follow{FollowerID: follower.UserID, FolloweeID: author.UserID}).Error)
+	post := createTestPost(t, config.DB, author)
+
+	rec := uploadPostAttachment(t, post.PostID, "photo.png", testPNG(t, 40, 30), authorToken)
+	var attachment models.AttachmentResponse
+	if !assert.Equal(t, http.StatusCreated, rec.Code) || !assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &attachment)) {
+		return
+	}
+	downloadAs := func(tokenString string) *httptest.ResponseRecorder {
+		parsed, _ := url.Parse(attachment.URL)
+		aid := fmt.Sprint(attachment.AttachmentID)
+		rec, err := serveRestricted(handlers.GetAttachment, http.MethodGet, parsed.RequestURI(), "", tokenString, "aid", aid)
+		assert.NoError(t, err)
+		return rec
+	}
+
+	rec = downloadAttachment(t, attachment.URL, nil)
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.True(t, strings.HasPrefix(rec.Header().Get(echo.HeaderCacheControl), "public"))
+	}
+
+	// A valid link is not enough once the post is for followers only: anonymous viewers are refused, followers are not
+	assert.NoError(t, config.DB.Model(post).Update("visibility", "followers").Error)
+	assert.Equal(t, http.StatusNotFound, downloadAttachment(t, attachment.URL, nil).Code)
+	rec = downloadAs(followerToken)
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.True(t, strings.HasPrefix(rec.Header().Get(echo.HeaderCacheControl), "private"))
+	}
+
+	// Nor when the author blocked the viewer, or the post is hidden by moderators
+	assert.NoError(t, config.DB.Create(&models.Block{BlockerID: author.UserID, BlockedID: follower.UserID}).Error)
+	assert.Equal(t, http.StatusNotFound, downloadAs(followerToken).Code)
+	assert.Equal(t, http.StatusOK, downloadAs(authorToken).Code)
+	assert.NoError(t, config.DB.Model(post).Update("hidden_at", time.Now()).Error)
+	assert.Equal(t, http.StatusNotFound, downloadAs(authorToken).Code)
+}
+
+func TestUploadAttachment_Invalid(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	userMock := createTestUser(t, config.DB)
+	otherUser := createTestUserNamed(t, config.DB, "otheruser")
+	postMock := createTestPost(t, config.DB, userMock)
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+
+	tests := []struct {
+		name     string
+		filename string
+		data     []byte
+		token    string
+		status   int
+	}{
+		{"Not an image", "notes.png", []byte("just some text pretending to be a picture"), tokenString, http.StatusUnsupportedMediaType},
+		{"Broken image", "broken.png", testPNG(t, 10, 10)[:60], tokenString, http.StatusBadRequest},
+		{"Too large", "huge.png", append(testPNG(t, 10, 10), make([]byte, 10<<20)...), tokenString, http.StatusRequestEntityTooLarge},
+		{"Not the author", "photo.png", testPNG(t, 10, 10), createJWTTokenTest(t, otherUser.UserID), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := uploadPostAttachment(t, postMock.PostID, tt.filename, tt.data, tt.token)
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+
+	// At most 4 attachments per post
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusCreated, uploadPostAttachment(t, postMock.PostID, "photo.png", testPNG(t, 10+i, 10), tokenString).Code)
+	}
+	assert.Equal(t, http.StatusBadRequest, uploadPostAttachment(t, postMock.PostID, "photo.png", testPNG(t, 20, 10), tokenString).Code)
+}
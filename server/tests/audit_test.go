@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/handlers"
+	"server/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getAuditLogs(t *testing.T, query string) []models.AuditLogResponse {
+	rec := serveAdmin(t, handlers.GetAuditLogs, http.MethodGet, "/api/v1/admin/audit-logs?"+query, "")
+	entries := []models.AuditLogResponse{}
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+	}
+	return entries
+}
+
+// ----------- API Testing ----------- //
+func TestAuditLog(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	user, tokenString := registeredUser(t)
+	uid := fmt.Sprint(user.UserID)
+
+	// Registration and login attempts
+	assert.Equal(t, http.StatusUnauthorized, login(t, "testuser", "wrong-password").Code)
+	assert.Equal(t, http.StatusUnauthorized, login(t, "nobody", "password123").Code)
+	assert.Equal(t, http.StatusOK, login(t, "testuser", "password123").Code)
+
+	registered := getAuditLogs(t, "action="+handlers.AuditUserRegister)
+	if assert.Len(t, registered, 1) {
+		assert.Equal(t, handlers.AuditActorUser, registered[0].ActorType)
+		assert.Equal(t, uid, registered[0].TargetID)
+	}
+	failed := getAuditLogs(t, "action="+handlers.AuditLoginFailed)
+	if assert.Len(t, failed, 2) {
+		// Newest first
+		assert.Equal(t, handlers.AuditActorAnonymous, failed[0].ActorType)
+		assert.Equal(t, "nobody", failed[0].ActorName)
+		assert.Equal(t, "Invalid password", failed[1].Details)
+		if assert.NotNil(t, failed[1].ActorID) {
+			assert.Equal(t, user.UserID, *failed[1].ActorID)
+		}
+	}
+	assert.Len(t, getAuditLogs(t, "action="+handlers.AuditLogin+"&actor_id="+uid), 1)
+
+	// Account changes keep a diff, passwords never appear in it
+	rec, err := serveRestricted(handlers.UpdateUser, http.MethodPut, "/api/v1/restricted/users/"+uid, `{"username":"renamed","firstname":"Test","surname":"User"}`, tokenString, "uid", uid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	updates := getAuditLogs(t, "action="+handlers.AuditUserUpdate)
+	if assert.Len(t, updates, 1) {
+		var changes map[string]map[string]interface{}
+		assert.NoError(t, json.Unmarshal(updates[0].Changes, &changes))
+		assert.Equal(t, map[string]interface{}{"from": "testuser", "to": "renamed"}, changes["username"])
+		assert.NotContains(t, changes, "firstname")
+	}
+
+	rec, err = serveRestricted(handlers.ChangePassword, http.MethodPut, "/api/v1/restricted/users-update-password/"+uid, `{"password":"new-password-1"}`, tokenString, "uid", uid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	passwordChanges := getAuditLogs(t, "action="+handlers.AuditPasswordChange)
+	if assert.Len(t, passwordChanges, 1) {
+		assert.Empty(t, passwordChanges[0].Changes)
+		assert.Equal(t, uid, passwordChanges[0].TargetID)
+	}
+
+	// Admin actions name the admin and are written with the change they describe
+	rec = setAccountStatus(t, user.UserID, `{"status":"banned","reason":"Spam"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	statusChanges := getAuditLogs(t, "actor_type=admin&target_type=user&target_id="+uid)
+	if assert.Len(t, statusChanges, 1) {
+		assert.Equal(t, handlers.AuditAccountStatusChange, statusChanges[0].Action)
+		assert.Equal(t, "mod1", statusChanges[0].ActorName)
+		assert.Equal(t, "Spam", statusChanges[0].Details)
+		assert.Contains(t, string(statusChanges[0].Changes), `"status":{"from":"active","to":"banned"}`)
+	}
+
+	// Filters and paging
+	assert.Len(t, getAuditLogs(t, "page_size=2"), 2)
+	assert.Empty(t, getAuditLogs(t, "since=2999-01-01T00:00:00Z"))
+	assert.NotEmpty(t, getAuditLogs(t, "until=2999-01-01T00:00:00Z"))
+	assert.Equal(t, http.StatusBadRequest, serveAdmin(t, handlers.GetAuditLogs, http.MethodGet, "/api/v1/admin/audit-logs?since=yesterday", "").Code)
+
+	// Nothing in this test updates or deletes entries
+	var total int64
+	assert.NoError(t, config.DB.Model(&models.AuditLog{}).Count(&total).Error)
+	assert.Len(t, getAuditLogs(t, "page_size=50"), int(total))
+}
+
+func TestAuditLog_Export(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	registeredUser(t)
+	for i := 0; i < 3; i++ {
+		login(t, "testuser", "wrong-password")
+	}
+
+	// Identifiers of unknown accounts are stored as they were typed: cut by characters, and never read as a formula.
+	// The export lists the newest entries first.
+	login(t, "=HYPERLINK(\"http://evil.example\")", "password")
+	login(t, strings.Repeat("é", 100), "password")
+
+	rec := serveAdmin(t, handlers.GetAuditLogs, http.MethodGet, "/api/v1/admin/audit-logs?format=csv&action="+handlers.AuditLoginFailed, "")
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+		records, err := csv.NewReader(strings.NewReader(rec.Body.String())).ReadAll()
+		if assert.NoError(t, err) && assert.Len(t, records, 6) {
+			assert.Equal(t, "audit_log_id", records[0][0])
+			assert.Equal(t, handlers.AuditLoginFailed, records[1][5])
+			assert.Equal(t, `'=HYPERLINK("http://evil.example")`, records[2][4])
+			assert.Equal(t, strings.Repeat("é", 64), records[1][4])
+		}
+	}
+
+	rec = serveAdmin(t, handlers.GetAuditLogs, http.MethodGet, "/api/v1/admin/audit-logs?format=jsonl", "")
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+		lines := 0
+		scanner := bufio.NewScanner(strings.NewReader(rec.Body.String()))
+		for scanner.Scan() {
+			var entry models.AuditLogResponse
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+			lines++
+		}
+		// The registration and the five failed logins
+		assert.Equal(t, 6, lines)
+	}
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/handlers"
+	"server/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listPostsAs(t *testing.T, tokenString string) []models.GetPublicPostsRequest {
+	rec, err := serveRestricted(handlers.GetPosts, http.MethodGet, "/api/v1/posts", "", tokenString)
+	posts := []models.GetPublicPostsRequest{}
+	if assert.NoError(t, err) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &posts))
+	}
+	return posts
+}
+
+func listCommentsAs(t *testing.T, postID uint, tokenString string) []models.GetCommentRequest {
+	pid := fmt.Sprint(postID)
+	rec, err := serveRestricted(handlers.GetComments, http.MethodGet, "/api/v1/comments/"+pid, "", tokenString, "pid", pid)
+	comments := []models.GetCommentRequest{}
+	if assert.NoError(t, err) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &comments))
+	}
+	return comments
+}
+
+func commentOn(t *testing.T, postID uint, message string, tokenString string) int {
+	commentJSON := `{"post_id":` + fmt.Sprint(postID) + `,"comment_msg":"` + message + `"}`
+	rec, err := serveRestricted(handlers.CreateComment, http.MethodPost, "/api/v1/restricted/comments", commentJSON, tokenString)
+	assert.NoError(t, err)
+	return rec.Code
+}
+
+// ----------- API Testing ----------- //
+func TestBlockUser(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	alice := createTestUserNamed(t, config.DB, "alice")
+	bob := createTestUserNamed(t, config.DB, "bob")
+	aliceToken := createJWTTokenTest(t, alice.UserID)
+	bobToken := createJWTTokenTest(t, bob.UserID)
+	bid := fmt.Sprint(bob.UserID)
+	aid := fmt.Sprint(alice.UserID)
+
+	alicePost := createPostViaAPI(t, aliceToken, "Alice here")
+	createPostViaAPI(t, bobToken, "Bob here")
+	_, err := serveRestricted(handlers.FollowUser, http.MethodPut, "/api/v1/restricted/follows/"+aid, "", bobToken, "uid", aid)
+	assert.NoError(t, err)
+
+	rec, err := serveRestricted(handlers.BlockUser, http.MethodPut, "/api/v1/restricted/blocks/"+bid, "", aliceToken, "uid", bid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	rec, err = serveRestricted(handlers.BlockUser, http.MethodPut, "/api/v1/restricted/blocks/"+aid, "", aliceToken, "uid", aid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// Blocking removes the follow and keeps Bob from following or commenting again
+	var follows int64
+	config.DB.Model(&models.Follow{}).Count(&follows)
+	assert.Equal(t, int64(0), follows)
+	rec, err = serveRestricted(handlers.FollowUser, http.MethodPut, "/api/v1/restricted/follows/"+aid, "", bobToken, "uid", aid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+	assert.Equal(t, http.StatusForbidden, commentOn(t, alicePost.PostID, "Let me in", bobToken))
+
+	// Alice no longer sees Bob, everyone else still does
+	if posts := listPostsAs(t, aliceToken); assert.Len(t, posts, 1) {
+		assert.Equal(t, "alice", posts[0].Username)
+	}
+	assert.Len(t, listPostsAs(t, bobToken), 2)
+	assert.Len(t, listVisiblePosts(t), 2)
+
+	rec, err = serveRestricted(handlers.GetBlocks, http.MethodGet, "/api/v1/restricted/blocks", "", aliceToken)
+	if assert.NoError(t, err) {
+		var blocked []models.UserRelationResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &blocked))
+		if assert.Len(t, blocked, 1) {
+			assert.Equal(t, "bob", blocked[0].Username)
+		}
+	}
+
+	// Unblocking restores everything but the follow
+	_, err = serveRestricted(handlers.UnblockUser, http.MethodDelete, "/api/v1/restricted/blocks/"+bid, "", aliceToken, "uid", bid)
+	assert.NoError(t, err)
+	assert.Len(t, listPostsAs(t, aliceToken), 2)
+	assert.Equal(t, http.StatusCreated, commentOn(t, alicePost.PostID, "Thanks", bobToken))
+}
+
+func TestMuteUser(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	alice := createTestUserNamed(t, config.DB, "alice")
+	carol := createTestUserNamed(t, config.DB, "carol")
+	aliceToken := createJWTTokenTest(t, alice.UserID)
+	carolToken := createJWTTokenTest(t, carol.UserID)
+	cid := fmt.Sprint(carol.UserID)
+
+	post := createPostViaAPI(t, aliceToken, "Alice here")
+	assert.Equal(t, http.StatusCreated, commentOn(t, post.PostID, "First", carolToken))
+	assert.Len(t, getNotifications(t, aliceToken, ""), 1)
+
+	rec, err := serveRestricted(handlers.MuteUser, http.MethodPut, "/api/v1/restricted/mutes/"+cid, "", aliceToken, "uid", cid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// Carol can still comment, but Alice sees neither the comments nor any notification, old or new
+	assert.Equal(t, http.StatusCreated, commentOn(t, post.PostID, "Hey @alice", carolToken))
+	assert.Empty(t, listCommentsAs(t, post.PostID, aliceToken))
+	assert.Len(t, listCommentsAs(t, post.PostID, carolToken), 2)
+	assert.Empty(t, getNotifications(t, aliceToken, ""))
+	assert.Equal(t, int64(0), getUnreadCount(t, aliceToken))
+
+	var stored int64
+	config.DB.Model(&models.Notification{}).Where("user_id = ?", alice.UserID).Count(&stored)
+	assert.Equal(t, int64(1), stored)
+
+	rec, err = serveRestricted(handlers.GetMutes, http.MethodGet, "/api/v1/restricted/mutes", "", aliceToken)
+	if assert.NoError(t, err) {
+		var muted []models.UserRelationResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &muted))
+		if assert.Len(t, muted, 1) {
+			assert.Equal(t, carol.UserID, muted[0].UserID)
+		}
+	}
+
+	_, err = serveRestricted(handlers.UnmuteUser, http.MethodDelete, "/api/v1/restricted/mutes/"+cid, "", aliceToken, "uid", cid)
+	assert.NoError(t, err)
+	assert.Len(t, listCommentsAs(t, post.PostID, aliceToken), 2)
+	assert.Len(t, getNotifications(t, aliceToken, ""), 1)
+}
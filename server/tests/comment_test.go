@@ -17,6 +17,7 @@ import (
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 // Requirement:
@@ -71,5 +72,81 @@ func TestCreateComment(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, "This is a test comment", createComment.CommentMSG)
 		assert.Equal(t, postMock.PostID, createComment.PostID)
+		assert.Equal(t, userMock.UserID, createComment.AuthorID)
 	}
 }
+
+// BenchmarkGetComments lists the 100 comments of a post among 20000, each with its author (go test -bench GetComments ./tests).
+// Besides the handler it times the listing query on its own, once reading comments.author_id and once through the
+// comment_users link table that author_id replaced, rebuilt from the same comments as create_database.sql defined it.
+func BenchmarkGetComments(b *testing.B) {
+	createTables()
+	defer teardown()
+
+	users := make([]models.User, 50)
+	for i := range users {
+		users[i] = models.User{Username: fmt.Sprint("user", i), Firstname: "Test", Surname: "User", Email: fmt.Sprint("user", i, "@example.com"), Password: "password"}
+	}
+	config.DB.Create(&users)
+	posts := make([]models.Post, 200)
+	for i := range posts {
+		posts[i] = models.Post{UserID: users[i%len(users)].UserID, Message: "This is a test post"}
+	}
+	config.DB.Create(&posts)
+	for _, post := range posts {
+		comments := make([]models.Comment, 100)
+		for i := range comments {
+			comments[i] = models.Comment{PostID: post.PostID, AuthorID: users[i%len(users)].UserID, CommentMSG: "This is a test comment"}
+		}
+		config.DB.Create(&comments)
+	}
+
+	defer config.DB.Exec("DROP TABLE IF EXISTS comment_users")
+	for _, statement := range []string{
+		"CREATE TABLE comment_users (comment_id INT NOT NULL, user_id INT NOT NULL, PRIMARY KEY (comment_id, user_id))",
+		"CREATE INDEX idx_comment_users_user_id ON comment_users (user_id)",
+		"INSERT INTO comment_users (comment_id, user_id) SELECT comment_id, author_id FROM comments",
+	} {
+		if err := config.DB.Exec(statement).Error; err != nil {
+			b.Fatalf("Failed to create comment_users: %v", err)
+		}
+	}
+
+	e := echo.New()
+	pid := fmt.Sprint(posts[len(posts)/2].PostID)
+	b.Run("handler", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/comments/"+pid, nil), rec)
+			c.SetParamNames("pid")
+			c.SetParamValues(pid)
+			if err := handlers.GetComments(c); err != nil || rec.Code != http.StatusOK {
+				b.Fatalf("GetComments failed: %v %d", err, rec.Code)
+			}
+		}
+	})
+
+	listComments := func(b *testing.B, query func() *gorm.DB) {
+		for i := 0; i < b.N; i++ {
+			var comments []models.GetCommentRequest
+			if err := query().Scan(&comments).Error; err != nil || len(comments) != 100 {
+				b.Fatalf("Listing the comments failed: %v %d", err, len(comments))
+			}
+		}
+	}
+	b.Run("query/author_id", func(b *testing.B) {
+		listComments(b, func() *gorm.DB {
+			return config.DB.Table("comments").Select("comments.comment_id, users.username, comments.comment_msg").
+				Joins("inner join users on users.user_id = comments.author_id").
+				Where("comments.post_id = ? AND comments.hidden_at IS NULL", pid).Order("comments.comment_id")
+		})
+	})
+	b.Run("query/comment_users", func(b *testing.B) {
+		listComments(b, func() *gorm.DB {
+			return config.DB.Table("comments").Select("comments.comment_id, users.username, comments.comment_msg").
+				Joins("inner join comment_users on comment_users.comment_id = comments.comment_id").
+				Joins("inner join users on users.user_id = comment_users.user_id").
+				Where("comments.post_id = ? AND comments.hidden_at IS NULL", pid).Order("comments.comment_id")
+		})
+	})
+}
@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/models"
+	"server/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func requestExport(t *testing.T, tokenString string) models.ExportJobResponse {
+	rec, err := serveRestricted(handlers.RequestExport, http.MethodPost, "/api/v1/restricted/me/export", "", tokenString)
+	var job models.ExportJobResponse
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusAccepted, rec.Code) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	}
+	return job
+}
+
+func getExport(t *testing.T, jobID uint, tokenString string) (*httptest.ResponseRecorder, models.ExportJobResponse) {
+	jid := fmt.Sprint(jobID)
+	rec, err := serveRestricted(handlers.GetExport, http.MethodGet, "/api/v1/restricted/me/export/"+jid, "", tokenString, "jid", jid)
+	var job models.ExportJobResponse
+	if assert.NoError(t, err) && rec.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	}
+	return rec, job
+}
+
+// poll the export until the background job has finished
+func waitForExport(t *testing.T, jobID uint, tokenString string) models.ExportJobResponse {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, job := getExport(t, jobID, tokenString)
+		if job.Status == handlers.ExportReady || job.Status == handlers.ExportFailed || time.Now().After(deadline) {
+			return job
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// download an export through its signed URL
+func downloadExport(t *testing.T, rawURL string) *httptest.ResponseRecorder {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Invalid export URL: %v", err)
+	}
+
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	req := httptest.NewRequest(http.MethodGet, parsed.RequestURI(), nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("jid")
+	c.SetParamValues(strings.TrimPrefix(parsed.Path, "/api/v1/exports/"))
+
+	assert.NoError(t, handlers.DownloadExport(c))
+	return rec
+}
+
+func readExportFile(t *testing.T, archive *zip.Reader, name string, target interface{}) {
+	file, err := archive.Open(name)
+	if !assert.NoError(t, err, name) {
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, target), name)
+}
+
+// ----------- API Testing ----------- //
+func TestPersonalDataExport(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	user, tokenString := registeredUser(t)
+	other := createTestUserNamed(t, config.DB, "exportfriend")
+	otherToken := createJWTTokenTest(t, other.UserID)
+
+	post := createPostViaAPI(t, tokenString, "My exported post")
+	otherPost := createPostViaAPI(t, otherToken, "Someone else's post")
+	assert.Equal(t, http.StatusCreated, commentOn(t, otherPost.PostID, "My exported comment", tokenString))
+	assert.Equal(t, http.StatusCreated, commentOn(t, post.PostID, "Not my comment", otherToken))
+	_, err := reactToPost(t, http.MethodPut, otherPost.PostID, "like", tokenString)
+	assert.NoError(t, err)
+	assert.NoError(t, config.DB.Create(&models.Follow{FollowerID: user.UserID, FolloweeID: other.UserID}).Error)
+	assert.NoError(t, config.DB.Create(&models.Follow{FollowerID: other.UserID, FolloweeID: user.UserID}).Error)
+
+	job := requestExport(t, tokenString)
+	assert.NotZero(t, job.ExportJobID)
+	assert.Empty(t, job.DownloadURL)
+
+	// Other users cannot see the job
+	rec, _ := getExport(t, job.ExportJobID, otherToken)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	job = waitForExport(t, job.ExportJobID, tokenString)
+	if !assert.Equal(t, handlers.ExportReady, job.Status) {
+		return
+	}
+	assert.NotZero(t, job.Size)
+	assert.NotNil(t, job.ExpiresAt)
+	assert.NotEmpty(t, job.DownloadURL)
+
+	rec = downloadExport(t, job.DownloadURL)
+	if !assert.Equal(t, http.StatusOK, rec.Code) {
+		return
+	}
+	assert.Equal(t, "application/zip", rec.Header().Get(echo.HeaderContentType))
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var profile models.ExportProfile
+	readExportFile(t, archive, "profile.json", &profile)
+	assert.Equal(t, user.UserID, profile.UserID)
+	assert.Equal(t, user.Email, profile.Email)
+
+	var posts []models.Post
+	readExportFile(t, archive, "posts.json", &posts)
+	if assert.Len(t, posts, 1) {
+		assert.Equal(t, "My exported post", posts[0].Message)
+	}
+
+	var comments []models.Comment
+	readExportFile(t, archive, "comments.json", &comments)
+	if assert.Len(t, comments, 1) {
+		assert.Equal(t, "My exported comment", comments[0].CommentMSG)
+	}
+
+	var reactions []models.ExportReaction
+	readExportFile(t, archive, "reactions.json", &reactions)
+	if assert.Len(t, reactions, 1) {
+		assert.Equal(t, "post", reactions[0].TargetType)
+		assert.Equal(t, otherPost.PostID, reactions[0].TargetID)
+		assert.Equal(t, "like", reactions[0].ReactionType)
+	}
+
+	var follows models.ExportFollows
+	readExportFile(t, archive, "follows.json", &follows)
+	if assert.Len(t, follows.Following, 1) && assert.Len(t, follows.Followers, 1) {
+		assert.Equal(t, "exportfriend", follows.Following[0].Username)
+		assert.Equal(t, "exportfriend", follows.Followers[0].Username)
+	}
+
+	// A tampered signature is refused, unknown exports answer the same
+	assert.Equal(t, http.StatusForbidden, downloadExport(t, strings.Replace(job.DownloadURL, "signature=", "signature=0", 1)).Code)
+	unknown := strings.Replace(job.DownloadURL, fmt.Sprintf("/exports/%d?", job.ExportJobID), fmt.Sprintf("/exports/%d?", job.ExportJobID+100), 1)
+	assert.Equal(t, http.StatusForbidden, downloadExport(t, unknown).Code)
+	assert.Equal(t, http.StatusForbidden, downloadExport(t, fmt.Sprintf("/api/v1/exports/%d?expires=1&signature=x", job.ExportJobID)).Code)
+}
+
+func TestPersonalDataExport_Expiry(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	_, tokenString := registeredUser(t)
+	job := waitForExport(t, requestExport(t, tokenString).ExportJobID, tokenString)
+	if !assert.Equal(t, handlers.ExportReady, job.Status) {
+		return
+	}
+	var stored models.ExportJob
+	assert.NoError(t, config.DB.First(&stored, job.ExportJobID).Error)
+	key := stored.StorageKey
+
+	// Past its expiry the job reports expired and the link stops working, even before the cleanup runs
+	assert.NoError(t, config.DB.Model(&stored).Update("expires_at", time.Now().Add(-time.Minute)).Error)
+	_, expired := getExport(t, job.ExportJobID, tokenString)
+	assert.Equal(t, handlers.ExportExpired, expired.Status)
+	assert.Empty(t, expired.DownloadURL)
+	assert.Equal(t, http.StatusGone, downloadExport(t, job.DownloadURL).Code)
+
+	// The cleanup deletes the archive
+	assert.NoError(t, handlers.PurgeExpiredExports(config.DB))
+	assert.NoError(t, config.DB.First(&stored, job.ExportJobID).Error)
+	assert.Equal(t, handlers.ExportExpired, stored.Status)
+	assert.Empty(t, stored.StorageKey)
+	_, err := storage.Default.Open(key)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.Equal(t, http.StatusForbidden, downloadExport(t, job.DownloadURL).Code)
+
+	// A new export can be requested afterwards
+	next := requestExport(t, tokenString)
+	assert.NotEqual(t, job.ExportJobID, next.ExportJobID)
+	assert.Equal(t, handlers.ExportReady, waitForExport(t, next.ExportJobID, tokenString).Status)
+}
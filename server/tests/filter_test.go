@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/filter"
+	"server/handlers"
+	"server/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func postMessage(t *testing.T, tokenString string, message string) (*httptest.ResponseRecorder, models.Post) {
+	body, _ := json.Marshal(models.CreatePostRequest{Message: message})
+	rec, err := serveRestricted(handlers.CreatePost, http.MethodPost, "/api/v1/restricted/posts", string(body), tokenString)
+	assert.NoError(t, err)
+
+	var post models.Post
+	if rec.Code == http.StatusCreated || rec.Code == http.StatusAccepted {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &post))
+	}
+	return rec, post
+}
+
+// ----------- Unit Testing ----------- //
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, []string{"cafe", "spam"}, filter.Normalize("Café SP4M!"))
+	assert.Contains(t, filter.Normalize("buy b.a.d stuff"), "bad")
+	assert.Contains(t, filter.Normalize("so b a d"), "bad")
+}
+
+func TestBannedWords(t *testing.T) {
+	words := filter.NewBannedWords("banned_words", []string{"scam"}, filter.Reject)
+
+	for _, text := range []string{"What a SCAM", "what a sc4m", "s c a m alert", "ščam"} {
+		verdict, _, err := words.Check(filter.Content{Text: text})
+		assert.NoError(t, err)
+		assert.Equal(t, filter.Reject, verdict, text)
+	}
+	verdict, _, _ := words.Check(filter.Content{Text: "Scampi for dinner"})
+	assert.Equal(t, filter.Allow, verdict)
+}
+
+func TestLinkFilter(t *testing.T) {
+	blocked := filter.NewLinkFilter(filter.LinksBlock, nil)
+	verdict, _, _ := blocked.Check(filter.Content{Text: "see https://example.com"})
+	assert.Equal(t, filter.Reject, verdict)
+	verdict, _, _ = blocked.Check(filter.Content{Text: "no links here"})
+	assert.Equal(t, filter.Allow, verdict)
+
+	allowlist := filter.NewLinkFilter(filter.LinksAllowlist, []string{"golang.org"})
+	for text, expected := range map[string]filter.Verdict{
+		"docs at https://go.dev/doc":         filter.Reject,
+		"docs at https://pkg.golang.org/x":   filter.Allow,
+		"docs at www.golang.org":             filter.Allow,
+		"docs at https://golang.org.evil.io": filter.Reject,
+	} {
+		verdict, _, _ := allowlist.Check(filter.Content{Text: text})
+		assert.Equal(t, expected, verdict, text)
+	}
+}
+
+// ----------- API Testing ----------- //
+func TestContentFilter(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	spam := filter.NewSpamFilter(config.DB)
+	spam.NewAccountBurst = 2
+	filter.Default = filter.Pipeline{
+		filter.NewBannedWords("banned_words", []string{"scam"}, filter.Reject),
+		filter.NewBannedWords("review_words", []string{"crypto"}, filter.Hold),
+		spam,
+	}
+	defer func() { filter.Default = nil }()
+
+	user := createTestUser(t, config.DB)
+	tokenString := createJWTTokenTest(t, user.UserID)
+	friend := createTestUserNamed(t, config.DB, "friend")
+	friendToken := createJWTTokenTest(t, friend.UserID)
+
+	// Rejected content is not stored, only logged
+	rec, _ := postMessage(t, tokenString, "Total sc4m")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	// Held content is stored hidden and queued for the moderators
+	rec, held := postMessage(t, tokenString, "Free crypto for everyone @friend")
+	if assert.Equal(t, http.StatusAccepted, rec.Code) {
+		assert.NotNil(t, held.HiddenAt)
+	}
+	assert.Empty(t, listVisiblePosts(t))
+
+	rec = serveAdmin(t, handlers.GetReports, http.MethodGet, "/api/v1/admin/reports?reason=filter", "")
+	var reports []models.ReportResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reports))
+	if assert.Len(t, reports, 1) {
+		assert.Nil(t, reports[0].ReporterID)
+		assert.Equal(t, held.PostID, reports[0].TargetID)
+		assert.Equal(t, "review_words: Contains a word that is not allowed", reports[0].Details)
+	}
+
+	rec = serveAdmin(t, handlers.GetFilterEvents, http.MethodGet, "/api/v1/admin/filter-events", "")
+	var events []models.FilterEventResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &events))
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "hold", events[0].Verdict)
+		assert.Equal(t, "reject", events[1].Verdict)
+		assert.Equal(t, "banned_words", events[1].Filter)
+		assert.Equal(t, "Total sc4m", events[1].Excerpt)
+		assert.Nil(t, events[1].TargetID)
+		assert.Equal(t, "testuser", events[1].Username)
+	}
+
+	assert.Empty(t, getNotifications(t, friendToken, ""))
+
+	// Approving the held post from the queue publishes it, and announces it like a new post
+	rec = actOnReport(t, reports[0].ReportID, `{"action":"unhide","reason":"Fine"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, listVisiblePosts(t), 1)
+	notifications := getNotifications(t, friendToken, "")
+	if assert.Len(t, notifications, 1) {
+		assert.Equal(t, handlers.NotificationMention, notifications[0].Type)
+	}
+	assert.Len(t, searchFor(t, "crypto", "post"), 1)
+
+	// The same for a held comment, the author of the post hears about the reply once it is approved
+	commentJSON := `{"post_id":` + fmt.Sprint(held.PostID) + `,"comment_msg":"More crypto please"}`
+	rec, err := serveRestricted(handlers.CreateComment, http.MethodPost, "/api/v1/restricted/comments", commentJSON, friendToken)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, getNotifications(t, tokenString, ""))
+
+	rec = serveAdmin(t, handlers.GetReports, http.MethodGet, "/api/v1/admin/reports?reason=filter", "")
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reports))
+	if assert.Len(t, reports, 1) {
+		rec = actOnReport(t, reports[0].ReportID, `{"action":"unhide","reason":"Fine"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	notifications = getNotifications(t, tokenString, "")
+	if assert.Len(t, notifications, 1) {
+		assert.Equal(t, handlers.NotificationReply, notifications[0].Type)
+	}
+	assert.Len(t, searchFor(t, "crypto", "comment"), 1)
+
+	// Posting the same text twice is rejected
+	rec, _ = postMessage(t, tokenString, "Hello world")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	rec, _ = postMessage(t, tokenString, "Hello world")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	// A new account has used its allowance of 2 posts within the burst window
+	rec, _ = postMessage(t, tokenString, "One more thing")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	// Edits are screened as well
+	post := createTestPost(t, config.DB, createTestUserNamed(t, config.DB, "other"))
+	otherToken := createJWTTokenTest(t, post.UserID)
+	rec, err = serveRestricted(handlers.UpdatePost, http.MethodPut, "/api/v1/restricted/posts/"+fmt.Sprint(post.PostID), `{"message":"what a scam"}`, otherToken, "pid", fmt.Sprint(post.PostID))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
@@ -0,0 +1,327 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/models"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type graphQLResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// serveGraphQL runs a GraphQL request, anonymously when tokenString is empty
+func serveGraphQL(t *testing.T, query string, variables map[string]interface{}, tokenString string) (int, graphQLResponse) {
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if tokenString != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokenString)
+	}
+	rec := httptest.NewRecorder()
+
+	jwtConfig := testJWTConfig()
+	jwtConfig.ContinueOnIgnoredError = true
+	jwtConfig.ErrorHandler = func(c echo.Context, err error) error {
+		return nil
+	}
+	assert.NoError(t, echojwt.WithConfig(jwtConfig)(handlers.GraphQL)(e.NewContext(req, rec)))
+	var response graphQLResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response), rec.Body.String())
+	return rec.Code, response
+}
+
+func createTestCommentAs(t *testing.T, db *gorm.DB, post *models.Post, user *models.User, message string) models.Comment {
+	comment := models.Comment{PostID: post.PostID, AuthorID: user.UserID, CommentMSG: message}
+	if err := db.Create(&comment).Error; err != nil {
+		t.Fatalf("Failed to create test comment: %v", err)
+	}
+	return comment
+}
+
+// queryCounter marks the context of the session countQueries counts on
+type queryCounter struct{}
+
+// countQueries counts the SQL queries the handlers run until the returned function is called, which returns the count.
+// The handlers get a session of config.DB that carries the counter, the background job workers keep their own
+// connection and are not counted.
+func countQueries(t *testing.T) func() int64 {
+	var queries int64
+	count := func(db *gorm.DB) {
+		if counter, ok := db.Statement.Context.Value(queryCounter{}).(*int64); ok {
+			atomic.AddInt64(counter, 1)
+		}
+	}
+	assert.NoError(t, config.DB.Callback().Query().After("gorm:query").Register("tests:count_queries", count))
+	assert.NoError(t, config.DB.Callback().Row().After("gorm:row").Register("tests:count_rows", count))
+	db := config.DB
+	config.DB = db.WithContext(context.WithValue(context.Background(), queryCounter{}, &queries))
+	return func() int64 {
+		config.DB = db
+		db.Callback().Query().Remove("tests:count_queries")
+		db.Callback().Row().Remove("tests:count_rows")
+		return atomic.LoadInt64(&queries)
+	}
+}
+
+// ----------- API Testing ----------- //
+func TestGraphQLQueryRelations(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	alice := createTestUserNamed(t, config.DB, "alice")
+	bob := createTestUserNamed(t, config.DB, "bob")
+	post := createTestPost(t, config.DB, alice)
+	createTestCommentAs(t, config.DB, post, bob, "First!")
+	createTestCommentAs(t, config.DB, post, alice, "Thanks")
+	bobToken := createJWTTokenTest(t, bob.UserID)
+	_, err := reactToPost(t, http.MethodPut, post.PostID, "like", bobToken)
+	assert.NoError(t, err)
+
+	query := `query($pid: Int!) {
+		viewer { username }
+		post(id: $pid) {
+			message
+			author { username posts { id } }
+			comments { message author { username } post { id } }
+			commentCount
+			reactions { type count }
+			myReactions
+		}
+	}`
+	code, response := serveGraphQL(t, query, map[string]interface{}{"pid": post.PostID}, bobToken)
+	if !assert.Equal(t, http.StatusOK, code) || !assert.Empty(t, response.Errors) {
+		return
+	}
+	assert.Equal(t, map[string]interface{}{"username": "bob"}, response.Data["viewer"])
+	got := response.Data["post"].(map[string]interface{})
+	assert.Equal(t, "This is a test post", got["message"])
+	assert.Equal(t, "alice", got["author"].(map[string]interface{})["username"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"id": float64(post.PostID)}}, got["author"].(map[string]interface{})["posts"])
+	comments := got["comments"].([]interface{})
+	if assert.Len(t, comments, 2) {
+		assert.Equal(t, "First!", comments[0].(map[string]interface{})["message"])
+		assert.Equal(t, "bob", comments[0].(map[string]interface{})["author"].(map[string]interface{})["username"])
+		assert.Equal(t, "alice", comments[1].(map[string]interface{})["author"].(map[string]interface{})["username"])
+	}
+	assert.Equal(t, float64(2), got["commentCount"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"type": "like", "count": float64(1)}}, got["reactions"])
+	assert.Equal(t, []interface{}{"like"}, got["myReactions"])
+
+	// Anonymous viewers only get public posts, and never the email of a user
+	assert.NoError(t, config.DB.Model(post).Update("visibility", handlers.VisibilityPrivate).Error)
+	code, response = serveGraphQL(t, `{ viewer { uid } post(id: `+fmt.Sprint(post.PostID)+`) { id } user(username: "alice") { uid posts { id } } }`, nil, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+	assert.Nil(t, response.Data["viewer"])
+	assert.Nil(t, response.Data["post"])
+	assert.Equal(t, map[string]interface{}{"uid": float64(alice.UserID), "posts": []interface{}{}}, response.Data["user"])
+	code, response = serveGraphQL(t, `{ user(username: "alice") { email } }`, nil, "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.NotEmpty(t, response.Errors)
+}
+
+func TestGraphQLBatchesQueries(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	query := `{
+		posts(pageSize: 20) {
+			author { username posts(first: 2) { id } }
+			comments(first: 10) { author { username } }
+			commentCount
+			reactions { type count }
+			myReactions
+		}
+	}`
+	users := []*models.User{}
+	addUsers := func(count int) {
+		for i := 0; i < count; i++ {
+			user := createTestUserNamed(t, config.DB, fmt.Sprintf("user%d", len(users)))
+			users = append(users, user)
+			for j := 0; j < 2; j++ {
+				post := createTestPost(t, config.DB, user)
+				for _, commenter := range users {
+					createTestCommentAs(t, config.DB, post, commenter, "Nice")
+				}
+			}
+		}
+	}
+	run := func() (int64, int) {
+		stop := countQueries(t)
+		code, response := serveGraphQL(t, query, nil, createJWTTokenTest(t, users[0].UserID))
+		queries := stop()
+		assert.Equal(t, http.StatusOK, code)
+		assert.Empty(t, response.Errors)
+		posts, _ := response.Data["posts"].([]interface{})
+		return queries, len(posts)
+	}
+
+	addUsers(2)
+	few, count := run()
+	assert.Equal(t, 4, count)
+	addUsers(8)
+	many, count := run()
+	assert.Equal(t, 20, count)
+	// The number of queries depends on the shape of the query, not on the number of posts
+	assert.Equal(t, few, many)
+	assert.Greater(t, many, int64(5))
+	assert.LessOrEqual(t, many, int64(12))
+}
+
+func TestGraphQLMutations(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	alice := createTestUserNamed(t, config.DB, "alice")
+	aliceToken := createJWTTokenTest(t, alice.UserID)
+	bob := createTestUserNamed(t, config.DB, "bob")
+	bobToken := createJWTTokenTest(t, bob.UserID)
+
+	createPost := `mutation($message: String!, $visibility: String) {
+		createPost(message: $message, visibility: $visibility) { id message visibility author { username } }
+	}`
+	code, response := serveGraphQL(t, createPost, map[string]interface{}{"message": "Hello #graphql"}, "")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, "Unauthorized", response.Errors[0].Message)
+		assert.Equal(t, float64(http.StatusUnauthorized), response.Errors[0].Extensions["status"])
+	}
+
+	// The REST validation applies
+	_, response = serveGraphQL(t, createPost, map[string]interface{}{"message": ""}, aliceToken)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, float64(http.StatusBadRequest), response.Errors[0].Extensions["status"])
+		assert.Equal(t, "BAD_REQUEST", response.Errors[0].Extensions["code"])
+	}
+	_, response = serveGraphQL(t, createPost, map[string]interface{}{"message": "Hi", "visibility": "everyone"}, aliceToken)
+	assert.Len(t, response.Errors, 1)
+
+	_, response = serveGraphQL(t, createPost, map[string]interface{}{"message": "Hello #graphql", "visibility": "followers"}, aliceToken)
+	if !assert.Empty(t, response.Errors) {
+		return
+	}
+	created := response.Data["createPost"].(map[string]interface{})
+	assert.Equal(t, "followers", created["visibility"])
+	assert.Equal(t, "alice", created["author"].(map[string]interface{})["username"])
+	postID := created["id"]
+	var tags int64
+	config.DB.Table("post_hashtags").Where("post_id = ?", postID).Count(&tags)
+	assert.Equal(t, int64(1), tags)
+
+	// publishAt schedules the post like publish_at on the REST route
+	schedule := `mutation($at: DateTime) { createPost(message: "Later", publishAt: $at) { publishAt } }`
+	publishAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	_, response = serveGraphQL(t, schedule, map[string]interface{}{"at": publishAt.Format(time.RFC3339)}, aliceToken)
+	if assert.Empty(t, response.Errors) {
+		scheduled, _ := time.Parse(time.RFC3339, response.Data["createPost"].(map[string]interface{})["publishAt"].(string))
+		assert.True(t, publishAt.Equal(scheduled))
+	}
+	_, response = serveGraphQL(t, schedule, map[string]interface{}{"at": time.Now().Add(-time.Hour).Format(time.RFC3339)}, aliceToken)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, "publish_at must be in the future", response.Errors[0].Message)
+	}
+
+	updatePost := `mutation($id: Int!) { updatePost(id: $id, message: "Edited", visibility: "public") { message visibility } }`
+	_, response = serveGraphQL(t, updatePost, map[string]interface{}{"id": postID}, bobToken)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, "FORBIDDEN", response.Errors[0].Extensions["code"])
+	}
+	_, response = serveGraphQL(t, updatePost, map[string]interface{}{"id": postID}, aliceToken)
+	assert.Empty(t, response.Errors)
+	assert.Equal(t, map[string]interface{}{"message": "Edited", "visibility": "public"}, response.Data["updatePost"])
+
+	createComment := `mutation($pid: Int!, $message: String!) { createComment(postId: $pid, message: $message) { id message author { username } post { id } } }`
+	_, response = serveGraphQL(t, createComment, map[string]interface{}{"pid": postID, "message": "Nice post"}, bobToken)
+	if !assert.Empty(t, response.Errors) {
+		return
+	}
+	comment := response.Data["createComment"].(map[string]interface{})
+	assert.Equal(t, "bob", comment["author"].(map[string]interface{})["username"])
+	assert.Equal(t, postID, comment["post"].(map[string]interface{})["id"])
+	_, response = serveGraphQL(t, createComment, map[string]interface{}{"pid": 9999, "message": "Nice post"}, bobToken)
+	assert.Len(t, response.Errors, 1)
+
+	updateComment := `mutation($id: Int!) { updateComment(id: $id, message: "Very nice post") { message } }`
+	_, response = serveGraphQL(t, updateComment, map[string]interface{}{"id": comment["id"]}, aliceToken)
+	assert.Len(t, response.Errors, 1)
+	_, response = serveGraphQL(t, updateComment, map[string]interface{}{"id": comment["id"]}, bobToken)
+	assert.Empty(t, response.Errors)
+	assert.Equal(t, map[string]interface{}{"message": "Very nice post"}, response.Data["updateComment"])
+
+	_, response = serveGraphQL(t, `mutation { updateProfile(bio: "GraphQL fan", website: "not a url") { bio } }`, nil, aliceToken)
+	assert.Len(t, response.Errors, 1)
+	_, response = serveGraphQL(t, `mutation { updateProfile(displayName: "Alice", bio: "GraphQL fan") { displayName bio } }`, nil, aliceToken)
+	assert.Empty(t, response.Errors)
+	assert.Equal(t, map[string]interface{}{"displayName": "Alice", "bio": "GraphQL fan"}, response.Data["updateProfile"])
+
+	// Suspended accounts cannot use their tokens, like on the REST routes
+	assert.NoError(t, config.DB.Model(bob).Update("status", handlers.AccountBanned).Error)
+	_, response = serveGraphQL(t, createPost, map[string]interface{}{"message": "Still here"}, bobToken)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Equal(t, float64(http.StatusForbidden), response.Errors[0].Extensions["status"])
+	}
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	alice := createTestUserNamed(t, config.DB, "alice")
+	createTestPost(t, config.DB, alice)
+
+	deep := `{ posts { author { posts { author { posts { author { posts { author { uid } } } } } } } } }`
+	code, response := serveGraphQL(t, deep, nil, "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Contains(t, response.Errors[0].Message, "nested 9 levels deep")
+	}
+
+	// Fragments count like the fields they stand for
+	fragments := `{ posts { ...author } } fragment author on Post { author { posts { author { posts { author { posts { author { uid } } } } } } } }`
+	code, _ = serveGraphQL(t, fragments, nil, "")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	complex := `query($size: Int) { posts(pageSize: $size) { comments(first: 50) { author { posts(first: 50) { id } } } } }`
+	code, response = serveGraphQL(t, complex, map[string]interface{}{"size": 50}, "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	if assert.Len(t, response.Errors, 1) {
+		assert.Contains(t, response.Errors[0].Message, "complexity")
+	}
+	code, response = serveGraphQL(t, complex, map[string]interface{}{"size": 1}, "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, response = serveGraphQL(t, `{ posts(pageSize: 2) { id comments(first: 5) { id author { uid } } } }`, nil, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+
+	_, response = serveGraphQL(t, `{ posts(pageSize: 500) { id } }`, nil, "")
+	if assert.Len(t, response.Errors, 1) {
+		assert.Contains(t, response.Errors[0].Message, "pageSize must be between 1 and 50")
+	}
+
+	// Introspection is not limited
+	code, response = serveGraphQL(t, `{ __schema { types { name fields { name type { name ofType { name ofType { name } } } } } } }`, nil, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Errors)
+}
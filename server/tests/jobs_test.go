@@ -0,0 +1,271 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/handlers"
+	"server/jobs"
+	"server/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testJobPayload struct {
+	Value string `json:"value"`
+}
+
+func getJob(t *testing.T, jobID uint) models.Job {
+	var job models.Job
+	assert.NoError(t, config.DB.First(&job, jobID).Error)
+	return job
+}
+
+func retryJob(t *testing.T, jobID uint) (int, models.JobResponse) {
+	jid := fmt.Sprint(jobID)
+	rec := serveAdmin(t, handlers.RetryJob, http.MethodPost, "/api/v1/admin/jobs/"+jid+"/retry", "", "jid", jid)
+	var job models.JobResponse
+	if rec.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	}
+	return rec.Code, job
+}
+
+// make the jobs waiting for their next attempt due now
+func makeJobsDue(t *testing.T) {
+	assert.NoError(t, config.DB.Model(&models.Job{}).Where("status = ?", jobs.StatusPending).Update("run_at", time.Now().Add(-time.Second)).Error)
+}
+
+// ----------- Unit Testing ----------- //
+func TestJobQueue(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	ctx := context.Background()
+	queue := jobs.New(config.DB, jobs.Config{Workers: 2, BackoffBase: time.Minute})
+
+	var mu sync.Mutex
+	var done []string
+	queue.Register("test.ok", func(ctx context.Context, job models.Job) error {
+		var payload testJobPayload
+		if err := jobs.Decode(job, &payload); err != nil {
+			return err
+		}
+		mu.Lock()
+		done = append(done, payload.Value)
+		mu.Unlock()
+		return nil
+	}, jobs.Options{})
+	queue.Register("test.flaky", func(ctx context.Context, job models.Job) error {
+		if job.Attempts == 1 {
+			return errors.New("try again")
+		}
+		return nil
+	}, jobs.Options{})
+	queue.Register("test.broken", func(ctx context.Context, job models.Job) error {
+		panic("always broken")
+	}, jobs.Options{MaxAttempts: 2})
+	queue.Register("test.permanent", func(ctx context.Context, job models.Job) error {
+		return jobs.Permanent(errors.New("bad payload"))
+	}, jobs.Options{})
+
+	ok, err := queue.EnqueueAt(config.DB, "test.ok", testJobPayload{Value: "hello"}, time.Now())
+	assert.NoError(t, err)
+	flaky, _ := queue.EnqueueAt(config.DB, "test.flaky", nil, time.Now())
+	broken, _ := queue.EnqueueAt(config.DB, "test.broken", nil, time.Now())
+	permanent, _ := queue.EnqueueAt(config.DB, "test.permanent", nil, time.Now())
+	later, _ := queue.EnqueueAt(config.DB, "test.ok", testJobPayload{Value: "later"}, time.Now().Add(time.Hour))
+	assert.Equal(t, 2, broken.MaxAttempts)
+
+	ran, err := queue.RunDue(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, ran)
+	assert.Equal(t, []string{"hello"}, done)
+	assert.Equal(t, jobs.StatusSucceeded, getJob(t, ok.JobID).Status)
+	assert.Equal(t, jobs.StatusPending, getJob(t, later.JobID).Status)
+
+	// A failed attempt is retried after the backoff
+	retried := getJob(t, flaky.JobID)
+	assert.Equal(t, jobs.StatusPending, retried.Status)
+	assert.Equal(t, 1, retried.Attempts)
+	assert.Equal(t, "try again", retried.LastError)
+	assert.True(t, retried.RunAt.After(time.Now().Add(50*time.Second)))
+	assert.Equal(t, "panic: always broken", getJob(t, broken.JobID).LastError)
+
+	// A permanent failure is dead right away
+	dead := getJob(t, permanent.JobID)
+	assert.Equal(t, jobs.StatusDead, dead.Status)
+	assert.Equal(t, 1, dead.Attempts)
+
+	// Out of attempts the job is dead
+	assert.NoError(t, config.DB.Model(&models.Job{}).Where("job_id IN ?", []uint{flaky.JobID, broken.JobID}).Update("run_at", time.Now().Add(-time.Second)).Error)
+	ran, err = queue.RunDue(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ran)
+	assert.Equal(t, jobs.StatusSucceeded, getJob(t, flaky.JobID).Status)
+	assert.Equal(t, jobs.StatusDead, getJob(t, broken.JobID).Status)
+	assert.Equal(t, 2, getJob(t, broken.JobID).Attempts)
+
+	// A running job whose lock expired was abandoned by its worker and runs again
+	abandoned := models.Job{Type: "test.ok", Payload: `{"value":"abandoned"}`, Status: jobs.StatusRunning, Attempts: 1, MaxAttempts: 5, RunAt: time.Now()}
+	expired := time.Now().Add(-time.Minute)
+	abandoned.LockedUntil = &expired
+	assert.NoError(t, config.DB.Create(&abandoned).Error)
+	ran, err = queue.RunDue(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ran)
+	assert.Equal(t, []string{"hello", "abandoned"}, done)
+	assert.Equal(t, 2, getJob(t, abandoned.JobID).Attempts)
+}
+
+func TestJobQueue_Concurrency(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	queue := jobs.New(config.DB, jobs.Config{Workers: 4})
+	var mu sync.Mutex
+	current, highest := 0, 0
+	queue.Register("test.slow", func(ctx context.Context, job models.Job) error {
+		mu.Lock()
+		current++
+		if current > highest {
+			highest = current
+		}
+		mu.Unlock()
+		time.Sleep(30 * time.Millisecond)
+		mu.Lock()
+		current--
+		mu.Unlock()
+		return nil
+	}, jobs.Options{Concurrency: 2})
+
+	for i := 0; i < 5; i++ {
+		_, err := queue.EnqueueAt(config.DB, "test.slow", nil, time.Now())
+		assert.NoError(t, err)
+	}
+
+	ran, err := queue.RunDue(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, ran)
+	assert.Equal(t, 2, highest)
+}
+
+func TestJobQueue_LockExtended(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	// The handler ignores its context and runs well past the lock it got when it was claimed
+	queue := jobs.New(config.DB, jobs.Config{Workers: 1})
+	other := jobs.New(config.DB, jobs.Config{Workers: 1})
+	var mu sync.Mutex
+	runs := 0
+	slow := func(ctx context.Context, job models.Job) error {
+		mu.Lock()
+		runs++
+		mu.Unlock()
+		time.Sleep(1800 * time.Millisecond)
+		return nil
+	}
+	queue.Register("test.long", slow, jobs.Options{Timeout: 100 * time.Millisecond})
+	other.Register("test.long", slow, jobs.Options{Timeout: 100 * time.Millisecond})
+
+	job, err := queue.EnqueueAt(config.DB, "test.long", nil, time.Now())
+	assert.NoError(t, err)
+
+	finished := make(chan struct{})
+	go func() {
+		queue.RunDue(context.Background())
+		close(finished)
+	}()
+
+	// Another worker looking for abandoned jobs after the first lock expired leaves it alone
+	time.Sleep(1400 * time.Millisecond)
+	running := getJob(t, job.JobID)
+	assert.Equal(t, jobs.StatusRunning, running.Status)
+	assert.True(t, running.LockedUntil.After(time.Now()))
+	ran, err := other.RunDue(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, ran)
+
+	<-finished
+	assert.Equal(t, 1, runs)
+	done := getJob(t, job.JobID)
+	assert.Equal(t, jobs.StatusSucceeded, done.Status)
+	assert.Equal(t, 1, done.Attempts)
+}
+
+func TestJobQueue_Purge(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	queue := jobs.New(config.DB, jobs.Config{Workers: 1, Retention: time.Hour})
+	old := time.Now().Add(-2 * time.Hour)
+	recent := time.Now().Add(-time.Minute)
+	oldSucceeded := models.Job{Type: "test.ok", Payload: "null", Status: jobs.StatusSucceeded, MaxAttempts: 5, RunAt: old, CompletedAt: &old}
+	recentSucceeded := models.Job{Type: "test.ok", Payload: "null", Status: jobs.StatusSucceeded, MaxAttempts: 5, RunAt: recent, CompletedAt: &recent}
+	oldDead := models.Job{Type: "test.ok", Payload: "null", Status: jobs.StatusDead, MaxAttempts: 5, RunAt: old, CompletedAt: &old}
+	pending := models.Job{Type: "test.ok", Payload: "null", Status: jobs.StatusPending, MaxAttempts: 5, RunAt: old}
+	for _, job := range []*models.Job{&oldSucceeded, &recentSucceeded, &oldDead, &pending} {
+		assert.NoError(t, config.DB.Create(job).Error)
+	}
+
+	// Only the succeeded jobs older than the retention go, dead jobs stay for the admins
+	purged, err := queue.Purge()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	var left []uint
+	assert.NoError(t, config.DB.Model(&models.Job{}).Order("job_id").Pluck("job_id", &left).Error)
+	assert.Equal(t, []uint{recentSucceeded.JobID, oldDead.JobID, pending.JobID}, left)
+}
+
+// ----------- API Testing ----------- //
+func TestJobAdmin(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	queue := jobs.New(config.DB, jobs.Config{Workers: 1})
+	queue.Register("test.broken", func(ctx context.Context, job models.Job) error {
+		return jobs.Permanent(errors.New("broken"))
+	}, jobs.Options{})
+	broken, _ := queue.EnqueueAt(config.DB, "test.broken", testJobPayload{Value: "x"}, time.Now())
+	waiting, _ := queue.EnqueueAt(config.DB, "test.broken", nil, time.Now().Add(time.Hour))
+	_, err := queue.RunDue(context.Background())
+	assert.NoError(t, err)
+
+	rec := serveAdmin(t, handlers.GetJobs, http.MethodGet, "/api/v1/admin/jobs?status=dead", "")
+	var listed []models.JobResponse
+	if assert.Equal(t, http.StatusOK, rec.Code) && assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed)) && assert.Len(t, listed, 1) {
+		assert.Equal(t, broken.JobID, listed[0].JobID)
+		assert.Equal(t, "broken", listed[0].LastError)
+		assert.JSONEq(t, `{"value":"x"}`, string(listed[0].Payload))
+	}
+	assert.Equal(t, http.StatusBadRequest, serveAdmin(t, handlers.GetJobs, http.MethodGet, "/api/v1/admin/jobs?status=lost", "").Code)
+
+	jid := fmt.Sprint(broken.JobID)
+	assert.Equal(t, http.StatusOK, serveAdmin(t, handlers.GetJob, http.MethodGet, "/api/v1/admin/jobs/"+jid, "", "jid", jid).Code)
+	assert.Equal(t, http.StatusNotFound, serveAdmin(t, handlers.GetJob, http.MethodGet, "/api/v1/admin/jobs/999", "", "jid", "999").Code)
+
+	// Only dead jobs can be retried, with a fresh set of attempts
+	code, job := retryJob(t, broken.JobID)
+	if assert.Equal(t, http.StatusOK, code) {
+		assert.Equal(t, jobs.StatusPending, job.Status)
+		assert.Zero(t, job.Attempts)
+	}
+	code, _ = retryJob(t, waiting.JobID)
+	assert.Equal(t, http.StatusConflict, code)
+	code, _ = retryJob(t, 999)
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Len(t, getAuditLogs(t, "action="+handlers.AuditJobRetry+"&target_id="+jid), 1)
+
+	makeJobsDue(t)
+	ran, err := queue.RunDue(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ran)
+	assert.Equal(t, jobs.StatusDead, getJob(t, broken.JobID).Status)
+}
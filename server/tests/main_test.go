@@ -1,10 +1,20 @@
 package tests
 
 import (
+	"context"
 	"fmt"
+	"net/http/httptest"
 	"os"
 	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/jobs"
 	"server/models"
+	"server/passwords"
+	"server/search"
+	"server/storage"
+	"server/tokens"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +23,8 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
 	gormSQL "gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -32,6 +44,39 @@ func createJWTTokenTest(t *testing.T, userID uint) string {
 	return tokenString
 }
 
+// run a handler behind the same JWT middleware as the restricted routes, params are name/value pairs
+func serveRestricted(handler echo.HandlerFunc, method string, path string, body string, tokenString string, params ...string) (*httptest.ResponseRecorder, error) {
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokenString)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	names, values := []string{}, []string{}
+	for i := 0; i+1 < len(params); i += 2 {
+		names = append(names, params[i])
+		values = append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+
+	jwtMiddleware := echojwt.WithConfig(testJWTConfig())
+
+	return rec, jwtMiddleware(handler)(c)
+}
+
+// testJWTConfig verifies the HS256 tokens of createJWTTokenTest
+func testJWTConfig() echojwt.Config {
+	return echojwt.Config{
+		NewClaimsFunc: func(c echo.Context) jwt.Claims {
+			return new(models.JWTClaims)
+		},
+		SigningKey: []byte("testing_mock"),
+	}
+}
+
 func RollbackFunc(model interface{}) {
 	config.DB.Begin()
 	defer config.DB.Rollback()
@@ -87,17 +132,104 @@ func createTables() {
 	if err != nil {
 		log.Fatalf("Failed to migrate Comment table: %v", err)
 	}
-	err = config.DB.AutoMigrate(&models.CommentUser{})
+	err = config.DB.AutoMigrate(&models.PostReaction{}, &models.CommentReaction{}, &models.PostReactionCount{}, &models.CommentReactionCount{})
+	if err != nil {
+		log.Fatalf("Failed to migrate Reaction tables: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.Hashtag{}, &models.PostHashtag{}, &models.CommentHashtag{}, &models.PostMention{}, &models.CommentMention{})
+	if err != nil {
+		log.Fatalf("Failed to migrate Hashtag and Mention tables: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.Follow{}, &models.Notification{}, &models.NotificationPreference{})
+	if err != nil {
+		log.Fatalf("Failed to migrate Follow and Notification tables: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.Attachment{})
+	if err != nil {
+		log.Fatalf("Failed to migrate Attachment table: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.Report{}, &models.ModerationAction{})
+	if err != nil {
+		log.Fatalf("Failed to migrate Report and ModerationAction tables: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.FilterEvent{})
+	if err != nil {
+		log.Fatalf("Failed to migrate FilterEvent table: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.Block{}, &models.Mute{})
 	if err != nil {
-		log.Fatalf("Failed to migrate CommentUser table: %v", err)
+		log.Fatalf("Failed to migrate Block and Mute tables: %v", err)
 	}
 
+	err = config.DB.AutoMigrate(&models.ExportJob{})
+	if err != nil {
+		log.Fatalf("Failed to migrate ExportJob table: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.AuditLog{})
+	if err != nil {
+		log.Fatalf("Failed to migrate AuditLog table: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.Job{})
+	if err != nil {
+		log.Fatalf("Failed to migrate Job table: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.Webhook{}, &models.WebhookDelivery{})
+	if err != nil {
+		log.Fatalf("Failed to migrate Webhook tables: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.APIKey{})
+	if err != nil {
+		log.Fatalf("Failed to migrate APIKey table: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.UserIdentity{}, &models.OIDCLoginState{})
+	if err != nil {
+		log.Fatalf("Failed to migrate UserIdentity and OIDCLoginState tables: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.SigningKey{})
+	if err != nil {
+		log.Fatalf("Failed to migrate SigningKey table: %v", err)
+	}
+
+	err = config.DB.AutoMigrate(&models.PasswordHistory{})
+	if err != nil {
+		log.Fatalf("Failed to migrate PasswordHistory table: %v", err)
+	}
+
+	// Rebuild the search index (or create the FULLTEXT indexes on MySQL) for the fresh tables
+	search.Init(config.DB)
+
 	fmt.Println("Tables migrated successfully")
 }
 
 func teardown() {
 	migrator := config.DB.Migrator()
-	migrator.DropTable(&models.CommentUser{})
+	migrator.DropTable(&models.PasswordHistory{})
+	migrator.DropTable(&models.SigningKey{})
+	migrator.DropTable(&models.OIDCLoginState{}, &models.UserIdentity{})
+	migrator.DropTable(&models.APIKey{})
+	migrator.DropTable(&models.WebhookDelivery{}, &models.Webhook{})
+	migrator.DropTable(&models.Job{})
+	migrator.DropTable(&models.AuditLog{})
+	migrator.DropTable(&models.ExportJob{})
+	migrator.DropTable(&models.Block{}, &models.Mute{})
+	migrator.DropTable(&models.FilterEvent{})
+	migrator.DropTable(&models.ModerationAction{}, &models.Report{})
+	migrator.DropTable(&models.Attachment{})
+	migrator.DropTable(&models.NotificationPreference{}, &models.Notification{}, &models.Follow{})
+	migrator.DropTable(&models.CommentMention{}, &models.PostMention{}, &models.CommentHashtag{}, &models.PostHashtag{}, &models.Hashtag{})
+	migrator.DropTable(&models.CommentReactionCount{}, &models.PostReactionCount{}, &models.CommentReaction{}, &models.PostReaction{})
 	migrator.DropTable(&models.Comment{})
 	migrator.DropTable(&models.Post{})
 	migrator.DropTable(&models.User{})
@@ -106,7 +238,36 @@ func teardown() {
 func TestMain(m *testing.M) {
 	config.DB = setupTestDB()
 	createTables()
+
+	// Attachments are written to a throwaway directory
+	uploads, err := os.MkdirTemp("", "uploads")
+	if err != nil {
+		log.Fatalf("Failed to create upload directory: %v", err)
+	}
+	storage.Default = storage.NewLocalStore(uploads)
+	storage.SigningKey = []byte("testing_mock")
+
+	// Login tokens are signed with a key created for the run, the tables are dropped between tests but the key stays loaded
+	tokens.Default, err = tokens.New(tokens.Config{})
+	if err != nil {
+		log.Fatalf("Failed to configure the JWT signing keys: %v", err)
+	}
+	if err := tokens.Default.Rotate(config.DB); err != nil {
+		log.Fatalf("Failed to create a JWT signing key: %v", err)
+	}
+
+	// Hashing with the production argon2 parameters would make every test that creates a user slow
+	passwords.Default.Argon2 = passwords.Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+	// Background jobs (such as exports) run while the tests poll for them
+	jobs.Default = jobs.New(config.DB, jobs.Config{Workers: 4, PollInterval: 50 * time.Millisecond})
+	handlers.RegisterJobs(jobs.Default, config.DB)
+	ctx, stop := context.WithCancel(context.Background())
+	jobs.Default.Start(ctx)
+
 	code := m.Run()
+	stop()
 	teardown()
+	os.RemoveAll(uploads)
 	os.Exit(code)
 }
@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/models"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// run an admin handler as the moderator "mod1", the basic auth middleware itself is not under test
+func serveAdmin(t *testing.T, handler echo.HandlerFunc, method string, path string, body string, params ...string) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.SetBasicAuth("mod1", "secret")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	names, values := []string{}, []string{}
+	for i := 0; i+1 < len(params); i += 2 {
+		names = append(names, params[i])
+		values = append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+
+	if err := handler(c); err != nil {
+		if httpError, ok := err.(*echo.HTTPError); ok {
+			rec.Code = httpError.Code
+			return rec
+		}
+		t.Fatalf("Admin handler failed: %v", err)
+	}
+	return rec
+}
+
+func report(t *testing.T, tokenString string, targetType string, targetID uint) (*httptest.ResponseRecorder, models.ReportResponse) {
+	body := fmt.Sprintf(`{"target_type":"%s","target_id":%d,"reason":"spam","details":"Buy now"}`, targetType, targetID)
+	rec, err := serveRestricted(handlers.CreateReport, http.MethodPost, "/api/v1/restricted/reports", body, tokenString)
+	assert.NoError(t, err)
+
+	var response models.ReportResponse
+	if rec.Code == http.StatusCreated {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	}
+	return rec, response
+}
+
+func actOnReport(t *testing.T, reportID uint, body string) *httptest.ResponseRecorder {
+	rid := fmt.Sprint(reportID)
+	return serveAdmin(t, handlers.ActOnReport, http.MethodPost, "/api/v1/admin/reports/"+rid+"/actions", body, "rid", rid)
+}
+
+func listVisiblePosts(t *testing.T) []models.GetPublicPostsRequest {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/posts", nil)
+	rec := httptest.NewRecorder()
+	var posts []models.GetPublicPostsRequest
+	if assert.NoError(t, handlers.GetPosts(e.NewContext(req, rec))) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &posts))
+	}
+	return posts
+}
+
+func searchFor(t *testing.T, text string, docType string) []models.SearchResult {
+	rec, err := searchRequest(t, "q="+text+"&type="+docType)
+	var response models.SearchResponse
+	if assert.NoError(t, err) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	}
+	return response.Results
+}
+
+// ----------- API Testing ----------- //
+func TestCreateReport(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	author := createTestUser(t, config.DB)
+	reporter := createTestUserNamed(t, config.DB, "reporter")
+	reporterToken := createJWTTokenTest(t, reporter.UserID)
+	post := createTestPost(t, config.DB, author)
+
+	rec, created := report(t, reporterToken, "post", post.PostID)
+	if assert.Equal(t, http.StatusCreated, rec.Code) {
+		assert.Equal(t, "open", created.Status)
+		assert.Equal(t, "reporter", created.ReporterUsername)
+		assert.Equal(t, post.Message, created.TargetPreview)
+	}
+
+	rec, _ = report(t, reporterToken, "post", post.PostID)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec, _ = report(t, reporterToken, "user", reporter.UserID)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec, _ = report(t, reporterToken, "comment", 9999)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	_, err := serveRestricted(handlers.CreateReport, http.MethodPost, "/api/v1/restricted/reports", `{"target_type":"post","target_id":1,"reason":"boring"}`, reporterToken)
+	assert.Error(t, err)
+}
+
+func TestModerationQueue(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	author := createTestUser(t, config.DB)
+	reporter := createTestUserNamed(t, config.DB, "reporter")
+	other := createTestUserNamed(t, config.DB, "other")
+	authorToken := createJWTTokenTest(t, author.UserID)
+	post := createPostViaAPI(t, authorToken, "Cheap #pills here")
+	commentOn(t, post.PostID, "Buy pills now", createJWTTokenTest(t, other.UserID))
+
+	_, first := report(t, createJWTTokenTest(t, reporter.UserID), "post", post.PostID)
+	_, second := report(t, createJWTTokenTest(t, other.UserID), "post", post.PostID)
+
+	// Queue, oldest first
+	rec := serveAdmin(t, handlers.GetReports, http.MethodGet, "/api/v1/admin/reports", "")
+	var reports []models.ReportResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reports))
+	if assert.Len(t, reports, 2) {
+		assert.Equal(t, first.ReportID, reports[0].ReportID)
+	}
+
+	// Assignment and filtering
+	rid := fmt.Sprint(first.ReportID)
+	rec = serveAdmin(t, handlers.AssignReport, http.MethodPut, "/api/v1/admin/reports/"+rid+"/assign", `{"assigned_to":"mod1"}`, "rid", rid)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = serveAdmin(t, handlers.GetReports, http.MethodGet, "/api/v1/admin/reports?assigned_to=mod1", "")
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reports))
+	assert.Len(t, reports, 1)
+	rec = serveAdmin(t, handlers.GetReports, http.MethodGet, "/api/v1/admin/reports?assigned_to=-", "")
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reports))
+	if assert.Len(t, reports, 1) {
+		assert.Equal(t, second.ReportID, reports[0].ReportID)
+	}
+
+	// Hiding closes every open report on the post and removes it from the feed, tags and search
+	rec = actOnReport(t, first.ReportID, `{"action":"hide","reason":"Spam"}`)
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		var closed models.ReportResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &closed))
+		assert.Equal(t, "resolved", closed.Status)
+		assert.NotNil(t, closed.ResolvedAt)
+	}
+	rec = serveAdmin(t, handlers.GetReports, http.MethodGet, "/api/v1/admin/reports", "")
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reports))
+	assert.Empty(t, reports)
+	assert.Empty(t, listVisiblePosts(t))
+	assert.Empty(t, searchFor(t, "pills", "post"))
+	assert.Empty(t, searchFor(t, "pills", "comment"))
+
+	var action models.ModerationAction
+	config.DB.Where("report_id = ?", first.ReportID).First(&action)
+	assert.Equal(t, "mod1", action.Moderator)
+	assert.Equal(t, "Spam", action.Reason)
+
+	rec = actOnReport(t, second.ReportID, `{"action":"dismiss","reason":"Already handled"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// A new report can bring it back
+	_, third := report(t, createJWTTokenTest(t, reporter.UserID), "post", post.PostID)
+	rec = actOnReport(t, third.ReportID, `{"action":"unhide","reason":"Appeal accepted"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, listVisiblePosts(t), 1)
+	assert.Len(t, searchFor(t, "pills", "post"), 1)
+	assert.Len(t, searchFor(t, "pills", "comment"), 1)
+}
+
+func TestModerationActionsOnUsers(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	GenerateNewUser(t)
+	var author models.User
+	config.DB.Where("username = ?", "testuser").First(&author)
+	reporter := createTestUserNamed(t, config.DB, "reporter")
+	reporterToken := createJWTTokenTest(t, reporter.UserID)
+	authorToken := createJWTTokenTest(t, author.UserID)
+	post := createTestPost(t, config.DB, &author)
+
+	// Warn the author of a comment
+	commentJSON := `{"post_id":` + fmt.Sprint(post.PostID) + `,"comment_msg":"Rude words"}`
+	rec, err := serveRestricted(handlers.CreateComment, http.MethodPost, "/api/v1/restricted/comments", commentJSON, authorToken)
+	assert.NoError(t, err)
+	var comment models.Comment
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &comment))
+
+	_, commentReport := report(t, reporterToken, "comment", comment.CommentID)
+	rec = actOnReport(t, commentReport.ReportID, `{"action":"warn","reason":"Be nice"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec, err = serveRestricted(handlers.GetWarnings, http.MethodGet, "/api/v1/restricted/warnings", "", authorToken)
+	if assert.NoError(t, err) {
+		var warnings []models.WarningResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &warnings))
+		if assert.Len(t, warnings, 1) {
+			assert.Equal(t, "Be nice", warnings[0].Reason)
+			assert.Equal(t, comment.CommentID, warnings[0].TargetID)
+		}
+	}
+
+	// Users cannot be hidden, and suspension needs a duration
+	_, userReport := report(t, reporterToken, "user", author.UserID)
+	assert.Equal(t, http.StatusBadRequest, actOnReport(t, userReport.ReportID, `{"action":"hide","reason":"No"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, actOnReport(t, userReport.ReportID, `{"action":"suspend","reason":"No"}`).Code)
+
+	rec = actOnReport(t, userReport.ReportID, `{"action":"suspend","reason":"Repeated abuse","suspend_hours":24}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(`{"identifier":"testuser","password":"password123"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	loginRec := httptest.NewRecorder()
+	if assert.NoError(t, handlers.LoggedInUser(e.NewContext(req, loginRec))) {
+		assert.Equal(t, http.StatusForbidden, loginRec.Code)
+		assert.Contains(t, loginRec.Body.String(), "suspended")
+	}
+
+	// Deleting removes the content for good, the comments on a post go with it and drop out of search too
+	assert.Len(t, searchFor(t, "rude", "comment"), 1)
+	_, postReport := report(t, reporterToken, "post", post.PostID)
+	rec = actOnReport(t, postReport.ReportID, `{"action":"delete","reason":"Illegal"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var remaining int64
+	config.DB.Model(&models.Post{}).Where("post_id = ?", post.PostID).Count(&remaining)
+	assert.Equal(t, int64(0), remaining)
+	assert.Empty(t, searchFor(t, "rude", "comment"))
+}
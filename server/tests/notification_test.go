@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/handlers"
+	"server/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getNotifications(t *testing.T, tokenString string, query string) []models.NotificationResponse {
+	rec, err := serveRestricted(handlers.GetNotifications, http.MethodGet, "/api/v1/restricted/notifications"+query, "", tokenString)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+
+	notifications := []models.NotificationResponse{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &notifications))
+	return notifications
+}
+
+func getUnreadCount(t *testing.T, tokenString string) int64 {
+	rec, err := serveRestricted(handlers.GetUnreadNotificationCount, http.MethodGet, "/api/v1/restricted/notifications/unread-count", "", tokenString)
+	if !assert.NoError(t, err) {
+		return -1
+	}
+
+	var count models.UnreadCountResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &count))
+	return count.Unread
+}
+
+// ----------- API Testing ----------- //
+func TestReplyAndMentionNotifications(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	author := createTestUser(t, config.DB)
+	replier := createTestUserNamed(t, config.DB, "replier")
+	authorToken := createJWTTokenTest(t, author.UserID)
+	replierToken := createJWTTokenTest(t, replier.UserID)
+
+	post := createPostViaAPI(t, authorToken, "Hello @replier")
+
+	commentJSON := `{"post_id":` + fmt.Sprint(post.PostID) + `,"comment_msg":"Hi @testuser"}`
+	_, err := serveRestricted(handlers.CreateComment, http.MethodPost, "/api/v1/restricted/comments", commentJSON, replierToken)
+	assert.NoError(t, err)
+
+	// The author is told about the reply once even though it mentions them, the replier got a mention from the post
+	authorNotifications := getNotifications(t, authorToken, "")
+	if assert.Len(t, authorNotifications, 1) {
+		assert.Equal(t, handlers.NotificationReply, authorNotifications[0].Type)
+		assert.Equal(t, "replier", authorNotifications[0].ActorUsername)
+	}
+	replierNotifications := getNotifications(t, replierToken, "")
+	if assert.Len(t, replierNotifications, 1) {
+		assert.Equal(t, handlers.NotificationMention, replierNotifications[0].Type)
+		assert.Equal(t, post.PostID, *replierNotifications[0].PostID)
+	}
+
+	commentJSON = `{"post_id":` + fmt.Sprint(post.PostID) + `,"comment_msg":"One more thing"}`
+	_, err = serveRestricted(handlers.CreateComment, http.MethodPost, "/api/v1/restricted/comments", commentJSON, replierToken)
+	assert.NoError(t, err)
+	authorNotifications = getNotifications(t, authorToken, "")
+	if !assert.Len(t, authorNotifications, 2) {
+		return
+	}
+
+	// Mark one as read, then the rest
+	assert.Equal(t, int64(2), getUnreadCount(t, authorToken))
+	nid := fmt.Sprint(authorNotifications[0].NotificationID)
+	rec, err := serveRestricted(handlers.MarkNotificationRead, http.MethodPut, "/api/v1/restricted/notifications/"+nid+"/read", "", authorToken, "nid", nid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	assert.Equal(t, int64(1), getUnreadCount(t, authorToken))
+	assert.Len(t, getNotifications(t, authorToken, "?unread=true"), 1)
+
+	// Someone else's notification cannot be marked
+	rec, err = serveRestricted(handlers.MarkNotificationRead, http.MethodPut, "/api/v1/restricted/notifications/"+nid+"/read", "", replierToken, "nid", nid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	_, err = serveRestricted(handlers.MarkAllNotificationsRead, http.MethodPut, "/api/v1/restricted/notifications/read-all", "", authorToken)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), getUnreadCount(t, authorToken))
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	author := createTestUser(t, config.DB)
+	fan := createTestUserNamed(t, config.DB, "fan")
+	authorToken := createJWTTokenTest(t, author.UserID)
+	fanToken := createJWTTokenTest(t, fan.UserID)
+
+	rec, err := serveRestricted(handlers.UpdateNotificationPreferences, http.MethodPut, "/api/v1/restricted/notification-preferences", `{"reaction":false}`, authorToken)
+	if assert.NoError(t, err) {
+		var preferences map[string]bool
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &preferences))
+		assert.False(t, preferences["reaction"])
+		assert.True(t, preferences["follow"])
+	}
+
+	rec, err = serveRestricted(handlers.UpdateNotificationPreferences, http.MethodPut, "/api/v1/restricted/notification-preferences", `{"poke":true}`, authorToken)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// Reaction is muted, follow is not
+	post := createTestPost(t, config.DB, author)
+	pid := fmt.Sprint(post.PostID)
+	_, err = serveRestricted(handlers.AddPostReaction, http.MethodPut, "/api/v1/restricted/posts/"+pid+"/reactions/like", "", fanToken, "pid", pid, "type", "like")
+	assert.NoError(t, err)
+
+	uid := fmt.Sprint(author.UserID)
+	for i := 0; i < 2; i++ {
+		rec, err = serveRestricted(handlers.FollowUser, http.MethodPut, "/api/v1/restricted/follows/"+uid, "", fanToken, "uid", uid)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	}
+
+	notifications := getNotifications(t, authorToken, "")
+	if assert.Len(t, notifications, 1) {
+		assert.Equal(t, handlers.NotificationFollow, notifications[0].Type)
+	}
+}
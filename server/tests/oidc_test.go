@@ -0,0 +1,388 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"server/config"
+	"server/handlers"
+	"server/jwks"
+	"server/models"
+	"server/oidc"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubIdP is a local OpenID Connect provider. Authorize stands in for the user logging in at the provider.
+type stubIdP struct {
+	*httptest.Server
+	key          *rsa.PrivateKey
+	clientID     string
+	clientSecret string
+
+	mu    sync.Mutex
+	codes map[string]stubAuthorization
+}
+
+type stubAuthorization struct {
+	challenge   string
+	redirectURI string
+	claims      jwt.MapClaims
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	idp := &stubIdP{key: key, clientID: "social-feed", clientSecret: "client-secret", codes: map[string]stubAuthorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		published, _ := jwks.New("stub-key", "RS256", &key.PublicKey)
+		json.NewEncoder(w).Encode(jwks.Set{Keys: []jwks.Key{published}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		idp.mu.Lock()
+		authorization, ok := idp.codes[r.PostFormValue("code")]
+		delete(idp.codes, r.PostFormValue("code"))
+		idp.mu.Unlock()
+
+		switch {
+		case id != idp.clientID || secret != idp.clientSecret:
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		case !ok || r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != authorization.redirectURI:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		case oidc.CodeChallenge(r.PostFormValue("code_verifier")) != authorization.challenge:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, authorization.claims)
+		token.Header["kid"] = "stub-key"
+		idToken, _ := token.SignedString(idp.key)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "token_type": "Bearer", "id_token": idToken})
+	})
+	idp.Server = httptest.NewServer(mux)
+
+	oidc.Register(oidc.Config{
+		Name:         "stub",
+		Issuer:       idp.URL,
+		ClientID:     idp.clientID,
+		ClientSecret: idp.clientSecret,
+		RedirectURL:  "http://localhost:1323/api/v1/auth/oidc/stub/callback",
+	})
+	return idp
+}
+
+// authorize checks the authorization request and returns the code the provider would redirect back with.
+// Claims are added to the standard ones of the ID token and may override them.
+func (idp *stubIdP) authorize(t *testing.T, query url.Values, claims jwt.MapClaims) string {
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, idp.clientID, query.Get("client_id"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.Contains(t, strings.Fields(query.Get("scope")), "openid")
+
+	idTokenClaims := jwt.MapClaims{
+		"iss":   idp.URL,
+		"aud":   idp.clientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		idTokenClaims[name] = value
+	}
+
+	code, _ := oidc.RandomString()
+	idp.mu.Lock()
+	idp.codes[code] = stubAuthorization{challenge: query.Get("code_challenge"), redirectURI: query.Get("redirect_uri"), claims: idTokenClaims}
+	idp.mu.Unlock()
+	return code
+}
+
+func serveOIDC(t *testing.T, handler echo.HandlerFunc, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("provider")
+	c.SetParamValues("stub")
+	if err := handler(c); err != nil {
+		t.Fatalf("OIDC handler failed: %v", err)
+	}
+	return rec
+}
+
+// startOIDCLogin starts a login and returns the authorization request sent to the provider with the state cookie
+func startOIDCLogin(t *testing.T) (url.Values, *http.Cookie) {
+	rec := serveOIDC(t, handlers.OIDCLogin, "/api/v1/auth/oidc/stub", nil)
+	if !assert.Equal(t, http.StatusFound, rec.Code) {
+		return url.Values{}, nil
+	}
+	location, err := url.Parse(rec.Header().Get(echo.HeaderLocation))
+	assert.NoError(t, err)
+	var cookie *http.Cookie
+	for _, set := range rec.Result().Cookies() {
+		if set.Name == "OIDCState" {
+			cookie = set
+		}
+	}
+	assert.NotNil(t, cookie)
+	return location.Query(), cookie
+}
+
+func finishOIDCLogin(t *testing.T, state string, code string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	query := url.Values{"state": {state}, "code": {code}}
+	return serveOIDC(t, handlers.OIDCCallback, "/api/v1/auth/oidc/stub/callback?"+query.Encode(), cookie)
+}
+
+// oidcLogin runs the whole flow for a user of the stub provider
+func oidcLogin(t *testing.T, idp *stubIdP, claims jwt.MapClaims) *httptest.ResponseRecorder {
+	query, cookie := startOIDCLogin(t)
+	return finishOIDCLogin(t, query.Get("state"), idp.authorize(t, query, claims), cookie)
+}
+
+// startOIDCLink starts linking the stub provider to the account of the token, like startOIDCLogin
+func startOIDCLink(t *testing.T, tokenString string, password string) (url.Values, *http.Cookie, error) {
+	rec, err := serveRestricted(handlers.LinkOIDCProvider, http.MethodPost, "/api/v1/restricted/auth/oidc/stub/link", fmt.Sprintf(`{"password":%q}`, password), tokenString, "provider", "stub")
+	if err != nil {
+		return nil, nil, err
+	}
+	var body models.OIDCRedirectResponse
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	location, err := url.Parse(body.RedirectURL)
+	assert.NoError(t, err)
+	var cookie *http.Cookie
+	for _, set := range rec.Result().Cookies() {
+		if set.Name == "OIDCState" {
+			cookie = set
+		}
+	}
+	assert.NotNil(t, cookie)
+	return location.Query(), cookie, nil
+}
+
+func identityUser(t *testing.T, subject string) models.User {
+	var identity models.UserIdentity
+	assert.NoError(t, config.DB.Preload("User").Where("provider = ? AND subject = ?", "stub", subject).First(&identity).Error)
+	return identity.User
+}
+
+// ----------- API Testing ----------- //
+func TestOIDCLogin(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	idp := newStubIdP(t)
+	defer idp.Close()
+
+	rec := servePublic(t, handlers.GetOIDCProviders, "/api/v1/auth/oidc")
+	assert.Contains(t, rec.Body.String(), `"stub"`)
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/nope", nil), httptest.NewRecorder())
+	c.SetParamNames("provider")
+	c.SetParamValues("nope")
+	assert.NoError(t, handlers.OIDCLogin(c))
+	assert.Equal(t, http.StatusNotFound, c.Response().Status)
+
+	// A new user gets an account named after their preferred username
+	rec = oidcLogin(t, idp, jwt.MapClaims{"sub": "alice-1", "email": "alice@idp.test", "email_verified": true, "preferred_username": "alice", "name": "Alice Liddell"})
+	var body map[string]string
+	if assert.Equal(t, http.StatusOK, rec.Code) && assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body)) {
+		assert.NotEmpty(t, body["token"])
+	}
+	alice := identityUser(t, "alice-1")
+	assert.Equal(t, "alice", alice.Username)
+	assert.Equal(t, "Alice", alice.Firstname)
+	assert.Equal(t, "Liddell", alice.Surname)
+	assert.Empty(t, alice.Password)
+	assert.Len(t, getAuditLogs(t, "action="+handlers.AuditUserRegister), 1)
+
+	// The same identity logs in to the same account
+	rec = oidcLogin(t, idp, jwt.MapClaims{"sub": "alice-1", "email": "alice@idp.test", "email_verified": true, "preferred_username": "alice"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var users int64
+	config.DB.Model(&models.User{}).Count(&users)
+	assert.EqualValues(t, 1, users)
+
+	// Usernames that are taken get a number
+	rec = oidcLogin(t, idp, jwt.MapClaims{"sub": "alice-2", "email": "other.alice@idp.test", "email_verified": "true", "preferred_username": "alice"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "alice2", identityUser(t, "alice-2").Username)
+
+	// An account created by a provider has a verified email, another identity with that email is linked to it
+	assert.NotNil(t, alice.EmailVerifiedAt)
+	rec = oidcLogin(t, idp, jwt.MapClaims{"sub": "alice-3", "email": "alice@idp.test", "email_verified": true})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, alice.UserID, identityUser(t, "alice-3").UserID)
+	rec = oidcLogin(t, idp, jwt.MapClaims{"sub": "nomail"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// An account registered with a password is never linked by its email, even a verified one
+	existing, tokenString := registeredUser(t)
+	assert.Nil(t, existing.EmailVerifiedAt)
+	rec = oidcLogin(t, idp, jwt.MapClaims{"sub": "bob-1", "email": existing.Email, "email_verified": false})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = oidcLogin(t, idp, jwt.MapClaims{"sub": "bob-1", "email": existing.Email, "email_verified": true})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	var identities int64
+	config.DB.Model(&models.UserIdentity{}).Where("user_id = ?", existing.UserID).Count(&identities)
+	assert.EqualValues(t, 0, identities)
+
+	// Its owner links the provider after confirming the password, then logs in with it
+	_, _, err := startOIDCLink(t, tokenString, "wrong")
+	assertHTTPError(t, err, http.StatusUnauthorized)
+	query, cookie, err := startOIDCLink(t, tokenString, "password123")
+	assert.NoError(t, err)
+	rec = finishOIDCLogin(t, query.Get("state"), idp.authorize(t, query, jwt.MapClaims{"sub": "bob-1", "email": existing.Email, "email_verified": true}), cookie)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, existing.UserID, identityUser(t, "bob-1").UserID)
+	rec = oidcLogin(t, idp, jwt.MapClaims{"sub": "bob-1", "email": existing.Email, "email_verified": true})
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// An identity of another account cannot be linked
+	query, cookie, err = startOIDCLink(t, tokenString, "password123")
+	assert.NoError(t, err)
+	rec = finishOIDCLogin(t, query.Get("state"), idp.authorize(t, query, jwt.MapClaims{"sub": "alice-1", "email": "alice@idp.test", "email_verified": true}), cookie)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, alice.UserID, identityUser(t, "alice-1").UserID)
+
+	// Restricted accounts cannot log in this way either
+	assert.NoError(t, config.DB.Model(&models.User{}).Where("user_id = ?", existing.UserID).Update("status", handlers.AccountBanned).Error)
+	rec = oidcLogin(t, idp, jwt.MapClaims{"sub": "bob-1", "email": existing.Email, "email_verified": true})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestOIDCLogin_Rejected(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	idp := newStubIdP(t)
+	defer idp.Close()
+	claims := jwt.MapClaims{"sub": "mallory", "email": "mallory@idp.test", "email_verified": true}
+
+	// The state has to match the cookie of the browser that started the login
+	query, cookie := startOIDCLogin(t)
+	code := idp.authorize(t, query, claims)
+	assert.Equal(t, http.StatusBadRequest, finishOIDCLogin(t, query.Get("state"), code, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, finishOIDCLogin(t, "forged", code, &http.Cookie{Name: "OIDCState", Value: "forged"}).Code)
+
+	// A state is used once
+	assert.Equal(t, http.StatusOK, finishOIDCLogin(t, query.Get("state"), code, cookie).Code)
+	assert.Equal(t, http.StatusBadRequest, finishOIDCLogin(t, query.Get("state"), idp.authorize(t, query, claims), cookie).Code)
+
+	// Expired logins are refused
+	query, cookie = startOIDCLogin(t)
+	assert.NoError(t, config.DB.Model(&models.OIDCLoginState{}).Where("state_hash = ?", oidc.HashState(query.Get("state"))).Update("expires_at", time.Now().Add(-time.Second)).Error)
+	assert.Equal(t, http.StatusBadRequest, finishOIDCLogin(t, query.Get("state"), idp.authorize(t, query, claims), cookie).Code)
+
+	// The provider checks the PKCE verifier
+	query, cookie = startOIDCLogin(t)
+	assert.NoError(t, config.DB.Model(&models.OIDCLoginState{}).Where("state_hash = ?", oidc.HashState(query.Get("state"))).Update("code_verifier", "stolen").Error)
+	assert.Equal(t, http.StatusUnauthorized, finishOIDCLogin(t, query.Get("state"), idp.authorize(t, query, claims), cookie).Code)
+
+	// ID tokens with another nonce, audience or issuer are refused
+	for _, override := range []jwt.MapClaims{{"nonce": "replayed"}, {"aud": "another-client"}, {"iss": "https://evil.test"}, {"exp": time.Now().Add(-time.Hour).Unix()}} {
+		query, cookie = startOIDCLogin(t)
+		tampered := jwt.MapClaims{}
+		for name, value := range claims {
+			tampered[name] = value
+		}
+		for name, value := range override {
+			tampered[name] = value
+		}
+		assert.Equal(t, http.StatusUnauthorized, finishOIDCLogin(t, query.Get("state"), idp.authorize(t, query, tampered), cookie).Code, override)
+	}
+
+	// The provider refusing the login
+	rec := serveOIDC(t, handlers.OIDCCallback, "/api/v1/auth/oidc/stub/callback?error=access_denied", nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestOIDCReauthentication(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	idp := newStubIdP(t)
+	defer idp.Close()
+	claims := jwt.MapClaims{"sub": "carol-1", "email": "carol@idp.test", "email_verified": true}
+	assert.Equal(t, http.StatusOK, oidcLogin(t, idp, claims).Code)
+	carol := identityUser(t, "carol-1")
+	tokenString := createJWTTokenTest(t, carol.UserID)
+
+	// Without a password the account is confirmed by a recent login with the provider, a login from an old session does not count
+	_, err := serveRestricted(handlers.DeactivateAccount, http.MethodPost, "/api/v1/restricted/account/deactivate", `{}`, tokenString)
+	assertHTTPError(t, err, http.StatusUnauthorized)
+	_, err = serveRestricted(handlers.DeactivateAccount, http.MethodPost, "/api/v1/restricted/account/deactivate", `{"password":""}`, tokenString)
+	assertHTTPError(t, err, http.StatusUnauthorized)
+	withAuthTime := jwt.MapClaims{"auth_time": time.Now().Add(-time.Hour).Unix()}
+	for name, value := range claims {
+		withAuthTime[name] = value
+	}
+	assert.Equal(t, http.StatusOK, oidcLogin(t, idp, withAuthTime).Code)
+	_, err = serveRestricted(handlers.DeactivateAccount, http.MethodPost, "/api/v1/restricted/account/deactivate", `{}`, tokenString)
+	assertHTTPError(t, err, http.StatusUnauthorized)
+
+	// The provider vouches for a fresh sign-in with auth_time
+	withAuthTime["auth_time"] = time.Now().Unix()
+	assert.Equal(t, http.StatusOK, oidcLogin(t, idp, withAuthTime).Code)
+	rec, err := serveRestricted(handlers.DeactivateAccount, http.MethodPost, "/api/v1/restricted/account/deactivate", `{}`, tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// The confirmation expires
+	assert.NoError(t, config.DB.Model(&models.UserIdentity{}).Where("subject = ?", "carol-1").Update("authenticated_at", time.Now().Add(-10*time.Minute)).Error)
+	assert.Equal(t, http.StatusOK, oidcLogin(t, idp, claims).Code)
+	_, err = serveRestricted(handlers.DeleteAccount, http.MethodDelete, "/api/v1/restricted/account", `{}`, tokenString)
+	assertHTTPError(t, err, http.StatusUnauthorized)
+
+	// A login asking the provider to prompt for the credentials confirms the account even without auth_time
+	rec = serveOIDC(t, handlers.OIDCLogin, "/api/v1/auth/oidc/stub?reauthenticate=true", nil)
+	location, err := url.Parse(rec.Header().Get(echo.HeaderLocation))
+	assert.NoError(t, err)
+	query := location.Query()
+	assert.Equal(t, "login", query.Get("prompt"))
+	assert.Equal(t, "0", query.Get("max_age"))
+	var cookie *http.Cookie
+	for _, set := range rec.Result().Cookies() {
+		if set.Name == "OIDCState" {
+			cookie = set
+		}
+	}
+	assert.Equal(t, http.StatusOK, finishOIDCLogin(t, query.Get("state"), idp.authorize(t, query, claims), cookie).Code)
+	rec, err = serveRestricted(handlers.DeleteAccount, http.MethodDelete, "/api/v1/restricted/account", `{}`, tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var users int64
+	config.DB.Model(&models.User{}).Where("user_id = ?", carol.UserID).Count(&users)
+	assert.EqualValues(t, 0, users)
+}
@@ -0,0 +1,226 @@
+package tests
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/models"
+	"server/passwords"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// writeBreachedList writes a password list in the Pwned Passwords format, sorted by hash
+func writeBreachedList(t *testing.T, lineEnding string, breached ...string) string {
+	lines := []string{}
+	for _, password := range breached {
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(sum[:]))+":"+fmt.Sprint(len(password)))
+	}
+	sort.Strings(lines)
+	path := filepath.Join(t.TempDir(), "pwned-passwords.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, lineEnding)+lineEnding), 0o644); err != nil {
+		t.Fatalf("Failed to write the breached password list: %v", err)
+	}
+	return path
+}
+
+func createUserWithPassword(t *testing.T, username string, password string) (int, string) {
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	body := fmt.Sprintf(`{"username":%q,"firstname":"Test","surname":"User","email":"%s@example.com","password":%q}`, username, username, password)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	if err := handlers.CreateUser(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec.Code, rec.Body.String()
+}
+
+func changePassword(t *testing.T, userID uint, password string) (int, string) {
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/restricted/users-update-password/"+fmt.Sprint(userID), strings.NewReader(fmt.Sprintf(`{"password":%q}`, password)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("uid")
+	c.SetParamValues(fmt.Sprint(userID))
+	if err := handlers.ChangePassword(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec.Code, rec.Body.String()
+}
+
+func logInWithPassword(t *testing.T, identifier string, password string) int {
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(fmt.Sprintf(`{"identifier":%q,"password":%q}`, identifier, password)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, handlers.LoggedInUser(e.NewContext(req, rec)))
+	return rec.Code
+}
+
+func storedPassword(t *testing.T, username string) string {
+	var user models.User
+	assert.NoError(t, config.DB.Where("username = ?", username).First(&user).Error)
+	return user.Password
+}
+
+// ----------- Unit Testing ----------- //
+func TestPasswordHashing(t *testing.T) {
+	policy := passwords.Default
+
+	hash, err := policy.Hash("correct horse battery staple")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	other, _ := policy.Hash("correct horse battery staple")
+	assert.NotEqual(t, hash, other, "every hash has its own salt")
+
+	matches, outdated := policy.Verify(hash, "correct horse battery staple")
+	assert.True(t, matches)
+	assert.False(t, outdated)
+	matches, _ = policy.Verify(hash, "correct horse battery stapler")
+	assert.False(t, matches)
+	matches, _ = policy.Verify("", "")
+	assert.False(t, matches)
+	matches, _ = policy.Verify("$argon2id$v=19$m=1024,t=1,p=1$bm9wZQ", "correct horse battery staple")
+	assert.False(t, matches)
+
+	// Stronger parameters make existing hashes outdated, they still verify
+	stronger := policy
+	stronger.Argon2.Iterations = 2
+	matches, outdated = stronger.Verify(hash, "correct horse battery staple")
+	assert.True(t, matches)
+	assert.True(t, outdated)
+
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("correct horse battery staple"), bcrypt.MinCost)
+	matches, outdated = policy.Verify(string(legacy), "correct horse battery staple")
+	assert.True(t, matches)
+	assert.True(t, outdated)
+}
+
+func TestBreachedPasswordList(t *testing.T) {
+	breached := []string{}
+	for i := 0; i < 500; i++ {
+		breached = append(breached, fmt.Sprintf("leaked-%d", i))
+	}
+	for _, lineEnding := range []string{"\n", "\r\n"} {
+		path := writeBreachedList(t, lineEnding, breached...)
+		for _, password := range breached {
+			found, err := passwords.Breached(path, password)
+			if !assert.NoError(t, err) || !assert.True(t, found, password) {
+				return
+			}
+		}
+		for _, password := range []string{"", "leaked-500", "Leaked-1", "unique and long passphrase"} {
+			found, err := passwords.Breached(path, password)
+			assert.NoError(t, err)
+			assert.False(t, found, password)
+		}
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	assert.NoError(t, os.WriteFile(empty, nil, 0o644))
+	found, err := passwords.Breached(empty, "leaked-1")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+// ----------- API Testing ----------- //
+func TestPasswordPolicy(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	defaults := passwords.Default
+	defer func() { passwords.Default = defaults }()
+	passwords.Default.MinLength = 10
+	passwords.Default.History = 2
+	passwords.Default.BreachedList = writeBreachedList(t, "\n", "iloveyou123", "qwertyuiop")
+
+	code, body := createUserWithPassword(t, "alice", "short123")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, "at least 10 characters")
+	code, body = createUserWithPassword(t, "alice", "xx-ALICE-rules")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, "username")
+	code, body = createUserWithPassword(t, "alice", "iloveyou123")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, "breached")
+	code, _ = createUserWithPassword(t, "alice", strings.Repeat("long enough ", 20))
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = createUserWithPassword(t, "alice", "first passphrase")
+	if !assert.Equal(t, http.StatusCreated, code) {
+		return
+	}
+	var user models.User
+	assert.NoError(t, config.DB.Where("username = ?", "alice").First(&user).Error)
+	assert.True(t, strings.HasPrefix(user.Password, "$argon2id$"))
+
+	// The last two passwords, the current one included, cannot be used again
+	code, body = changePassword(t, user.UserID, "first passphrase")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, "last 2 passwords")
+	code, _ = changePassword(t, user.UserID, "qwertyuiop")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = changePassword(t, user.UserID, "second passphrase")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = changePassword(t, user.UserID, "first passphrase")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = changePassword(t, user.UserID, "third passphrase")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = changePassword(t, user.UserID, "first passphrase")
+	assert.Equal(t, http.StatusOK, code)
+
+	var history int64
+	config.DB.Model(&models.PasswordHistory{}).Where("user_id = ?", user.UserID).Count(&history)
+	assert.Equal(t, int64(2), history)
+	assert.Equal(t, http.StatusOK, logInWithPassword(t, "alice", "first passphrase"))
+	assert.Equal(t, http.StatusUnauthorized, logInWithPassword(t, "alice", "third passphrase"))
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	// An account from before argon2id
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	user := createTestUserNamed(t, config.DB, "veteran")
+	assert.NoError(t, config.DB.Model(user).Update("password", string(legacy)).Error)
+
+	assert.Equal(t, http.StatusUnauthorized, logInWithPassword(t, "veteran", "password124"))
+	assert.Equal(t, string(legacy), storedPassword(t, "veteran"))
+	// A password over the maximum length is refused before it is hashed
+	assert.Equal(t, http.StatusUnauthorized, logInWithPassword(t, "veteran", strings.Repeat("x", passwords.Default.MaxLength+1)))
+	assert.Len(t, getAuditLogs(t, "action="+handlers.AuditLoginFailed), 2)
+
+	assert.Equal(t, http.StatusOK, logInWithPassword(t, "veteran", "password123"))
+	rehashed := storedPassword(t, "veteran")
+	assert.True(t, strings.HasPrefix(rehashed, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.Equal(t, http.StatusOK, logInWithPassword(t, "veteran", "password123"))
+	assert.Equal(t, rehashed, storedPassword(t, "veteran"))
+
+	// Raising the parameters upgrades the hash at the next login
+	defaults := passwords.Default
+	defer func() { passwords.Default = defaults }()
+	passwords.Default.Argon2.Iterations = 2
+	assert.Equal(t, http.StatusOK, logInWithPassword(t, "veteran", "password123"))
+	assert.True(t, strings.HasPrefix(storedPassword(t, "veteran"), "$argon2id$v=19$m=1024,t=2,p=1$"))
+	assert.Equal(t, http.StatusOK, logInWithPassword(t, "veteran", "password123"))
+}
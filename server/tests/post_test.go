@@ -32,6 +32,34 @@ func createTestPost(t *testing.T, db *gorm.DB, user *models.User) *models.Post {
 	return &post
 }
 
+// create post via API, so hashtags, mentions and the search index are handled like in production
+func createPostViaAPI(t *testing.T, tokenString string, message string) models.Post {
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+
+	body, _ := json.Marshal(models.CreatePostRequest{Message: message})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/restricted/posts", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokenString)
+	rec := httptest.NewRecorder()
+
+	jwtMiddleware := echojwt.WithConfig(echojwt.Config{
+		NewClaimsFunc: func(c echo.Context) jwt.Claims {
+			return new(models.JWTClaims)
+		},
+		SigningKey: []byte("testing_mock"),
+	})
+	if err := jwtMiddleware(handlers.CreatePost)(e.NewContext(req, rec)); err != nil {
+		t.Fatalf("Failed to create post via API: %v", err)
+	}
+
+	var post models.Post
+	if err := json.Unmarshal(rec.Body.Bytes(), &post); err != nil {
+		t.Fatalf("Failed to unmarshal created post: %v", err)
+	}
+	return post
+}
+
 // ----------- Model Testing ----------- //
 // Requirement:
 // UserID must not be negative | uint is always positive and Golang is type strict
@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"server/config"
+	"server/handlers"
+	"server/models"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func getProfile(t *testing.T, username string) (*httptest.ResponseRecorder, models.UserProfileResponse) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/"+username, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("username")
+	c.SetParamValues(username)
+
+	var profile models.UserProfileResponse
+	if assert.NoError(t, handlers.GetUserProfile(c)) && rec.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &profile))
+	}
+	return rec, profile
+}
+
+func getAvatar(t *testing.T, avatarURL string) *httptest.ResponseRecorder {
+	parsed, err := url.Parse(avatarURL)
+	if err != nil {
+		t.Fatalf("Invalid avatar URL: %v", err)
+	}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, parsed.RequestURI(), nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("username")
+	c.SetParamValues("testuser")
+
+	assert.NoError(t, handlers.GetAvatar(c))
+	return rec
+}
+
+// ----------- API Testing ----------- //
+func TestUserProfile(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	userMock := createTestUser(t, config.DB)
+	fan := createTestUserNamed(t, config.DB, "fan")
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+	fanToken := createJWTTokenTest(t, fan.UserID)
+
+	createTestPost(t, config.DB, userMock)
+	createTestPost(t, config.DB, userMock)
+	uid := fmt.Sprint(userMock.UserID)
+	_, err := serveRestricted(handlers.FollowUser, http.MethodPut, "/api/v1/restricted/follows/"+uid, "", fanToken, "uid", uid)
+	assert.NoError(t, err)
+
+	rec, err := serveRestricted(handlers.UpdateProfile, http.MethodPut, "/api/v1/restricted/profile", `{"display_name":" Test User ","bio":"Writing tests","location":"Bangkok","website":"https://example.com"}`, tokenString)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	rec, profile := getProfile(t, "testuser")
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.Equal(t, "Test User", profile.DisplayName)
+		assert.Equal(t, "Writing tests", profile.Bio)
+		assert.Equal(t, "https://example.com", profile.Website)
+		assert.Equal(t, int64(2), profile.PostCount)
+		assert.Equal(t, int64(1), profile.FollowerCount)
+		assert.Equal(t, int64(0), profile.FollowingCount)
+		assert.Empty(t, profile.AvatarURL)
+
+		// Private fields never leave the server
+		var raw map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &raw))
+		assert.NotContains(t, raw, "Email")
+		assert.NotContains(t, raw, "email")
+		assert.NotContains(t, raw, "is_admin")
+	}
+
+	// The viewer sees whether they follow the user
+	rec, err = serveRestricted(handlers.GetUserProfile, http.MethodGet, "/api/v1/users/testuser", "", fanToken, "username", "testuser")
+	if assert.NoError(t, err) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &profile))
+		assert.True(t, profile.Following)
+	}
+
+	rec, _ = getProfile(t, "nobody")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestUpdateProfile_Invalid(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	userMock := createTestUser(t, config.DB)
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"Script website", `{"website":"javascript:alert(1)"}`},
+		{"Not a URL", `{"website":"my site"}`},
+		{"Bio too long", fmt.Sprintf(`{"bio":"%0281d"}`, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := serveRestricted(handlers.UpdateProfile, http.MethodPut, "/api/v1/restricted/profile", tt.body, tokenString)
+			if assert.Error(t, err) {
+				assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+			}
+		})
+	}
+}
+
+func TestAvatar(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	userMock := createTestUser(t, config.DB)
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+
+	rec := uploadFile(t, handlers.UploadAvatar, http.MethodPut, "/api/v1/restricted/profile/avatar", "me.png", testPNG(t, 600, 400), tokenString)
+	if !assert.Equal(t, http.StatusOK, rec.Code) {
+		return
+	}
+	var profile models.UserProfileResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &profile))
+	assert.Contains(t, profile.AvatarURL, "/api/v1/users/testuser/avatar?v=")
+
+	// Cropped to a square and resized
+	rec = getAvatar(t, profile.AvatarURL)
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.Contains(t, rec.Header().Get(echo.HeaderCacheControl), "immutable")
+		avatar, format, err := image.DecodeConfig(rec.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "jpeg", format)
+		assert.Equal(t, 256, avatar.Width)
+		assert.Equal(t, 256, avatar.Height)
+	}
+
+	rec = uploadFile(t, handlers.UploadAvatar, http.MethodPut, "/api/v1/restricted/profile/avatar", "me.png", []byte("not an image at all"), tokenString)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+	rec, err := serveRestricted(handlers.DeleteAvatar, http.MethodDelete, "/api/v1/restricted/profile/avatar", "", tokenString)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	rec = getAvatar(t, profile.AvatarURL)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/models"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func reactToPost(t *testing.T, method string, postID uint, reactionType string, tokenString string) (*httptest.ResponseRecorder, error) {
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+
+	req := httptest.NewRequest(method, fmt.Sprintf("/api/v1/restricted/posts/%d/reactions/%s", postID, reactionType), nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokenString)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/restricted/posts/:pid/reactions/:type")
+	c.SetParamNames("pid", "type")
+	c.SetParamValues(fmt.Sprint(postID), reactionType)
+
+	access_config := echojwt.Config{
+		NewClaimsFunc: func(c echo.Context) jwt.Claims {
+			return new(models.JWTClaims)
+		},
+		SigningKey: []byte("testing_mock"),
+	}
+	jwtMiddleware := echojwt.WithConfig(access_config)
+
+	handler := handlers.AddPostReaction
+	if method == http.MethodDelete {
+		handler = handlers.RemovePostReaction
+	}
+
+	return rec, jwtMiddleware(handler)(c)
+}
+
+// ----------- API Testing ----------- //
+func TestAddPostReaction_Idempotent(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	userMock := createTestUser(t, config.DB)
+	postMock := createTestPost(t, config.DB, userMock)
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+
+	// Reacting twice with the same type must only count once
+	for i := 0; i < 2; i++ {
+		rec, err := reactToPost(t, http.MethodPut, postMock.PostID, "like", tokenString)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	}
+
+	var summary models.ReactionSummaryResponse
+	rec, err := reactToPost(t, http.MethodPut, postMock.PostID, "love", tokenString)
+	if assert.NoError(t, err) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &summary))
+		assert.Equal(t, int64(1), summary.Reactions["like"])
+		assert.Equal(t, int64(1), summary.Reactions["love"])
+		assert.ElementsMatch(t, []string{"like", "love"}, summary.MyReactions)
+	}
+
+	var counter models.PostReactionCount
+	assert.NoError(t, config.DB.Where("post_id = ? AND reaction_type = ?", postMock.PostID, "like").First(&counter).Error)
+	assert.Equal(t, int64(1), counter.Total)
+}
+
+func TestRemovePostReaction(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	userMock := createTestUser(t, config.DB)
+	postMock := createTestPost(t, config.DB, userMock)
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+
+	_, err := reactToPost(t, http.MethodPut, postMock.PostID, "wow", tokenString)
+	assert.NoError(t, err)
+
+	// Removing twice must not push the counter below zero
+	for i := 0; i < 2; i++ {
+		rec, err := reactToPost(t, http.MethodDelete, postMock.PostID, "wow", tokenString)
+		if assert.NoError(t, err) {
+			var summary models.ReactionSummaryResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &summary))
+			assert.Equal(t, int64(0), summary.Reactions["wow"])
+			assert.Empty(t, summary.MyReactions)
+		}
+	}
+
+	var counter models.PostReactionCount
+	assert.NoError(t, config.DB.Where("post_id = ? AND reaction_type = ?", postMock.PostID, "wow").First(&counter).Error)
+	assert.Equal(t, int64(0), counter.Total)
+}
+
+func TestAddPostReaction_Invalid(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	userMock := createTestUser(t, config.DB)
+	postMock := createTestPost(t, config.DB, userMock)
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+
+	_, err := reactToPost(t, http.MethodPut, postMock.PostID, "dislike", tokenString)
+	httpError, ok := err.(*echo.HTTPError)
+	if assert.True(t, ok) {
+		assert.Equal(t, http.StatusBadRequest, httpError.Code)
+	}
+
+	rec, err := reactToPost(t, http.MethodPut, postMock.PostID+100, "like", tokenString)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestGetPostsIncludesReactions(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	userMock := createTestUser(t, config.DB)
+	postMock := createTestPost(t, config.DB, userMock)
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+
+	_, err := reactToPost(t, http.MethodPut, postMock.PostID, "laugh", tokenString)
+	assert.NoError(t, err)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/posts", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, handlers.GetPosts(c)) {
+		var posts []models.GetPublicPostsRequest
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &posts))
+		if assert.Len(t, posts, 1) {
+			assert.Equal(t, int64(1), posts[0].Reactions["laugh"])
+			// Anonymous viewer, so no "did I react" flags
+			assert.Empty(t, posts[0].MyReactions)
+		}
+	}
+}
+
+func TestAddCommentReaction_Hidden(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	author := createTestUserNamed(t, config.DB, "author")
+	reader := createTestUserNamed(t, config.DB, "reader")
+	post := createTestPost(t, config.DB, author)
+	comment := createTestCommentAs(t, config.DB, post, author, "Moderated")
+	tokenString := createJWTTokenTest(t, reader.UserID)
+	react := func(reactionType string) int {
+		cid := fmt.Sprint(comment.CommentID)
+		rec, err := serveRestricted(handlers.AddCommentReaction, http.MethodPut, "/api/v1/restricted/comments/"+cid+"/reactions/"+reactionType, "", tokenString, "cid", cid, "type", reactionType)
+		assert.NoError(t, err)
+		return rec.Code
+	}
+	notifications := func() (count int64) {
+		config.DB.Model(&models.Notification{}).Where("user_id = ? AND type = ?", author.UserID, handlers.NotificationReaction).Count(&count)
+		return count
+	}
+
+	assert.Equal(t, http.StatusOK, react("like"))
+	assert.Equal(t, int64(1), notifications())
+
+	// Hidden comments cannot be reacted to and their author is not notified
+	assert.NoError(t, config.DB.Model(&comment).Update("hidden_at", time.Now()).Error)
+	assert.Equal(t, http.StatusNotFound, react("love"))
+	assert.Equal(t, int64(1), notifications())
+}
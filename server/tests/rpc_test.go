@@ -0,0 +1,245 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	socialfeedv1 "server/gen/socialfeed/v1"
+	"server/gen/socialfeed/v1/socialfeedv1connect"
+	"server/handlers"
+	"server/helpers"
+	"server/models"
+	"server/rpc"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type rpcClients struct {
+	users    socialfeedv1connect.UserServiceClient
+	posts    socialfeedv1connect.PostServiceClient
+	comments socialfeedv1connect.CommentServiceClient
+}
+
+// serveRPC starts the gRPC services on a test server, the clients speak the gRPC protocol over HTTP/2
+func serveRPC(t *testing.T) rpcClients {
+	server := httptest.NewUnstartedServer(rpc.NewHandler())
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return rpcClients{
+		users:    socialfeedv1connect.NewUserServiceClient(server.Client(), server.URL, connect.WithGRPC()),
+		posts:    socialfeedv1connect.NewPostServiceClient(server.Client(), server.URL, connect.WithGRPC()),
+		comments: socialfeedv1connect.NewCommentServiceClient(server.Client(), server.URL, connect.WithGRPC()),
+	}
+}
+
+// rpcRequest wraps a message with a metadata header, no header is set when value is empty
+func rpcRequest[T any](message *T, header string, value string) *connect.Request[T] {
+	request := connect.NewRequest(message)
+	if value != "" {
+		request.Header().Set(header, value)
+	}
+	return request
+}
+
+func bearer(t *testing.T, user *models.User) string {
+	tokenString, err := helpers.GenerateJWTToken(*user)
+	if err != nil {
+		t.Fatalf("Failed to create JWT token: %v", err)
+	}
+	return "Bearer " + tokenString
+}
+
+func assertRPCCode(t *testing.T, code connect.Code, err error) {
+	t.Helper()
+	if assert.Error(t, err) {
+		assert.Equal(t, code, connect.CodeOf(err), err.Error())
+	}
+}
+
+// ----------- API Testing ----------- //
+func TestRPCPosts(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	ctx := context.Background()
+	clients := serveRPC(t)
+	alice := createTestUserNamed(t, config.DB, "alice")
+	bob := createTestUserNamed(t, config.DB, "bob")
+
+	// Mutations need credentials, and bad credentials are rejected rather than treated as anonymous
+	_, err := clients.posts.CreatePost(ctx, connect.NewRequest(&socialfeedv1.CreatePostRequest{Message: "Hello"}))
+	assertRPCCode(t, connect.CodeUnauthenticated, err)
+	_, err = clients.posts.ListPosts(ctx, rpcRequest(&socialfeedv1.ListPostsRequest{}, echo.HeaderAuthorization, "Bearer not-a-token"))
+	assertRPCCode(t, connect.CodeUnauthenticated, err)
+
+	// Same validation as the REST route
+	_, err = clients.posts.CreatePost(ctx, rpcRequest(&socialfeedv1.CreatePostRequest{Message: ""}, echo.HeaderAuthorization, bearer(t, alice)))
+	assertRPCCode(t, connect.CodeInvalidArgument, err)
+	_, err = clients.posts.CreatePost(ctx, rpcRequest(&socialfeedv1.CreatePostRequest{Message: "Hello", Visibility: "everyone"}, echo.HeaderAuthorization, bearer(t, alice)))
+	assertRPCCode(t, connect.CodeInvalidArgument, err)
+
+	created, err := clients.posts.CreatePost(ctx, rpcRequest(&socialfeedv1.CreatePostRequest{Message: "Hello over gRPC"}, echo.HeaderAuthorization, bearer(t, alice)))
+	if assert.NoError(t, err) {
+		assert.NotZero(t, created.Msg.PostId)
+		assert.Equal(t, uint64(alice.UserID), created.Msg.UserId)
+		assert.Equal(t, "public", created.Msg.Visibility)
+		assert.False(t, created.Msg.Hidden)
+	}
+	private, err := clients.posts.CreatePost(ctx, rpcRequest(&socialfeedv1.CreatePostRequest{Message: "Only me", Visibility: "private"}, echo.HeaderAuthorization, bearer(t, alice)))
+	assert.NoError(t, err)
+
+	// The post is stored like a REST post, so the REST feed and the gRPC feed match
+	feed, err := clients.posts.ListPosts(ctx, connect.NewRequest(&socialfeedv1.ListPostsRequest{}))
+	if assert.NoError(t, err) {
+		var restFeed []models.GetPublicPostsRequest
+		assert.NoError(t, json.Unmarshal(servePublic(t, handlers.GetPosts, "/api/v1/posts").Body.Bytes(), &restFeed))
+		if assert.Len(t, feed.Msg.Posts, len(restFeed)) && assert.Len(t, restFeed, 1) {
+			assert.Equal(t, uint64(restFeed[0].PostID), feed.Msg.Posts[0].PostId)
+			assert.Equal(t, "alice", feed.Msg.Posts[0].Username)
+			assert.Equal(t, "Hello over gRPC", feed.Msg.Posts[0].Message)
+		}
+	}
+
+	// Visibility rules apply to reads
+	_, err = clients.posts.GetPost(ctx, connect.NewRequest(&socialfeedv1.GetPostRequest{PostId: private.Msg.PostId}))
+	assertRPCCode(t, connect.CodeNotFound, err)
+	post, err := clients.posts.GetPost(ctx, rpcRequest(&socialfeedv1.GetPostRequest{PostId: private.Msg.PostId}, echo.HeaderAuthorization, bearer(t, alice)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Only me", post.Msg.Message)
+	}
+
+	// Only the author edits a post
+	_, err = clients.posts.UpdatePost(ctx, rpcRequest(&socialfeedv1.UpdatePostRequest{PostId: created.Msg.PostId, Message: "Mine now"}, echo.HeaderAuthorization, bearer(t, bob)))
+	assertRPCCode(t, connect.CodePermissionDenied, err)
+	updated, err := clients.posts.UpdatePost(ctx, rpcRequest(&socialfeedv1.UpdatePostRequest{PostId: created.Msg.PostId, Message: "Edited over gRPC"}, echo.HeaderAuthorization, bearer(t, alice)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Edited over gRPC", updated.Msg.Message)
+	}
+	_, err = clients.posts.UpdatePost(ctx, rpcRequest(&socialfeedv1.UpdatePostRequest{PostId: 9999, Message: "Nothing"}, echo.HeaderAuthorization, bearer(t, alice)))
+	assertRPCCode(t, connect.CodeNotFound, err)
+
+	// A banned account cannot post anymore, its token only reads like an anonymous caller
+	assert.NoError(t, config.DB.Model(alice).Update("status", handlers.AccountBanned).Error)
+	_, err = clients.posts.CreatePost(ctx, rpcRequest(&socialfeedv1.CreatePostRequest{Message: "Still here"}, echo.HeaderAuthorization, bearer(t, alice)))
+	assertRPCCode(t, connect.CodePermissionDenied, err)
+	_, err = clients.posts.GetPost(ctx, rpcRequest(&socialfeedv1.GetPostRequest{PostId: private.Msg.PostId}, echo.HeaderAuthorization, bearer(t, alice)))
+	assertRPCCode(t, connect.CodeNotFound, err)
+}
+
+func TestRPCCommentsAndProfiles(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	ctx := context.Background()
+	clients := serveRPC(t)
+	alice := createTestUserNamed(t, config.DB, "alice")
+	bob := createTestUserNamed(t, config.DB, "bob")
+	post := createTestPost(t, config.DB, alice)
+
+	_, err := clients.comments.CreateComment(ctx, rpcRequest(&socialfeedv1.CreateCommentRequest{PostId: 9999, Message: "Hi"}, echo.HeaderAuthorization, bearer(t, bob)))
+	assertRPCCode(t, connect.CodeInvalidArgument, err)
+
+	comment, err := clients.comments.CreateComment(ctx, rpcRequest(&socialfeedv1.CreateCommentRequest{PostId: uint64(post.PostID), Message: "Nice post"}, echo.HeaderAuthorization, bearer(t, bob)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, uint64(post.PostID), comment.Msg.PostId)
+
+	_, err = clients.comments.UpdateComment(ctx, rpcRequest(&socialfeedv1.UpdateCommentRequest{CommentId: comment.Msg.CommentId, Message: "Not mine"}, echo.HeaderAuthorization, bearer(t, alice)))
+	assertRPCCode(t, connect.CodePermissionDenied, err)
+	_, err = clients.comments.UpdateComment(ctx, rpcRequest(&socialfeedv1.UpdateCommentRequest{CommentId: comment.Msg.CommentId, Message: "Very nice post"}, echo.HeaderAuthorization, bearer(t, bob)))
+	assert.NoError(t, err)
+
+	comments, err := clients.comments.ListComments(ctx, connect.NewRequest(&socialfeedv1.ListCommentsRequest{PostId: uint64(post.PostID)}))
+	if assert.NoError(t, err) && assert.Len(t, comments.Msg.Comments, 1) {
+		assert.Equal(t, "bob", comments.Msg.Comments[0].Username)
+		assert.Equal(t, "Very nice post", comments.Msg.Comments[0].Message)
+	}
+
+	// Creating the comment notified the author, like the REST route does
+	var notifications int64
+	config.DB.Model(&models.Notification{}).Where("user_id = ?", alice.UserID).Count(&notifications)
+	assert.Equal(t, int64(1), notifications)
+
+	_, err = clients.users.UpdateProfile(ctx, rpcRequest(&socialfeedv1.UpdateProfileRequest{Website: "not a url"}, echo.HeaderAuthorization, bearer(t, alice)))
+	assertRPCCode(t, connect.CodeInvalidArgument, err)
+	updated, err := clients.users.UpdateProfile(ctx, rpcRequest(&socialfeedv1.UpdateProfileRequest{DisplayName: "Alice", Bio: "Hello"}, echo.HeaderAuthorization, bearer(t, alice)))
+	assert.NoError(t, err)
+
+	// Every call gets a request ID like the REST routes, the audit log records it and the response carries it back
+	if assert.NotNil(t, updated) {
+		requestID := updated.Header().Get(echo.HeaderXRequestID)
+		assert.Len(t, requestID, 32)
+		assert.Len(t, getAuditLogs(t, "request_id="+requestID), 1)
+	}
+	traced := rpcRequest(&socialfeedv1.UpdateProfileRequest{DisplayName: "Alice", Bio: "Hello"}, echo.HeaderAuthorization, bearer(t, alice))
+	traced.Header().Set(echo.HeaderXRequestID, "trace-123")
+	updated, err = clients.users.UpdateProfile(ctx, traced)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "trace-123", updated.Header().Get(echo.HeaderXRequestID))
+		assert.Len(t, getAuditLogs(t, "request_id=trace-123"), 1)
+	}
+	_, err = clients.users.UpdateProfile(ctx, rpcRequest(&socialfeedv1.UpdateProfileRequest{Website: "not a url"}, echo.HeaderAuthorization, bearer(t, alice)))
+	var connectError *connect.Error
+	if assert.ErrorAs(t, err, &connectError) {
+		assert.NotEmpty(t, connectError.Meta().Get(echo.HeaderXRequestID))
+	}
+
+	profile, err := clients.users.GetProfile(ctx, rpcRequest(&socialfeedv1.GetProfileRequest{Username: "alice"}, echo.HeaderAuthorization, bearer(t, bob)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(alice.UserID), profile.Msg.UserId)
+		assert.Equal(t, "Alice", profile.Msg.DisplayName)
+		assert.Equal(t, "Hello", profile.Msg.Bio)
+		assert.Equal(t, int64(1), profile.Msg.PostCount)
+		assert.False(t, profile.Msg.Following)
+	}
+	_, err = clients.users.GetProfile(ctx, connect.NewRequest(&socialfeedv1.GetProfileRequest{Username: "nobody"}))
+	assertRPCCode(t, connect.CodeNotFound, err)
+}
+
+func TestRPCAPIKeys(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	ctx := context.Background()
+	clients := serveRPC(t)
+	user := createTestUserNamed(t, config.DB, "scripter")
+	tokenString := createJWTTokenTest(t, user.UserID)
+
+	code, readKey := createAPIKey(t, tokenString, `{"name":"reader","scopes":["read"]}`)
+	assert.Equal(t, http.StatusCreated, code)
+	code, postKey := createAPIKey(t, tokenString, `{"name":"poster","scopes":["read","post"]}`)
+	assert.Equal(t, http.StatusCreated, code)
+
+	_, err := clients.posts.ListPosts(ctx, rpcRequest(&socialfeedv1.ListPostsRequest{}, handlers.APIKeyHeader, "ak_unknown"))
+	assertRPCCode(t, connect.CodeUnauthenticated, err)
+
+	// The scopes of the key decide, like on the REST routes
+	_, err = clients.posts.ListPosts(ctx, rpcRequest(&socialfeedv1.ListPostsRequest{}, handlers.APIKeyHeader, readKey.Key))
+	assert.NoError(t, err)
+	_, err = clients.posts.CreatePost(ctx, rpcRequest(&socialfeedv1.CreatePostRequest{Message: "From a script"}, handlers.APIKeyHeader, readKey.Key))
+	assertRPCCode(t, connect.CodePermissionDenied, err)
+
+	post, err := clients.posts.CreatePost(ctx, rpcRequest(&socialfeedv1.CreatePostRequest{Message: "From a script"}, echo.HeaderAuthorization, handlers.APIKeyScheme+" "+postKey.Key))
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(user.UserID), post.Msg.UserId)
+	}
+	_, err = clients.comments.CreateComment(ctx, rpcRequest(&socialfeedv1.CreateCommentRequest{PostId: post.Msg.GetPostId(), Message: "Reply"}, handlers.APIKeyHeader, postKey.Key))
+	assertRPCCode(t, connect.CodePermissionDenied, err)
+	_, err = clients.users.UpdateProfile(ctx, rpcRequest(&socialfeedv1.UpdateProfileRequest{Bio: "Bot"}, handlers.APIKeyHeader, postKey.Key))
+	assertRPCCode(t, connect.CodePermissionDenied, err)
+
+	// A revoked key stops working right away
+	rec := serveAuthenticated(t, http.MethodDelete, "/api/v1/restricted/api-keys/"+fmt.Sprint(postKey.APIKeyID), "", echo.HeaderAuthorization, "Bearer "+tokenString)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	_, err = clients.posts.ListPosts(ctx, rpcRequest(&socialfeedv1.ListPostsRequest{}, handlers.APIKeyHeader, postKey.Key))
+	assertRPCCode(t, connect.CodeUnauthenticated, err)
+}
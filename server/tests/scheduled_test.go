@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/handlers"
+	"server/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func schedulePost(t *testing.T, tokenString string, message string, publishAt time.Time) (int, models.Post) {
+	body, _ := json.Marshal(models.CreatePostRequest{Message: message, PublishAt: &publishAt})
+	rec, err := serveRestricted(handlers.CreatePost, http.MethodPost, "/api/v1/restricted/posts", string(body), tokenString)
+	var post models.Post
+	if assert.NoError(t, err) && rec.Code == http.StatusCreated {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &post))
+	}
+	return rec.Code, post
+}
+
+func getScheduledPosts(t *testing.T, tokenString string) []models.GetPublicPostsRequest {
+	rec, err := serveRestricted(handlers.GetScheduledPosts, http.MethodGet, "/api/v1/restricted/scheduled-posts", "", tokenString)
+	posts := []models.GetPublicPostsRequest{}
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &posts))
+	}
+	return posts
+}
+
+func reschedulePost(t *testing.T, postID uint, publishAt time.Time, tokenString string) int {
+	pid := fmt.Sprint(postID)
+	body, _ := json.Marshal(models.ReschedulePostRequest{PublishAt: publishAt})
+	rec, err := serveRestricted(handlers.ReschedulePost, http.MethodPut, "/api/v1/restricted/scheduled-posts/"+pid, string(body), tokenString, "pid", pid)
+	assert.NoError(t, err)
+	return rec.Code
+}
+
+// make a scheduled post due without waiting for it
+func makeDue(t *testing.T, postID uint) {
+	assert.NoError(t, config.DB.Model(&models.Post{}).Where("post_id = ?", postID).Update("publish_at", time.Now().Add(-time.Second)).Error)
+}
+
+// ----------- API Testing ----------- //
+func TestScheduledPosts(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	author := createTestUserNamed(t, config.DB, "author")
+	reader := createTestUserNamed(t, config.DB, "reader")
+	authorToken := createJWTTokenTest(t, author.UserID)
+	readerToken := createJWTTokenTest(t, reader.UserID)
+
+	code, _ := schedulePost(t, authorToken, "Too late", time.Now().Add(-time.Minute))
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, post := schedulePost(t, authorToken, "Launch day @reader", time.Now().Add(time.Hour))
+	if !assert.Equal(t, http.StatusCreated, code) {
+		return
+	}
+	assert.NotNil(t, post.PublishAt)
+
+	// Until it is published only the author sees it, and only in the scheduled list
+	assert.Empty(t, listPostsAs(t, authorToken))
+	assert.Empty(t, listPostsAs(t, readerToken))
+	assert.Equal(t, http.StatusNotFound, getPostStatus(t, post.PostID, readerToken))
+	assert.Equal(t, http.StatusOK, getPostStatus(t, post.PostID, authorToken))
+	assert.Equal(t, http.StatusBadRequest, commentOn(t, post.PostID, "First", readerToken))
+	assert.Empty(t, getNotifications(t, readerToken, ""))
+	assert.Empty(t, searchFor(t, "launch", "post"))
+	scheduled := getScheduledPosts(t, authorToken)
+	if assert.Len(t, scheduled, 1) && assert.NotNil(t, scheduled[0].PublishAt) {
+		assert.Equal(t, post.PostID, scheduled[0].PostID)
+	}
+	assert.Empty(t, getScheduledPosts(t, readerToken))
+
+	// Rescheduling
+	assert.Equal(t, http.StatusBadRequest, reschedulePost(t, post.PostID, time.Now().Add(-time.Hour), authorToken))
+	assert.Equal(t, http.StatusNotFound, reschedulePost(t, post.PostID, time.Now().Add(2*time.Hour), readerToken))
+	assert.Equal(t, http.StatusOK, reschedulePost(t, post.PostID, time.Now().Add(2*time.Hour), authorToken))
+
+	// Nothing is due yet
+	published, err := handlers.PublishDuePosts(config.DB)
+	assert.NoError(t, err)
+	assert.Zero(t, published)
+
+	// A due post belongs to the publisher
+	makeDue(t, post.PostID)
+	assert.Equal(t, http.StatusConflict, reschedulePost(t, post.PostID, time.Now().Add(time.Hour), authorToken))
+
+	published, err = handlers.PublishDuePosts(config.DB)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.ElementsMatch(t, []string{"Launch day @reader"}, postMessages(listPostsAs(t, readerToken)))
+	assert.Len(t, getNotifications(t, readerToken, ""), 1)
+	assert.Len(t, searchFor(t, "launch", "post"), 1)
+	assert.Empty(t, getScheduledPosts(t, authorToken))
+
+	// Running again publishes nothing twice
+	published, err = handlers.PublishDuePosts(config.DB)
+	assert.NoError(t, err)
+	assert.Zero(t, published)
+	assert.Len(t, getNotifications(t, readerToken, ""), 1)
+	assert.Equal(t, http.StatusNotFound, reschedulePost(t, post.PostID, time.Now().Add(time.Hour), authorToken))
+}
+
+func TestScheduledPosts_Cancel(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	author := createTestUserNamed(t, config.DB, "author")
+	authorToken := createJWTTokenTest(t, author.UserID)
+
+	code, post := schedulePost(t, authorToken, "Maybe later", time.Now().Add(time.Hour))
+	if !assert.Equal(t, http.StatusCreated, code) {
+		return
+	}
+
+	pid := fmt.Sprint(post.PostID)
+	rec, err := serveRestricted(handlers.CancelScheduledPost, http.MethodDelete, "/api/v1/restricted/scheduled-posts/"+pid, "", authorToken, "pid", pid)
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		var cancelled models.Post
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &cancelled))
+		assert.Nil(t, cancelled.PublishAt)
+		assert.Equal(t, handlers.VisibilityDraft, cancelled.Visibility)
+	}
+	assert.Empty(t, getScheduledPosts(t, authorToken))
+
+	// A cancelled post is never published, it waits in the drafts
+	published, err := handlers.PublishDuePosts(config.DB)
+	assert.NoError(t, err)
+	assert.Zero(t, published)
+	rec, err = serveRestricted(handlers.GetDrafts, http.MethodGet, "/api/v1/restricted/drafts", "", authorToken)
+	if assert.NoError(t, err) {
+		var drafts []models.GetPublicPostsRequest
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &drafts))
+		assert.ElementsMatch(t, []string{"Maybe later"}, postMessages(drafts))
+	}
+
+	rec, err = serveRestricted(handlers.CancelScheduledPost, http.MethodDelete, "/api/v1/restricted/scheduled-posts/"+pid, "", authorToken, "pid", pid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
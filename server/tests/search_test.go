@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/models"
+	"server/search"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func searchRequest(t *testing.T, query string) (*httptest.ResponseRecorder, error) {
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/search?"+query, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	return rec, handlers.Search(c)
+}
+
+// ----------- Unit Testing ----------- //
+func TestHighlight(t *testing.T) {
+	snippet := search.Highlight("Learning <Golang> generics", search.Tokenize("golang"))
+	assert.Equal(t, "Learning &lt;<mark>Golang</mark>&gt; generics", snippet)
+
+	long := strings.Repeat("filler ", 60) + "gopher" + strings.Repeat(" filler", 60)
+	snippet = search.Highlight(long, []string{"gopher"})
+	assert.Contains(t, snippet, "<mark>gopher</mark>")
+	assert.True(t, strings.HasPrefix(snippet, "…"))
+	assert.True(t, strings.HasSuffix(snippet, "…"))
+}
+
+func TestBooleanQuery(t *testing.T) {
+	assert.Equal(t, "learning* golang*", search.BooleanQuery("Learning golang"))
+	// Boolean operators and single characters are dropped
+	assert.Equal(t, "gola* rust*", search.BooleanQuery(`+gola -"rust" a* (~`))
+	assert.Empty(t, search.BooleanQuery("+-*"))
+}
+
+func TestMemoryEngine(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	alice := createTestUserNamed(t, config.DB, "alice")
+	engine := search.NewMemoryEngine(config.DB)
+	engine.IndexPost(models.Post{PostID: 1, UserID: alice.UserID, Message: "Learning golang generics"})
+	engine.IndexPost(models.Post{PostID: 2, UserID: alice.UserID, Message: "golang golang golang"})
+	engine.IndexComment(models.Comment{CommentID: 1, PostID: 1, AuthorID: alice.UserID, CommentMSG: "Gophers love go"})
+	engine.IndexUser(*alice)
+
+	query := func(text string, types ...string) ([]models.SearchResult, int64) {
+		results, total, err := engine.Search(search.Query{Text: text, Types: types, Page: 1, PageSize: 10})
+		assert.NoError(t, err)
+		return results, total
+	}
+
+	// The post repeating the term ranks first, usernames are resolved from the database
+	results, total := query("golang")
+	assert.Equal(t, int64(2), total)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, uint(2), results[0].ID)
+		assert.Equal(t, uint(1), results[1].ID)
+		assert.Equal(t, "alice", results[0].Username)
+		assert.Greater(t, results[0].Score, results[1].Score)
+	}
+
+	// Terms match by prefix, but only at the start of a term
+	_, total = query("gola")
+	assert.Equal(t, int64(2), total)
+	_, total = query("lang")
+	assert.Zero(t, total)
+	_, total = query("go")
+	assert.Equal(t, int64(3), total)
+
+	results, _ = query("go", search.TypeComment)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, search.TypeComment, results[0].Type)
+		assert.Equal(t, uint(1), results[0].PostID)
+	}
+	results, _ = query("alice", search.TypeUser)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, alice.UserID, results[0].ID)
+	}
+
+	// Reindexing replaces the old terms, removing drops the document and its terms
+	engine.IndexPost(models.Post{PostID: 2, UserID: alice.UserID, Message: "Rust ownership"})
+	_, total = query("golang")
+	assert.Equal(t, int64(1), total)
+	_, total = query("rust")
+	assert.Equal(t, int64(1), total)
+	engine.Remove(search.TypePost, 2)
+	_, total = query("rust")
+	assert.Zero(t, total)
+	engine.Remove(search.TypePost, 2)
+
+	// Paging past the end returns no results but keeps the total
+	results, total, err := engine.Search(search.Query{Text: "go", Page: 3, PageSize: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Empty(t, results)
+
+	// Rebuild reloads only the public content of the database
+	post := createTestPost(t, config.DB, alice)
+	assert.NoError(t, config.DB.Create(&models.Post{UserID: alice.UserID, Message: "Secret test", Visibility: "private"}).Error)
+	assert.NoError(t, engine.Rebuild())
+	results, total = query("test", search.TypePost)
+	assert.Equal(t, int64(1), total)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, post.PostID, results[0].ID)
+	}
+	_, total = query("golang")
+	assert.Zero(t, total)
+}
+
+// ----------- API Testing ----------- //
+func TestSearch(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	// Register through the API so the user is indexed as well
+	GenerateNewUser(t)
+	var userMock models.User
+	assert.NoError(t, config.DB.Where("username = ?", "testuser").First(&userMock).Error)
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	jwtMiddleware := echojwt.WithConfig(echojwt.Config{
+		NewClaimsFunc: func(c echo.Context) jwt.Claims {
+			return new(models.JWTClaims)
+		},
+		SigningKey: []byte("testing_mock"),
+	})
+
+	// Create content through the handlers so the index is updated the same way as in production
+	var postID uint
+	for _, message := range []string{"Learning golang generics today", "Nothing to see here"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/restricted/posts", strings.NewReader(`{"message":"`+message+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokenString)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, jwtMiddleware(handlers.CreatePost)(e.NewContext(req, rec)))
+
+		var post models.Post
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &post))
+		if postID == 0 {
+			postID = post.PostID
+		}
+	}
+
+	commentJSON := `{"post_id":` + fmt.Sprint(postID) + `,"comment_msg":"golang gophers are great"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/restricted/comments", strings.NewReader(commentJSON))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokenString)
+	assert.NoError(t, jwtMiddleware(handlers.CreateComment)(e.NewContext(req, httptest.NewRecorder())))
+
+	rec, err := searchRequest(t, "q=golang")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var response models.SearchResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, int64(2), response.Total)
+		for _, result := range response.Results {
+			assert.Equal(t, "testuser", result.Username)
+			assert.Contains(t, result.Snippet, "<mark>golang</mark>")
+			assert.Equal(t, postID, result.PostID)
+		}
+	}
+
+	// Both engines match terms by prefix, and only at the start of a term
+	for query, expected := range map[string]int64{"q=gola": 2, "q=gophers": 1, "q=lang": 0} {
+		rec, err = searchRequest(t, query)
+		if assert.NoError(t, err) {
+			var response models.SearchResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Equal(t, expected, response.Total, query)
+		}
+	}
+
+	rec, err = searchRequest(t, "q=golang&type=comment")
+	if assert.NoError(t, err) {
+		var response models.SearchResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		if assert.Len(t, response.Results, 1) {
+			assert.Equal(t, search.TypeComment, response.Results[0].Type)
+		}
+	}
+
+	rec, err = searchRequest(t, "q=testuser&type=user")
+	if assert.NoError(t, err) {
+		var response models.SearchResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		if assert.Len(t, response.Results, 1) {
+			assert.Equal(t, userMock.UserID, response.Results[0].ID)
+		}
+	}
+
+	rec, err = searchRequest(t, "q=golang&page=2&page_size=1")
+	if assert.NoError(t, err) {
+		var response models.SearchResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, int64(2), response.Total)
+		assert.Len(t, response.Results, 1)
+	}
+}
+
+func TestSearch_Invalid(t *testing.T) {
+	_, err := searchRequest(t, "q=")
+	httpError, ok := err.(*echo.HTTPError)
+	if assert.True(t, ok) {
+		assert.Equal(t, http.StatusBadRequest, httpError.Code)
+	}
+
+	rec, err := searchRequest(t, "q=golang&type=hashtag")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/handlers"
+	"server/models"
+	"server/pubsub"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type streamEvent struct {
+	Type string
+	Data string
+}
+
+// open a stream on a real server, the returned channel receives every event until the context is cancelled
+func openStream(t *testing.T, ctx context.Context, query string) (chan streamEvent, int) {
+	e := echo.New()
+	e.GET("/api/v1/stream", handlers.Stream, echojwt.WithConfig(echojwt.Config{
+		NewClaimsFunc: func(c echo.Context) jwt.Claims {
+			return new(models.JWTClaims)
+		},
+		SigningKey:  []byte("testing_mock"),
+		TokenLookup: "header:Authorization:Bearer ,query:token",
+	}))
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/stream"+query, nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, res.StatusCode
+	}
+
+	events := make(chan streamEvent, 16)
+	connected := make(chan struct{})
+	go func() {
+		defer res.Body.Close()
+		defer close(events)
+		scanner := bufio.NewScanner(res.Body)
+		event := streamEvent{}
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == ": connected":
+				close(connected)
+			case strings.HasPrefix(line, "event: "):
+				event.Type = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.Data = strings.TrimPrefix(line, "data: ")
+			case line == "" && event.Type != "":
+				events <- event
+				event = streamEvent{}
+			}
+		}
+	}()
+
+	select {
+	case <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stream did not connect")
+	}
+	return events, http.StatusOK
+}
+
+func nextEvent(t *testing.T, events chan streamEvent) streamEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("No event received")
+		return streamEvent{}
+	}
+}
+
+// ----------- Broker Testing ----------- //
+func TestMemoryBroker(t *testing.T) {
+	broker := pubsub.NewMemoryBroker(1)
+	posts := broker.Subscribe(pubsub.TopicPosts, pubsub.PostTopic(1))
+	other := broker.Subscribe(pubsub.PostTopic(2))
+
+	broker.Publish(pubsub.PostTopic(1), pubsub.Event{Type: pubsub.EventCommentCreated, Data: 1})
+	// The buffer is full, this one is dropped instead of blocking
+	broker.Publish(pubsub.TopicPosts, pubsub.Event{Type: pubsub.EventPostCreated, Data: 2})
+
+	event := <-posts.Events()
+	assert.Equal(t, pubsub.EventCommentCreated, event.Type)
+	assert.Len(t, other.Events(), 0)
+
+	posts.Close()
+	posts.Close()
+	_, open := <-posts.Events()
+	assert.False(t, open)
+
+	// Publishing after a close does not panic
+	broker.Publish(pubsub.TopicPosts, pubsub.Event{Type: pubsub.EventPostCreated})
+	other.Close()
+}
+
+// ----------- API Testing ----------- //
+func TestStream(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	reader := createTestUser(t, config.DB)
+	writer := createTestUserNamed(t, config.DB, "writer")
+	readerToken := createJWTTokenTest(t, reader.UserID)
+	writerToken := createJWTTokenTest(t, writer.UserID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Unauthenticated streams are rejected
+	_, status := openStream(t, ctx, "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	post := createTestPost(t, config.DB, reader)
+	events, _ := openStream(t, ctx, fmt.Sprintf("?token=%s&post=%d", readerToken, post.PostID))
+
+	// New post
+	createPostViaAPI(t, writerToken, "Live #update")
+	event := nextEvent(t, events)
+	if assert.Equal(t, pubsub.EventPostCreated, event.Type) {
+		var created models.GetPublicPostsRequest
+		assert.NoError(t, json.Unmarshal([]byte(event.Data), &created))
+		assert.Equal(t, "writer", created.Username)
+		assert.Equal(t, "Live #update", created.Message)
+		assert.Len(t, created.Entities, 1)
+	}
+
+	// New comment on the subscribed post, followed by the reply notification of the reader
+	commentJSON := `{"post_id":` + fmt.Sprint(post.PostID) + `,"comment_msg":"Streaming"}`
+	_, err := serveRestricted(handlers.CreateComment, http.MethodPost, "/api/v1/restricted/comments", commentJSON, writerToken)
+	assert.NoError(t, err)
+
+	received := map[string]string{}
+	for i := 0; i < 2; i++ {
+		event := nextEvent(t, events)
+		received[event.Type] = event.Data
+	}
+	if assert.Contains(t, received, pubsub.EventCommentCreated) {
+		var comment models.GetCommentRequest
+		assert.NoError(t, json.Unmarshal([]byte(received[pubsub.EventCommentCreated]), &comment))
+		assert.Equal(t, "Streaming", comment.CommentMSG)
+		assert.Equal(t, "writer", comment.Username)
+	}
+	if assert.Contains(t, received, pubsub.EventNotification) {
+		var notification models.NotificationResponse
+		assert.NoError(t, json.Unmarshal([]byte(received[pubsub.EventNotification]), &notification))
+		assert.Equal(t, handlers.NotificationReply, notification.Type)
+		assert.Equal(t, "writer", notification.ActorUsername)
+	}
+
+	// Comments on other posts and notifications of other users are not delivered
+	otherPost := createTestPost(t, config.DB, writer)
+	commentJSON = `{"post_id":` + fmt.Sprint(otherPost.PostID) + `,"comment_msg":"Elsewhere"}`
+	_, err = serveRestricted(handlers.CreateComment, http.MethodPost, "/api/v1/restricted/comments", commentJSON, readerToken)
+	assert.NoError(t, err)
+	select {
+	case event := <-events:
+		t.Errorf("Unexpected event %s: %s", event.Type, event.Data)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	_, status = openStream(t, ctx, "?token="+readerToken+"&post=abc")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/models"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// ----------- Unit Testing ----------- //
+func TestExtractEntities(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		expected []models.TextEntity
+	}{
+		{
+			name: "Hashtag and mention",
+			text: "Hello @testuser, #GoLang rocks",
+			expected: []models.TextEntity{
+				{Type: helpers.EntityMention, Value: "testuser", Start: 6, End: 15},
+				{Type: helpers.EntityHashtag, Value: "golang", Start: 17, End: 24},
+			},
+		},
+		{
+			name:     "Email and inline hash are ignored",
+			text:     "mail me at someone@example.com about issue#12",
+			expected: []models.TextEntity{},
+		},
+		{
+			name:     "Numeric hashtag is ignored",
+			text:     "#2024 was fun",
+			expected: []models.TextEntity{},
+		},
+		{
+			name: "Offsets count characters, not bytes",
+			text: "ยินดี #ไทย",
+			expected: []models.TextEntity{
+				{Type: helpers.EntityHashtag, Value: "ไทย", Start: 6, End: 10},
+			},
+		},
+		{
+			name: "Trailing dot is not part of a mention",
+			text: "thanks @jane.doe.",
+			expected: []models.TextEntity{
+				{Type: helpers.EntityMention, Value: "jane.doe", Start: 7, End: 16},
+			},
+		},
+	}
+
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, helpers.ExtractEntities(testCase.text))
+		})
+	}
+}
+
+// ----------- API Testing ----------- //
+func TestPostEntities(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	userMock := createTestUser(t, config.DB)
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+
+	post := createPostViaAPI(t, tokenString, "Shout out to @testuser and @nobody for #Golang #golang #Go")
+	if assert.Len(t, post.Entities, 4) {
+		assert.Equal(t, userMock.UserID, post.Entities[0].UserID)
+	}
+
+	var tags []string
+	config.DB.Table("hashtags").Joins("inner join post_hashtags on post_hashtags.hashtag_id = hashtags.hashtag_id").Where("post_hashtags.post_id = ?", post.PostID).Order("tag").Pluck("tag", &tags)
+	assert.Equal(t, []string{"go", "golang"}, tags)
+
+	var mentions int64
+	config.DB.Model(&models.PostMention{}).Where("post_id = ?", post.PostID).Count(&mentions)
+	assert.Equal(t, int64(1), mentions)
+
+	// Listing by tag finds the post regardless of case or a leading #
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tags/GOLANG", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/tags/:tag")
+	c.SetParamNames("tag")
+	c.SetParamValues("#GOLANG")
+
+	if assert.NoError(t, handlers.GetPostsByTag(c)) {
+		var posts []models.GetPublicPostsRequest
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &posts))
+		if assert.Len(t, posts, 1) {
+			assert.Equal(t, post.PostID, posts[0].PostID)
+			assert.Len(t, posts[0].Entities, 4)
+		}
+	}
+}
+
+func TestUpdatePostEntities(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	userMock := createTestUser(t, config.DB)
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+	post := createPostViaAPI(t, tokenString, "Old #news")
+
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/restricted/posts/", strings.NewReader(`{"message":"Fresh #update"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokenString)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/api/v1/restricted/posts/:pid")
+	c.SetParamNames("pid")
+	c.SetParamValues(fmt.Sprint(post.PostID))
+
+	jwtMiddleware := echojwt.WithConfig(echojwt.Config{
+		NewClaimsFunc: func(c echo.Context) jwt.Claims {
+			return new(models.JWTClaims)
+		},
+		SigningKey: []byte("testing_mock"),
+	})
+
+	if assert.NoError(t, jwtMiddleware(handlers.UpdatePost)(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	var tags []string
+	config.DB.Table("hashtags").Joins("inner join post_hashtags on post_hashtags.hashtag_id = hashtags.hashtag_id").Where("post_hashtags.post_id = ?", post.PostID).Pluck("tag", &tags)
+	assert.Equal(t, []string{"update"}, tags)
+
+	// Someone else cannot edit the post
+	otherToken := createJWTTokenTest(t, userMock.UserID+1)
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/restricted/posts/", strings.NewReader(`{"message":"Hijacked"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+otherToken)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetPath("/api/v1/restricted/posts/:pid")
+	c.SetParamNames("pid")
+	c.SetParamValues(fmt.Sprint(post.PostID))
+
+	if assert.NoError(t, jwtMiddleware(handlers.UpdatePost)(c)) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestGetTrendingTags(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	userMock := createTestUser(t, config.DB)
+	tokenString := createJWTTokenTest(t, userMock.UserID)
+	createPostViaAPI(t, tokenString, "#golang #echo")
+	createPostViaAPI(t, tokenString, "#golang again")
+
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/trending/tags?window=1h&limit=5", nil)
+	rec := httptest.NewRecorder()
+
+	if assert.NoError(t, handlers.GetTrendingTags(e.NewContext(req, rec))) {
+		var tags []models.TrendingTagResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tags))
+		assert.Equal(t, []models.TrendingTagResponse{{Tag: "golang", Uses: 2}, {Tag: "echo", Uses: 1}}, tags)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/trending/tags?window=30d", nil)
+	rec = httptest.NewRecorder()
+	if assert.NoError(t, handlers.GetTrendingTags(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
@@ -0,0 +1,236 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/jwks"
+	"server/models"
+	"server/tokens"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	echojwt "github.com/labstack/echo-jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTokenManager(t *testing.T, tokenConfig tokens.Config) *tokens.Manager {
+	manager, err := tokens.New(tokenConfig)
+	if err != nil {
+		t.Fatalf("Failed to create the token manager: %v", err)
+	}
+	if err := manager.Rotate(config.DB); err != nil {
+		t.Fatalf("Failed to create a signing key: %v", err)
+	}
+	return manager
+}
+
+// moveSigningKey changes when a key starts signing, as if it had been created earlier
+func moveSigningKey(t *testing.T, kid string, notBefore time.Time) {
+	err := config.DB.Model(&models.SigningKey{}).Where("kid = ?", kid).Update("not_before", notBefore.Truncate(time.Second)).Error
+	assert.NoError(t, err)
+}
+
+// ----------- API Testing ----------- //
+func TestTokenSigning(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	for _, algorithm := range []string{tokens.AlgorithmRS256, tokens.AlgorithmEdDSA} {
+		config.DB.Where("1 = 1").Delete(&models.SigningKey{})
+		manager := newTokenManager(t, tokens.Config{Issuer: "https://feed.example", Audience: "feed-api", Algorithm: algorithm})
+
+		tokenString, err := manager.Sign(&models.JWTClaims{UserID: 7, Username: "testuser"})
+		if !assert.NoError(t, err) {
+			continue
+		}
+		token, err := manager.Parse(tokenString)
+		if assert.NoError(t, err, algorithm) {
+			claims := token.Claims.(*models.JWTClaims)
+			assert.Equal(t, algorithm, token.Method.Alg())
+			assert.Equal(t, manager.Keys()[0].Kid, token.Header["kid"])
+			assert.Equal(t, uint(7), claims.UserID)
+			assert.Equal(t, "7", claims.Subject)
+			assert.Equal(t, "https://feed.example", claims.Issuer)
+			assert.Equal(t, jwt.ClaimStrings{"feed-api"}, claims.Audience)
+			assert.NotNil(t, claims.IssuedAt)
+			assert.NotNil(t, claims.NotBefore)
+			assert.WithinDuration(t, time.Now().Add(tokens.DefaultTTL), claims.ExpiresAt.Time, time.Minute)
+		}
+
+		// A server with another issuer or audience, even with the same keys, rejects the token
+		otherIssuer := newTokenManager(t, tokens.Config{Issuer: "https://other.example", Audience: "feed-api", Algorithm: algorithm})
+		_, err = otherIssuer.Parse(tokenString)
+		assert.ErrorIs(t, err, tokens.ErrInvalidToken)
+		otherAudience := newTokenManager(t, tokens.Config{Issuer: "https://feed.example", Audience: "admin-api", Algorithm: algorithm})
+		_, err = otherAudience.Parse(tokenString)
+		assert.ErrorIs(t, err, tokens.ErrInvalidToken)
+	}
+
+	config.DB.Where("1 = 1").Delete(&models.SigningKey{})
+	manager := newTokenManager(t, tokens.Config{TTL: time.Second})
+	tokenString, err := manager.Sign(&models.JWTClaims{UserID: 7})
+	assert.NoError(t, err)
+
+	// Tampered, unsigned and shared secret tokens are rejected
+	_, err = manager.Parse(tokenString[:len(tokenString)-4] + "AAAA")
+	assert.ErrorIs(t, err, tokens.ErrInvalidToken)
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, &models.JWTClaims{UserID: 7}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	_, err = manager.Parse(unsigned)
+	assert.ErrorIs(t, err, tokens.ErrInvalidToken)
+	_, err = manager.Parse(createJWTTokenTest(t, 7))
+	assert.ErrorIs(t, err, tokens.ErrInvalidToken)
+
+	// Tokens without nbf are not ours, even when the signature matches
+	key := manager.Keys()[len(manager.Keys())-1]
+	noNotBefore := jwt.NewWithClaims(jwt.SigningMethodRS256, &models.JWTClaims{UserID: 7, RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:    tokens.DefaultIssuer,
+		Audience:  jwt.ClaimStrings{tokens.DefaultIssuer},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}})
+	noNotBefore.Header["kid"] = key.Kid
+	forged, err := noNotBefore.SignedString(key.Private)
+	assert.NoError(t, err)
+	_, err = manager.Parse(forged)
+	assert.ErrorIs(t, err, tokens.ErrInvalidToken)
+
+	// Expired tokens are rejected
+	time.Sleep(1100 * time.Millisecond)
+	_, err = manager.Parse(tokenString)
+	assert.ErrorIs(t, err, tokens.ErrInvalidToken)
+
+	// The old shared secret is accepted while it is configured
+	legacy := newTokenManager(t, tokens.Config{LegacySecret: []byte("testing_mock")})
+	_, err = legacy.Parse(createJWTTokenTest(t, 7))
+	assert.NoError(t, err)
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	tokenConfig := tokens.Config{TTL: 30 * time.Minute, Rotation: time.Hour, Prepublish: 10 * time.Minute}
+	manager := newTokenManager(t, tokenConfig)
+	if !assert.Len(t, manager.Keys(), 1) {
+		return
+	}
+	first := manager.Keys()[0]
+
+	// Nothing to do until the prepublish period of the next key starts
+	assert.NoError(t, manager.Rotate(config.DB))
+	assert.Len(t, manager.Keys(), 1)
+
+	// The next key is published ahead of time, the current key keeps signing
+	moveSigningKey(t, first.Kid, time.Now().Add(-55*time.Minute))
+	assert.NoError(t, manager.Rotate(config.DB))
+	if !assert.Len(t, manager.Keys(), 2) {
+		return
+	}
+	next := manager.Keys()[1]
+	assert.True(t, next.NotBefore.After(time.Now()))
+	assert.Len(t, manager.JWKS().Keys, 2)
+	oldToken, err := manager.Sign(&models.JWTClaims{UserID: 7})
+	assert.NoError(t, err)
+	token, err := manager.Parse(oldToken)
+	if assert.NoError(t, err) {
+		assert.Equal(t, first.Kid, token.Header["kid"])
+	}
+
+	// Another server sharing the table loads the same keys
+	other := newTokenManager(t, tokenConfig)
+	assert.Len(t, other.Keys(), 2)
+	_, err = other.Parse(oldToken)
+	assert.NoError(t, err)
+
+	// Once the next key signs, tokens of the old key stay valid until they expire
+	moveSigningKey(t, next.Kid, time.Now().Add(-time.Minute))
+	assert.NoError(t, manager.Rotate(config.DB))
+	newToken, err := manager.Sign(&models.JWTClaims{UserID: 7})
+	assert.NoError(t, err)
+	token, err = manager.Parse(newToken)
+	if assert.NoError(t, err) {
+		assert.Equal(t, next.Kid, token.Header["kid"])
+	}
+	_, err = manager.Parse(oldToken)
+	assert.NoError(t, err)
+
+	// Then the old key is deleted and its tokens are rejected
+	moveSigningKey(t, first.Kid, time.Now().Add(-2*time.Hour))
+	moveSigningKey(t, next.Kid, time.Now().Add(-31*time.Minute))
+	assert.NoError(t, manager.Rotate(config.DB))
+	if assert.Len(t, manager.Keys(), 1) {
+		assert.Equal(t, next.Kid, manager.Keys()[0].Kid)
+	}
+	var stored int64
+	config.DB.Model(&models.SigningKey{}).Count(&stored)
+	assert.Equal(t, int64(1), stored)
+	_, err = manager.Parse(oldToken)
+	assert.ErrorIs(t, err, tokens.ErrInvalidToken)
+
+	// A server down past the planned rotation still publishes the next key before it signs
+	moveSigningKey(t, next.Kid, time.Now().Add(-3*time.Hour))
+	assert.NoError(t, manager.Rotate(config.DB))
+	if assert.Len(t, manager.Keys(), 2) {
+		assert.True(t, manager.Keys()[1].NotBefore.After(time.Now().Add(9*time.Minute)))
+	}
+	lateToken, err := manager.Sign(&models.JWTClaims{UserID: 7})
+	assert.NoError(t, err)
+	token, err = manager.Parse(lateToken)
+	if assert.NoError(t, err) {
+		assert.Equal(t, next.Kid, token.Header["kid"])
+	}
+}
+
+func TestJWKSEndpoint(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	user := createTestUserNamed(t, config.DB, "verified")
+	tokenString, err := helpers.GenerateJWTToken(*user)
+	assert.NoError(t, err)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	rec := httptest.NewRecorder()
+	if !assert.NoError(t, handlers.GetJWKS(e.NewContext(req, rec))) {
+		return
+	}
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "max-age=")
+	assert.NotContains(t, rec.Body.String(), "PRIVATE")
+
+	// Another service verifies our tokens with the published keys only
+	var set jwks.Set
+	if !assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &set)) {
+		return
+	}
+	keys := set.PublicKeys()
+	claims := new(models.JWTClaims)
+	_, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return keys[token.Header["kid"].(string)], nil
+	}, jwt.WithIssuer(tokens.DefaultIssuer), jwt.WithAudience(tokens.DefaultIssuer))
+	if assert.NoError(t, err) {
+		assert.Equal(t, user.UserID, claims.UserID)
+	}
+
+	// The restricted routes accept the token, and no longer the shared secret
+	restricted := e.Group("/api/v1/restricted", handlers.JWTAPIMiddleware(echojwt.Config{
+		ParseTokenFunc: func(c echo.Context, auth string) (interface{}, error) {
+			return tokens.Default.Parse(auth)
+		},
+	}), handlers.ActiveAccount)
+	restricted.GET("/drafts", handlers.GetDrafts)
+	for tokenString, status := range map[string]int{tokenString: http.StatusOK, createJWTTokenTest(t, user.UserID): http.StatusUnauthorized} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/restricted/drafts", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokenString)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, status, rec.Code)
+	}
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/config"
+	"server/handlers"
+	"server/models"
+	"server/pubsub"
+	"server/unitofwork"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func countRows(t *testing.T, model interface{}) int64 {
+	var count int64
+	if err := config.DB.Model(model).Count(&count).Error; err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	return count
+}
+
+// ----------- Unit Testing ----------- //
+func TestUnitOfWork(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	// A failed unit leaves nothing behind and skips its after commit functions
+	ran := false
+	failure := errors.New("second write failed")
+	err := unitofwork.Run(config.DB, func(unit *unitofwork.Unit) error {
+		if err := unit.Tx.Create(&models.User{Username: "ghost", Email: "ghost@example.com", Password: "password"}).Error; err != nil {
+			return err
+		}
+		unit.AfterCommit(func() { ran = true })
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	assert.False(t, ran)
+	assert.Zero(t, countRows(t, &models.User{}))
+
+	// A panic rolls back too
+	assert.Panics(t, func() {
+		unitofwork.Run(config.DB, func(unit *unitofwork.Unit) error {
+			unit.Tx.Create(&models.User{Username: "ghost", Email: "ghost@example.com", Password: "password"})
+			panic("crash")
+		})
+	})
+	assert.Zero(t, countRows(t, &models.User{}))
+
+	// After commit functions run in order once the writes are visible
+	var order []string
+	err = unitofwork.Run(config.DB, func(unit *unitofwork.Unit) error {
+		unit.AfterCommit(func() { order = append(order, fmt.Sprint("users:", countRows(t, &models.User{}))) })
+		unit.AfterCommit(func() { order = append(order, "second") })
+		return unit.Tx.Create(&models.User{Username: "alice", Email: "alice@example.com", Password: "password"}).Error
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"users:1", "second"}, order)
+}
+
+// ----------- API Testing ----------- //
+func TestCreateCommentRollsBack(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	author := createTestUserNamed(t, config.DB, "author")
+	commenter := createTestUserNamed(t, config.DB, "commenter")
+	post := createTestPost(t, config.DB, author)
+	tokenString := createJWTTokenTest(t, commenter.UserID)
+	body := fmt.Sprintf(`{"post_id":%d,"comment_msg":"Nice post #golang"}`, post.PostID)
+
+	// Storing the hashtags of the comment fails: the comment must not be left behind without them
+	assert.NoError(t, config.DB.Migrator().DropTable(&models.CommentHashtag{}))
+	rec, err := serveRestricted(handlers.CreateComment, http.MethodPost, "/api/v1/restricted/comments", body, tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Zero(t, countRows(t, &models.Comment{}))
+	assert.Zero(t, countRows(t, &models.Hashtag{}))
+	assert.Zero(t, countRows(t, &models.Notification{}))
+
+	assert.NoError(t, config.DB.AutoMigrate(&models.CommentHashtag{}))
+	rec, err = serveRestricted(handlers.CreateComment, http.MethodPost, "/api/v1/restricted/comments", body, tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, int64(1), countRows(t, &models.Comment{}))
+	assert.Equal(t, int64(1), countRows(t, &models.CommentHashtag{}))
+	assert.Equal(t, int64(1), countRows(t, &models.Notification{}))
+}
+
+func TestCreatePostRollsBack(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	user := createTestUserNamed(t, config.DB, "author")
+	createTestUserNamed(t, config.DB, "friend")
+	tokenString := createJWTTokenTest(t, user.UserID)
+	body := `{"message":"Hello @friend #golang"}`
+
+	// Storing the hashtags of the post fails: the post and the hashtag are rolled back and nobody is notified
+	assert.NoError(t, config.DB.Migrator().DropTable(&models.PostHashtag{}))
+	rec, err := serveRestricted(handlers.CreatePost, http.MethodPost, "/api/v1/restricted/posts", body, tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Zero(t, countRows(t, &models.Post{}))
+	assert.Zero(t, countRows(t, &models.Hashtag{}))
+	assert.Zero(t, countRows(t, &models.Notification{}))
+
+	assert.NoError(t, config.DB.AutoMigrate(&models.PostHashtag{}))
+	rec, err = serveRestricted(handlers.CreatePost, http.MethodPost, "/api/v1/restricted/posts", body, tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, int64(1), countRows(t, &models.Post{}))
+	assert.Equal(t, int64(1), countRows(t, &models.PostHashtag{}))
+	assert.Equal(t, int64(1), countRows(t, &models.Notification{}))
+}
+
+func TestPublishScheduledPostRollsBack(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	author := createTestUserNamed(t, config.DB, "author")
+	friend := createTestUserNamed(t, config.DB, "friend")
+	_, post := schedulePost(t, createJWTTokenTest(t, author.UserID), "Hello @friend #golang", time.Now().Add(time.Hour))
+	makeDue(t, post.PostID)
+	subscription := pubsub.Default.Subscribe(pubsub.TopicPosts, pubsub.UserTopic(friend.UserID))
+	defer subscription.Close()
+
+	// Moving the hashtags of the post fails: it stays scheduled and nobody is notified, in the table or on the streams
+	assert.NoError(t, config.DB.Migrator().DropTable(&models.PostHashtag{}))
+	published, err := handlers.PublishDuePosts(config.DB)
+	assert.NoError(t, err)
+	assert.Zero(t, published)
+	var stored models.Post
+	assert.NoError(t, config.DB.First(&stored, post.PostID).Error)
+	assert.NotNil(t, stored.PublishAt)
+	assert.Zero(t, countRows(t, &models.Notification{}))
+	assert.Empty(t, subscription.Events())
+
+	assert.NoError(t, config.DB.AutoMigrate(&models.PostHashtag{}))
+	published, err = handlers.PublishDuePosts(config.DB)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, int64(1), countRows(t, &models.Notification{}))
+	assert.Len(t, subscription.Events(), 2)
+}
@@ -34,6 +34,21 @@ func createTestUser(t *testing.T, db *gorm.DB) *models.User {
 	return &user
 }
 
+// generate a second user via GORM, for tests that need two users
+func createTestUserNamed(t *testing.T, db *gorm.DB, username string) *models.User {
+	user := models.User{
+		Username:  username,
+		Firstname: "Test",
+		Surname:   "User",
+		Email:     username + "@example.com",
+		Password:  "password",
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	return &user
+}
+
 // via API, for integration test and also generate user for other tests
 func GenerateNewUser(t *testing.T) {
 	e := echo.New()
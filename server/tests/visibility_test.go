@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/handlers"
+	"server/helpers"
+	"server/models"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func createPostWithVisibility(t *testing.T, tokenString string, message string, visibility string) models.Post {
+	body, _ := json.Marshal(models.CreatePostRequest{Message: message, Visibility: visibility})
+	rec, err := serveRestricted(handlers.CreatePost, http.MethodPost, "/api/v1/restricted/posts", string(body), tokenString)
+	var post models.Post
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusCreated, rec.Code) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &post))
+	}
+	return post
+}
+
+// servePublic runs a public handler without a token
+func servePublic(t *testing.T, handler echo.HandlerFunc, path string, params ...string) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.Validator = helpers.NewValidator()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	names, values := []string{}, []string{}
+	for i := 0; i+1 < len(params); i += 2 {
+		names = append(names, params[i])
+		values = append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+
+	assert.NoError(t, handler(c))
+	return rec
+}
+
+// getPostStatus fetches one post by ID, anonymously when tokenString is empty
+func getPostStatus(t *testing.T, postID uint, tokenString string) int {
+	pid := fmt.Sprint(postID)
+	if tokenString == "" {
+		return servePublic(t, handlers.GetPosts, "/api/v1/posts?pid="+pid).Code
+	}
+	rec, err := serveRestricted(handlers.GetPosts, http.MethodGet, "/api/v1/posts?pid="+pid, "", tokenString)
+	assert.NoError(t, err)
+	return rec.Code
+}
+
+func postMessages(posts []models.GetPublicPostsRequest) []string {
+	messages := []string{}
+	for _, post := range posts {
+		messages = append(messages, post.Message)
+	}
+	return messages
+}
+
+// ----------- API Testing ----------- //
+func TestPostVisibility(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	author := createTestUserNamed(t, config.DB, "author")
+	follower := createTestUserNamed(t, config.DB, "follower")
+	stranger := createTestUserNamed(t, config.DB, "stranger")
+	authorToken := createJWTTokenTest(t, author.UserID)
+	followerToken := createJWTTokenTest(t, follower.UserID)
+	strangerToken := createJWTTokenTest(t, stranger.UserID)
+	assert.NoError(t, config.DB.Create(&models.Follow{FollowerID: follower.UserID, FolloweeID: author.UserID}).Error)
+
+	public := createPostWithVisibility(t, authorToken, "Public hello", "")
+	followers := createPostWithVisibility(t, authorToken, "Followers hello @stranger", handlers.VisibilityFollowers)
+	private := createPostWithVisibility(t, authorToken, "Private hello @follower", handlers.VisibilityPrivate)
+	draft := createPostWithVisibility(t, authorToken, "Draft hello", handlers.VisibilityDraft)
+	assert.Equal(t, handlers.VisibilityPublic, public.Visibility)
+
+	// Feeds
+	var anonymous []models.GetPublicPostsRequest
+	assert.NoError(t, json.Unmarshal(servePublic(t, handlers.GetPosts, "/api/v1/posts").Body.Bytes(), &anonymous))
+	assert.ElementsMatch(t, []string{"Public hello"}, postMessages(anonymous))
+	assert.ElementsMatch(t, []string{"Public hello"}, postMessages(listPostsAs(t, strangerToken)))
+	assert.ElementsMatch(t, []string{"Public hello", "Followers hello @stranger"}, postMessages(listPostsAs(t, followerToken)))
+	assert.ElementsMatch(t, []string{"Public hello", "Followers hello @stranger", "Private hello @follower"}, postMessages(listPostsAs(t, authorToken)))
+
+	// Single posts are not found rather than forbidden
+	for _, check := range []struct {
+		post     models.Post
+		token    string
+		expected int
+	}{
+		{public, "", http.StatusOK},
+		{followers, "", http.StatusNotFound},
+		{followers, strangerToken, http.StatusNotFound},
+		{followers, followerToken, http.StatusOK},
+		{private, followerToken, http.StatusNotFound},
+		{private, authorToken, http.StatusOK},
+		{draft, followerToken, http.StatusNotFound},
+		{draft, authorToken, http.StatusOK},
+	} {
+		assert.Equal(t, check.expected, getPostStatus(t, check.post.PostID, check.token), "%s as %q", check.post.Message, check.token)
+	}
+
+	// Comments and reactions need a readable post
+	assert.Equal(t, http.StatusBadRequest, commentOn(t, followers.PostID, "Let me in", strangerToken))
+	assert.Equal(t, http.StatusCreated, commentOn(t, followers.PostID, "Nice one", followerToken))
+	assert.Equal(t, http.StatusBadRequest, servePublic(t, handlers.GetComments, "/api/v1/comments/"+fmt.Sprint(followers.PostID), "pid", fmt.Sprint(followers.PostID)).Code)
+	assert.Len(t, listCommentsAs(t, followers.PostID, followerToken), 1)
+	rec, err := reactToPost(t, http.MethodPut, private.PostID, "like", followerToken)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+
+	// Mentions do not reach users who cannot read the post
+	assert.Empty(t, getNotifications(t, strangerToken, ""))
+	assert.Empty(t, getNotifications(t, followerToken, ""))
+
+	// Only public posts are searchable
+	results := searchFor(t, "hello", "post")
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, public.PostID, results[0].ID)
+	}
+
+	// Profile counts only include what the viewer can read
+	rec = servePublic(t, handlers.GetUserProfile, "/api/v1/users/author", "username", "author")
+	var profile models.UserProfileResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &profile))
+	assert.Equal(t, int64(1), profile.PostCount)
+}
+
+func TestDrafts(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	author := createTestUserNamed(t, config.DB, "author")
+	reader := createTestUserNamed(t, config.DB, "reader")
+	authorToken := createJWTTokenTest(t, author.UserID)
+	readerToken := createJWTTokenTest(t, reader.UserID)
+
+	draft := createPostWithVisibility(t, authorToken, "Work in progress @reader", handlers.VisibilityDraft)
+	assert.Equal(t, handlers.VisibilityDraft, draft.Visibility)
+
+	// Drafts stay out of every feed, including the author's, and cannot be commented on
+	assert.Empty(t, listPostsAs(t, authorToken))
+	assert.Equal(t, http.StatusBadRequest, commentOn(t, draft.PostID, "Too early", authorToken))
+	assert.Empty(t, getNotifications(t, readerToken, ""))
+	assert.Empty(t, searchFor(t, "progress", "post"))
+
+	rec, err := serveRestricted(handlers.GetDrafts, http.MethodGet, "/api/v1/restricted/drafts", "", authorToken)
+	var drafts []models.GetPublicPostsRequest
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &drafts))
+		assert.ElementsMatch(t, []string{"Work in progress @reader"}, postMessages(drafts))
+	}
+	rec, err = serveRestricted(handlers.GetDrafts, http.MethodGet, "/api/v1/restricted/drafts", "", readerToken)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "[]\n", rec.Body.String())
+	}
+
+	// Publishing notifies the mentioned users and makes the post searchable
+	pid := fmt.Sprint(draft.PostID)
+	rec, err = serveRestricted(handlers.UpdatePost, http.MethodPut, "/api/v1/restricted/posts/"+pid, `{"message":"Finished @reader","visibility":"public"}`, authorToken, "pid", pid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	assert.ElementsMatch(t, []string{"Finished @reader"}, postMessages(listPostsAs(t, readerToken)))
+	assert.Len(t, getNotifications(t, readerToken, ""), 1)
+	assert.Len(t, searchFor(t, "finished", "post"), 1)
+
+	// A published post cannot go back to draft
+	rec, err = serveRestricted(handlers.UpdatePost, http.MethodPut, "/api/v1/restricted/posts/"+pid, `{"message":"Finished","visibility":"draft"}`, authorToken, "pid", pid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// Restricting a published post removes it from search
+	rec, err = serveRestricted(handlers.UpdatePost, http.MethodPut, "/api/v1/restricted/posts/"+pid, `{"message":"Finished","visibility":"private"}`, authorToken, "pid", pid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	assert.Empty(t, searchFor(t, "finished", "post"))
+	assert.Equal(t, http.StatusNotFound, getPostStatus(t, draft.PostID, readerToken))
+}
@@ -0,0 +1,279 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/handlers"
+	"server/models"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type receivedWebhook struct {
+	Event     string
+	ID        string
+	Signature string
+	Timestamp int64
+	Body      []byte
+}
+
+// webhookReceiver is a local endpoint that records the webhooks it gets and answers with the queued status codes, then 200
+type webhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	received []receivedWebhook
+	statuses []int
+}
+
+func newWebhookReceiver(statuses ...int) *webhookReceiver {
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(handlers.WebhookHeaderTimestamp), 10, 64)
+		receiver.mu.Lock()
+		receiver.received = append(receiver.received, receivedWebhook{
+			Event:     r.Header.Get(handlers.WebhookHeaderEvent),
+			ID:        r.Header.Get(handlers.WebhookHeaderID),
+			Signature: r.Header.Get(handlers.WebhookHeaderSignature),
+			Timestamp: timestamp,
+			Body:      body,
+		})
+		status := http.StatusOK
+		if len(receiver.statuses) > 0 {
+			status, receiver.statuses = receiver.statuses[0], receiver.statuses[1:]
+		}
+		receiver.mu.Unlock()
+		w.WriteHeader(status)
+		fmt.Fprint(w, "ok")
+	}))
+	return receiver
+}
+
+func (receiver *webhookReceiver) all() []receivedWebhook {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	return append([]receivedWebhook{}, receiver.received...)
+}
+
+// waitFor waits until the receiver got count requests
+func (receiver *webhookReceiver) waitFor(t *testing.T, count int) []receivedWebhook {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(receiver.all()) < count && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	received := receiver.all()
+	assert.Len(t, received, count)
+	return received
+}
+
+func createWebhook(t *testing.T, url string, events ...string) models.WebhookResponse {
+	body, _ := json.Marshal(models.CreateWebhookRequest{URL: url, Events: events})
+	rec := serveAdmin(t, handlers.CreateWebhook, http.MethodPost, "/api/v1/admin/webhooks", string(body))
+	var webhook models.WebhookResponse
+	if assert.Equal(t, http.StatusCreated, rec.Code) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &webhook))
+	}
+	return webhook
+}
+
+func getWebhookDeliveries(t *testing.T, webhookID uint) []models.WebhookDeliveryResponse {
+	wid := fmt.Sprint(webhookID)
+	rec := serveAdmin(t, handlers.GetWebhookDeliveries, http.MethodGet, "/api/v1/admin/webhooks/"+wid+"/deliveries", "", "wid", wid)
+	deliveries := []models.WebhookDeliveryResponse{}
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &deliveries))
+	}
+	return deliveries
+}
+
+func assertSigned(t *testing.T, secret string, received receivedWebhook) {
+	assert.Equal(t, handlers.SignWebhookPayload(secret, received.Timestamp, received.Body), received.Signature)
+	assert.WithinDuration(t, time.Now(), time.Unix(received.Timestamp, 0), time.Minute)
+}
+
+// ----------- Unit Testing ----------- //
+func TestSignWebhookPayload(t *testing.T) {
+	signature := handlers.SignWebhookPayload("secret", 1700000000, []byte(`{"id":"1"}`))
+	assert.Equal(t, signature, handlers.SignWebhookPayload("secret", 1700000000, []byte(`{"id":"1"}`)))
+	assert.Len(t, signature, len("sha256=")+64)
+	assert.NotEqual(t, signature, handlers.SignWebhookPayload("other", 1700000000, []byte(`{"id":"1"}`)))
+	assert.NotEqual(t, signature, handlers.SignWebhookPayload("secret", 1700000001, []byte(`{"id":"1"}`)))
+	assert.NotEqual(t, signature, handlers.SignWebhookPayload("secret", 1700000000, []byte(`{"id":"2"}`)))
+}
+
+// ----------- API Testing ----------- //
+func TestWebhooks(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	receiver := newWebhookReceiver()
+	defer receiver.Close()
+
+	assert.Equal(t, http.StatusBadRequest, serveAdmin(t, handlers.CreateWebhook, http.MethodPost, "/api/v1/admin/webhooks", `{"url":"not a url","events":["post.created"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serveAdmin(t, handlers.CreateWebhook, http.MethodPost, "/api/v1/admin/webhooks", `{"url":"http://example.com","events":["post.deleted"]}`).Code)
+
+	webhook := createWebhook(t, receiver.URL, handlers.WebhookUserCreated, handlers.WebhookPostCreated)
+	assert.Len(t, webhook.Secret, 64)
+	assert.True(t, webhook.Active)
+	assert.Len(t, getAuditLogs(t, "action="+handlers.AuditWebhookCreate), 1)
+
+	// The secret is only returned on creation
+	wid := fmt.Sprint(webhook.WebhookID)
+	rec := serveAdmin(t, handlers.GetWebhook, http.MethodGet, "/api/v1/admin/webhooks/"+wid, "", "wid", wid)
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		assert.NotContains(t, rec.Body.String(), webhook.Secret)
+	}
+	assert.Equal(t, http.StatusNotFound, serveAdmin(t, handlers.GetWebhook, http.MethodGet, "/api/v1/admin/webhooks/999", "", "wid", "999").Code)
+
+	// Subscribed events are delivered signed, the others and non-public posts are not sent
+	user, tokenString := registeredUser(t)
+	createPostWithVisibility(t, tokenString, "Only for me", handlers.VisibilityPrivate)
+	post := createPostViaAPI(t, tokenString, "Hello webhooks")
+	commentOn(t, post.PostID, "Not subscribed", tokenString)
+
+	received := receiver.waitFor(t, 2)
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, receiver.all(), 2)
+
+	events := map[string]receivedWebhook{}
+	for _, delivery := range received {
+		assertSigned(t, webhook.Secret, delivery)
+		events[delivery.Event] = delivery
+	}
+	var userEvent struct {
+		ID   string                      `json:"id"`
+		Type string                      `json:"type"`
+		Data models.UserRelationResponse `json:"data"`
+	}
+	if assert.NoError(t, json.Unmarshal(events[handlers.WebhookUserCreated].Body, &userEvent)) {
+		assert.Equal(t, handlers.WebhookUserCreated, userEvent.Type)
+		assert.Equal(t, events[handlers.WebhookUserCreated].ID, userEvent.ID)
+		assert.Equal(t, user.UserID, userEvent.Data.UserID)
+		assert.NotContains(t, string(events[handlers.WebhookUserCreated].Body), user.Email)
+	}
+	var postEvent struct {
+		Data models.GetPublicPostsRequest `json:"data"`
+	}
+	if assert.NoError(t, json.Unmarshal(events[handlers.WebhookPostCreated].Body, &postEvent)) {
+		assert.Equal(t, post.PostID, postEvent.Data.PostID)
+		assert.Equal(t, "Hello webhooks", postEvent.Data.Message)
+	}
+
+	deliveries := getWebhookDeliveries(t, webhook.WebhookID)
+	if assert.Len(t, deliveries, 2) {
+		for _, delivery := range deliveries {
+			assert.True(t, delivery.Success)
+			assert.Equal(t, http.StatusOK, delivery.StatusCode)
+			assert.Equal(t, 1, delivery.Attempt)
+			assert.Equal(t, "ok", delivery.ResponseBody)
+		}
+	}
+
+	// Subscribing to comments, then pausing the webhook
+	rec = serveAdmin(t, handlers.UpdateWebhook, http.MethodPut, "/api/v1/admin/webhooks/"+wid, `{"events":["comment.created"]}`, "wid", wid)
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		var updated models.WebhookResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+		assert.Equal(t, []string{handlers.WebhookCommentCreated}, updated.Events)
+	}
+	commentOn(t, post.PostID, "Now subscribed", tokenString)
+	received = receiver.waitFor(t, 3)
+	if assert.Len(t, received, 3) {
+		assert.Equal(t, handlers.WebhookCommentCreated, received[2].Event)
+		assert.Contains(t, string(received[2].Body), fmt.Sprintf(`"post_id":%d`, post.PostID))
+	}
+
+	assert.Equal(t, http.StatusOK, serveAdmin(t, handlers.UpdateWebhook, http.MethodPut, "/api/v1/admin/webhooks/"+wid, `{"active":false}`, "wid", wid).Code)
+	commentOn(t, post.PostID, "Paused", tokenString)
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, receiver.all(), 3)
+
+	// A ping is sent right away, even to a paused webhook
+	rec = serveAdmin(t, handlers.PingWebhook, http.MethodPost, "/api/v1/admin/webhooks/"+wid+"/ping", "", "wid", wid)
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		var ping models.WebhookDeliveryResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ping))
+		assert.True(t, ping.Success)
+		assert.Equal(t, handlers.WebhookPing, ping.EventType)
+	}
+	received = receiver.all()
+	if assert.Len(t, received, 4) {
+		assert.Equal(t, handlers.WebhookPing, received[3].Event)
+		assertSigned(t, webhook.Secret, received[3])
+	}
+
+	assert.Equal(t, http.StatusOK, serveAdmin(t, handlers.DeleteWebhook, http.MethodDelete, "/api/v1/admin/webhooks/"+wid, "", "wid", wid).Code)
+	assert.Equal(t, http.StatusNotFound, serveAdmin(t, handlers.GetWebhook, http.MethodGet, "/api/v1/admin/webhooks/"+wid, "", "wid", wid).Code)
+	var left int64
+	config.DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", webhook.WebhookID).Count(&left)
+	assert.Zero(t, left)
+}
+
+func TestWebhooks_Retry(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	receiver := newWebhookReceiver(http.StatusInternalServerError, http.StatusServiceUnavailable)
+	defer receiver.Close()
+	webhook := createWebhook(t, receiver.URL, handlers.WebhookUserCreated)
+
+	GenerateNewUser(t)
+	first := receiver.waitFor(t, 1)
+
+	// Failed attempts are retried with the same event and body until the receiver accepts it
+	deadline := time.Now().Add(5 * time.Second)
+	for len(receiver.all()) < 3 && time.Now().Before(deadline) {
+		makeJobsDue(t)
+		time.Sleep(20 * time.Millisecond)
+	}
+	received := receiver.waitFor(t, 3)
+	for _, delivery := range received {
+		assert.Equal(t, first[0].ID, delivery.ID)
+		assert.Equal(t, first[0].Body, delivery.Body)
+		assertSigned(t, webhook.Secret, delivery)
+	}
+
+	deliveries := getWebhookDeliveries(t, webhook.WebhookID)
+	for len(deliveries) < 3 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		deliveries = getWebhookDeliveries(t, webhook.WebhookID)
+	}
+	if assert.Len(t, deliveries, 3) {
+		// Newest first
+		assert.True(t, deliveries[0].Success)
+		assert.Equal(t, 3, deliveries[0].Attempt)
+		assert.False(t, deliveries[1].Success)
+		assert.Equal(t, http.StatusServiceUnavailable, deliveries[1].StatusCode)
+		assert.Equal(t, "receiver answered 503", deliveries[1].Error)
+		assert.Equal(t, 1, deliveries[2].Attempt)
+		assert.Equal(t, http.StatusInternalServerError, deliveries[2].StatusCode)
+	}
+}
+
+func TestWebhooks_InvalidResponseBody(t *testing.T) {
+	createTables()
+	defer teardown()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok \xff\xfe"))
+	}))
+	defer receiver.Close()
+	webhook := createWebhook(t, receiver.URL, handlers.WebhookUserCreated)
+	wid := fmt.Sprint(webhook.WebhookID)
+
+	// Bytes that are not UTF-8 are replaced before the delivery is stored
+	rec := serveAdmin(t, handlers.PingWebhook, http.MethodPost, "/api/v1/admin/webhooks/"+wid+"/ping", "", "wid", wid)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	deliveries := getWebhookDeliveries(t, webhook.WebhookID)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, "ok �", deliveries[0].ResponseBody)
+	}
+}
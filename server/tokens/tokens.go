@@ -0,0 +1,403 @@
+// Package tokens signs and verifies the JWTs this server issues. Tokens are signed with asymmetric keys (RS256 or EdDSA)
+// identified by kid, the keys rotate on a schedule and the public halves are published as a JWKS so other services can
+// verify the tokens without sharing a secret.
+package tokens
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"server/jwks"
+	"server/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// Signing algorithms
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Defaults of Config
+const (
+	DefaultTTL        = 24 * time.Hour
+	DefaultRotation   = 30 * 24 * time.Hour
+	DefaultPrepublish = 24 * time.Hour
+	DefaultIssuer     = "simple-social-feed"
+)
+
+// Errors of Sign and Parse
+var (
+	ErrNoSigningKey = errors.New("tokens: no signing key")
+	ErrInvalidToken = errors.New("tokens: invalid token")
+)
+
+// Config of a Manager
+type Config struct {
+	// Issuer is the iss claim, Audience the aud claim of the tokens. Both are checked when a token is parsed.
+	Issuer   string
+	Audience string
+	// Algorithm of new keys, AlgorithmRS256 or AlgorithmEdDSA. Existing keys keep theirs until they are rotated out.
+	Algorithm string
+	// TTL is how long a token is valid
+	TTL time.Duration
+	// Rotation is how long a key signs new tokens before the next one takes over
+	Rotation time.Duration
+	// Prepublish is how long a key is in the JWKS before it signs, so verifiers caching the set know it in time.
+	// 0 lets new keys sign right away.
+	Prepublish time.Duration
+	// LegacySecret, when set, still accepts HS256 tokens signed with it (without iss and aud) until they expire.
+	// It eases the switch from the shared secret: set it to the old JWT_SECRET for one TTL, then remove it.
+	LegacySecret []byte
+}
+
+// Key is a loaded signing key
+type Key struct {
+	Kid       string
+	Algorithm string
+	NotBefore time.Time
+	Private   crypto.Signer
+	// Until is when the last token the key may have signed expires, zero until a newer key signs
+	Until time.Time
+}
+
+// Manager holds the signing keys loaded from the signing_keys table
+type Manager struct {
+	config Config
+
+	mu   sync.RWMutex
+	keys []Key
+}
+
+// Default is the manager used by the handlers and the JWT middleware
+var Default *Manager
+
+// Init sets Default from JWT_ISSUER, JWT_AUDIENCE (the issuer by default), JWT_ALGORITHM (RS256 or EdDSA), JWT_TTL (24h),
+// JWT_KEY_ROTATION (720h), JWT_KEY_PREPUBLISH (24h) and JWT_LEGACY_SECRET, then loads the keys, creating the first one if needed
+func Init(db *gorm.DB) {
+	config := Config{
+		Issuer:       os.Getenv("JWT_ISSUER"),
+		Audience:     os.Getenv("JWT_AUDIENCE"),
+		Algorithm:    os.Getenv("JWT_ALGORITHM"),
+		Prepublish:   DefaultPrepublish,
+		LegacySecret: []byte(os.Getenv("JWT_LEGACY_SECRET")),
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("JWT_TTL")); err == nil && ttl > 0 {
+		config.TTL = ttl
+	}
+	if rotation, err := time.ParseDuration(os.Getenv("JWT_KEY_ROTATION")); err == nil && rotation > 0 {
+		config.Rotation = rotation
+	}
+	if prepublish, err := time.ParseDuration(os.Getenv("JWT_KEY_PREPUBLISH")); err == nil && prepublish >= 0 {
+		config.Prepublish = prepublish
+	}
+
+	manager, err := New(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := manager.Rotate(db); err != nil {
+		log.Fatal("Failed to load the JWT signing keys: ", err)
+	}
+	Default = manager
+}
+
+// New returns a manager without keys, call Rotate to load them
+func New(config Config) (*Manager, error) {
+	if config.Issuer == "" {
+		config.Issuer = DefaultIssuer
+	}
+	if config.Audience == "" {
+		config.Audience = config.Issuer
+	}
+	if config.Algorithm == "" {
+		config.Algorithm = AlgorithmRS256
+	}
+	if config.Algorithm != AlgorithmRS256 && config.Algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("tokens: unsupported algorithm %q, use %s or %s", config.Algorithm, AlgorithmRS256, AlgorithmEdDSA)
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultTTL
+	}
+	if config.Rotation <= 0 {
+		config.Rotation = DefaultRotation
+	}
+	if config.Prepublish < 0 {
+		config.Prepublish = 0
+	}
+	if config.Prepublish >= config.Rotation {
+		return nil, errors.New("tokens: the prepublish period must be shorter than the rotation period")
+	}
+	return &Manager{config: config}, nil
+}
+
+// Config returns the configuration in use, with defaults applied
+func (m *Manager) Config() Config {
+	return m.config
+}
+
+// Sign fills in the registered claims (iss, aud, sub, iat, nbf, exp) and signs the token with the current key
+func (m *Manager) Sign(claims *models.JWTClaims) (string, error) {
+	now := time.Now()
+	key, ok := m.current(now)
+	if !ok {
+		return "", ErrNoSigningKey
+	}
+
+	claims.Issuer = m.config.Issuer
+	claims.Audience = jwt.ClaimStrings{m.config.Audience}
+	claims.Subject = strconv.FormatUint(uint64(claims.UserID), 10)
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(m.config.TTL))
+
+	token := jwt.NewWithClaims(signingMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.Kid
+	return token.SignedString(key.Private)
+}
+
+// Parse verifies the signature, expiry, issuer, audience, not before and issued at of a token
+func (m *Manager) Parse(tokenString string) (*jwt.Token, error) {
+	methods := []string{AlgorithmRS256, AlgorithmEdDSA}
+	if len(m.config.LegacySecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	claims := new(models.JWTClaims)
+	token, err := jwt.ParseWithClaims(tokenString, claims, m.keyFunc,
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if token.Method == jwt.SigningMethodHS256 {
+		return token, nil
+	}
+
+	// The parser only checks iss, aud, nbf and iat when they are present, our tokens always carry them
+	if claims.Issuer != m.config.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if !containsAudience(claims.Audience, m.config.Audience) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	if claims.NotBefore == nil || claims.IssuedAt == nil {
+		return nil, fmt.Errorf("%w: missing nbf or iat", ErrInvalidToken)
+	}
+	return token, nil
+}
+
+func (m *Manager) keyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method == jwt.SigningMethodHS256 {
+		if _, ok := token.Header["kid"]; ok {
+			return nil, errors.New("HS256 tokens with a kid are not ours")
+		}
+		return m.config.LegacySecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range m.keys {
+		if key.Kid == kid {
+			if key.Algorithm != token.Method.Alg() {
+				return nil, fmt.Errorf("key %q is not a %s key", kid, token.Method.Alg())
+			}
+			return key.Private.Public(), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// current is the newest key that may sign at the time
+func (m *Manager) current(now time.Time) (Key, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		if !m.keys[i].NotBefore.After(now) {
+			return m.keys[i], true
+		}
+	}
+	return Key{}, false
+}
+
+// Keys returns the loaded keys, oldest first
+func (m *Manager) Keys() []Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Key(nil), m.keys...)
+}
+
+// JWKS returns the public keys that can verify tokens: the current key, older keys whose tokens have not expired
+// yet and the next key, published ahead of time
+func (m *Manager) JWKS() jwks.Set {
+	set := jwks.Set{Keys: []jwks.Key{}}
+	for _, key := range m.Keys() {
+		if public, ok := jwks.New(key.Kid, key.Algorithm, key.Private.Public()); ok {
+			set.Keys = append(set.Keys, public)
+		}
+	}
+	return set
+}
+
+// Rotate creates the next key once the current one is close to the end of its rotation period, deletes keys whose
+// tokens have all expired and reloads the keys. Several servers can share the table: they all load the same keys.
+func (m *Manager) Rotate(db *gorm.DB) error {
+	now := time.Now()
+	var rows []models.SigningKey
+	if err := db.Order("not_before").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	var next time.Time
+	switch {
+	case len(rows) == 0:
+		// Nothing can sign yet, the first key is used right away
+		next = now
+	case rows[len(rows)-1].Algorithm != m.config.Algorithm:
+		next = now.Add(m.config.Prepublish)
+	case !now.Before(rows[len(rows)-1].NotBefore.Add(m.config.Rotation - m.config.Prepublish)):
+		// The newest key keeps signing until the next one was published for the whole prepublish period,
+		// even when the server was down at the planned time
+		next = rows[len(rows)-1].NotBefore.Add(m.config.Rotation)
+		if earliest := now.Add(m.config.Prepublish); next.Before(earliest) {
+			next = earliest
+		}
+	}
+	if !next.IsZero() {
+		row, err := m.generate(next.Truncate(time.Second))
+		if err != nil {
+			return err
+		}
+		// Another server may have created the key at the same time, the unique not_before keeps only one
+		if err := db.Create(&row).Error; err != nil {
+			var count int64
+			if db.Model(&models.SigningKey{}).Where("not_before = ?", row.NotBefore).Count(&count); count == 0 {
+				return err
+			}
+		}
+		if err := db.Order("not_before").Find(&rows).Error; err != nil {
+			return err
+		}
+	}
+
+	// A key is kept until the tokens it signed before the next key took over have expired
+	keys := make([]Key, 0, len(rows))
+	retired := []uint{}
+	for i, row := range rows {
+		key := Key{Kid: row.Kid, Algorithm: row.Algorithm, NotBefore: row.NotBefore}
+		if i+1 < len(rows) && !rows[i+1].NotBefore.After(now) {
+			key.Until = rows[i+1].NotBefore.Add(m.config.TTL)
+			if !key.Until.After(now) {
+				retired = append(retired, row.SigningKeyID)
+				continue
+			}
+		}
+		private, err := parsePrivateKey(row.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", row.Kid, err)
+		}
+		key.Private = private
+		keys = append(keys, key)
+	}
+	if len(retired) > 0 {
+		if err := db.Delete(&models.SigningKey{}, retired).Error; err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
+	return nil
+}
+
+// StartRotation runs Rotate every interval for the lifetime of the process. The interval should be well below the
+// prepublish period, servers that did not create the next key pick it up from the table on their next run.
+func (m *Manager) StartRotation(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := m.Rotate(db); err != nil {
+				log.Println("Failed to rotate the JWT signing keys:", err)
+			}
+		}
+	}()
+}
+
+func (m *Manager) generate(notBefore time.Time) (models.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch m.config.Algorithm {
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return models.SigningKey{}, err
+	}
+	return models.SigningKey{
+		Kid:        notBefore.UTC().Format("20060102") + "-" + hex.EncodeToString(random),
+		Algorithm:  m.config.Algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		NotBefore:  notBefore,
+	}, nil
+}
+
+func parsePrivateKey(encoded string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(encoded))
+	if block == nil {
+		return nil, errors.New("not PEM encoded")
+	}
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("not a signing key")
+	}
+	return signer, nil
+}
+
+func signingMethod(algorithm string) jwt.SigningMethod {
+	if algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+func containsAudience(audience jwt.ClaimStrings, expected string) bool {
+	for _, value := range audience {
+		if value == expected {
+			return true
+		}
+	}
+	return false
+}
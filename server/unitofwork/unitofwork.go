@@ -0,0 +1,36 @@
+// Package unitofwork groups the writes of one operation that span several tables. They run in a single
+// transaction, so a failed statement leaves no partial rows behind (such as a comment without its author), and the
+// side effects that must only follow a commit (notifications, search indexing, events) wait until it succeeded.
+package unitofwork
+
+import "gorm.io/gorm"
+
+// Unit is one unit of work, every write of the unit goes through Tx
+type Unit struct {
+	Tx          *gorm.DB
+	afterCommit []func()
+}
+
+// AfterCommit runs f once the unit is committed, in the order they were added. Nothing runs after a rollback.
+func (u *Unit) AfterCommit(f func()) {
+	u.afterCommit = append(u.afterCommit, f)
+}
+
+// Run runs work in a transaction of db and commits it when work returns nil. An error or a panic in work rolls every
+// write back, the error is returned as it is. db is not a transaction itself: the after commit functions would run
+// before the outer transaction commits.
+func Run(db *gorm.DB, work func(unit *Unit) error) error {
+	unit := &Unit{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		unit.Tx = tx
+		return work(unit)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, f := range unit.afterCommit {
+		f()
+	}
+	return nil
+}